@@ -0,0 +1,346 @@
+package http
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+type AuthHandler struct {
+	Usecase auth.UseCase
+}
+
+// NewAuthHandler registers the auth routes. requireUser guards the routes
+// acting on a user, left to the user themselves and admins, and requireAdmin
+// the routes reserved to auth.RoleAdmin.
+func NewAuthHandler(r *gin.Engine, us auth.UseCase, requireUser gin.HandlerFunc, requireAdmin gin.HandlerFunc) {
+	handler := &AuthHandler{
+		Usecase: us,
+	}
+	v1 := r.Group("/api/v1")
+	v1.POST("/auth/login", handler.Login)
+	v1.POST("/auth/login/2fa", handler.LoginMFA)
+	v1.POST("/auth/refresh", handler.Refresh)
+	v1.POST("/auth/logout", handler.Logout)
+	v1.DELETE("/users/:id/sessions", requireUser, handler.RevokeSessions)
+	v1.PUT("/users/:id/password", requireUser, handler.ChangePassword)
+	v1.POST("/users/:id/unlock", requireAdmin, handler.Unlock)
+}
+
+// Login godoc
+// @Summary      Login
+// @Description  Exchange an email and password for an access token and a refresh token. Repeated failures delay further attempts, then lock the account or client address for a while. Users with two-factor authentication get an MFAToken instead, to complete the login at /auth/login/2fa.
+// @Tags         Auth
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        Login  body     DTOs.Login  true  "Credentials"
+// @Success      200  {object}  entity.TokenPair
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      423  {object}  httputil.HTTPError
+// @Failure      429  {object}  httputil.HTTPError
+// @Header       423,429  {int}  Retry-After  "Seconds to wait before trying again"
+// @Router /auth/login [post]
+func (a *AuthHandler) Login(c *gin.Context) {
+	var login DTOs.Login
+	if err := httputil.Bind(c, &login); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&login); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx = models.WithClientIP(ctx, c.ClientIP())
+
+	res, err := a.Usecase.Login(ctx, login.Email, login.Password)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// LoginMFA godoc
+// @Summary      LoginMFA
+// @Description  Complete a login with two-factor authentication, exchanging the MFAToken of the password step and a TOTP or recovery code for an access token and a refresh token. Wrong codes count as failed logins.
+// @Tags         Auth
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        LoginMFA  body     DTOs.LoginMFA  true  "MFA token and code"
+// @Success      200  {object}  entity.TokenPair
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      423  {object}  httputil.HTTPError
+// @Failure      429  {object}  httputil.HTTPError
+// @Header       423,429  {int}  Retry-After  "Seconds to wait before trying again"
+// @Router /auth/login/2fa [post]
+func (a *AuthHandler) LoginMFA(c *gin.Context) {
+	var body DTOs.LoginMFA
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx = models.WithClientIP(ctx, c.ClientIP())
+
+	res, err := a.Usecase.LoginMFA(ctx, body.MFAToken, body.Code)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// Refresh godoc
+// @Summary      Refresh
+// @Description  Exchange a refresh token for a new pair. Each refresh token works once; reusing one revokes its session.
+// @Tags         Auth
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        RefreshToken  body     DTOs.RefreshToken  true  "Refresh token"
+// @Success      200  {object}  entity.TokenPair
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Router /auth/refresh [post]
+func (a *AuthHandler) Refresh(c *gin.Context) {
+	var body DTOs.RefreshToken
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Refresh(ctx, body.RefreshToken)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// Logout godoc
+// @Summary      Logout
+// @Description  Revoke the session of a refresh token
+// @Tags         Auth
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Param        RefreshToken  body  DTOs.RefreshToken  true  "Refresh token"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Router /auth/logout [post]
+func (a *AuthHandler) Logout(c *gin.Context) {
+	var body DTOs.RefreshToken
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	err := a.Usecase.Logout(ctx, body.RefreshToken)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeSessions godoc
+// @Summary      RevokeSessions
+// @Description  Revoke every session of a user. Access tokens already issued stay valid until they expire. Users may only revoke their own sessions, admins those of anyone.
+// @Tags         Auth
+// @Param        id  path  string  true  "User ID"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /users/{id}/sessions [delete]
+func (a *AuthHandler) RevokeSessions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if !selfOrAdmin(ctx, id) {
+		httputil.NewError(c, http.StatusForbidden, models.ErrForbidden)
+		return
+	}
+	err = a.Usecase.RevokeSessions(ctx, id)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ChangePassword godoc
+// @Summary      ChangePassword
+// @Description  Set a new password for a user, ending their sessions. Users may only change their own password and must give the current one; admins may set anyone's without it. Wrong current passwords count as failed logins.
+// @Tags         Auth
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Param        id              path  string               true  "User ID"
+// @Param        PasswordChange  body  DTOs.PasswordChange  true  "Current and new password"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      423  {object}  httputil.HTTPError
+// @Failure      429  {object}  httputil.HTTPError
+// @Header       423,429  {int}  Retry-After  "Seconds to wait before trying again"
+// @Router /users/{id}/password [put]
+func (a *AuthHandler) ChangePassword(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+	var body DTOs.PasswordChange
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if !selfOrAdmin(ctx, id) {
+		httputil.NewError(c, http.StatusForbidden, models.ErrForbidden)
+		return
+	}
+	err = a.Usecase.ChangePassword(ctx, id, body.CurrentPassword, body.Password)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Unlock godoc
+// @Summary      Unlock
+// @Description  Clear the failed logins and lockout of a user's account. Admins only.
+// @Tags         Auth
+// @Param        id  path  string  true  "User ID"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /users/{id}/unlock [post]
+func (a *AuthHandler) Unlock(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	err = a.Usecase.Unlock(ctx, id)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// selfOrAdmin reports whether the caller is the user with id or an admin.
+func selfOrAdmin(ctx context.Context, id uuid.UUID) bool {
+	userID, ok := models.UserIDFromContext(ctx)
+	return ok && userID == id || models.HasRole(ctx, auth.RoleAdmin)
+}
+
+// writeError writes the error response of err, telling throttled clients when
+// to try again.
+func writeError(c *gin.Context, err error) {
+	var throttled *auth.ThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		err = throttled.Err
+	}
+	httputil.NewError(c, getStatusCode(err), err)
+}
+
+func getStatusCode(err error) int {
+	var code int
+	switch err {
+	case models.ErrInvalidCode:
+		code = http.StatusBadRequest
+	case models.ErrInvalidCredentials, models.ErrInvalidToken:
+		code = http.StatusUnauthorized
+	case models.ErrAccountDisabled:
+		code = http.StatusForbidden
+	case models.ErrTwoFactorEnabled:
+		code = http.StatusConflict
+	case models.ErrAccountLocked:
+		code = http.StatusLocked
+	case models.ErrTooManyAttempts:
+		code = http.StatusTooManyRequests
+	default:
+		return httputil.StatusCode(err)
+	}
+	logrus.Error(err)
+	return code
+}
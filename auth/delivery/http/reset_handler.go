@@ -0,0 +1,97 @@
+package http
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+type PasswordResetHandler struct {
+	Usecase auth.PasswordResetUseCase
+}
+
+func NewPasswordResetHandler(r *gin.Engine, us auth.PasswordResetUseCase) {
+	handler := &PasswordResetHandler{
+		Usecase: us,
+	}
+	v1 := r.Group("/api/v1")
+	v1.POST("/auth/password-reset/request", handler.RequestReset)
+	v1.POST("/auth/password-reset/confirm", handler.ConfirmReset)
+}
+
+// RequestReset godoc
+// @Summary      RequestPasswordReset
+// @Description  Mail a password reset link to the user with this email. The answer is the same whether or not there is such a user. Requests are limited per email and per client address.
+// @Tags         Auth
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Param        PasswordResetRequest  body  DTOs.PasswordResetRequest  true  "Email"
+// @Success      202
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      429  {object}  httputil.HTTPError
+// @Header       429  {int}  Retry-After  "Seconds to wait before trying again"
+// @Router /auth/password-reset/request [post]
+func (a *PasswordResetHandler) RequestReset(c *gin.Context) {
+	var body DTOs.PasswordResetRequest
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx = models.WithClientIP(ctx, c.ClientIP())
+
+	err := a.Usecase.RequestReset(ctx, body.Email)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// ConfirmReset godoc
+// @Summary      ConfirmPasswordReset
+// @Description  Set a new password with the token of a reset link. Each link works once and expires quickly; the sessions of the user are revoked.
+// @Tags         Auth
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Param        PasswordResetConfirm  body  DTOs.PasswordResetConfirm  true  "Token and new password"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Router /auth/password-reset/confirm [post]
+func (a *PasswordResetHandler) ConfirmReset(c *gin.Context) {
+	var body DTOs.PasswordResetConfirm
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	err := a.Usecase.Reset(ctx, body.Token, body.Password)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+const mimePNG = "image/png"
+
+type TwoFactorHandler struct {
+	Usecase auth.TwoFactorUseCase
+}
+
+// NewTwoFactorHandler registers the two-factor routes, which act on the
+// authenticated user. requireUser rejects anonymous requests.
+func NewTwoFactorHandler(r *gin.Engine, us auth.TwoFactorUseCase, requireUser gin.HandlerFunc) {
+	handler := &TwoFactorHandler{
+		Usecase: us,
+	}
+	v1 := r.Group("/api/v1", requireUser)
+	v1.POST("/auth/2fa/enroll", handler.Enroll)
+	v1.POST("/auth/2fa/confirm", handler.Confirm)
+	v1.DELETE("/auth/2fa", handler.Disable)
+}
+
+// Enroll godoc
+// @Summary      EnrollTOTP
+// @Description  Start enrolling an authenticator app for the authenticated user, replacing an unconfirmed one. Answers the secret, its otpauth URI and a QR code of the URI, or only the PNG QR code when image/png is preferred.
+// @Tags         Auth
+// @Produce      json,xml,application/x-yaml,application/x-msgpack,png
+// @Success      200  {object}  entity.TOTPEnrollment
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      409  {object}  httputil.HTTPError
+// @Router /auth/2fa/enroll [post]
+func (a *TwoFactorHandler) Enroll(c *gin.Context) {
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	userID, _ := models.UserIDFromContext(ctx)
+	res, err := a.Usecase.Enroll(ctx, userID)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	if c.NegotiateFormat(append(append([]string{}, httputil.Offered...), mimePNG)...) == mimePNG {
+		c.Data(http.StatusOK, mimePNG, res.QRCode)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// Confirm godoc
+// @Summary      ConfirmTOTP
+// @Description  Enable two-factor authentication with the first code of the enrolled authenticator. Answers the recovery codes, shown only this once, and ends the other sessions of the user.
+// @Tags         Auth
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        TOTPCode  body     DTOs.TOTPCode  true  "Code"
+// @Success      200  {object}  entity.RecoveryCodes
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      409  {object}  httputil.HTTPError
+// @Router /auth/2fa/confirm [post]
+func (a *TwoFactorHandler) Confirm(c *gin.Context) {
+	var body DTOs.TOTPCode
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	userID, _ := models.UserIDFromContext(ctx)
+	res, err := a.Usecase.Confirm(ctx, userID, body.Code)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// Disable godoc
+// @Summary      DisableTOTP
+// @Description  Turn two-factor authentication off for the authenticated user, given a TOTP or recovery code
+// @Tags         Auth
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Param        TOTPCode  body  DTOs.TOTPCode  true  "Code"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /auth/2fa [delete]
+func (a *TwoFactorHandler) Disable(c *gin.Context) {
+	var body DTOs.TOTPCode
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	userID, _ := models.UserIDFromContext(ctx)
+	err := a.Usecase.Disable(ctx, userID, body.Code)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
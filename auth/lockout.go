@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"time"
+)
+
+const (
+	ScopeAccount = "account"
+	ScopeIP      = "ip"
+)
+
+// LockoutPolicy throttles failed logins per account and per IP address. Each
+// failure makes the next attempt wait BaseDelay, doubled per failure up to
+// MaxDelay, and reaching the threshold of a scope locks it for Duration.
+// Failures older than Window are forgotten. A threshold of 0 disables
+// lockouts for its scope.
+type LockoutPolicy struct {
+	AccountThreshold int
+	IPThreshold      int
+	Window           time.Duration
+	Duration         time.Duration
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+}
+
+// ThrottledError rejects a login attempt, or a password reset request, made
+// too early. Err is
+// ErrAccountLocked or ErrTooManyAttempts.
+type ThrottledError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+func (p *LockoutPolicy) Threshold(scope string) int {
+	if scope == ScopeAccount {
+		return p.AccountThreshold
+	}
+	return p.IPThreshold
+}
+
+// Delay is the wait imposed after failures consecutive failures.
+func (p *LockoutPolicy) Delay(failures int) time.Duration {
+	if failures <= 0 || p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// Check returns a ThrottledError when attempts forbid logging in at now, or
+// nil. Locked accounts fail with ErrAccountLocked, everything else with
+// ErrTooManyAttempts.
+func (p *LockoutPolicy) Check(attempts *entity.LoginAttempts, now time.Time) error {
+	if attempts == nil {
+		return nil
+	}
+	if attempts.LockedUntil != nil && now.Before(*attempts.LockedUntil) {
+		err := models.ErrTooManyAttempts
+		if attempts.Scope == ScopeAccount {
+			err = models.ErrAccountLocked
+		}
+		return &ThrottledError{Err: err, RetryAfter: attempts.LockedUntil.Sub(now)}
+	}
+	if now.Sub(attempts.LastFailure) > p.Window {
+		return nil
+	}
+	if next := attempts.LastFailure.Add(p.Delay(attempts.Failures)); now.Before(next) {
+		return &ThrottledError{Err: models.ErrTooManyAttempts, RetryAfter: next.Sub(now)}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"GoMastersTest/models"
+	"bufio"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// PasswordPolicy is what passwords must satisfy to be set. Lengths count
+// characters, and a MaxLength of 0 means no limit.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// breached holds the lower-cased passwords of the breach list.
+	breached map[string]bool
+}
+
+func NewPasswordPolicy(minLength int, maxLength int) *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength: minLength,
+		MaxLength: maxLength,
+		breached:  make(map[string]bool),
+	}
+}
+
+// LoadBreachList rejects the passwords listed in the file at path, one per
+// line, ignoring case. Blank lines and lines starting with # are skipped.
+func (p *PasswordPolicy) LoadBreachList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.breached[strings.ToLower(line)] = true
+	}
+	return scanner.Err()
+}
+
+// Check returns the first rule password breaks, or nil.
+func (p *PasswordPolicy) Check(password string) error {
+	n := utf8.RuneCountInString(password)
+	switch {
+	case n < p.MinLength:
+		return models.ErrPasswordTooShort
+	case p.MaxLength > 0 && n > p.MaxLength:
+		return models.ErrPasswordTooLong
+	case p.breached[strings.ToLower(password)]:
+		return models.ErrPasswordBreached
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type CredentialRepository interface {
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.Credential, error)
+	// Set stores hash as the password of the user, replacing any previous one.
+	Set(ctx context.Context, userID uuid.UUID, hash string) error
+}
+
+// RoleRepository lists the roles granted to users, such as RoleAdmin.
+type RoleRepository interface {
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]string, error)
+}
+
+type LoginAttemptRepository interface {
+	// Get returns nil when key has no recent failures, and locks its attempts
+	// until the end of the transaction otherwise.
+	Get(ctx context.Context, scope string, key string) (*entity.LoginAttempts, error)
+	// RecordFailure counts a failed attempt made at now, starting over when the
+	// previous failure is older than window.
+	RecordFailure(ctx context.Context, scope string, key string, now time.Time, window time.Duration) (*entity.LoginAttempts, error)
+	// Lock locks key until the given time and clears its failures.
+	Lock(ctx context.Context, scope string, key string, until time.Time) error
+	Reset(ctx context.Context, scope string, key string) error
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, t *entity.RefreshToken) error
+	// GetByHash locks the token until the end of the transaction.
+	GetByHash(ctx context.Context, hash string) (*entity.RefreshToken, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) error
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, r *entity.PasswordReset) error
+	// GetByHash locks the reset until the end of the transaction.
+	GetByHash(ctx context.Context, hash string) (*entity.PasswordReset, error)
+	// MarkUsed uses up every outstanding reset of the user.
+	MarkUsed(ctx context.Context, userID uuid.UUID) error
+}
+
+type TOTPRepository interface {
+	// GetByUserID locks the enrollment until the end of the transaction.
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.TOTP, error)
+	// Set replaces the enrollment of the user with t, unconfirmed.
+	Set(ctx context.Context, t *entity.TOTP) error
+	// Confirm confirms the enrollment of the user, whose first code was for
+	// step.
+	Confirm(ctx context.Context, userID uuid.UUID, step int64) error
+	SetLastStep(ctx context.Context, userID uuid.UUID, step int64) error
+	Delete(ctx context.Context, userID uuid.UUID) error
+}
+
+type RecoveryCodeRepository interface {
+	// Replace discards the recovery codes of the user for those hashed as
+	// hashes.
+	Replace(ctx context.Context, userID uuid.UUID, hashes []string) error
+	// Use uses up the unused code of the user hashed as hash, reporting
+	// whether there was one.
+	Use(ctx context.Context, userID uuid.UUID, hash string) (bool, error)
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/models/entity"
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[[2]string]entity.LoginAttempts
+}
+
+// NewMemoryLoginAttemptRepository keeps login attempts in memory, for tests
+// and single instance deployments. Get does not lock: concurrent logins may
+// each see the attempts before the other's failure.
+func NewMemoryLoginAttemptRepository() auth.LoginAttemptRepository {
+	return &memoryLoginAttemptRepository{attempts: make(map[[2]string]entity.LoginAttempts)}
+}
+
+func (m *memoryLoginAttemptRepository) Get(ctx context.Context, scope string, key string) (*entity.LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.attempts[[2]string{scope, key}]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (m *memoryLoginAttemptRepository) RecordFailure(ctx context.Context, scope string, key string, now time.Time, window time.Duration) (*entity.LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.attempts[[2]string{scope, key}]
+	if !ok {
+		t = entity.LoginAttempts{Scope: scope, Key: key}
+	}
+	if t.LastFailure.Before(now.Add(-window)) {
+		t.Failures = 0
+	}
+	t.Failures++
+	t.LastFailure = now
+	m.attempts[[2]string{scope, key}] = t
+	return &t, nil
+}
+
+func (m *memoryLoginAttemptRepository) Lock(ctx context.Context, scope string, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.attempts[[2]string{scope, key}]
+	if ok {
+		t.Failures = 0
+		t.LockedUntil = &until
+		m.attempts[[2]string{scope, key}] = t
+	}
+	return nil
+}
+
+func (m *memoryLoginAttemptRepository) Reset(ctx context.Context, scope string, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, [2]string{scope, key})
+	return nil
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+)
+
+type postgreCredentialRepository struct {
+	Conn *sql.DB
+}
+
+func NewPostgreCredentialRepository(Conn *sql.DB) auth.CredentialRepository {
+	return &postgreCredentialRepository{Conn}
+}
+
+func (m *postgreCredentialRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.Credential, error) {
+	query := `select user_id, password_hash, updated from user_credentials where user_id = $1`
+
+	t := new(entity.Credential)
+	err := dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, userID.String()).Scan(&t.UserID, &t.Hash, &t.Updated)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *postgreCredentialRepository) Set(ctx context.Context, userID uuid.UUID, hash string) error {
+	query := `INSERT INTO user_credentials (user_id, password_hash, updated) VALUES ($1, $2, now())
+						ON CONFLICT (user_id) DO UPDATE SET password_hash = excluded.password_hash, updated = excluded.updated`
+
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query, userID.String(), hash)
+	return err
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models/entity"
+	"context"
+	"database/sql"
+	"time"
+)
+
+type postgreLoginAttemptRepository struct {
+	Conn *sql.DB
+}
+
+func NewPostgreLoginAttemptRepository(Conn *sql.DB) auth.LoginAttemptRepository {
+	return &postgreLoginAttemptRepository{Conn}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanLoginAttempts(row rowScanner) (*entity.LoginAttempts, error) {
+	t := new(entity.LoginAttempts)
+	err := row.Scan(&t.Scope, &t.Key, &t.Failures, &t.LastFailure, &t.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *postgreLoginAttemptRepository) Get(ctx context.Context, scope string, key string) (*entity.LoginAttempts, error) {
+	query := `select scope, key, failures, last_failure, locked_until
+						from login_attempts where scope = $1 and key = $2 for update`
+
+	res, err := scanLoginAttempts(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, scope, key))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return res, err
+}
+
+func (m *postgreLoginAttemptRepository) RecordFailure(ctx context.Context, scope string, key string, now time.Time, window time.Duration) (*entity.LoginAttempts, error) {
+	query := `INSERT INTO login_attempts (scope, key, failures, last_failure) VALUES ($1, $2, 1, $3)
+						ON CONFLICT (scope, key) DO UPDATE SET
+							failures = CASE WHEN login_attempts.last_failure < $4 THEN 1 ELSE login_attempts.failures + 1 END,
+							last_failure = excluded.last_failure
+						RETURNING scope, key, failures, last_failure, locked_until`
+
+	return scanLoginAttempts(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, scope, key, now, now.Add(-window)))
+}
+
+func (m *postgreLoginAttemptRepository) Lock(ctx context.Context, scope string, key string, until time.Time) error {
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx,
+		`UPDATE login_attempts SET failures = 0, locked_until = $3 WHERE scope = $1 AND key = $2`, scope, key, until)
+	return err
+}
+
+func (m *postgreLoginAttemptRepository) Reset(ctx context.Context, scope string, key string) error {
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx,
+		`DELETE FROM login_attempts WHERE scope = $1 AND key = $2`, scope, key)
+	return err
+}
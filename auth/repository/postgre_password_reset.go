@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+)
+
+type postgrePasswordResetRepository struct {
+	Conn *sql.DB
+}
+
+func NewPostgrePasswordResetRepository(Conn *sql.DB) auth.PasswordResetRepository {
+	return &postgrePasswordResetRepository{Conn}
+}
+
+func (m *postgrePasswordResetRepository) Create(ctx context.Context, r *entity.PasswordReset) error {
+	query := `INSERT INTO password_resets (id, user_id, token_hash, expires_at, created) VALUES ($1, $2, $3, $4, now())`
+
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query, r.ID.String(), r.UserID.String(), r.Hash, r.ExpiresAt)
+	return err
+}
+
+func (m *postgrePasswordResetRepository) GetByHash(ctx context.Context, hash string) (*entity.PasswordReset, error) {
+	query := `select id, user_id, token_hash, expires_at, used_at, created
+						from password_resets where token_hash = $1 for update`
+
+	r := new(entity.PasswordReset)
+	err := dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, hash).Scan(
+		&r.ID,
+		&r.UserID,
+		&r.Hash,
+		&r.ExpiresAt,
+		&r.UsedAt,
+		&r.Created,
+	)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (m *postgrePasswordResetRepository) MarkUsed(ctx context.Context, userID uuid.UUID) error {
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx,
+		`UPDATE password_resets SET used_at = now() WHERE user_id = $1 AND used_at IS NULL`, userID.String())
+	return err
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+)
+
+type postgreRefreshTokenRepository struct {
+	Conn *sql.DB
+}
+
+func NewPostgreRefreshTokenRepository(Conn *sql.DB) auth.RefreshTokenRepository {
+	return &postgreRefreshTokenRepository{Conn}
+}
+
+func (m *postgreRefreshTokenRepository) Create(ctx context.Context, t *entity.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created)
+						VALUES ($1, $2, $3, $4, $5, now())`
+
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query,
+		t.ID.String(), t.UserID.String(), t.FamilyID.String(), t.Hash, t.ExpiresAt)
+	return err
+}
+
+func (m *postgreRefreshTokenRepository) GetByHash(ctx context.Context, hash string) (*entity.RefreshToken, error) {
+	query := `select id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created
+						from refresh_tokens where token_hash = $1 for update`
+
+	t := new(entity.RefreshToken)
+	err := dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, hash).Scan(
+		&t.ID,
+		&t.UserID,
+		&t.FamilyID,
+		&t.Hash,
+		&t.ExpiresAt,
+		&t.UsedAt,
+		&t.RevokedAt,
+		&t.Created,
+	)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *postgreRefreshTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx,
+		`UPDATE refresh_tokens SET used_at = now() WHERE id = $1 AND used_at IS NULL`, id.String())
+	return err
+}
+
+func (m *postgreRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL`, familyID.String())
+	return err
+}
+
+func (m *postgreRefreshTokenRepository) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`, userID.String())
+	return err
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type postgreRoleRepository struct {
+	Conn *sql.DB
+}
+
+func NewPostgreRoleRepository(Conn *sql.DB) auth.RoleRepository {
+	return &postgreRoleRepository{Conn}
+}
+
+func (m *postgreRoleRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	query := `select coalesce(array_agg(role order by role), '{}') from user_roles where user_id = $1`
+
+	roles := make([]string, 0)
+	err := dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, userID.String()).Scan(pq.Array(&roles))
+	if err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type postgreTOTPRepository struct {
+	Conn *sql.DB
+}
+
+func NewPostgreTOTPRepository(Conn *sql.DB) auth.TOTPRepository {
+	return &postgreTOTPRepository{Conn}
+}
+
+func (m *postgreTOTPRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.TOTP, error) {
+	query := `select user_id, secret, confirmed_at, last_step, created from user_totp where user_id = $1 for update`
+
+	t := new(entity.TOTP)
+	err := dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, userID.String()).Scan(
+		&t.UserID,
+		&t.Secret,
+		&t.ConfirmedAt,
+		&t.LastStep,
+		&t.Created,
+	)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *postgreTOTPRepository) Set(ctx context.Context, t *entity.TOTP) error {
+	query := `INSERT INTO user_totp (user_id, secret, confirmed_at, last_step, created) VALUES ($1, $2, NULL, 0, now())
+						ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, confirmed_at = NULL, last_step = 0,
+							created = excluded.created`
+
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query, t.UserID.String(), t.Secret)
+	return err
+}
+
+func (m *postgreTOTPRepository) Confirm(ctx context.Context, userID uuid.UUID, step int64) error {
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx,
+		`UPDATE user_totp SET confirmed_at = now(), last_step = $2 WHERE user_id = $1`, userID.String(), step)
+	return err
+}
+
+func (m *postgreTOTPRepository) SetLastStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx,
+		`UPDATE user_totp SET last_step = $2 WHERE user_id = $1`, userID.String(), step)
+	return err
+}
+
+func (m *postgreTOTPRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID.String())
+	return err
+}
+
+type postgreRecoveryCodeRepository struct {
+	Conn *sql.DB
+}
+
+func NewPostgreRecoveryCodeRepository(Conn *sql.DB) auth.RecoveryCodeRepository {
+	return &postgreRecoveryCodeRepository{Conn}
+}
+
+func (m *postgreRecoveryCodeRepository) Replace(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	conn := dbutil.Conn(ctx, m.Conn)
+	_, err := conn.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID.String())
+	if err != nil || len(hashes) == 0 {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) SELECT $1, unnest($2::text[])`,
+		userID.String(), pq.Array(hashes))
+	return err
+}
+
+func (m *postgreRecoveryCodeRepository) Use(ctx context.Context, userID uuid.UUID, hash string) (bool, error) {
+	res, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx,
+		`UPDATE recovery_codes SET used_at = now() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID.String(), hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"time"
+)
+
+// Password reset requests are counted in the login attempts store under
+// their own scopes.
+const (
+	ScopeResetAccount = "reset_account"
+	ScopeResetIP      = "reset_ip"
+)
+
+// PasswordResetPolicy limits password reset requests to AccountLimit per
+// email and IPLimit per client address, counted until Window passes without
+// any. Reset tokens expire after TTL. A limit of 0 disables it.
+type PasswordResetPolicy struct {
+	TTL          time.Duration
+	Window       time.Duration
+	AccountLimit int
+	IPLimit      int
+}
+
+func (p *PasswordResetPolicy) Limit(scope string) int {
+	if scope == ScopeResetAccount {
+		return p.AccountLimit
+	}
+	return p.IPLimit
+}
+
+// Check returns a ThrottledError with ErrTooManyAttempts when attempts, the
+// requests made so far, reach the limit of their scope, or nil.
+func (p *PasswordResetPolicy) Check(attempts *entity.LoginAttempts, now time.Time) error {
+	if attempts == nil {
+		return nil
+	}
+	limit := p.Limit(attempts.Scope)
+	if limit <= 0 || attempts.Failures < limit {
+		return nil
+	}
+	if until := attempts.LastFailure.Add(p.Window); now.Before(until) {
+		return &ThrottledError{Err: models.ErrTooManyAttempts, RetryAfter: until.Sub(now)}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// SecretBox encrypts secrets at rest, such as TOTP secrets, with AES-256-GCM.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox derives the encryption key from key with SHA-256. Like
+// signing keys, key must be at least 32 bytes.
+func NewSecretBox(key string) (*SecretBox, error) {
+	if len(key) < minSigningKeyLength {
+		return nil, errors.New("encryption key must be at least 32 bytes")
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal returns plaintext encrypted under a random nonce, which it starts with.
+func (b *SecretBox) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts a sealed secret, failing if it was tampered with.
+func (b *SecretBox) Open(sealed []byte) ([]byte, error) {
+	size := b.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("sealed secret is too short")
+	}
+	return b.aead.Open(nil, sealed[:size], sealed[size:], nil)
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// minSigningKeyLength is the size of the SHA-256 block HS256 keys are mixed
+// into; shorter keys are easier to brute-force.
+const minSigningKeyLength = 32
+
+// TokenIssuer signs access tokens as HS256 JWTs and generates opaque refresh
+// tokens.
+type TokenIssuer struct {
+	key        []byte
+	issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+func NewTokenIssuer(key string, issuer string, accessTTL time.Duration, refreshTTL time.Duration) (*TokenIssuer, error) {
+	if len(key) < minSigningKeyLength {
+		return nil, errors.New("token signing key must be at least 32 bytes")
+	}
+	return &TokenIssuer{
+		key:        []byte(key),
+		issuer:     issuer,
+		AccessTTL:  accessTTL,
+		RefreshTTL: refreshTTL,
+	}, nil
+}
+
+// accessClaims are the JWT claims of access tokens: the user is the subject
+// and sid is the refresh token family of the session.
+type accessClaims struct {
+	SessionID string   `json:"sid"`
+	Roles     []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Sign returns an access token for the session of userID, who has roles.
+func (t *TokenIssuer) Sign(userID uuid.UUID, sessionID uuid.UUID, roles []string, now time.Time) (string, error) {
+	claims := accessClaims{
+		SessionID: sessionID.String(),
+		Roles:     roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    t.issuer,
+			Subject:   userID.String(),
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.AccessTTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.key)
+}
+
+// Parse verifies an access token and returns its claims, or ErrInvalidToken.
+func (t *TokenIssuer) Parse(token string) (*entity.AccessClaims, error) {
+	var claims accessClaims
+	parsed, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return t.key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !parsed.Valid || !claims.VerifyIssuer(t.issuer, true) || claims.ExpiresAt == nil ||
+		len(claims.Audience) > 0 {
+		return nil, models.ErrInvalidToken
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, models.ErrInvalidToken
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return nil, models.ErrInvalidToken
+	}
+	return &entity.AccessClaims{UserID: userID, SessionID: sessionID, Roles: claims.Roles, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// Purposes of action tokens: the tokens in email verification links, the
+// tokens completing logins with a second factor, and the tokens in invitation
+// links, whose subject is the organization rather than a user.
+const (
+	PurposeVerifyEmail = "verify-email"
+	PurposeMFA         = "mfa"
+	PurposeInvitation  = "invitation"
+)
+
+// SignAction returns a token allowing a one-off action on the user subject,
+// such as verifying an email. purpose becomes the audience, so that tokens
+// cannot be used for another action or as access tokens; id names the stored
+// request that makes the token single-use.
+func (t *TokenIssuer) SignAction(purpose string, id uuid.UUID, subject uuid.UUID, expiresAt time.Time, now time.Time) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    t.issuer,
+		Subject:   subject.String(),
+		Audience:  jwt.ClaimStrings{purpose},
+		ID:        id.String(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.key)
+}
+
+// ParseAction verifies a token signed by SignAction for purpose and returns
+// its request ID and subject, or ErrInvalidToken.
+func (t *TokenIssuer) ParseAction(purpose string, token string) (id uuid.UUID, subject uuid.UUID, err error) {
+	var claims jwt.RegisteredClaims
+	parsed, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return t.key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !parsed.Valid || !claims.VerifyIssuer(t.issuer, true) || claims.ExpiresAt == nil ||
+		!claims.VerifyAudience(purpose, true) {
+		return uuid.Nil, uuid.Nil, models.ErrInvalidToken
+	}
+
+	if id, err = uuid.Parse(claims.ID); err != nil {
+		return uuid.Nil, uuid.Nil, models.ErrInvalidToken
+	}
+	if subject, err = uuid.Parse(claims.Subject); err != nil {
+		return uuid.Nil, uuid.Nil, models.ErrInvalidToken
+	}
+	return id, subject, nil
+}
+
+// NewOpaqueToken returns a random token, such as a refresh token, and the hash
+// it is stored under.
+func NewOpaqueToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashOpaqueToken(token), nil
+}
+
+// HashOpaqueToken returns the hex SHA-256 of token. Opaque tokens are random
+// enough that a fast unsalted hash is safe.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
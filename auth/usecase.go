@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+)
+
+// RoleAdmin is granted to the operators of the service.
+const RoleAdmin = "admin"
+
+// PasswordUseCase sets user passwords. Setting a password ends the sessions of
+// the user.
+type PasswordUseCase interface {
+	SetPassword(ctx context.Context, userID uuid.UUID, password string) error
+}
+
+// Authenticator verifies access tokens.
+type Authenticator interface {
+	Authenticate(ctx context.Context, accessToken string) (*entity.AccessClaims, error)
+}
+
+// SessionRevoker ends the sessions of users. Access tokens already issued stay
+// valid until they expire.
+type SessionRevoker interface {
+	RevokeSessions(ctx context.Context, userID uuid.UUID) error
+}
+
+type UseCase interface {
+	PasswordUseCase
+	Authenticator
+	SessionRevoker
+	Login(ctx context.Context, email string, password string) (*entity.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (*entity.TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+	// LoginMFA completes a login started by a user with two-factor
+	// authentication, exchanging the MFAToken of the password step and a TOTP
+	// or recovery code for tokens.
+	LoginMFA(ctx context.Context, mfaToken string, code string) (*entity.TokenPair, error)
+	// Unlock clears the failed logins and lockout of the user's account.
+	Unlock(ctx context.Context, userID uuid.UUID) error
+	// ChangePassword sets the password of the user once current is checked,
+	// and ends their sessions. Operators may leave current empty.
+	ChangePassword(ctx context.Context, userID uuid.UUID, current string, password string) error
+}
+
+// SecondFactor checks the second factor of users who enabled two-factor
+// authentication.
+type SecondFactor interface {
+	// Enabled reports whether the user confirmed an authenticator.
+	Enabled(ctx context.Context, userID uuid.UUID) (bool, error)
+	// Verify accepts a TOTP code or an unused recovery code of the user, and
+	// uses it up. Other codes fail with ErrInvalidCredentials.
+	Verify(ctx context.Context, userID uuid.UUID, code string) error
+}
+
+// TwoFactorUseCase lets users enable TOTP two-factor authentication.
+type TwoFactorUseCase interface {
+	SecondFactor
+	// Enroll starts enrolling a new authenticator, replacing any unconfirmed
+	// one.
+	Enroll(ctx context.Context, userID uuid.UUID) (*entity.TOTPEnrollment, error)
+	// Confirm enables two-factor authentication with the first code of the
+	// enrolled authenticator, ending the sessions of the user.
+	Confirm(ctx context.Context, userID uuid.UUID, code string) (*entity.RecoveryCodes, error)
+	// Disable turns two-factor authentication off given a current code.
+	Disable(ctx context.Context, userID uuid.UUID, code string) error
+}
+
+// PasswordResetUseCase lets users who forgot their password set a new one
+// through a link mailed to them.
+type PasswordResetUseCase interface {
+	// RequestReset mails a reset link to the user with email, if any. It
+	// succeeds whether or not there is such a user.
+	RequestReset(ctx context.Context, email string) error
+	// Reset sets the password of the user a reset token was mailed to, and
+	// ends their sessions.
+	Reset(ctx context.Context, token string, password string) error
+}
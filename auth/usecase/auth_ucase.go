@@ -0,0 +1,549 @@
+package usecase
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const tokenTypeBearer = "Bearer"
+
+// mfaTokenTTL is how long users have to enter their code after their password.
+const mfaTokenTTL = 5 * time.Minute
+
+type authUseCase struct {
+	userRepository         user.Repository
+	historyRepository      user.HistoryRepository
+	credentialRepository   auth.CredentialRepository
+	tokenRepository        auth.RefreshTokenRepository
+	roleRepository         auth.RoleRepository
+	loginAttemptRepository auth.LoginAttemptRepository
+	hasher                 auth.Hasher
+	policy                 *auth.PasswordPolicy
+	lockout                *auth.LockoutPolicy
+	tokens                 *auth.TokenIssuer
+	secondFactor           auth.SecondFactor
+	mfaRoles               map[string]bool
+	transactor             dbutil.Transactor
+	contextTimeout         time.Duration
+}
+
+// NewAuthUseCase asks users who enabled it for their second factor sf. The
+// mfaRoles are only granted to users who enabled it.
+func NewAuthUseCase(u user.Repository, hr user.HistoryRepository, c auth.CredentialRepository, r auth.RefreshTokenRepository,
+	ro auth.RoleRepository, la auth.LoginAttemptRepository, h auth.Hasher, p *auth.PasswordPolicy, l *auth.LockoutPolicy,
+	t *auth.TokenIssuer, sf auth.SecondFactor, mfaRoles []string, tx dbutil.Transactor, timeout time.Duration) auth.UseCase {
+	required := make(map[string]bool, len(mfaRoles))
+	for _, role := range mfaRoles {
+		required[role] = true
+	}
+	return &authUseCase{
+		userRepository:         u,
+		historyRepository:      hr,
+		credentialRepository:   c,
+		tokenRepository:        r,
+		roleRepository:         ro,
+		loginAttemptRepository: la,
+		hasher:                 h,
+		policy:                 p,
+		lockout:                l,
+		tokens:                 t,
+		secondFactor:           sf,
+		mfaRoles:               required,
+		transactor:             tx,
+		contextTimeout:         timeout,
+	}
+}
+
+func (a *authUseCase) SetPassword(c context.Context, userID uuid.UUID, password string) error {
+	if err := a.policy.Check(password); err != nil {
+		return err
+	}
+	hash, err := a.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := a.credentialRepository.Set(ctx, userID, hash)
+		if err != nil {
+			return err
+		}
+		return a.tokenRepository.RevokeUser(ctx, userID)
+	})
+}
+
+// ChangePassword checks current like a login: wrong passwords fail with
+// ErrInvalidCredentials and count against the account under the lockout
+// policy, whose refusals fail with a ThrottledError.
+func (a *authUseCase) ChangePassword(c context.Context, userID uuid.UUID, current string, password string) error {
+	if err := a.policy.Check(password); err != nil {
+		return err
+	}
+	check := current != "" || !models.HasRole(c, auth.RoleAdmin)
+
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	now := time.Now()
+	var failed bool
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		u, err := a.userRepository.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if check {
+			ok, err := a.checkPassword(ctx, u, current, now)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				// the failure is committed, and the change fails afterwards
+				failed = true
+				return a.recordFailure(ctx, []attemptKey{{auth.ScopeAccount, strings.ToLower(u.Email)}}, u, now)
+			}
+		}
+		return a.SetPassword(ctx, userID, password)
+	})
+	if err != nil {
+		return err
+	}
+	if failed {
+		return models.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// checkPassword reports whether password is the one of u, once the lockout
+// policy lets the account try at now.
+func (a *authUseCase) checkPassword(ctx context.Context, u *entity.User, password string, now time.Time) (bool, error) {
+	attempts, err := a.loginAttemptRepository.Get(ctx, auth.ScopeAccount, strings.ToLower(u.Email))
+	if err != nil {
+		return false, err
+	}
+	if err = a.lockout.Check(attempts, now); err != nil {
+		return false, err
+	}
+
+	cred, err := a.credentialRepository.GetByUserID(ctx, u.ID)
+	if err == models.ErrNotFound {
+		_, _ = a.hasher.Hash(password)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return auth.VerifyPassword(cred.Hash, password)
+}
+
+// Login starts a session for the user with email and password. Unknown emails,
+// users without a password and wrong passwords all fail with
+// ErrInvalidCredentials after hashing once, so callers cannot tell them apart.
+// Failures are counted against the email, whether or not a user has it, and
+// against the client IP; attempts the lockout policy forbids fail with a
+// ThrottledError without checking the password.
+//
+// Suspended and deactivated users fail with ErrAccountDisabled once their
+// password is checked.
+//
+// Users with two-factor authentication get an MFAToken instead of tokens, to
+// complete the login with LoginMFA. Their account failures are only cleared
+// once the code is right, so that guessing codes counts towards the lockout.
+func (a *authUseCase) Login(c context.Context, email string, password string) (*entity.TokenPair, error) {
+	// users are looked up by email before anyone is authenticated, in any
+	// organization
+	ctx, cancel := context.WithTimeout(models.AsSystem(c), a.contextTimeout)
+	defer cancel()
+
+	keys := []attemptKey{{auth.ScopeAccount, strings.ToLower(email)}}
+	if ip := models.ClientIPFromContext(ctx); ip != "" {
+		keys = append(keys, attemptKey{auth.ScopeIP, ip})
+	}
+	now := time.Now()
+
+	var res *entity.TokenPair
+	var failed bool
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		for _, k := range keys {
+			attempts, err := a.loginAttemptRepository.Get(ctx, k.scope, k.key)
+			if err != nil {
+				return err
+			}
+			if err = a.lockout.Check(attempts, now); err != nil {
+				return err
+			}
+		}
+
+		u, cred, err := a.credentialByEmail(ctx, email)
+		if err != nil {
+			return err
+		}
+		ok := false
+		if cred == nil {
+			_, _ = a.hasher.Hash(password)
+		} else if ok, err = auth.VerifyPassword(cred.Hash, password); err != nil {
+			return err
+		}
+		if !ok {
+			// the failures are committed, and the login fails afterwards
+			failed = true
+			return a.recordFailure(ctx, keys, u, now)
+		}
+		if u.Disabled() {
+			return models.ErrAccountDisabled
+		}
+
+		if a.hasher.NeedsRehash(cred.Hash) {
+			hash, err := a.hasher.Hash(password)
+			if err != nil {
+				return err
+			}
+			if err = a.credentialRepository.Set(ctx, cred.UserID, hash); err != nil {
+				return err
+			}
+		}
+
+		enabled, err := a.secondFactor.Enabled(ctx, cred.UserID)
+		if err != nil {
+			return err
+		}
+		if enabled {
+			token, err := a.tokens.SignAction(auth.PurposeMFA, uuid.New(), cred.UserID, now.Add(mfaTokenTTL), now)
+			if err != nil {
+				return err
+			}
+			res = &entity.TokenPair{MFAToken: token}
+			return nil
+		}
+
+		err = a.loginAttemptRepository.Reset(ctx, auth.ScopeAccount, keys[0].key)
+		if err != nil {
+			return err
+		}
+		res, err = a.issue(ctx, cred.UserID, uuid.New())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failed {
+		return nil, models.ErrInvalidCredentials
+	}
+	return res, nil
+}
+
+// LoginMFA fails with ErrInvalidToken when mfaToken is forged or expired.
+// Wrong codes fail with ErrInvalidCredentials and count as failed logins,
+// against the account and the client IP, under the lockout policy.
+func (a *authUseCase) LoginMFA(c context.Context, mfaToken string, code string) (*entity.TokenPair, error) {
+	_, userID, err := a.tokens.ParseAction(auth.PurposeMFA, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(models.AsSystem(c), a.contextTimeout)
+	defer cancel()
+
+	now := time.Now()
+	var res *entity.TokenPair
+	var failed bool
+	err = a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		u, err := a.userRepository.GetByID(ctx, userID)
+		if err == models.ErrNotFound {
+			return models.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		if u.Disabled() {
+			return models.ErrAccountDisabled
+		}
+
+		keys := []attemptKey{{auth.ScopeAccount, strings.ToLower(u.Email)}}
+		if ip := models.ClientIPFromContext(ctx); ip != "" {
+			keys = append(keys, attemptKey{auth.ScopeIP, ip})
+		}
+		for _, k := range keys {
+			attempts, err := a.loginAttemptRepository.Get(ctx, k.scope, k.key)
+			if err != nil {
+				return err
+			}
+			if err = a.lockout.Check(attempts, now); err != nil {
+				return err
+			}
+		}
+
+		err = a.secondFactor.Verify(ctx, userID, code)
+		if err == models.ErrInvalidCredentials {
+			failed = true
+			return a.recordFailure(ctx, keys, u, now)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = a.loginAttemptRepository.Reset(ctx, auth.ScopeAccount, keys[0].key)
+		if err != nil {
+			return err
+		}
+		res, err = a.issue(ctx, userID, uuid.New())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failed {
+		return nil, models.ErrInvalidCredentials
+	}
+	return res, nil
+}
+
+type attemptKey struct {
+	scope string
+	key   string
+}
+
+// recordFailure counts a failed login against keys, locking those that reach
+// their threshold. Account lockouts of existing users are audited.
+func (a *authUseCase) recordFailure(ctx context.Context, keys []attemptKey, u *entity.User, now time.Time) error {
+	for _, k := range keys {
+		attempts, err := a.loginAttemptRepository.RecordFailure(ctx, k.scope, k.key, now, a.lockout.Window)
+		if err != nil {
+			return err
+		}
+		threshold := a.lockout.Threshold(k.scope)
+		if threshold <= 0 || attempts.Failures < threshold {
+			continue
+		}
+
+		err = a.loginAttemptRepository.Lock(ctx, k.scope, k.key, now.Add(a.lockout.Duration))
+		if err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{"scope": k.scope, "key": k.key, "failures": attempts.Failures}).
+			Warn("login locked out")
+		if k.scope == auth.ScopeAccount && u != nil {
+			if err = a.audit(ctx, entity.OperationLock, u); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// audit records an operation that leaves the user unchanged.
+func (a *authUseCase) audit(ctx context.Context, operation string, u *entity.User) error {
+	return a.historyRepository.Store(ctx, &entity.UserHistory{
+		UserID:    u.ID,
+		Operation: operation,
+		Actor:     models.ActorFromContext(ctx),
+		RequestID: models.RequestIDFromContext(ctx),
+		Before:    u,
+		After:     u,
+		Changes:   entity.FieldChanges{},
+	})
+}
+
+// Unlock is audited only when the account had failures or a lockout.
+func (a *authUseCase) Unlock(c context.Context, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		u, err := a.userRepository.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		key := strings.ToLower(u.Email)
+		attempts, err := a.loginAttemptRepository.Get(ctx, auth.ScopeAccount, key)
+		if err != nil || attempts == nil {
+			return err
+		}
+
+		err = a.loginAttemptRepository.Reset(ctx, auth.ScopeAccount, key)
+		if err != nil {
+			return err
+		}
+		return a.audit(ctx, entity.OperationUnlock, u)
+	})
+}
+
+// credentialByEmail returns the user with email and their credential. Either
+// is nil when there is no such user or the user has no password.
+func (a *authUseCase) credentialByEmail(ctx context.Context, email string) (*entity.User, *entity.Credential, error) {
+	users, err := a.userRepository.GetByEmails(ctx, []string{email})
+	if err == models.ErrNotFound || err == nil && len(users) == 0 {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cred, err := a.credentialRepository.GetByUserID(ctx, users[0].ID)
+	if err == models.ErrNotFound {
+		return users[0], nil, nil
+	}
+	return users[0], cred, err
+}
+
+// Refresh uses refreshToken up and issues a new pair in the same session.
+// Sessions of suspended or deactivated users cannot be refreshed.
+// Presenting a token that was already used means it leaked: the whole session
+// is revoked and the call fails like an unknown token.
+func (a *authUseCase) Refresh(c context.Context, refreshToken string) (*entity.TokenPair, error) {
+	ctx, cancel := context.WithTimeout(models.AsSystem(c), a.contextTimeout)
+	defer cancel()
+
+	var res *entity.TokenPair
+	var reused *entity.RefreshToken
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		t, err := a.tokenRepository.GetByHash(ctx, auth.HashOpaqueToken(refreshToken))
+		if err == models.ErrNotFound {
+			return models.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case t.RevokedAt != nil || time.Now().After(t.ExpiresAt):
+			return models.ErrInvalidToken
+		case t.UsedAt != nil:
+			// revoke in this transaction and fail once it is committed
+			reused = t
+			return a.tokenRepository.RevokeFamily(ctx, t.FamilyID)
+		}
+
+		u, err := a.userRepository.GetByID(ctx, t.UserID)
+		if err == models.ErrNotFound {
+			return models.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		if u.Disabled() {
+			return models.ErrInvalidToken
+		}
+
+		err = a.tokenRepository.MarkUsed(ctx, t.ID)
+		if err != nil {
+			return err
+		}
+		res, err = a.issue(ctx, t.UserID, t.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused != nil {
+		logrus.WithFields(logrus.Fields{"user_id": reused.UserID, "session_id": reused.FamilyID}).
+			Warn("refresh token reused, session revoked")
+		return nil, models.ErrInvalidToken
+	}
+	return res, nil
+}
+
+// Logout revokes the session of refreshToken. Unknown tokens are ignored.
+func (a *authUseCase) Logout(c context.Context, refreshToken string) error {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		t, err := a.tokenRepository.GetByHash(ctx, auth.HashOpaqueToken(refreshToken))
+		if err == models.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return a.tokenRepository.RevokeFamily(ctx, t.FamilyID)
+	})
+}
+
+// RevokeSessions revokes every session of the user. Access tokens already
+// issued stay valid until they expire.
+func (a *authUseCase) RevokeSessions(c context.Context, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	_, err := a.userRepository.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return a.tokenRepository.RevokeUser(ctx, userID)
+}
+
+func (a *authUseCase) Authenticate(_ context.Context, accessToken string) (*entity.AccessClaims, error) {
+	return a.tokens.Parse(accessToken)
+}
+
+// issue stores a new refresh token in session and signs an access token for
+// it, carrying the current roles of the user. Roles requiring two-factor
+// authentication are left out until the user enables it.
+func (a *authUseCase) issue(ctx context.Context, userID uuid.UUID, session uuid.UUID) (*entity.TokenPair, error) {
+	refresh, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err = a.tokenRepository.Create(ctx, &entity.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  session,
+		Hash:      hash,
+		ExpiresAt: now.Add(a.tokens.RefreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := a.roleRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	roles, withheld, err := a.grantableRoles(ctx, userID, roles)
+	if err != nil {
+		return nil, err
+	}
+	access, err := a.tokens.Sign(userID, session, roles, now)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.TokenPair{
+		AccessToken:           access,
+		RefreshToken:          refresh,
+		TokenType:             tokenTypeBearer,
+		ExpiresIn:             int(a.tokens.AccessTTL.Seconds()),
+		MFAEnrollmentRequired: withheld,
+	}, nil
+}
+
+// grantableRoles drops the roles requiring two-factor authentication from
+// roles when the user has not enabled it, reporting whether it dropped any.
+func (a *authUseCase) grantableRoles(ctx context.Context, userID uuid.UUID, roles []string) ([]string, bool, error) {
+	granted := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if !a.mfaRoles[role] {
+			granted = append(granted, role)
+		}
+	}
+	if len(granted) == len(roles) {
+		return roles, false, nil
+	}
+
+	enabled, err := a.secondFactor.Enabled(ctx, userID)
+	if err != nil || enabled {
+		return roles, false, err
+	}
+	return granted, true, nil
+}
@@ -0,0 +1,409 @@
+package usecase_test
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/auth/repository"
+	"GoMastersTest/auth/usecase"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeUsers struct {
+	user.Repository
+	users []*entity.User
+}
+
+func (f *fakeUsers) GetByEmails(ctx context.Context, emails []string) ([]*entity.User, error) {
+	res := make([]*entity.User, 0)
+	for _, u := range f.users {
+		if strings.EqualFold(u.Email, emails[0]) {
+			res = append(res, u)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, id uuid.UUID, fields ...string) (*entity.User, error) {
+	for _, u := range f.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+type fakeCredentials map[uuid.UUID]string
+
+func (f fakeCredentials) GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.Credential, error) {
+	hash, ok := f[userID]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	return &entity.Credential{UserID: userID, Hash: hash}, nil
+}
+
+func (f fakeCredentials) Set(ctx context.Context, userID uuid.UUID, hash string) error {
+	f[userID] = hash
+	return nil
+}
+
+type fakeTokens struct {
+	tokens []*entity.RefreshToken
+}
+
+func (f *fakeTokens) Create(ctx context.Context, t *entity.RefreshToken) error {
+	f.tokens = append(f.tokens, t)
+	return nil
+}
+
+func (f *fakeTokens) GetByHash(ctx context.Context, hash string) (*entity.RefreshToken, error) {
+	for _, t := range f.tokens {
+		if t.Hash == hash {
+			return t, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (f *fakeTokens) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	for _, t := range f.tokens {
+		if t.ID == id {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (f *fakeTokens) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	return f.revoke(func(t *entity.RefreshToken) bool { return t.FamilyID == familyID })
+}
+
+func (f *fakeTokens) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	return f.revoke(func(t *entity.RefreshToken) bool { return t.UserID == userID })
+}
+
+func (f *fakeTokens) revoke(match func(t *entity.RefreshToken) bool) error {
+	now := time.Now()
+	for _, t := range f.tokens {
+		if match(t) && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakeRoles map[uuid.UUID][]string
+
+func (f fakeRoles) GetByUserID(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	return f[userID], nil
+}
+
+type fakeHistory struct {
+	user.HistoryRepository
+	records []*entity.UserHistory
+}
+
+func (f *fakeHistory) Store(ctx context.Context, record *entity.UserHistory) error {
+	f.records = append(f.records, record)
+	return nil
+}
+
+type noTransactor struct{}
+
+func (noTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+const password = "correct horse battery staple"
+
+var lockout = &auth.LockoutPolicy{
+	AccountThreshold: 3,
+	IPThreshold:      5,
+	Window:           time.Hour,
+	Duration:         time.Hour,
+}
+
+func newAuthUseCase(t *testing.T) (auth.UseCase, *entity.User, fakeCredentials, *fakeTokens) {
+	uc, u, credentials, tokens, _ := newLockoutUseCase(t, lockout)
+	return uc, u, credentials, tokens
+}
+
+func newLockoutUseCase(t *testing.T, lockout *auth.LockoutPolicy) (auth.UseCase, *entity.User, fakeCredentials, *fakeTokens, *fakeHistory) {
+	f := newAuthFixture(t, lockout, nil)
+	return f.auth, f.user, f.credentials, f.tokens, f.history
+}
+
+type authFixture struct {
+	user        *entity.User
+	credentials fakeCredentials
+	tokens      *fakeTokens
+	history     *fakeHistory
+	twoFactor   auth.TwoFactorUseCase
+	auth        auth.UseCase
+}
+
+// newAuthFixture returns a user with a password and the admin role, who needs
+// two-factor authentication for the mfaRoles.
+func newAuthFixture(t *testing.T, lockout *auth.LockoutPolicy, mfaRoles []string) *authFixture {
+	u := &entity.User{User: DTOs.User{Firstname: "Igor", Email: "igor@example.com"}, ID: uuid.New()}
+	f := &authFixture{
+		user:        u,
+		credentials: make(fakeCredentials),
+		tokens:      new(fakeTokens),
+		history:     new(fakeHistory),
+	}
+	users := &fakeUsers{users: []*entity.User{u}}
+	roles := fakeRoles{u.ID: {auth.RoleAdmin}}
+
+	hasher, err := auth.NewArgon2idHasher(auth.Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1})
+	require.NoError(t, err)
+	issuer, err := auth.NewTokenIssuer(strings.Repeat("k", 32), "test", time.Minute, time.Hour)
+	require.NoError(t, err)
+	box, err := auth.NewSecretBox(strings.Repeat("s", 32))
+	require.NoError(t, err)
+	policy := auth.NewPasswordPolicy(12, 64)
+
+	f.twoFactor = usecase.NewTwoFactorUseCase(users, make(fakeTOTPs), make(fakeRecoveryCodes), f.tokens, box, "test",
+		noTransactor{}, time.Second)
+	f.auth = usecase.NewAuthUseCase(users, f.history, f.credentials, f.tokens, roles,
+		repository.NewMemoryLoginAttemptRepository(), hasher, policy, lockout, issuer, f.twoFactor, mfaRoles,
+		noTransactor{}, time.Second)
+	require.NoError(t, f.auth.SetPassword(context.Background(), u.ID, password))
+	return f
+}
+
+func TestLogin(t *testing.T) {
+	uc, u, credentials, _ := newAuthUseCase(t)
+	ctx := context.Background()
+
+	_, err := uc.Login(ctx, "igor@example.com", "wrong password")
+	assert.Equal(t, models.ErrInvalidCredentials, err)
+	_, err = uc.Login(ctx, "nobody@example.com", password)
+	assert.Equal(t, models.ErrInvalidCredentials, err)
+
+	pair, err := uc.Login(ctx, "IGOR@example.com", password)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", pair.TokenType)
+	assert.Equal(t, 60, pair.ExpiresIn)
+
+	claims, err := uc.Authenticate(ctx, pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, u.ID, claims.UserID)
+	assert.Equal(t, []string{auth.RoleAdmin}, claims.Roles)
+
+	_, err = uc.Authenticate(ctx, pair.AccessToken+"x")
+	assert.Equal(t, models.ErrInvalidToken, err)
+
+	assert.True(t, strings.HasPrefix(credentials[u.ID], "$argon2id$"))
+	assert.NotContains(t, credentials[u.ID], password)
+}
+
+func TestLoginRehashes(t *testing.T) {
+	uc, u, credentials, _ := newAuthUseCase(t)
+	bcryptHasher, err := auth.NewBcryptHasher(4)
+	require.NoError(t, err)
+	credentials[u.ID], err = bcryptHasher.Hash(password)
+	require.NoError(t, err)
+
+	_, err = uc.Login(context.Background(), u.Email, password)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(credentials[u.ID], "$argon2id$"))
+}
+
+func TestRefreshRotation(t *testing.T) {
+	uc, _, _, tokens := newAuthUseCase(t)
+	ctx := context.Background()
+
+	first, err := uc.Login(ctx, "igor@example.com", password)
+	require.NoError(t, err)
+	second, err := uc.Refresh(ctx, first.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	// replaying the used token revokes the session, including its newest token
+	_, err = uc.Refresh(ctx, first.RefreshToken)
+	assert.Equal(t, models.ErrInvalidToken, err)
+	_, err = uc.Refresh(ctx, second.RefreshToken)
+	assert.Equal(t, models.ErrInvalidToken, err)
+
+	for _, tok := range tokens.tokens {
+		assert.NotNil(t, tok.RevokedAt)
+		assert.NotEqual(t, first.RefreshToken, tok.Hash)
+	}
+}
+
+func TestLogoutAndRevokeSessions(t *testing.T) {
+	uc, u, _, _ := newAuthUseCase(t)
+	ctx := context.Background()
+
+	a, err := uc.Login(ctx, u.Email, password)
+	require.NoError(t, err)
+	b, err := uc.Login(ctx, u.Email, password)
+	require.NoError(t, err)
+
+	require.NoError(t, uc.Logout(ctx, a.RefreshToken))
+	_, err = uc.Refresh(ctx, a.RefreshToken)
+	assert.Equal(t, models.ErrInvalidToken, err)
+	_, err = uc.Refresh(ctx, b.RefreshToken)
+	require.NoError(t, err)
+
+	// setting a password ends the other sessions too
+	c, err := uc.Login(ctx, u.Email, password)
+	require.NoError(t, err)
+	require.NoError(t, uc.SetPassword(ctx, u.ID, "another long passphrase"))
+	_, err = uc.Refresh(ctx, c.RefreshToken)
+	assert.Equal(t, models.ErrInvalidToken, err)
+
+	assert.NoError(t, uc.Logout(ctx, "unknown"))
+	assert.Equal(t, models.ErrNotFound, uc.RevokeSessions(ctx, uuid.New()))
+}
+
+func TestDisabledUsersCannotSignIn(t *testing.T) {
+	uc, u, _, _ := newAuthUseCase(t)
+	ctx := context.Background()
+
+	pair, err := uc.Login(ctx, u.Email, password)
+	require.NoError(t, err)
+
+	u.Status = entity.StatusSuspended
+	_, err = uc.Login(ctx, u.Email, "wrong password")
+	assert.Equal(t, models.ErrInvalidCredentials, err, "the status is only told to who knows the password")
+	_, err = uc.Login(ctx, u.Email, password)
+	assert.Equal(t, models.ErrAccountDisabled, err)
+	_, err = uc.Refresh(ctx, pair.RefreshToken)
+	assert.Equal(t, models.ErrInvalidToken, err)
+
+	u.Status = entity.StatusActive
+	_, err = uc.Login(ctx, u.Email, password)
+	assert.NoError(t, err)
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	uc, u, _, _ := newAuthUseCase(t)
+	ctx := context.Background()
+
+	assert.Equal(t, models.ErrPasswordTooShort, uc.SetPassword(ctx, u.ID, "short"))
+	assert.Equal(t, models.ErrPasswordTooLong, uc.SetPassword(ctx, u.ID, strings.Repeat("a", 65)))
+
+	policy := auth.NewPasswordPolicy(1, 0)
+	path := t.TempDir() + "/breached.txt"
+	require.NoError(t, os.WriteFile(path, []byte("# common passwords\nPassword123\n\nqwerty\n"), 0644))
+	require.NoError(t, policy.LoadBreachList(path))
+	assert.Equal(t, models.ErrPasswordBreached, policy.Check("password123"))
+	assert.NoError(t, policy.Check("password1234"))
+}
+
+func TestChangePassword(t *testing.T) {
+	uc, u, _, _ := newAuthUseCase(t)
+	self := models.WithUserID(context.Background(), u.ID)
+	const next = "another long passphrase"
+
+	pair, err := uc.Login(self, u.Email, password)
+	require.NoError(t, err)
+
+	assert.Equal(t, models.ErrInvalidCredentials, uc.ChangePassword(self, u.ID, "", next))
+	assert.Equal(t, models.ErrInvalidCredentials, uc.ChangePassword(self, u.ID, "wrong password", next))
+	assert.Equal(t, models.ErrPasswordTooShort, uc.ChangePassword(self, u.ID, password, "short"))
+	require.NoError(t, uc.ChangePassword(self, u.ID, password, next))
+	_, err = uc.Refresh(self, pair.RefreshToken)
+	assert.Equal(t, models.ErrInvalidToken, err, "changing the password ends the sessions")
+	_, err = uc.Login(self, u.Email, next)
+	require.NoError(t, err)
+
+	// wrong passwords lock the account like failed logins
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, models.ErrInvalidCredentials, uc.ChangePassword(self, u.ID, "wrong password", password))
+	}
+	err = uc.ChangePassword(self, u.ID, next, password)
+	var throttled *auth.ThrottledError
+	require.ErrorAs(t, err, &throttled)
+	assert.Equal(t, models.ErrAccountLocked, throttled.Err)
+
+	admin := models.WithRoles(models.WithUserID(context.Background(), uuid.New()), []string{auth.RoleAdmin})
+	require.NoError(t, uc.ChangePassword(admin, u.ID, "", password))
+	assert.Equal(t, models.ErrNotFound, uc.ChangePassword(admin, uuid.New(), "", password))
+}
+
+func TestCredentialHashNotSerialized(t *testing.T) {
+	b, err := json.Marshal(entity.Credential{Hash: "$argon2id$secret"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "secret")
+
+	b, err = json.Marshal(entity.User{ID: uuid.New()})
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "Password")
+}
+
+func TestAccountLockout(t *testing.T) {
+	uc, u, _, _, history := newLockoutUseCase(t, lockout)
+	ctx := models.WithClientIP(context.Background(), "192.0.2.1")
+
+	for i := 0; i < 3; i++ {
+		_, err := uc.Login(ctx, u.Email, "wrong password")
+		assert.Equal(t, models.ErrInvalidCredentials, err)
+	}
+
+	// even the right password is refused while locked, from any address
+	_, err := uc.Login(models.WithClientIP(context.Background(), "192.0.2.2"), u.Email, password)
+	var throttled *auth.ThrottledError
+	require.ErrorAs(t, err, &throttled)
+	assert.Equal(t, models.ErrAccountLocked, throttled.Err)
+	assert.InDelta(t, time.Hour.Seconds(), throttled.RetryAfter.Seconds(), 1)
+
+	require.Len(t, history.records, 1)
+	assert.Equal(t, entity.OperationLock, history.records[0].Operation)
+	assert.Equal(t, u.ID, history.records[0].UserID)
+
+	require.NoError(t, uc.Unlock(ctx, u.ID))
+	_, err = uc.Login(ctx, u.Email, password)
+	require.NoError(t, err)
+	require.Len(t, history.records, 2)
+	assert.Equal(t, entity.OperationUnlock, history.records[1].Operation)
+}
+
+func TestIPLockout(t *testing.T) {
+	uc, u, _, _, history := newLockoutUseCase(t, lockout)
+	ctx := models.WithClientIP(context.Background(), "192.0.2.1")
+
+	// spraying many accounts from one address locks the address
+	for i := 0; i < 5; i++ {
+		_, err := uc.Login(ctx, uuid.NewString()+"@example.com", password)
+		assert.Equal(t, models.ErrInvalidCredentials, err)
+	}
+	_, err := uc.Login(ctx, u.Email, password)
+	assert.ErrorIs(t, err, models.ErrTooManyAttempts)
+	assert.Empty(t, history.records)
+
+	_, err = uc.Login(models.WithClientIP(context.Background(), "192.0.2.2"), u.Email, password)
+	assert.NoError(t, err)
+}
+
+func TestProgressiveDelay(t *testing.T) {
+	uc, u, _, _, _ := newLockoutUseCase(t, &auth.LockoutPolicy{Window: time.Hour, BaseDelay: time.Hour, MaxDelay: 4 * time.Hour})
+	ctx := context.Background()
+
+	_, err := uc.Login(ctx, u.Email, "wrong password")
+	assert.Equal(t, models.ErrInvalidCredentials, err)
+	_, err = uc.Login(ctx, u.Email, password)
+	var throttled *auth.ThrottledError
+	require.ErrorAs(t, err, &throttled)
+	assert.Equal(t, models.ErrTooManyAttempts, throttled.Err)
+
+	policy := &auth.LockoutPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	assert.Equal(t, time.Duration(0), policy.Delay(0))
+	assert.Equal(t, time.Second, policy.Delay(1))
+	assert.Equal(t, 4*time.Second, policy.Delay(3))
+	assert.Equal(t, 5*time.Second, policy.Delay(10))
+}
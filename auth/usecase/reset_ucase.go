@@ -0,0 +1,158 @@
+package usecase
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/mail"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+type passwordResetUseCase struct {
+	userRepository         user.Repository
+	resetRepository        auth.PasswordResetRepository
+	loginAttemptRepository auth.LoginAttemptRepository
+	passwords              auth.PasswordUseCase
+	mailer                 mail.Mailer
+	templates              *mail.Templates
+	policy                 *auth.PasswordResetPolicy
+	link                   string
+	transactor             dbutil.Transactor
+	contextTimeout         time.Duration
+}
+
+// NewPasswordResetUseCase mails links to link, with the reset token in their
+// token query parameter. Requests are counted in la.
+func NewPasswordResetUseCase(u user.Repository, r auth.PasswordResetRepository, la auth.LoginAttemptRepository,
+	p auth.PasswordUseCase, m mail.Mailer, tpl *mail.Templates, policy *auth.PasswordResetPolicy, link string,
+	tx dbutil.Transactor, timeout time.Duration) auth.PasswordResetUseCase {
+	return &passwordResetUseCase{
+		userRepository:         u,
+		resetRepository:        r,
+		loginAttemptRepository: la,
+		passwords:              p,
+		mailer:                 m,
+		templates:              tpl,
+		policy:                 policy,
+		link:                   link,
+		transactor:             tx,
+		contextTimeout:         timeout,
+	}
+}
+
+// RequestReset counts the request against the email, whether or not a user
+// has it, and against the client IP, failing with a ThrottledError once the
+// policy limit is reached. Mail failures are only logged, since reporting them
+// would tell which emails belong to users.
+func (a *passwordResetUseCase) RequestReset(c context.Context, email string) error {
+	// the requester is anonymous and the user may belong to any organization
+	ctx, cancel := context.WithTimeout(models.AsSystem(c), a.contextTimeout)
+	defer cancel()
+
+	keys := []attemptKey{{auth.ScopeResetAccount, strings.ToLower(email)}}
+	if ip := models.ClientIPFromContext(ctx); ip != "" {
+		keys = append(keys, attemptKey{auth.ScopeResetIP, ip})
+	}
+	now := time.Now()
+
+	var msg *mail.Message
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		for _, k := range keys {
+			attempts, err := a.loginAttemptRepository.Get(ctx, k.scope, k.key)
+			if err != nil {
+				return err
+			}
+			if err = a.policy.Check(attempts, now); err != nil {
+				return err
+			}
+		}
+		for _, k := range keys {
+			_, err := a.loginAttemptRepository.RecordFailure(ctx, k.scope, k.key, now, a.policy.Window)
+			if err != nil {
+				return err
+			}
+		}
+
+		users, err := a.userRepository.GetByEmails(ctx, []string{email})
+		if err == models.ErrNotFound || err == nil && len(users) == 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		token, hash, err := auth.NewOpaqueToken()
+		if err != nil {
+			return err
+		}
+		r := &entity.PasswordReset{
+			ID:        uuid.New(),
+			UserID:    users[0].ID,
+			Hash:      hash,
+			ExpiresAt: now.Add(a.policy.TTL),
+		}
+		link, err := mail.LinkWithToken(a.link, token)
+		if err != nil {
+			return err
+		}
+		msg, err = a.templates.Render(mail.TemplatePasswordReset, users[0].Email,
+			mail.LinkData{User: users[0], Link: link, ExpiresAt: r.ExpiresAt})
+		if err != nil {
+			return err
+		}
+		return a.resetRepository.Create(ctx, r)
+	})
+	if err != nil {
+		return err
+	}
+
+	if msg != nil {
+		if err = a.mailer.Send(ctx, msg); err != nil {
+			logrus.WithField("to", msg.To).Errorf("sending password reset: %v", err)
+		}
+	}
+	return nil
+}
+
+// Reset fails with ErrInvalidToken when the token is unknown, expired or used,
+// and with the password policy errors, leaving the token usable. It also uses
+// up the other resets of the user and clears the lockout of their account.
+func (a *passwordResetUseCase) Reset(c context.Context, token string, password string) error {
+	ctx, cancel := context.WithTimeout(models.AsSystem(c), a.contextTimeout)
+	defer cancel()
+
+	return a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		r, err := a.resetRepository.GetByHash(ctx, auth.HashOpaqueToken(token))
+		if err == models.ErrNotFound {
+			return models.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		if r.UsedAt != nil || time.Now().After(r.ExpiresAt) {
+			return models.ErrInvalidToken
+		}
+
+		u, err := a.userRepository.GetByID(ctx, r.UserID)
+		if err == models.ErrNotFound {
+			return models.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = a.passwords.SetPassword(ctx, u.ID, password); err != nil {
+			return err
+		}
+		if err = a.resetRepository.MarkUsed(ctx, u.ID); err != nil {
+			return err
+		}
+		return a.loginAttemptRepository.Reset(ctx, auth.ScopeAccount, strings.ToLower(u.Email))
+	})
+}
@@ -0,0 +1,138 @@
+package usecase_test
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/auth/repository"
+	"GoMastersTest/auth/usecase"
+	"GoMastersTest/mail"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+type fakeResets struct {
+	resets []*entity.PasswordReset
+}
+
+func (f *fakeResets) Create(ctx context.Context, r *entity.PasswordReset) error {
+	f.resets = append(f.resets, r)
+	return nil
+}
+
+func (f *fakeResets) GetByHash(ctx context.Context, hash string) (*entity.PasswordReset, error) {
+	for _, r := range f.resets {
+		if r.Hash == hash {
+			return r, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (f *fakeResets) MarkUsed(ctx context.Context, userID uuid.UUID) error {
+	now := time.Now()
+	for _, r := range f.resets {
+		if r.UserID == userID && r.UsedAt == nil {
+			r.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakeMailer struct {
+	sent []*mail.Message
+}
+
+func (f *fakeMailer) Send(ctx context.Context, m *mail.Message) error {
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+var resetLinkToken = regexp.MustCompile(`token=(\S+)`)
+
+func newResetUseCase(t *testing.T, policy *auth.PasswordResetPolicy) (auth.PasswordResetUseCase, auth.UseCase, *entity.User, *fakeMailer) {
+	uc, u, _, _ := newAuthUseCase(t)
+	templates, err := mail.LoadTemplates("")
+	require.NoError(t, err)
+	mailer := new(fakeMailer)
+
+	reset := usecase.NewPasswordResetUseCase(&fakeUsers{users: []*entity.User{u}}, new(fakeResets),
+		repository.NewMemoryLoginAttemptRepository(), uc, mailer, templates, policy, "http://localhost/reset-password",
+		noTransactor{}, time.Second)
+	return reset, uc, u, mailer
+}
+
+func TestPasswordReset(t *testing.T) {
+	reset, uc, u, mailer := newResetUseCase(t, &auth.PasswordResetPolicy{TTL: time.Minute, Window: time.Hour})
+	ctx := context.Background()
+
+	require.NoError(t, reset.RequestReset(ctx, "nobody@example.com"))
+	assert.Empty(t, mailer.sent)
+
+	session, err := uc.Login(ctx, u.Email, password)
+	require.NoError(t, err)
+
+	require.NoError(t, reset.RequestReset(ctx, "IGOR@example.com"))
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, u.Email, mailer.sent[0].To)
+	assert.Equal(t, "Reset your password", mailer.sent[0].Subject)
+	match := resetLinkToken.FindStringSubmatch(mailer.sent[0].Body)
+	require.NotNil(t, match)
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+
+	// a rejected password leaves the token usable
+	assert.Equal(t, models.ErrPasswordTooShort, reset.Reset(ctx, token, "short"))
+	require.NoError(t, reset.Reset(ctx, token, "a brand new passphrase"))
+
+	_, err = uc.Refresh(ctx, session.RefreshToken)
+	assert.Equal(t, models.ErrInvalidToken, err, "resetting revokes the sessions")
+	_, err = uc.Login(ctx, u.Email, password)
+	assert.Equal(t, models.ErrInvalidCredentials, err)
+	_, err = uc.Login(ctx, u.Email, "a brand new passphrase")
+	assert.NoError(t, err)
+
+	assert.Equal(t, models.ErrInvalidToken, reset.Reset(ctx, token, "yet another passphrase"))
+	assert.Equal(t, models.ErrInvalidToken, reset.Reset(ctx, "unknown", "yet another passphrase"))
+}
+
+func TestPasswordResetExpires(t *testing.T) {
+	reset, _, u, mailer := newResetUseCase(t, &auth.PasswordResetPolicy{TTL: -time.Second, Window: time.Hour})
+	ctx := context.Background()
+
+	require.NoError(t, reset.RequestReset(ctx, u.Email))
+	require.Len(t, mailer.sent, 1)
+	token, err := url.QueryUnescape(resetLinkToken.FindStringSubmatch(mailer.sent[0].Body)[1])
+	require.NoError(t, err)
+	assert.Equal(t, models.ErrInvalidToken, reset.Reset(ctx, token, "a brand new passphrase"))
+}
+
+func TestPasswordResetRateLimit(t *testing.T) {
+	reset, _, u, mailer := newResetUseCase(t, &auth.PasswordResetPolicy{TTL: time.Minute, Window: time.Hour,
+		AccountLimit: 2, IPLimit: 4})
+	ctx := models.WithClientIP(context.Background(), "10.0.0.1")
+
+	// unknown emails are limited alike, so limits tell nothing about accounts
+	for _, email := range []string{u.Email, "nobody@example.com"} {
+		require.NoError(t, reset.RequestReset(ctx, email))
+		require.NoError(t, reset.RequestReset(ctx, email), email)
+	}
+
+	var throttled *auth.ThrottledError
+	err := reset.RequestReset(context.Background(), u.Email)
+	require.True(t, errors.As(err, &throttled))
+	assert.Equal(t, models.ErrTooManyAttempts, throttled.Err)
+	assert.InDelta(t, time.Hour.Seconds(), throttled.RetryAfter.Seconds(), 5)
+
+	// the address made four requests over two emails
+	err = reset.RequestReset(ctx, "someone@example.com")
+	require.True(t, errors.As(err, &throttled))
+	assert.Len(t, mailer.sent, 2)
+}
@@ -0,0 +1,215 @@
+package usecase
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+// recoveryCodeCount is the number of recovery codes issued when two-factor
+// authentication is enabled.
+const recoveryCodeCount = 10
+
+type twoFactorUseCase struct {
+	userRepository     user.Repository
+	totpRepository     auth.TOTPRepository
+	recoveryRepository auth.RecoveryCodeRepository
+	tokenRepository    auth.RefreshTokenRepository
+	box                *auth.SecretBox
+	issuer             string
+	transactor         dbutil.Transactor
+	contextTimeout     time.Duration
+}
+
+// NewTwoFactorUseCase stores TOTP secrets encrypted with box. Authenticator
+// apps list the enrollments under issuer.
+func NewTwoFactorUseCase(u user.Repository, t auth.TOTPRepository, rc auth.RecoveryCodeRepository,
+	r auth.RefreshTokenRepository, box *auth.SecretBox, issuer string, tx dbutil.Transactor,
+	timeout time.Duration) auth.TwoFactorUseCase {
+	return &twoFactorUseCase{
+		userRepository:     u,
+		totpRepository:     t,
+		recoveryRepository: rc,
+		tokenRepository:    r,
+		box:                box,
+		issuer:             issuer,
+		transactor:         tx,
+		contextTimeout:     timeout,
+	}
+}
+
+// Enroll fails with ErrTwoFactorEnabled when the user already has a confirmed
+// authenticator; it must be disabled first.
+func (a *twoFactorUseCase) Enroll(c context.Context, userID uuid.UUID) (*entity.TOTPEnrollment, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	var res *entity.TOTPEnrollment
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		u, err := a.userRepository.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		t, err := a.totpRepository.GetByUserID(ctx, userID)
+		if err != nil && err != models.ErrNotFound {
+			return err
+		}
+		if t != nil && t.ConfirmedAt != nil {
+			return models.ErrTwoFactorEnabled
+		}
+
+		secret, err := auth.NewTOTPSecret()
+		if err != nil {
+			return err
+		}
+		sealed, err := a.box.Seal(secret)
+		if err != nil {
+			return err
+		}
+		uri := auth.TOTPURI(a.issuer, u.Email, secret)
+		qr, err := auth.TOTPQRCode(uri)
+		if err != nil {
+			return err
+		}
+		if err = a.totpRepository.Set(ctx, &entity.TOTP{UserID: userID, Secret: sealed}); err != nil {
+			return err
+		}
+		res = &entity.TOTPEnrollment{Secret: auth.EncodeTOTPSecret(secret), URI: uri, QRCode: qr}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Confirm fails with ErrNotFound when the user has not enrolled, and with
+// ErrInvalidCode when code is not the current one. Sessions started before
+// are ended, so that every session of the user passed the second factor.
+func (a *twoFactorUseCase) Confirm(c context.Context, userID uuid.UUID, code string) (*entity.RecoveryCodes, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	var res *entity.RecoveryCodes
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		t, err := a.totpRepository.GetByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if t.ConfirmedAt != nil {
+			return models.ErrTwoFactorEnabled
+		}
+		secret, err := a.box.Open(t.Secret)
+		if err != nil {
+			return err
+		}
+		step, ok := auth.ValidateTOTP(secret, code, time.Now(), t.LastStep)
+		if !ok {
+			return models.ErrInvalidCode
+		}
+
+		codes, hashes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+		if err != nil {
+			return err
+		}
+		if err = a.totpRepository.Confirm(ctx, userID, step); err != nil {
+			return err
+		}
+		if err = a.recoveryRepository.Replace(ctx, userID, hashes); err != nil {
+			return err
+		}
+		res = &entity.RecoveryCodes{Codes: codes}
+		return a.tokenRepository.RevokeUser(ctx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Disable fails with ErrNotFound when two-factor authentication is off, and
+// with ErrInvalidCode unless code is a TOTP or recovery code of the user.
+func (a *twoFactorUseCase) Disable(c context.Context, userID uuid.UUID, code string) error {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		t, err := a.totpRepository.GetByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if t.ConfirmedAt == nil {
+			return models.ErrNotFound
+		}
+		ok, err := a.check(ctx, t, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return models.ErrInvalidCode
+		}
+
+		if err = a.totpRepository.Delete(ctx, userID); err != nil {
+			return err
+		}
+		return a.recoveryRepository.Replace(ctx, userID, nil)
+	})
+}
+
+func (a *twoFactorUseCase) Enabled(c context.Context, userID uuid.UUID) (bool, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	t, err := a.totpRepository.GetByUserID(ctx, userID)
+	if err == models.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return t.ConfirmedAt != nil, nil
+}
+
+func (a *twoFactorUseCase) Verify(c context.Context, userID uuid.UUID, code string) error {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		t, err := a.totpRepository.GetByUserID(ctx, userID)
+		if err == models.ErrNotFound {
+			return models.ErrInvalidCredentials
+		}
+		if err != nil {
+			return err
+		}
+		if t.ConfirmedAt == nil {
+			return models.ErrInvalidCredentials
+		}
+		ok, err := a.check(ctx, t, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return models.ErrInvalidCredentials
+		}
+		return nil
+	})
+}
+
+// check reports whether code is a TOTP code of t or an unused recovery code
+// of its user, and uses it up.
+func (a *twoFactorUseCase) check(ctx context.Context, t *entity.TOTP, code string) (bool, error) {
+	secret, err := a.box.Open(t.Secret)
+	if err != nil {
+		return false, err
+	}
+	if step, ok := auth.ValidateTOTP(secret, code, time.Now(), t.LastStep); ok {
+		return true, a.totpRepository.SetLastStep(ctx, t.UserID, step)
+	}
+	return a.recoveryRepository.Use(ctx, t.UserID, auth.HashRecoveryCode(code))
+}
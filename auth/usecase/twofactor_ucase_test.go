@@ -0,0 +1,177 @@
+package usecase_test
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"bytes"
+	"context"
+	"encoding/base32"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeTOTPs map[uuid.UUID]*entity.TOTP
+
+func (f fakeTOTPs) GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.TOTP, error) {
+	t, ok := f[userID]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	res := *t
+	return &res, nil
+}
+
+func (f fakeTOTPs) Set(ctx context.Context, t *entity.TOTP) error {
+	f[t.UserID] = &entity.TOTP{UserID: t.UserID, Secret: t.Secret, Created: time.Now()}
+	return nil
+}
+
+func (f fakeTOTPs) Confirm(ctx context.Context, userID uuid.UUID, step int64) error {
+	now := time.Now()
+	f[userID].ConfirmedAt = &now
+	f[userID].LastStep = step
+	return nil
+}
+
+func (f fakeTOTPs) SetLastStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	f[userID].LastStep = step
+	return nil
+}
+
+func (f fakeTOTPs) Delete(ctx context.Context, userID uuid.UUID) error {
+	delete(f, userID)
+	return nil
+}
+
+// fakeRecoveryCodes tells the unused codes of each user.
+type fakeRecoveryCodes map[uuid.UUID]map[string]bool
+
+func (f fakeRecoveryCodes) Replace(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	f[userID] = make(map[string]bool)
+	for _, hash := range hashes {
+		f[userID][hash] = true
+	}
+	return nil
+}
+
+func (f fakeRecoveryCodes) Use(ctx context.Context, userID uuid.UUID, hash string) (bool, error) {
+	unused := f[userID][hash]
+	delete(f[userID], hash)
+	return unused, nil
+}
+
+// enableTwoFactor enrolls and confirms an authenticator for the user of f,
+// returning its secret, the step of the confirming code and the recovery
+// codes.
+func enableTwoFactor(t *testing.T, f *authFixture) ([]byte, int64, []string) {
+	ctx := context.Background()
+
+	enrollment, err := f.twoFactor.Enroll(ctx, f.user.ID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/test:igor@example.com?"), enrollment.URI)
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+	assert.True(t, bytes.HasPrefix(enrollment.QRCode, []byte("\x89PNG")))
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	require.NoError(t, err)
+
+	step := auth.TOTPStep(time.Now())
+	_, err = f.twoFactor.Confirm(ctx, f.user.ID, "not a code")
+	assert.Equal(t, models.ErrInvalidCode, err)
+	codes, err := f.twoFactor.Confirm(ctx, f.user.ID, auth.TOTPCode(secret, step))
+	require.NoError(t, err)
+	require.Len(t, codes.Codes, 10)
+	return secret, step, codes.Codes
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	f := newAuthFixture(t, lockout, []string{auth.RoleAdmin})
+	ctx := context.Background()
+
+	// the admin role waits for two-factor authentication
+	pair, err := f.auth.Login(ctx, f.user.Email, password)
+	require.NoError(t, err)
+	assert.True(t, pair.MFAEnrollmentRequired)
+	claims, err := f.auth.Authenticate(ctx, pair.AccessToken)
+	require.NoError(t, err)
+	assert.Empty(t, claims.Roles)
+
+	secret, step, recovery := enableTwoFactor(t, f)
+	_, err = f.auth.Refresh(ctx, pair.RefreshToken)
+	assert.Equal(t, models.ErrInvalidToken, err, "enabling ends the sessions")
+	_, err = f.twoFactor.Confirm(ctx, f.user.ID, auth.TOTPCode(secret, step+1))
+	assert.Equal(t, models.ErrTwoFactorEnabled, err)
+
+	pair, err = f.auth.Login(ctx, f.user.Email, password)
+	require.NoError(t, err)
+	assert.Empty(t, pair.AccessToken)
+	assert.Empty(t, pair.RefreshToken)
+	require.NotEmpty(t, pair.MFAToken)
+	_, err = f.auth.Authenticate(ctx, pair.MFAToken)
+	assert.Equal(t, models.ErrInvalidToken, err, "MFA tokens are not access tokens")
+
+	// the code that confirmed the authenticator is used up
+	_, err = f.auth.LoginMFA(ctx, pair.MFAToken, auth.TOTPCode(secret, step))
+	assert.Equal(t, models.ErrInvalidCredentials, err)
+
+	res, err := f.auth.LoginMFA(ctx, pair.MFAToken, auth.TOTPCode(secret, step+1))
+	require.NoError(t, err)
+	assert.False(t, res.MFAEnrollmentRequired)
+	claims, err = f.auth.Authenticate(ctx, res.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, []string{auth.RoleAdmin}, claims.Roles)
+
+	// recovery codes work once, whatever their case
+	_, err = f.auth.LoginMFA(ctx, pair.MFAToken, strings.ToUpper(recovery[0]))
+	require.NoError(t, err)
+	_, err = f.auth.LoginMFA(ctx, pair.MFAToken, recovery[0])
+	assert.Equal(t, models.ErrInvalidCredentials, err)
+
+	_, err = f.auth.LoginMFA(ctx, res.AccessToken, recovery[1])
+	assert.Equal(t, models.ErrInvalidToken, err)
+}
+
+func TestTwoFactorLoginLockout(t *testing.T) {
+	f := newAuthFixture(t, lockout, nil)
+	ctx := context.Background()
+	enableTwoFactor(t, f)
+
+	pair, err := f.auth.Login(ctx, f.user.Email, password)
+	require.NoError(t, err)
+	for i := 0; i < lockout.AccountThreshold; i++ {
+		_, err = f.auth.LoginMFA(ctx, pair.MFAToken, "not a code")
+		assert.Equal(t, models.ErrInvalidCredentials, err)
+	}
+
+	var throttled *auth.ThrottledError
+	_, err = f.auth.Login(ctx, f.user.Email, password)
+	require.ErrorAs(t, err, &throttled)
+	assert.Equal(t, models.ErrAccountLocked, throttled.Err)
+}
+
+func TestTwoFactorDisable(t *testing.T) {
+	f := newAuthFixture(t, lockout, nil)
+	ctx := context.Background()
+
+	assert.Equal(t, models.ErrNotFound, f.twoFactor.Disable(ctx, f.user.ID, "000000"))
+	_, _, recovery := enableTwoFactor(t, f)
+
+	assert.Equal(t, models.ErrInvalidCode, f.twoFactor.Disable(ctx, f.user.ID, "not a code"))
+	require.NoError(t, f.twoFactor.Disable(ctx, f.user.ID, recovery[0]))
+	enabled, err := f.twoFactor.Enabled(ctx, f.user.ID)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	pair, err := f.auth.Login(ctx, f.user.Email, password)
+	require.NoError(t, err)
+	assert.Empty(t, pair.MFAToken)
+	assert.NotEmpty(t, pair.AccessToken)
+
+	// the recovery codes went with the authenticator
+	enableTwoFactor(t, f)
+	assert.Equal(t, models.ErrInvalidCode, f.twoFactor.Disable(ctx, f.user.ID, recovery[1]))
+}
@@ -0,0 +1,115 @@
+package dbutil
+
+import (
+	"GoMastersTest/models"
+	"context"
+	"database/sql"
+
+	"github.com/sirupsen/logrus"
+)
+
+type txKey struct{}
+
+// DBTX is the subset of *sql.DB and *sql.Tx used by repositories.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Transactor runs a function inside a database transaction.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type sqlTransactor struct {
+	Conn *sql.DB
+}
+
+func NewTransactor(Conn *sql.DB) Transactor {
+	return &sqlTransactor{Conn}
+}
+
+// WithinTransaction commits when fn returns nil and rolls back otherwise.
+// Calls nested inside an open transaction join it instead of starting a new one.
+// Transactions are restricted to the organization and the caller of ctx by
+// the row-level security policies of the schema.
+func (t *sqlTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := t.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = setTenant(ctx, tx)
+	if err == nil {
+		err = fn(context.WithValue(ctx, txKey{}, tx))
+	}
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logrus.Error(rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SystemRole is the database role, granted to the service, that the
+// row-level security policies let through to every organization. Transactions
+// of models.AsSystem contexts switch to it.
+const SystemRole = "app_system"
+
+// setTenant sets app.tenant_id and app.user_id, read by the row-level security
+// policies, to the organization and the caller of ctx until tx ends, and
+// switches to SystemRole for models.AsSystem contexts. The policies match no
+// rows for transactions that set none of them.
+func setTenant(ctx context.Context, tx *sql.Tx) error {
+	tenant, user, system, ok := session(ctx)
+	if !ok {
+		return nil
+	}
+	query := `SELECT set_config('app.tenant_id', $1, true), set_config('app.user_id', $2, true)`
+	args := []interface{}{tenant, user}
+	if system {
+		query += `, set_config('role', $3, true)`
+		args = append(args, SystemRole)
+	}
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// session returns the organization and the caller of ctx, empty when unset,
+// whether it acts for the system, and false when it has none of them.
+func session(ctx context.Context) (tenant string, user string, system bool, ok bool) {
+	if orgID, scoped := models.OrgIDFromContext(ctx); scoped {
+		tenant = orgID.String()
+	}
+	if userID, authenticated := models.UserIDFromContext(ctx); authenticated {
+		user = userID.String()
+	}
+	system = models.IsSystem(ctx)
+	return tenant, user, system, tenant != "" || user != "" || system
+}
+
+// InTenant runs fn in a transaction when ctx names an organization, a caller
+// or the system, so that the row-level security policies apply to it, and as
+// is otherwise, when the policies hide every row from it.
+func InTenant(ctx context.Context, t Transactor, fn func(ctx context.Context) error) error {
+	if _, _, _, ok := session(ctx); !ok {
+		return fn(ctx)
+	}
+	return t.WithinTransaction(ctx, fn)
+}
+
+// Conn returns the transaction carried by ctx, or db when there is none.
+func Conn(ctx context.Context, db *sql.DB) DBTX {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
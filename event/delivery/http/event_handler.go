@@ -0,0 +1,115 @@
+package http
+
+import (
+	"GoMastersTest/event"
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var userEventTypes = map[string]bool{
+	entity.EventUserCreated:  true,
+	entity.EventUserUpdated:  true,
+	entity.EventUserDeleted:  true,
+	entity.EventUserRestored: true,
+}
+
+type EventHandler struct {
+	Usecase   event.StreamUseCase
+	Heartbeat time.Duration
+}
+
+// NewEventHandler registers the event stream, guarded by requireTenant.
+func NewEventHandler(r *gin.Engine, us event.StreamUseCase, heartbeat time.Duration, requireTenant gin.HandlerFunc) {
+	handler := &EventHandler{
+		Usecase:   us,
+		Heartbeat: heartbeat,
+	}
+	v1 := r.Group("/api/v1")
+	v1.GET("/users/events", requireTenant, handler.StreamUserEvents)
+}
+
+// StreamUserEvents godoc
+// @Summary      Stream User Events
+// @Description  Server-Sent Events stream of the user changes made in the organization of the request, or in any for admins without one. Send Last-Event-ID to resume after a disconnect.
+// @Tags         Users
+// @Produce      text/event-stream
+// @Param        types          query   string  false  "Comma separated event types, e.g. UserCreated,UserDeleted"
+// @Param        Last-Event-ID  header  string  false  "ID of the last event received"
+// @Success      200  {object}  entity.Event
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /users/events [get]
+func (a *EventHandler) StreamUserEvents(c *gin.Context) {
+	types := make([]string, 0)
+	if v := c.Query("types"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			if !userEventTypes[t] {
+				httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	ctx := c.Request.Context()
+	replay, subscription, err := a.Usecase.Subscribe(ctx, c.GetHeader("Last-Event-ID"), types)
+	if err != nil {
+		httputil.NewError(c, httputil.StatusCode(err), err)
+		return
+	}
+	defer a.Usecase.Unsubscribe(subscription)
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	for _, e := range replay {
+		if err := writeEvent(c.Writer, e); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(a.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-subscription.Events:
+			if !ok {
+				logrus.Warn("closing slow user event stream")
+				return
+			}
+			err = writeEvent(c.Writer, e)
+		case <-ticker.C:
+			_, err = io.WriteString(c.Writer, ": heartbeat\n\n")
+		}
+		if err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeEvent(w io.Writer, e *entity.Event) error {
+	return sse.Encode(w, sse.Event{
+		Id:    e.ID.String(),
+		Event: e.Type,
+		Data:  e,
+	})
+}
@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"GoMastersTest/event"
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type RelayWorker struct {
+	Usecase  event.RelayUseCase
+	Interval time.Duration
+}
+
+func NewRelayWorker(us event.RelayUseCase, interval time.Duration) *RelayWorker {
+	return &RelayWorker{
+		Usecase:  us,
+		Interval: interval,
+	}
+}
+
+// Run relays outbox events until ctx is cancelled. A batch that delivered
+// anything is followed immediately by the next one; otherwise the worker waits
+// for Interval. A non-positive Interval disables relaying.
+func (w *RelayWorker) Run(ctx context.Context) {
+	if w.Interval <= 0 {
+		logrus.Warnf("outbox interval is %v, events will not be relayed", w.Interval)
+		return
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		delivered, err := w.Usecase.RelayPending(ctx)
+		if err != nil {
+			logrus.Error(err)
+		}
+		if err == nil && delivered > 0 && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package event
+
+import (
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type OutboxRepository interface {
+	Store(ctx context.Context, e *entity.Event) error
+	StoreMany(ctx context.Context, events []*entity.Event) error
+	FetchPending(ctx context.Context, limit int) ([]*entity.Event, error)
+	// Claim postpones the next attempt of events to until, so that relays
+	// leave them alone while they are delivered.
+	Claim(ctx context.Context, ids []uuid.UUID, until time.Time) error
+	MarkDelivered(ctx context.Context, id uuid.UUID) error
+	MarkFailed(ctx context.Context, id uuid.UUID, nextAttempt time.Time, reason string) error
+}
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/event"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// batchChunkSize bounds the rows sent in one INSERT.
+const batchChunkSize = 1000
+
+type postgreOutboxRepository struct {
+	Conn *sql.DB
+}
+
+func NewPostgreOutboxRepository(Conn *sql.DB) event.OutboxRepository {
+	return &postgreOutboxRepository{Conn}
+}
+
+// Store appends e to the outbox with the next version of its aggregate. It
+// must run inside the transaction that made the change being announced.
+func (m *postgreOutboxRepository) Store(ctx context.Context, e *entity.Event) error {
+	return m.StoreMany(ctx, []*entity.Event{e})
+}
+
+// StoreMany appends events with one multi-row INSERT per chunk. Each event
+// must belong to a different aggregate.
+func (m *postgreOutboxRepository) StoreMany(ctx context.Context, events []*entity.Event) error {
+	for start := 0; start < len(events); start += batchChunkSize {
+		end := start + batchChunkSize
+		if end > len(events) {
+			end = len(events)
+		}
+		if err := m.storeChunk(ctx, events[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeChunk takes the next version of each aggregate from outbox_versions.
+// Bumping the counter locks its row until the transaction ends, so concurrent
+// changes to an aggregate get consecutive versions in commit order.
+func (m *postgreOutboxRepository) storeChunk(ctx context.Context, events []*entity.Event) error {
+	aggregates := make([]string, len(events))
+	for i, e := range events {
+		aggregates[i] = e.AggregateID.String()
+	}
+	versions, err := m.nextVersions(ctx, aggregates)
+	if err != nil {
+		return err
+	}
+
+	values := make([]string, len(events))
+	args := make([]interface{}, 0, len(events)*6)
+	byID := make(map[uuid.UUID]*entity.Event, len(events))
+	for i, e := range events {
+		id, err := uuid.NewUUID()
+		if err != nil {
+			return err
+		}
+
+		e.ID = id
+		byID[id] = e
+		var orgID interface{}
+		if e.OrgID != nil {
+			orgID = e.OrgID.String()
+		}
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d::uuid, $%d::uuid, $%d::uuid, $%d, $%d, $%d::jsonb)", n+1, n+2, n+3, n+4, n+5, n+6)
+		args = append(args, id.String(), e.AggregateID.String(), orgID, e.Type, versions[e.AggregateID], []byte(e.Payload))
+	}
+
+	query := `INSERT INTO outbox (id, aggregate_id, org_id, type, version, payload)
+						VALUES ` + strings.Join(values, ", ") + `
+						RETURNING id, version, created, next_attempt`
+
+	rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var version int
+		var created, nextAttempt time.Time
+		if err = rows.Scan(&id, &version, &created, &nextAttempt); err != nil {
+			return err
+		}
+		if e, ok := byID[id]; ok {
+			e.Version = version
+			e.Created = created
+			e.NextAttempt = nextAttempt
+		}
+	}
+	return rows.Err()
+}
+
+// nextVersions bumps the version counters of aggregates and returns the new
+// values.
+func (m *postgreOutboxRepository) nextVersions(ctx context.Context, aggregates []string) (map[uuid.UUID]int, error) {
+	query := `INSERT INTO outbox_versions (aggregate_id, version)
+						SELECT unnest($1::uuid[]), 1
+						ON CONFLICT (aggregate_id) DO UPDATE SET version = outbox_versions.version + 1
+						RETURNING aggregate_id, version`
+
+	rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, pq.Array(aggregates))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	res := make(map[uuid.UUID]int, len(aggregates))
+	for rows.Next() {
+		var id uuid.UUID
+		var version int
+		if err = rows.Scan(&id, &version); err != nil {
+			return nil, err
+		}
+		res[id] = version
+	}
+	return res, rows.Err()
+}
+
+// FetchPending returns due events that are the oldest undelivered event of
+// their aggregate, so a failing event holds back the ones queued after it.
+// The events are locked until the end of the transaction, and events locked by
+// another relay are skipped, along with the later ones of their aggregates.
+func (m *postgreOutboxRepository) FetchPending(ctx context.Context, limit int) ([]*entity.Event, error) {
+	query := `select o.id, o.aggregate_id, o.org_id, o.type, o.version, o.payload, o.created, o.attempts, o.next_attempt, o.last_error, o.delivered_at
+						from outbox o
+						where o.delivered_at is null and o.next_attempt <= now()
+						and not exists (select 1 from outbox p where p.aggregate_id = o.aggregate_id and p.delivered_at is null and p.version < o.version)
+						order by o.created, o.version limit $1
+						for update of o skip locked`
+
+	rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, limit)
+	if err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	result := make([]*entity.Event, 0)
+	for rows.Next() {
+		t := new(entity.Event)
+		var payload []byte
+		err = rows.Scan(
+			&t.ID,
+			&t.AggregateID,
+			&t.OrgID,
+			&t.Type,
+			&t.Version,
+			&payload,
+			&t.Created,
+			&t.Attempts,
+			&t.NextAttempt,
+			&t.LastError,
+			&t.Delivered,
+		)
+		if err != nil {
+			logrus.Error(err)
+			return nil, err
+		}
+		t.Payload = payload
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
+
+func (m *postgreOutboxRepository) Claim(ctx context.Context, ids []uuid.UUID, until time.Time) error {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+	query := `UPDATE outbox SET next_attempt = $2 WHERE id = ANY($1::uuid[])`
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query, pq.Array(keys), until)
+	return err
+}
+
+func (m *postgreOutboxRepository) MarkDelivered(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE outbox SET delivered_at = now(), attempts = attempts + 1, last_error = '' WHERE id = $1`
+	return m.exec(ctx, query, id.String())
+}
+
+func (m *postgreOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, nextAttempt time.Time, reason string) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, next_attempt = $2, last_error = $3 WHERE id = $1`
+	return m.exec(ctx, query, id.String(), nextAttempt, reason)
+}
+
+func (m *postgreOutboxRepository) exec(ctx context.Context, query string, args ...interface{}) error {
+	res, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if ra == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package event
+
+import (
+	"GoMastersTest/models/entity"
+	"context"
+)
+
+// Sink delivers events to a downstream consumer. Deliver may be called more
+// than once for the same event, so consumers must deduplicate by Event.ID.
+type Sink interface {
+	Deliver(ctx context.Context, e *entity.Event) error
+}
@@ -0,0 +1,54 @@
+package sink
+
+import (
+	"GoMastersTest/event"
+	"GoMastersTest/models/entity"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+type webhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookSink POSTs each event as JSON to url. Any non-2xx answer is a
+// failed delivery.
+func NewWebhookSink(url string, client *http.Client) event.Sink {
+	return &webhookSink{
+		URL:    url,
+		Client: client,
+	}
+}
+
+func (s *webhookSink) Deliver(ctx context.Context, e *entity.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", e.ID.String())
+	req.Header.Set("X-Event-Type", e.Type)
+
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook %s answered %d", s.URL, res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package sink
+
+import (
+	"GoMastersTest/event"
+	"GoMastersTest/models/entity"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+type writerSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink writes each event as one line of JSON to w, such as os.Stdout
+// or an append-only file.
+func NewWriterSink(w io.Writer) event.Sink {
+	return &writerSink{w: w}
+}
+
+func (s *writerSink) Deliver(ctx context.Context, e *entity.Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,37 @@
+package event
+
+import (
+	"GoMastersTest/models/entity"
+	"github.com/google/uuid"
+)
+
+// Subscription is a live listener of a StreamUseCase. Events is closed when
+// the listener is unsubscribed or falls too far behind.
+type Subscription struct {
+	Events chan *entity.Event
+	orgID  *uuid.UUID
+	types  map[string]bool
+}
+
+// NewSubscription listens to the events of the organization orgID, or of every
+// organization when it is nil.
+func NewSubscription(orgID *uuid.UUID, types []string, buffer int) *Subscription {
+	s := &Subscription{
+		Events: make(chan *entity.Event, buffer),
+		orgID:  orgID,
+		types:  make(map[string]bool),
+	}
+	for _, t := range types {
+		s.types[t] = true
+	}
+	return s
+}
+
+// Accepts reports whether the subscription wants e: an event of its
+// organization, of one of its types. An empty type list accepts every type.
+func (s *Subscription) Accepts(e *entity.Event) bool {
+	if s.orgID != nil && (e.OrgID == nil || *e.OrgID != *s.orgID) {
+		return false
+	}
+	return len(s.types) == 0 || s.types[e.Type]
+}
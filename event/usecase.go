@@ -0,0 +1,21 @@
+package event
+
+import (
+	"GoMastersTest/models/entity"
+	"context"
+)
+
+type RelayUseCase interface {
+	RelayPending(ctx context.Context) (int, error)
+}
+
+// StreamUseCase fans events out to live subscribers and keeps the most recent
+// ones for replay. It is fed by the outbox relay as a Sink.
+type StreamUseCase interface {
+	Sink
+	// Subscribe listens to the events of the organization of ctx, or of every
+	// organization for models.AsSystem contexts. Other contexts fail with
+	// ErrForbidden.
+	Subscribe(ctx context.Context, lastEventID string, types []string) ([]*entity.Event, *Subscription, error)
+	Unsubscribe(s *Subscription)
+}
@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/event"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/retry"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type relayUseCase struct {
+	outboxRepository event.OutboxRepository
+	sinks            []event.Sink
+	batchSize        int
+	minBackoff       time.Duration
+	maxBackoff       time.Duration
+	lease            time.Duration
+	transactor       dbutil.Transactor
+}
+
+// NewRelayUseCase claims the events of a batch for lease, which must outlast
+// the delivery of a whole batch: events still undelivered when it runs out,
+// such as those of a relay that stopped, are relayed again.
+func NewRelayUseCase(o event.OutboxRepository, sinks []event.Sink, batchSize int, minBackoff time.Duration, maxBackoff time.Duration,
+	lease time.Duration, tx dbutil.Transactor) event.RelayUseCase {
+	return &relayUseCase{
+		outboxRepository: o,
+		sinks:            sinks,
+		batchSize:        batchSize,
+		minBackoff:       minBackoff,
+		maxBackoff:       maxBackoff,
+		lease:            lease,
+		transactor:       tx,
+	}
+}
+
+// RelayPending delivers one batch of due events to every sink and returns how
+// many were delivered. An event is marked delivered only after all sinks
+// accepted it; otherwise it is rescheduled with exponential backoff. The batch
+// is claimed before it is delivered, so that no lock is held while the sinks
+// are called.
+func (r *relayUseCase) RelayPending(ctx context.Context) (int, error) {
+	events, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, e := range events {
+		if err := r.deliver(ctx, e); err != nil {
+			logrus.Errorf("event %s delivery attempt %d failed: %v", e.ID, e.Attempts+1, err)
+			next := time.Now().Add(retry.Backoff(e.Attempts+1, r.minBackoff, r.maxBackoff))
+			if err := r.outboxRepository.MarkFailed(ctx, e.ID, next, err.Error()); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+
+		if err := r.outboxRepository.MarkDelivered(ctx, e.ID); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+// claim fetches a batch of due events and postpones them by lease in a
+// transaction of its own, which keeps other relays from fetching them at the
+// same time and, once committed, until the lease runs out.
+func (r *relayUseCase) claim(ctx context.Context) ([]*entity.Event, error) {
+	var events []*entity.Event
+	err := r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		events, err = r.outboxRepository.FetchPending(ctx, r.batchSize)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(events))
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+		return r.outboxRepository.Claim(ctx, ids, time.Now().Add(r.lease))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *relayUseCase) deliver(ctx context.Context, e *entity.Event) error {
+	for _, s := range r.sinks {
+		if err := s.Deliver(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package usecase_test
+
+import (
+	"GoMastersTest/event"
+	"GoMastersTest/event/usecase"
+	"GoMastersTest/models/entity"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type noTransactor struct{}
+
+func (noTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type txKey struct{}
+
+// recordingTransactor marks the contexts of its transactions and counts them.
+type recordingTransactor struct {
+	count int
+}
+
+func (r *recordingTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	r.count++
+	return fn(context.WithValue(ctx, txKey{}, r.count))
+}
+
+type fakeOutbox struct {
+	pending   []*entity.Event
+	delivered []uuid.UUID
+	failed    map[uuid.UUID]time.Time
+	claimed   map[uuid.UUID]time.Time
+	// calls lists the transactions FetchPending, Claim and the marks ran in,
+	// 0 outside of any
+	calls []interface{}
+}
+
+func (f *fakeOutbox) Store(ctx context.Context, e *entity.Event) error {
+	f.pending = append(f.pending, e)
+	return nil
+}
+
+func (f *fakeOutbox) StoreMany(ctx context.Context, events []*entity.Event) error {
+	f.pending = append(f.pending, events...)
+	return nil
+}
+
+func (f *fakeOutbox) FetchPending(ctx context.Context, limit int) ([]*entity.Event, error) {
+	f.record(ctx)
+	return f.pending, nil
+}
+
+func (f *fakeOutbox) Claim(ctx context.Context, ids []uuid.UUID, until time.Time) error {
+	f.record(ctx)
+	if f.claimed == nil {
+		f.claimed = make(map[uuid.UUID]time.Time)
+	}
+	for _, id := range ids {
+		f.claimed[id] = until
+	}
+	return nil
+}
+
+func (f *fakeOutbox) MarkDelivered(ctx context.Context, id uuid.UUID) error {
+	f.record(ctx)
+	f.delivered = append(f.delivered, id)
+	return nil
+}
+
+func (f *fakeOutbox) MarkFailed(ctx context.Context, id uuid.UUID, nextAttempt time.Time, reason string) error {
+	f.record(ctx)
+	f.failed[id] = nextAttempt
+	return nil
+}
+
+func (f *fakeOutbox) record(ctx context.Context) {
+	tx := ctx.Value(txKey{})
+	if tx == nil {
+		tx = 0
+	}
+	f.calls = append(f.calls, tx)
+}
+
+type fakeSink struct {
+	fail map[uuid.UUID]bool
+	seen []uuid.UUID
+}
+
+func (f *fakeSink) Deliver(ctx context.Context, e *entity.Event) error {
+	f.seen = append(f.seen, e.ID)
+	if f.fail[e.ID] {
+		return errors.New("sink unavailable")
+	}
+	return nil
+}
+
+func TestRelayPending(t *testing.T) {
+	ok := &entity.Event{ID: uuid.New(), AggregateID: uuid.New(), Type: entity.EventUserCreated}
+	failing := &entity.Event{ID: uuid.New(), AggregateID: uuid.New(), Type: entity.EventUserUpdated, Attempts: 3}
+
+	outbox := &fakeOutbox{pending: []*entity.Event{ok, failing}, failed: map[uuid.UUID]time.Time{}}
+	s := &fakeSink{fail: map[uuid.UUID]bool{failing.ID: true}}
+	relay := usecase.NewRelayUseCase(outbox, []event.Sink{s}, 10, time.Second, time.Minute, time.Hour, noTransactor{})
+
+	start := time.Now()
+	delivered, err := relay.RelayPending(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, []uuid.UUID{ok.ID}, outbox.delivered)
+	assert.Equal(t, []uuid.UUID{ok.ID, failing.ID}, s.seen)
+
+	next, rescheduled := outbox.failed[failing.ID]
+	require.True(t, rescheduled)
+	// fourth attempt failed: 1s doubled three times
+	assert.WithinDuration(t, start.Add(8*time.Second), next, time.Second)
+}
+
+func TestRelayBackoffIsCapped(t *testing.T) {
+	failing := &entity.Event{ID: uuid.New(), AggregateID: uuid.New(), Attempts: 50}
+
+	outbox := &fakeOutbox{pending: []*entity.Event{failing}, failed: map[uuid.UUID]time.Time{}}
+	s := &fakeSink{fail: map[uuid.UUID]bool{failing.ID: true}}
+	relay := usecase.NewRelayUseCase(outbox, []event.Sink{s}, 10, time.Second, time.Minute, time.Hour, noTransactor{})
+
+	start := time.Now()
+	_, err := relay.RelayPending(context.Background())
+	require.NoError(t, err)
+
+	assert.Empty(t, outbox.delivered)
+	assert.WithinDuration(t, start.Add(time.Minute), outbox.failed[failing.ID], time.Second)
+}
+
+// claimCheckingSink refuses events that were not claimed beforehand, or that
+// it gets within a transaction.
+type claimCheckingSink struct {
+	outbox *fakeOutbox
+}
+
+func (s claimCheckingSink) Deliver(ctx context.Context, e *entity.Event) error {
+	if _, ok := s.outbox.claimed[e.ID]; !ok {
+		return errors.New("unclaimed event")
+	}
+	if ctx.Value(txKey{}) != nil {
+		return errors.New("delivered within a transaction")
+	}
+	return nil
+}
+
+func TestRelayClaimsTheBatchBeforeDelivering(t *testing.T) {
+	ok := &entity.Event{ID: uuid.New(), AggregateID: uuid.New(), Type: entity.EventUserCreated}
+	failing := &entity.Event{ID: uuid.New(), AggregateID: uuid.New(), Type: entity.EventUserUpdated}
+	outbox := &fakeOutbox{pending: []*entity.Event{ok, failing}, failed: make(map[uuid.UUID]time.Time)}
+	tx := new(recordingTransactor)
+	relay := usecase.NewRelayUseCase(outbox, []event.Sink{claimCheckingSink{outbox}, &fakeSink{fail: map[uuid.UUID]bool{failing.ID: true}}},
+		10, time.Second, time.Minute, time.Hour, tx)
+
+	start := time.Now()
+	delivered, err := relay.RelayPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, 1, tx.count)
+	assert.Equal(t, []interface{}{1, 1, 0, 0}, outbox.calls, "the batch is claimed in a transaction and marked after it")
+	require.Len(t, outbox.claimed, 2)
+	assert.WithinDuration(t, start.Add(time.Hour), outbox.claimed[ok.ID], time.Second)
+	assert.WithinDuration(t, start.Add(time.Second), outbox.failed[failing.ID], time.Second)
+}
+
+func TestRelayClaimsNothingWithoutEvents(t *testing.T) {
+	outbox := &fakeOutbox{failed: make(map[uuid.UUID]time.Time)}
+	relay := usecase.NewRelayUseCase(outbox, []event.Sink{&fakeSink{}}, 10, time.Second, time.Minute, time.Hour, new(recordingTransactor))
+
+	delivered, err := relay.RelayPending(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.Equal(t, []interface{}{1}, outbox.calls)
+}
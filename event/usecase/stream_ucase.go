@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"GoMastersTest/event"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+	"sync"
+)
+
+type streamUseCase struct {
+	mu           sync.Mutex
+	buffer       []*entity.Event
+	bufferSize   int
+	clientBuffer int
+	subscribers  map[*event.Subscription]struct{}
+}
+
+// NewStreamUseCase keeps the last bufferSize events for replay and gives every
+// subscriber a queue of clientBuffer events.
+func NewStreamUseCase(bufferSize int, clientBuffer int) event.StreamUseCase {
+	return &streamUseCase{
+		buffer:       make([]*entity.Event, 0, bufferSize),
+		bufferSize:   bufferSize,
+		clientBuffer: clientBuffer,
+		subscribers:  make(map[*event.Subscription]struct{}),
+	}
+}
+
+// Deliver records e and passes it to every interested subscriber. Subscribers
+// whose queue is full are dropped rather than blocking the relay; they can
+// reconnect and resume from the replay buffer.
+func (a *streamUseCase) Deliver(ctx context.Context, e *entity.Event) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, seen := range a.buffer {
+		if seen.ID == e.ID {
+			return nil
+		}
+	}
+
+	a.buffer = append(a.buffer, e)
+	if len(a.buffer) > a.bufferSize {
+		a.buffer = a.buffer[len(a.buffer)-a.bufferSize:]
+	}
+
+	for s := range a.subscribers {
+		if !s.Accepts(e) {
+			continue
+		}
+		select {
+		case s.Events <- e:
+		default:
+			delete(a.subscribers, s)
+			close(s.Events)
+		}
+	}
+	return nil
+}
+
+// Subscribe registers a subscriber and returns the buffered events it missed.
+// New clients, with an empty lastEventID, get none; clients resuming from an
+// event that has already been evicted get the whole buffer.
+func (a *streamUseCase) Subscribe(ctx context.Context, lastEventID string, types []string) ([]*entity.Event, *event.Subscription, error) {
+	var orgID *uuid.UUID
+	if id, ok := models.OrgIDFromContext(ctx); ok {
+		orgID = &id
+	} else if !models.IsSystem(ctx) {
+		return nil, nil, models.ErrForbidden
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := event.NewSubscription(orgID, types, a.clientBuffer)
+	a.subscribers[s] = struct{}{}
+
+	replay := make([]*entity.Event, 0)
+	if lastEventID == "" {
+		return replay, s, nil
+	}
+
+	start := 0
+	for i, e := range a.buffer {
+		if e.ID.String() == lastEventID {
+			start = i + 1
+			break
+		}
+	}
+
+	for _, e := range a.buffer[start:] {
+		if s.Accepts(e) {
+			replay = append(replay, e)
+		}
+	}
+	return replay, s, nil
+}
+
+func (a *streamUseCase) Unsubscribe(s *event.Subscription) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.subscribers[s]; ok {
+		delete(a.subscribers, s)
+		close(s.Events)
+	}
+}
@@ -0,0 +1,93 @@
+package usecase_test
+
+import (
+	"GoMastersTest/event"
+	"GoMastersTest/event/usecase"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newEvent(eventType string) *entity.Event {
+	return &entity.Event{ID: uuid.New(), AggregateID: uuid.New(), Type: eventType}
+}
+
+// subscribe subscribes to the events of every organization.
+func subscribe(t *testing.T, stream event.StreamUseCase, lastEventID string, types []string) ([]*entity.Event, *event.Subscription) {
+	replay, s, err := stream.Subscribe(models.AsSystem(context.Background()), lastEventID, types)
+	require.NoError(t, err)
+	return replay, s
+}
+
+func TestStreamReplayAfterLastEventID(t *testing.T) {
+	stream := usecase.NewStreamUseCase(2, 10)
+	first, second, third := newEvent(entity.EventUserCreated), newEvent(entity.EventUserUpdated), newEvent(entity.EventUserDeleted)
+	for _, e := range []*entity.Event{first, second, third, second} {
+		require.NoError(t, stream.Deliver(context.Background(), e))
+	}
+
+	replay, s := subscribe(t, stream, second.ID.String(), nil)
+	defer stream.Unsubscribe(s)
+	assert.Equal(t, []*entity.Event{third}, replay)
+
+	// first has been evicted, so resuming from it replays the whole buffer
+	replay, evicted := subscribe(t, stream, first.ID.String(), nil)
+	defer stream.Unsubscribe(evicted)
+	assert.Equal(t, []*entity.Event{second, third}, replay)
+
+	replay, fresh := subscribe(t, stream, "", nil)
+	defer stream.Unsubscribe(fresh)
+	assert.Empty(t, replay)
+}
+
+func TestStreamFiltersAndDropsSlowSubscribers(t *testing.T) {
+	stream := usecase.NewStreamUseCase(10, 1)
+
+	_, deletes := subscribe(t, stream, "", []string{entity.EventUserDeleted})
+	defer stream.Unsubscribe(deletes)
+	_, slow := subscribe(t, stream, "", nil)
+
+	created, deleted := newEvent(entity.EventUserCreated), newEvent(entity.EventUserDeleted)
+	require.NoError(t, stream.Deliver(context.Background(), created))
+	require.NoError(t, stream.Deliver(context.Background(), deleted))
+
+	assert.Equal(t, deleted, <-deletes.Events)
+
+	assert.Equal(t, created, <-slow.Events)
+	_, open := <-slow.Events
+	assert.False(t, open, "a subscriber with a full queue is dropped")
+	stream.Unsubscribe(slow)
+}
+
+func TestStreamIsolatesOrganizations(t *testing.T) {
+	stream := usecase.NewStreamUseCase(10, 10)
+	acme, globex := uuid.New(), uuid.New()
+
+	_, _, err := stream.Subscribe(context.Background(), "", nil)
+	assert.Equal(t, models.ErrForbidden, err, "unscoped callers see nothing")
+
+	_, s, err := stream.Subscribe(models.WithOrgID(context.Background(), acme), "", nil)
+	require.NoError(t, err)
+	defer stream.Unsubscribe(s)
+	_, all := subscribe(t, stream, "", nil)
+	defer stream.Unsubscribe(all)
+
+	mine, theirs, purged := newEvent(entity.EventUserCreated), newEvent(entity.EventUserCreated), newEvent(entity.EventUserDeleted)
+	mine.OrgID, theirs.OrgID = &acme, &globex
+	for _, e := range []*entity.Event{mine, theirs, purged} {
+		require.NoError(t, stream.Deliver(context.Background(), e))
+	}
+
+	assert.Equal(t, mine, <-s.Events)
+	assert.Len(t, s.Events, 0)
+	assert.Len(t, all.Events, 3)
+
+	replay, resumed, err := stream.Subscribe(models.WithOrgID(context.Background(), globex), mine.ID.String(), nil)
+	require.NoError(t, err)
+	defer stream.Unsubscribe(resumed)
+	assert.Equal(t, []*entity.Event{theirs}, replay)
+}
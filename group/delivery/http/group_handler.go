@@ -0,0 +1,429 @@
+package http
+
+import (
+	"GoMastersTest/group"
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+type GroupHandler struct {
+	Usecase       group.UseCase
+	MaxBatchItems int
+}
+
+// NewGroupHandler registers the group routes, all guarded by requireUser.
+// Bulk member changes over maxBatchItems users are rejected; 0 disables the
+// limit.
+func NewGroupHandler(r *gin.Engine, us group.UseCase, maxBatchItems int, requireUser gin.HandlerFunc) {
+	handler := &GroupHandler{
+		Usecase:       us,
+		MaxBatchItems: maxBatchItems,
+	}
+	v1 := r.Group("/api/v1", requireUser)
+	v1.GET("/groups", handler.GetAllGroups)
+	v1.GET("/groups/:id", handler.GetGroupByID)
+	v1.POST("/groups", handler.CreateGroup)
+	v1.PUT("/groups/:id", handler.UpdateGroup)
+	v1.DELETE("/groups/:id", handler.DeleteGroup)
+	v1.GET("/groups/:id/members", handler.GetMembers)
+	v1.POST("/groups/:id/members", handler.AddMembers)
+	v1.DELETE("/groups/:id/members", handler.RemoveMembers)
+	v1.PUT("/groups/:id/members/:userID", handler.AddMember)
+	v1.DELETE("/groups/:id/members/:userID", handler.RemoveMember)
+	v1.GET("/users/:id/groups", handler.GetUserGroups)
+}
+
+// GetAllGroups godoc
+// @Summary      GetAllGroups
+// @Description  Return the groups, by name
+// @Tags         Groups
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        limit   query  int  false  "Page size"
+// @Param        offset  query  int  false  "Page offset"
+// @Success      200  {object}  []entity.Group
+// @Header       200  {int}     X-Total-Count  "Total number of groups"
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Router /groups [get]
+func (a *GroupHandler) GetAllGroups(c *gin.Context) {
+	limit, offset, err := httputil.ParsePagination(c)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	groups, total, err := a.Usecase.GetAll(ctx, limit, offset)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	httputil.Respond(c, http.StatusOK, groups)
+}
+
+// GetGroupByID godoc
+// @Summary      GetGroupByID
+// @Description  Return a group
+// @Tags         Groups
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        id  path  string  true  "Group ID"
+// @Success      200  {object}  entity.Group
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /groups/{id} [get]
+func (a *GroupHandler) GetGroupByID(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.GetByID(ctx, id)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// CreateGroup godoc
+// @Summary      CreateGroup
+// @Description  Create a group, nested within ParentID when set
+// @Tags         Groups
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        Group  body  DTOs.Group  true  "Add group"
+// @Success      201  {object}  entity.Group
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /groups [post]
+func (a *GroupHandler) CreateGroup(c *gin.Context) {
+	var body DTOs.Group
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Create(ctx, &body)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("Location", c.FullPath()+"/"+res.ID.String())
+	httputil.Respond(c, http.StatusCreated, res)
+}
+
+// UpdateGroup godoc
+// @Summary      UpdateGroup
+// @Description  Update a group. Moving it within itself or one of its subgroups fails with 409.
+// @Tags         Groups
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        id     path  string      true  "Group ID"
+// @Param        Group  body  DTOs.Group  true  "Update group"
+// @Success      200  {object}  entity.Group
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      409  {object}  httputil.HTTPError
+// @Router /groups/{id} [put]
+func (a *GroupHandler) UpdateGroup(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	var body DTOs.Group
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Update(ctx, id, &body)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// DeleteGroup godoc
+// @Summary      DeleteGroup
+// @Description  Delete a group, keeping its users. Its subgroups become top level groups.
+// @Tags         Groups
+// @Param        id  path  string  true  "Group ID"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /groups/{id} [delete]
+func (a *GroupHandler) DeleteGroup(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err = a.Usecase.Delete(ctx, id); err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetMembers godoc
+// @Summary      GetGroupMembers
+// @Description  Return the users of a group and of its subgroups, in the order they were created. Direct tells the users added to the group itself; direct=true returns only those.
+// @Tags         Groups
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        id      path   string  true   "Group ID"
+// @Param        direct  query  bool    false  "Only the users added to the group itself"
+// @Param        limit   query  int     false  "Page size"
+// @Param        offset  query  int     false  "Page offset"
+// @Success      200  {object}  []entity.GroupMember
+// @Header       200  {int}     X-Total-Count  "Total number of users"
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /groups/{id}/members [get]
+func (a *GroupHandler) GetMembers(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	limit, offset, err := httputil.ParsePagination(c)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+	direct := false
+	if v, ok := c.GetQuery("direct"); ok {
+		if direct, err = strconv.ParseBool(v); err != nil {
+			httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	members, total, err := a.Usecase.GetMembers(ctx, id, direct, limit, offset)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	httputil.Respond(c, http.StatusOK, members)
+}
+
+// AddMembers godoc
+// @Summary      AddGroupMembers
+// @Description  Add users to a group. Users already in it are left as they are; when one of the users does not exist, no one is added.
+// @Tags         Groups
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Param        id            path  string             true  "Group ID"
+// @Param        GroupMembers  body  DTOs.GroupMembers  true  "Users to add"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      413  {object}  httputil.HTTPError
+// @Router /groups/{id}/members [post]
+func (a *GroupHandler) AddMembers(c *gin.Context) {
+	a.changeMembers(c, a.Usecase.AddMembers)
+}
+
+// RemoveMembers godoc
+// @Summary      RemoveGroupMembers
+// @Description  Remove users from a group, keeping the users. Fails with 404 when none of them was in the group.
+// @Tags         Groups
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Param        id            path  string             true  "Group ID"
+// @Param        GroupMembers  body  DTOs.GroupMembers  true  "Users to remove"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      413  {object}  httputil.HTTPError
+// @Router /groups/{id}/members [delete]
+func (a *GroupHandler) RemoveMembers(c *gin.Context) {
+	a.changeMembers(c, a.Usecase.RemoveMembers)
+}
+
+func (a *GroupHandler) changeMembers(c *gin.Context, change func(ctx context.Context, id uuid.UUID, userIDs []uuid.UUID) error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	var body DTOs.GroupMembers
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+	if a.MaxBatchItems > 0 && len(body.UserIDs) > a.MaxBatchItems {
+		httputil.NewError(c, http.StatusRequestEntityTooLarge, models.ErrBatchTooLarge)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err = change(ctx, id, body.UserIDs); err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AddMember godoc
+// @Summary      AddGroupMember
+// @Description  Add a user to a group. Adding a user already in it does nothing.
+// @Tags         Groups
+// @Param        id      path  string  true  "Group ID"
+// @Param        userID  path  string  true  "User ID"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /groups/{id}/members/{userID} [put]
+func (a *GroupHandler) AddMember(c *gin.Context) {
+	a.changeMember(c, a.Usecase.AddMembers)
+}
+
+// RemoveMember godoc
+// @Summary      RemoveGroupMember
+// @Description  Remove a user from a group, keeping the user
+// @Tags         Groups
+// @Param        id      path  string  true  "Group ID"
+// @Param        userID  path  string  true  "User ID"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /groups/{id}/members/{userID} [delete]
+func (a *GroupHandler) RemoveMember(c *gin.Context) {
+	a.changeMember(c, a.Usecase.RemoveMembers)
+}
+
+func (a *GroupHandler) changeMember(c *gin.Context, change func(ctx context.Context, id uuid.UUID, userIDs []uuid.UUID) error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err = change(ctx, id, []uuid.UUID{userID}); err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetUserGroups godoc
+// @Summary      GetUserGroups
+// @Description  Return the groups of a user, by name: those the user was added to, marked Direct, and the groups above them
+// @Tags         Groups
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        id  path  string  true  "User ID"
+// @Success      200  {object}  []entity.UserGroup
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /users/{id}/groups [get]
+func (a *GroupHandler) GetUserGroups(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.GetUserGroups(ctx, id)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+func getStatusCode(err error) int {
+	if err == models.ErrGroupCycle {
+		logrus.Error(err)
+		return http.StatusConflict
+	}
+	return httputil.StatusCode(err)
+}
@@ -0,0 +1,37 @@
+package group
+
+import (
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+)
+
+// Repository stores groups and their direct members. Requests scoped to an
+// organization with models.WithOrgID only reach its groups, and the groups
+// they create belong to it.
+type Repository interface {
+	GetAll(ctx context.Context, limit int, offset int) ([]*entity.Group, int, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Group, error)
+	Create(ctx context.Context, g *DTOs.Group) (*entity.Group, error)
+	Update(ctx context.Context, id uuid.UUID, g *DTOs.Group) (*entity.Group, error)
+	// Delete removes the group and its memberships. Its subgroups become top
+	// level groups.
+	Delete(ctx context.Context, id uuid.UUID) error
+	// LockHierarchy serializes changes to the parents of groups until the
+	// transaction of ctx ends.
+	LockHierarchy(ctx context.Context) error
+	// GetAncestorIDs returns the group and the groups above it, nearest first.
+	GetAncestorIDs(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+	// AddMembers adds the users that are not members of the group yet.
+	AddMembers(ctx context.Context, id uuid.UUID, userIDs []uuid.UUID) error
+	// RemoveMembers returns how many of the users were members of the group.
+	RemoveMembers(ctx context.Context, id uuid.UUID, userIDs []uuid.UUID) (int64, error)
+	// GetMembers returns a page of the users of the group that are not
+	// deleted, including those of its subgroups unless direct, and their
+	// total count.
+	GetMembers(ctx context.Context, id uuid.UUID, direct bool, limit int, offset int) ([]*entity.GroupMember, int, error)
+	// GetByUserID returns the groups the user was added to and the groups
+	// above them, by name.
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]*entity.UserGroup, error)
+}
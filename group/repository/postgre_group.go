@@ -0,0 +1,357 @@
+package repository
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/group"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type postgreGroupRepository struct {
+	Conn       *sql.DB
+	transactor dbutil.Transactor
+}
+
+// NewPostgreGroupRepository runs the queries of requests scoped to an
+// organization in transactions, like the user repository, so that row-level
+// security backs their tenant filters.
+func NewPostgreGroupRepository(Conn *sql.DB) group.Repository {
+	return &postgreGroupRepository{Conn, dbutil.NewTransactor(Conn)}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanGroup reads a row holding the group columns, then extra.
+func scanGroup(row rowScanner, extra ...interface{}) (*entity.Group, error) {
+	t := new(entity.Group)
+	dest := append([]interface{}{
+		&t.ID,
+		&t.OrgID,
+		&t.ParentID,
+		&t.Name,
+		&t.Description,
+		&t.Created,
+		&t.Updated,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// tenantCondition restricts groups to the organization the request acts in,
+// appending its argument to args. It is empty when the request is not scoped
+// to an organization.
+func tenantCondition(ctx context.Context, column string, args []interface{}) (string, []interface{}) {
+	orgID, ok := models.OrgIDFromContext(ctx)
+	if !ok {
+		return "", args
+	}
+	args = append(args, orgID.String())
+	return fmt.Sprintf("%s = $%d", column, len(args)), args
+}
+
+// andTenant is tenantCondition for queries that already have a where clause.
+func andTenant(ctx context.Context, column string, args []interface{}) (string, []interface{}) {
+	tenant, args := tenantCondition(ctx, column, args)
+	if tenant == "" {
+		return "", args
+	}
+	return " and " + tenant, args
+}
+
+// each runs a query and calls fn with every row it returns.
+func (m *postgreGroupRepository) each(ctx context.Context, fn func(row rowScanner) error, query string, args ...interface{}) error {
+	return dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, args...)
+		if err != nil {
+			logrus.Error(err)
+			return err
+		}
+
+		defer func() {
+			err := rows.Close()
+			if err != nil {
+				logrus.Error(err)
+			}
+		}()
+
+		for rows.Next() {
+			if err = fn(rows); err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
+
+// queryRow runs a query returning one row, ErrNotFound when it returns none.
+func (m *postgreGroupRepository) queryRow(ctx context.Context, dest []interface{}, query string, args ...interface{}) error {
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		return dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, args...).Scan(dest...)
+	})
+	if err == sql.ErrNoRows {
+		return models.ErrNotFound
+	}
+	return err
+}
+
+// queryGroup runs a query returning one group, ErrNotFound when it returns
+// none.
+func (m *postgreGroupRepository) queryGroup(ctx context.Context, query string, args ...interface{}) (*entity.Group, error) {
+	var res *entity.Group
+	err := m.each(ctx, func(row rowScanner) (err error) {
+		res, err = scanGroup(row)
+		return
+	}, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, models.ErrNotFound
+	}
+	return res, nil
+}
+
+// exec runs a statement and returns the number of rows it affected.
+func (m *postgreGroupRepository) exec(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	var ra int64
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		res, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		ra, err = res.RowsAffected()
+		return err
+	})
+	return ra, err
+}
+
+func (m *postgreGroupRepository) GetAll(ctx context.Context, limit int, offset int) ([]*entity.Group, int, error) {
+	where, args := tenantCondition(ctx, "org_id", nil)
+	if where != "" {
+		where = " where " + where
+	}
+
+	var total int
+	if err := m.queryRow(ctx, []interface{}{&total}, `select count(*) from groups`+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	query := `select id, org_id, parent_id, name, description, created, updated from groups` + where +
+		fmt.Sprintf(` order by name, id limit $%d offset $%d`, len(args)+1, len(args)+2)
+
+	result := make([]*entity.Group, 0)
+	err := m.each(ctx, func(row rowScanner) error {
+		t, err := scanGroup(row)
+		if err != nil {
+			return err
+		}
+		result = append(result, t)
+		return nil
+	}, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
+}
+
+func (m *postgreGroupRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Group, error) {
+	tenant, args := andTenant(ctx, "org_id", []interface{}{id.String()})
+	query := `select id, org_id, parent_id, name, description, created, updated from groups where id = $1` + tenant
+
+	return m.queryGroup(ctx, query, args...)
+}
+
+// Create puts the group in the organization the request acts in. Requests
+// scoped to no organization cannot create groups.
+func (m *postgreGroupRepository) Create(ctx context.Context, g *DTOs.Group) (*entity.Group, error) {
+	query := `INSERT INTO groups (id, org_id, parent_id, name, description, created, updated) VALUES ($1, $2, $3, $4, $5, $6, $6)
+						RETURNING id, org_id, parent_id, name, description, created, updated`
+
+	var orgID *string
+	if id, ok := models.OrgIDFromContext(ctx); ok {
+		if id == uuid.Nil {
+			return nil, models.ErrForbidden
+		}
+		s := id.String()
+		orgID = &s
+	}
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.queryGroup(ctx, query, id.String(), orgID, parentKey(g.ParentID), g.Name, g.Description, time.Now())
+}
+
+func (m *postgreGroupRepository) Update(ctx context.Context, id uuid.UUID, g *DTOs.Group) (*entity.Group, error) {
+	tenant, args := andTenant(ctx, "org_id", []interface{}{g.Name, g.Description, parentKey(g.ParentID), id.String()})
+	query := `UPDATE groups SET name=$1, description=$2, parent_id=$3, updated=now() WHERE id = $4` + tenant + `
+						RETURNING id, org_id, parent_id, name, description, created, updated`
+
+	return m.queryGroup(ctx, query, args...)
+}
+
+func (m *postgreGroupRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tenant, args := andTenant(ctx, "org_id", []interface{}{id.String()})
+
+	ra, err := m.exec(ctx, `DELETE FROM groups WHERE id = $1`+tenant, args...)
+	if err != nil {
+		return err
+	}
+	if ra == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+func (m *postgreGroupRepository) LockHierarchy(ctx context.Context) error {
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, `select pg_advisory_xact_lock(hashtext('groups'))`)
+	return err
+}
+
+func (m *postgreGroupRepository) GetAncestorIDs(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	query := `with recursive chain (id, parent_id, depth) as (
+							select id, parent_id, 0 from groups where id = $1
+							union all
+							select g.id, g.parent_id, c.depth + 1 from groups g join chain c on g.id = c.parent_id
+						)
+						select id from chain order by depth`
+
+	result := make([]uuid.UUID, 0)
+	err := m.each(ctx, func(row rowScanner) error {
+		var id uuid.UUID
+		if err := row.Scan(&id); err != nil {
+			return err
+		}
+		result = append(result, id)
+		return nil
+	}, query, id.String())
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (m *postgreGroupRepository) AddMembers(ctx context.Context, id uuid.UUID, userIDs []uuid.UUID) error {
+	query := `INSERT INTO group_members (group_id, user_id, created)
+						SELECT $1, unnest($2::uuid[]), now()
+						ON CONFLICT (group_id, user_id) DO NOTHING`
+
+	_, err := m.exec(ctx, query, id.String(), pq.Array(keys(userIDs)))
+	return err
+}
+
+func (m *postgreGroupRepository) RemoveMembers(ctx context.Context, id uuid.UUID, userIDs []uuid.UUID) (int64, error) {
+	query := `DELETE FROM group_members WHERE group_id = $1 AND user_id = ANY($2::uuid[])`
+
+	return m.exec(ctx, query, id.String(), pq.Array(keys(userIDs)))
+}
+
+// memberTree selects the members of group $1 and, unless $2, those of its
+// subgroups, the union stopping the recursion at any cycle. Users outside the
+// organization the request acts in are left out.
+func memberTree(ctx context.Context, args []interface{}) (string, []interface{}) {
+	tenant := ""
+	if orgID, ok := models.OrgIDFromContext(ctx); ok {
+		args = append(args, orgID.String())
+		tenant = fmt.Sprintf(" and u.id in (select user_id from org_memberships where org_id = $%d)", len(args))
+	}
+	return `with recursive tree (id) as (
+							select $1::uuid
+							union
+							select g.id from groups g join tree t on g.parent_id = t.id where not $2
+						)
+						select u.id, u.first_name, u.last_name, u.email, u.age, u.created, u.updated, u.deleted_at,
+							u.email_verified, u.status, bool_or(m.group_id = $1) as direct
+						from group_members m join tree t on t.id = m.group_id join users u on u.id = m.user_id
+						where u.deleted_at is null` + tenant + `
+						group by u.id`, args
+}
+
+func (m *postgreGroupRepository) GetMembers(ctx context.Context, id uuid.UUID, direct bool, limit int, offset int) ([]*entity.GroupMember, int, error) {
+	members, args := memberTree(ctx, []interface{}{id.String(), direct})
+
+	var total int
+	if err := m.queryRow(ctx, []interface{}{&total}, `select count(*) from (`+members+`) members`, args...); err != nil {
+		return nil, 0, err
+	}
+
+	query := members + fmt.Sprintf(` order by u.created, u.id limit $%d offset $%d`, len(args)+1, len(args)+2)
+
+	result := make([]*entity.GroupMember, 0)
+	err := m.each(ctx, func(row rowScanner) error {
+		t := new(entity.User)
+		member := &entity.GroupMember{User: t}
+		err := row.Scan(&t.ID, &t.Firstname, &t.Lastname, &t.Email, &t.Age, &t.Created, &t.Updated, &t.Deleted, &t.EmailVerified, &t.Status,
+			&member.Direct)
+		if err != nil {
+			return err
+		}
+		result = append(result, member)
+		return nil
+	}, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
+}
+
+func (m *postgreGroupRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*entity.UserGroup, error) {
+	where, args := tenantCondition(ctx, "g.org_id", []interface{}{userID.String()})
+	if where != "" {
+		where = " where " + where
+	}
+	query := `with recursive chain (id, direct) as (
+							select group_id, true from group_members where user_id = $1
+							union
+							select g.parent_id, false from groups g join chain c on g.id = c.id where g.parent_id is not null
+						)
+						select g.id, g.org_id, g.parent_id, g.name, g.description, g.created, g.updated, bool_or(c.direct)
+						from chain c join groups g on g.id = c.id` + where + `
+						group by g.id
+						order by g.name, g.id`
+
+	result := make([]*entity.UserGroup, 0)
+	err := m.each(ctx, func(row rowScanner) (err error) {
+		res := new(entity.UserGroup)
+		if res.Group, err = scanGroup(row, &res.Direct); err != nil {
+			return err
+		}
+		result = append(result, res)
+		return nil
+	}, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func parentKey(id *uuid.UUID) *string {
+	if id == nil {
+		return nil
+	}
+	s := id.String()
+	return &s
+}
+
+func keys(ids []uuid.UUID) []string {
+	res := make([]string, len(ids))
+	for i, id := range ids {
+		res[i] = id.String()
+	}
+	return res
+}
@@ -0,0 +1,31 @@
+package group
+
+import (
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+)
+
+type UseCase interface {
+	GetAll(ctx context.Context, limit int, offset int) ([]*entity.Group, int, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Group, error)
+	// Create and Update fail with ErrBadParamInput when the parent does not
+	// exist, and Update with ErrGroupCycle when the parent is the group or
+	// one of its subgroups.
+	Create(ctx context.Context, g *DTOs.Group) (*entity.Group, error)
+	Update(ctx context.Context, id uuid.UUID, g *DTOs.Group) (*entity.Group, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	// GetMembers lists the effective members of the group, those of its
+	// subgroups included, or only its direct members when direct.
+	GetMembers(ctx context.Context, id uuid.UUID, direct bool, limit int, offset int) ([]*entity.GroupMember, int, error)
+	// AddMembers fails with ErrNotFound, adding no one, when one of the users
+	// does not exist. Users already in the group are left as they are.
+	AddMembers(ctx context.Context, id uuid.UUID, userIDs []uuid.UUID) error
+	// RemoveMembers fails with ErrNotFound when none of the users was a direct
+	// member of the group.
+	RemoveMembers(ctx context.Context, id uuid.UUID, userIDs []uuid.UUID) error
+	// GetUserGroups lists the effective groups of the user, those above the
+	// groups they were added to included.
+	GetUserGroups(ctx context.Context, userID uuid.UUID) ([]*entity.UserGroup, error)
+}
@@ -0,0 +1,217 @@
+package usecase
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/group"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type groupUseCase struct {
+	groupRepository group.Repository
+	userRepository  user.Repository
+	transactor      dbutil.Transactor
+	contextTimeout  time.Duration
+}
+
+// NewGroupUseCase checks the users added to groups against u, which keeps
+// them to the organization the request acts in.
+func NewGroupUseCase(g group.Repository, u user.Repository, tx dbutil.Transactor, timeout time.Duration) group.UseCase {
+	return &groupUseCase{
+		groupRepository: g,
+		userRepository:  u,
+		transactor:      tx,
+		contextTimeout:  timeout,
+	}
+}
+
+func (a *groupUseCase) GetAll(c context.Context, limit int, offset int) ([]*entity.Group, int, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.groupRepository.GetAll(ctx, limit, offset)
+}
+
+func (a *groupUseCase) GetByID(c context.Context, id uuid.UUID) (*entity.Group, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.groupRepository.GetByID(ctx, id)
+}
+
+// checkParent fails with ErrBadParamInput when the parent is not a group the
+// request sees, and with ErrGroupCycle when id is the parent or one of the
+// groups above it. New groups, with no id yet, cannot be part of a cycle.
+func (a *groupUseCase) checkParent(ctx context.Context, id uuid.UUID, parentID *uuid.UUID) error {
+	if parentID == nil {
+		return nil
+	}
+	_, err := a.groupRepository.GetByID(ctx, *parentID)
+	if err == models.ErrNotFound {
+		return models.ErrBadParamInput
+	}
+	if err != nil || id == uuid.Nil {
+		return err
+	}
+
+	ancestors, err := a.groupRepository.GetAncestorIDs(ctx, *parentID)
+	if err != nil {
+		return err
+	}
+	for _, ancestor := range ancestors {
+		if ancestor == id {
+			return models.ErrGroupCycle
+		}
+	}
+	return nil
+}
+
+func (a *groupUseCase) Create(c context.Context, g *DTOs.Group) (*entity.Group, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	var res *entity.Group
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := a.checkParent(ctx, uuid.Nil, g.ParentID)
+		if err != nil {
+			return err
+		}
+		res, err = a.groupRepository.Create(ctx, g)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Update holds the hierarchy lock while it checks and moves the group, so that
+// two concurrent moves cannot close a cycle that neither of them sees.
+func (a *groupUseCase) Update(c context.Context, id uuid.UUID, g *DTOs.Group) (*entity.Group, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	var res *entity.Group
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := a.groupRepository.LockHierarchy(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err = a.groupRepository.GetByID(ctx, id); err != nil {
+			return err
+		}
+		if err = a.checkParent(ctx, id, g.ParentID); err != nil {
+			return err
+		}
+		res, err = a.groupRepository.Update(ctx, id, g)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a *groupUseCase) Delete(c context.Context, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.groupRepository.Delete(ctx, id)
+}
+
+func (a *groupUseCase) GetMembers(c context.Context, id uuid.UUID, direct bool, limit int, offset int) ([]*entity.GroupMember, int, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	var res []*entity.GroupMember
+	var total int
+	err := dbutil.InTenant(ctx, a.transactor, func(ctx context.Context) error {
+		_, err := a.groupRepository.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		res, total, err = a.groupRepository.GetMembers(ctx, id, direct, limit, offset)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+func (a *groupUseCase) AddMembers(c context.Context, id uuid.UUID, userIDs []uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	userIDs = unique(userIDs)
+	return a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		_, err := a.groupRepository.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		users, err := a.userRepository.GetByIDs(ctx, userIDs)
+		if err != nil {
+			return err
+		}
+		if len(users) != len(userIDs) {
+			return models.ErrNotFound
+		}
+		return a.groupRepository.AddMembers(ctx, id, userIDs)
+	})
+}
+
+func (a *groupUseCase) RemoveMembers(c context.Context, id uuid.UUID, userIDs []uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		_, err := a.groupRepository.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		removed, err := a.groupRepository.RemoveMembers(ctx, id, userIDs)
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			return models.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (a *groupUseCase) GetUserGroups(c context.Context, userID uuid.UUID) ([]*entity.UserGroup, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	var res []*entity.UserGroup
+	err := dbutil.InTenant(ctx, a.transactor, func(ctx context.Context) error {
+		_, err := a.userRepository.GetByID(ctx, userID, "ID")
+		if err != nil {
+			return err
+		}
+		res, err = a.groupRepository.GetByUserID(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func unique(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	res := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			res = append(res, id)
+		}
+	}
+	return res
+}
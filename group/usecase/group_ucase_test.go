@@ -0,0 +1,250 @@
+package usecase_test
+
+import (
+	"GoMastersTest/group"
+	"GoMastersTest/group/usecase"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fakeGroups keeps groups and their direct members in memory, walking the
+// hierarchy like the Postgres repository.
+type fakeGroups struct {
+	group.Repository
+	groups  map[uuid.UUID]*entity.Group
+	members map[uuid.UUID]map[uuid.UUID]bool
+}
+
+func newFakeGroups() *fakeGroups {
+	return &fakeGroups{groups: make(map[uuid.UUID]*entity.Group), members: make(map[uuid.UUID]map[uuid.UUID]bool)}
+}
+
+func (f *fakeGroups) GetByID(ctx context.Context, id uuid.UUID) (*entity.Group, error) {
+	g, ok := f.groups[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	return g, nil
+}
+
+func (f *fakeGroups) Create(ctx context.Context, g *DTOs.Group) (*entity.Group, error) {
+	res := &entity.Group{ID: uuid.New(), ParentID: g.ParentID, Name: g.Name, Description: g.Description, Created: time.Now()}
+	f.groups[res.ID] = res
+	f.members[res.ID] = make(map[uuid.UUID]bool)
+	return res, nil
+}
+
+func (f *fakeGroups) Update(ctx context.Context, id uuid.UUID, g *DTOs.Group) (*entity.Group, error) {
+	res, err := f.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	res.Name, res.Description, res.ParentID = g.Name, g.Description, g.ParentID
+	return res, nil
+}
+
+func (f *fakeGroups) LockHierarchy(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeGroups) GetAncestorIDs(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	res := make([]uuid.UUID, 0)
+	for g, ok := f.groups[id]; ok; g, ok = f.groups[*g.ParentID] {
+		res = append(res, g.ID)
+		if g.ParentID == nil {
+			break
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeGroups) AddMembers(ctx context.Context, id uuid.UUID, userIDs []uuid.UUID) error {
+	for _, userID := range userIDs {
+		f.members[id][userID] = true
+	}
+	return nil
+}
+
+func (f *fakeGroups) RemoveMembers(ctx context.Context, id uuid.UUID, userIDs []uuid.UUID) (int64, error) {
+	var removed int64
+	for _, userID := range userIDs {
+		if f.members[id][userID] {
+			delete(f.members[id], userID)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (f *fakeGroups) GetMembers(ctx context.Context, id uuid.UUID, direct bool, limit int, offset int) ([]*entity.GroupMember, int, error) {
+	found := make(map[uuid.UUID]bool)
+	for g := range f.groups {
+		ancestors, _ := f.GetAncestorIDs(ctx, g)
+		for i, ancestor := range ancestors {
+			if ancestor != id || (direct && i > 0) {
+				continue
+			}
+			for userID := range f.members[g] {
+				found[userID] = found[userID] || i == 0
+			}
+		}
+	}
+	res := make([]*entity.GroupMember, 0)
+	for userID, isDirect := range found {
+		res = append(res, &entity.GroupMember{User: &entity.User{ID: userID}, Direct: isDirect})
+	}
+	return res, len(res), nil
+}
+
+func (f *fakeGroups) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*entity.UserGroup, error) {
+	found := make(map[uuid.UUID]bool)
+	for g, members := range f.members {
+		if !members[userID] {
+			continue
+		}
+		ancestors, _ := f.GetAncestorIDs(ctx, g)
+		for i, ancestor := range ancestors {
+			found[ancestor] = found[ancestor] || i == 0
+		}
+	}
+	res := make([]*entity.UserGroup, 0)
+	for id, isDirect := range found {
+		res = append(res, &entity.UserGroup{Group: f.groups[id], Direct: isDirect})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Group.Name < res[j].Group.Name })
+	return res, nil
+}
+
+type fakeUsers struct {
+	user.Repository
+	users map[uuid.UUID]bool
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, id uuid.UUID, fields ...string) (*entity.User, error) {
+	if !f.users[id] {
+		return nil, models.ErrNotFound
+	}
+	return &entity.User{ID: id}, nil
+}
+
+func (f *fakeUsers) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*entity.User, error) {
+	res := make([]*entity.User, 0)
+	for _, id := range ids {
+		if f.users[id] {
+			res = append(res, &entity.User{ID: id})
+		}
+	}
+	return res, nil
+}
+
+type noTransactor struct{}
+
+func (noTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func newGroupUseCase(userIDs ...uuid.UUID) (group.UseCase, *fakeGroups) {
+	users := &fakeUsers{users: make(map[uuid.UUID]bool)}
+	for _, id := range userIDs {
+		users.users[id] = true
+	}
+	groups := newFakeGroups()
+	return usecase.NewGroupUseCase(groups, users, noTransactor{}, time.Second), groups
+}
+
+func memberIDs(members []*entity.GroupMember) map[uuid.UUID]bool {
+	res := make(map[uuid.UUID]bool)
+	for _, m := range members {
+		res[m.User.ID] = m.Direct
+	}
+	return res
+}
+
+func TestEffectiveMembership(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	uc, _ := newGroupUseCase(alice, bob)
+	ctx := context.Background()
+
+	staff, err := uc.Create(ctx, &DTOs.Group{Name: "Staff"})
+	require.NoError(t, err)
+	support, err := uc.Create(ctx, &DTOs.Group{Name: "Support", ParentID: &staff.ID})
+	require.NoError(t, err)
+
+	require.NoError(t, uc.AddMembers(ctx, staff.ID, []uuid.UUID{alice}))
+	require.NoError(t, uc.AddMembers(ctx, support.ID, []uuid.UUID{alice, bob, bob}))
+
+	members, total, err := uc.GetMembers(ctx, staff.ID, false, 20, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, map[uuid.UUID]bool{alice: true, bob: false}, memberIDs(members))
+
+	members, _, err = uc.GetMembers(ctx, staff.ID, true, 20, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[uuid.UUID]bool{alice: true}, memberIDs(members))
+
+	groups, err := uc.GetUserGroups(ctx, bob)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, staff.ID, groups[0].Group.ID)
+	assert.False(t, groups[0].Direct)
+	assert.True(t, groups[1].Direct)
+
+	_, err = uc.GetUserGroups(ctx, uuid.New())
+	assert.Equal(t, models.ErrNotFound, err)
+
+	require.NoError(t, uc.RemoveMembers(ctx, support.ID, []uuid.UUID{bob}))
+	assert.Equal(t, models.ErrNotFound, uc.RemoveMembers(ctx, support.ID, []uuid.UUID{bob}))
+	groups, err = uc.GetUserGroups(ctx, bob)
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+}
+
+func TestAddMembersChecksEveryUser(t *testing.T) {
+	alice := uuid.New()
+	uc, groups := newGroupUseCase(alice)
+	ctx := context.Background()
+
+	g, err := uc.Create(ctx, &DTOs.Group{Name: "Staff"})
+	require.NoError(t, err)
+
+	assert.Equal(t, models.ErrNotFound, uc.AddMembers(ctx, g.ID, []uuid.UUID{alice, uuid.New()}))
+	assert.Empty(t, groups.members[g.ID], "a missing user adds no one")
+	assert.Equal(t, models.ErrNotFound, uc.AddMembers(ctx, uuid.New(), []uuid.UUID{alice}))
+}
+
+func TestGroupCycles(t *testing.T) {
+	uc, _ := newGroupUseCase()
+	ctx := context.Background()
+
+	a, err := uc.Create(ctx, &DTOs.Group{Name: "A"})
+	require.NoError(t, err)
+	b, err := uc.Create(ctx, &DTOs.Group{Name: "B", ParentID: &a.ID})
+	require.NoError(t, err)
+	c, err := uc.Create(ctx, &DTOs.Group{Name: "C", ParentID: &b.ID})
+	require.NoError(t, err)
+
+	_, err = uc.Update(ctx, a.ID, &DTOs.Group{Name: "A", ParentID: &c.ID})
+	assert.Equal(t, models.ErrGroupCycle, err)
+	_, err = uc.Update(ctx, a.ID, &DTOs.Group{Name: "A", ParentID: &a.ID})
+	assert.Equal(t, models.ErrGroupCycle, err)
+
+	missing := uuid.New()
+	_, err = uc.Create(ctx, &DTOs.Group{Name: "D", ParentID: &missing})
+	assert.Equal(t, models.ErrBadParamInput, err)
+
+	// moving a group under a sibling branch is fine
+	res, err := uc.Update(ctx, c.ID, &DTOs.Group{Name: "C", ParentID: &a.ID})
+	require.NoError(t, err)
+	assert.Equal(t, a.ID, *res.ParentID)
+	_, err = uc.Update(ctx, b.ID, &DTOs.Group{Name: "B", ParentID: &c.ID})
+	assert.NoError(t, err)
+}
@@ -8,11 +8,12 @@ func NewError(ctx *gin.Context, status int, err error) {
 		Code:    status,
 		Message: err.Error(),
 	}
-	ctx.JSON(status, er)
+	// Errors are always sent, falling back to JSON when nothing is acceptable.
+	ctx.Render(status, renderer(ctx.NegotiateFormat(Offered...), er))
 }
 
 // HTTPError example
 type HTTPError struct {
-	Code    int    `json:"code" example:"400"`
-	Message string `json:"message" example:"status bad request"`
+	Code    int    `json:"code" xml:"code" yaml:"code" example:"400"`
+	Message string `json:"message" xml:"message" yaml:"message" example:"status bad request"`
 }
@@ -0,0 +1,89 @@
+package httputil
+
+import (
+	"GoMastersTest/models"
+	"encoding/xml"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+)
+
+// MIMEYAML2 is the registered YAML media type, accepted next to gin's
+// application/x-yaml.
+const MIMEYAML2 = "application/yaml"
+
+// Offered lists the media types Respond can produce, JSON first so that a
+// missing or wildcard Accept header keeps the historical behaviour.
+var Offered = []string{
+	binding.MIMEJSON,
+	binding.MIMEXML,
+	binding.MIMEXML2,
+	binding.MIMEYAML,
+	MIMEYAML2,
+	binding.MIMEMSGPACK,
+	binding.MIMEMSGPACK2,
+}
+
+// xmlList wraps top level slices, which have no root element of their own.
+type xmlList struct {
+	XMLName xml.Name    `xml:"Items"`
+	Items   interface{} `xml:"Item"`
+}
+
+// Respond renders obj in the format preferred by the Accept header and
+// answers 406 when none of the offered formats is acceptable.
+func Respond(c *gin.Context, status int, obj interface{}) {
+	format := c.NegotiateFormat(Offered...)
+	if format == "" {
+		NewError(c, http.StatusNotAcceptable, models.ErrUnsupportedFormat)
+		return
+	}
+	c.Render(status, renderer(format, obj))
+}
+
+// Bind decodes the request body according to its Content-Type, treating a
+// missing one as JSON. It returns models.ErrUnsupportedFormat for media types
+// it cannot decode.
+func Bind(c *gin.Context, obj interface{}) error {
+	var b binding.BindingBody
+	switch c.ContentType() {
+	case "", binding.MIMEJSON:
+		b = binding.JSON
+	case binding.MIMEXML, binding.MIMEXML2:
+		b = binding.XML
+	case binding.MIMEYAML, MIMEYAML2:
+		b = binding.YAML
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		b = binding.MsgPack
+	default:
+		return models.ErrUnsupportedFormat
+	}
+	return c.ShouldBindWith(obj, b)
+}
+
+// BindStatus is the status to answer when Bind fails.
+func BindStatus(err error) int {
+	if err == models.ErrUnsupportedFormat {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusUnprocessableEntity
+}
+
+func renderer(format string, obj interface{}) render.Render {
+	switch format {
+	case binding.MIMEXML, binding.MIMEXML2:
+		if v := reflect.ValueOf(obj); v.Kind() == reflect.Slice {
+			obj = xmlList{Items: obj}
+		}
+		return render.XML{Data: obj}
+	case binding.MIMEYAML, MIMEYAML2:
+		return render.YAML{Data: obj}
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		return render.MsgPack{Data: obj}
+	default:
+		return render.JSON{Data: obj}
+	}
+}
@@ -0,0 +1,108 @@
+package httputil_test
+
+import (
+	"GoMastersTest/httputil"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deleted := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	user := entity.User{
+		User:    DTOs.User{Firstname: "Igor", Lastname: "Kormich", Email: "igor@example.com", Age: 30},
+		Created: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		Updated: time.Date(2022, 2, 3, 4, 5, 6, 0, time.UTC),
+		Deleted: &deleted,
+		ID:      uuid.New(),
+	}
+
+	for _, mime := range httputil.Offered {
+		t.Run(mime, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.Header.Set("Accept", mime)
+			httputil.Respond(c, http.StatusOK, user)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			c, _ = gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rec.Body.Bytes()))
+			c.Request.Header.Set("Content-Type", mime)
+			var got entity.User
+			require.NoError(t, httputil.Bind(c, &got))
+
+			assert.Equal(t, user.User, got.User)
+			assert.Equal(t, user.ID, got.ID)
+			assert.True(t, user.Created.Equal(got.Created))
+			assert.True(t, user.Updated.Equal(got.Updated))
+			require.NotNil(t, got.Deleted)
+			assert.True(t, user.Deleted.Equal(*got.Deleted))
+		})
+	}
+}
+
+func TestRespondNotAcceptable(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.Header.Set("Accept", "text/csv")
+	httputil.Respond(c, http.StatusOK, entity.User{})
+
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+}
+
+func TestBindUnsupportedMediaType(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("a,b")))
+	c.Request.Header.Set("Content-Type", "text/csv")
+
+	err := httputil.Bind(c, &DTOs.User{})
+	assert.Equal(t, http.StatusUnsupportedMediaType, httputil.BindStatus(err))
+}
+
+func TestRespondPartialUsers(t *testing.T) {
+	user := &entity.User{User: DTOs.User{Firstname: "Igor", Email: "igor@example.com"}, ID: uuid.New()}
+	partial := []entity.PartialUser{user.Project([]string{"ID", "Firstname"})}
+
+	for _, mime := range httputil.Offered {
+		t.Run(mime, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.Header.Set("Accept", mime)
+			httputil.Respond(c, http.StatusOK, partial)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			c, _ = gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rec.Body.Bytes()))
+			c.Request.Header.Set("Content-Type", mime)
+			var got struct {
+				Items []entity.User `xml:"Item"`
+			}
+			if mime == binding.MIMEXML || mime == binding.MIMEXML2 {
+				require.NoError(t, httputil.Bind(c, &got))
+			} else {
+				require.NoError(t, httputil.Bind(c, &got.Items))
+			}
+
+			require.Len(t, got.Items, 1)
+			assert.Equal(t, user.ID, got.Items[0].ID)
+			assert.Equal(t, "Igor", got.Items[0].Firstname)
+			assert.Empty(t, got.Items[0].Email)
+			assert.NotContains(t, rec.Body.String(), "igor@example.com")
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package httputil
+
+import (
+	"GoMastersTest/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// ParsePagination reads the limit and offset query parameters, defaulting
+// limit to DefaultPageLimit, and rejects values outside 1..MaxPageLimit or
+// negative offsets with models.ErrBadParamInput.
+func ParsePagination(c *gin.Context) (limit int, offset int, err error) {
+	limit = DefaultPageLimit
+	if v, ok := c.GetQuery("limit"); ok {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > MaxPageLimit {
+			return 0, 0, models.ErrBadParamInput
+		}
+	}
+	if v, ok := c.GetQuery("offset"); ok {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, models.ErrBadParamInput
+		}
+	}
+	return limit, offset, nil
+}
@@ -0,0 +1,39 @@
+package httputil_test
+
+import (
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query  string
+		limit  int
+		offset int
+		err    error
+	}{
+		{"", httputil.DefaultPageLimit, 0, nil},
+		{"limit=5&offset=10", 5, 10, nil},
+		{"limit=100", httputil.MaxPageLimit, 0, nil},
+		{"limit=0", 0, 0, models.ErrBadParamInput},
+		{"limit=101", 0, 0, models.ErrBadParamInput},
+		{"limit=x", 0, 0, models.ErrBadParamInput},
+		{"offset=-1", 0, 0, models.ErrBadParamInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/users?"+tt.query, nil)
+
+			limit, offset, err := httputil.ParsePagination(c)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.limit, limit)
+			assert.Equal(t, tt.offset, offset)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package httputil
+
+import (
+	"GoMastersTest/models"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StatusCode maps the errors shared by every handler to an HTTP status and
+// logs err. Handlers map their own errors first and defer to it for the rest.
+func StatusCode(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	logrus.Error(err)
+	switch err {
+	case models.ErrNotFound:
+		return http.StatusNotFound
+	case models.ErrConflict:
+		return http.StatusConflict
+	case models.ErrBadParamInput, models.ErrPasswordTooShort, models.ErrPasswordTooLong, models.ErrPasswordBreached,
+		models.ErrPasswordOnUpdate:
+		return http.StatusBadRequest
+	case models.ErrUnauthorized:
+		return http.StatusUnauthorized
+	case models.ErrForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,19 @@
+package mail
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type logMailer struct{}
+
+// NewLogMailer logs messages instead of sending them, for development.
+func NewLogMailer() Mailer {
+	return logMailer{}
+}
+
+func (logMailer) Send(_ context.Context, m *Message) error {
+	logrus.WithFields(logrus.Fields{"to": m.To, "subject": m.Subject}).Info(m.Body)
+	return nil
+}
@@ -0,0 +1,110 @@
+package mail
+
+import (
+	"GoMastersTest/models/entity"
+	"bytes"
+	"embed"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Names of the templates of the emails sent by the service.
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+	TemplateInvitation    = "invitation"
+)
+
+// LinkData is what the templates of emails carrying a link to follow before
+// ExpiresAt are rendered with.
+type LinkData struct {
+	User      *entity.User
+	Link      string
+	ExpiresAt time.Time
+}
+
+// InvitationData is what the invitation template is rendered with: there is
+// no user yet, only the organization and the role they are invited to.
+type InvitationData struct {
+	Organization *entity.Organization
+	Role         string
+	Link         string
+	ExpiresAt    time.Time
+}
+
+// LinkWithToken returns base with token in its token query parameter.
+func LinkWithToken(base string, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// Templates render emails. Each template is a text/template producing a
+// "Subject: ..." line, a blank line and the body.
+type Templates struct {
+	templates map[string]*template.Template
+}
+
+// LoadTemplates parses the built-in templates, replacing those that have a
+// file of the same name in dir, such as verify_email.tmpl. An empty dir keeps
+// every built-in template.
+func LoadTemplates(dir string) (*Templates, error) {
+	entries, err := builtinTemplates.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{templates: make(map[string]*template.Template, len(entries))}
+	for _, e := range entries {
+		text, err := builtinTemplates.ReadFile("templates/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if dir != "" {
+			custom, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err == nil {
+				text = custom
+			} else if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+
+		name := strings.TrimSuffix(e.Name(), ".tmpl")
+		if t.templates[name], err = template.New(name).Option("missingkey=error").Parse(string(text)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Render returns the email name addressed to to, rendered with data.
+func (t *Templates) Render(name string, to string, data interface{}) (*Message, error) {
+	tpl, ok := t.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	header, body, ok := strings.Cut(buf.String(), "\n\n")
+	subject := strings.TrimPrefix(header, "Subject:")
+	if !ok || subject == header || strings.Contains(header, "\n") {
+		return nil, fmt.Errorf("mail template %q must start with a Subject line and a blank line", name)
+	}
+	return &Message{To: to, Subject: strings.TrimSpace(subject), Body: body}, nil
+}
@@ -0,0 +1,32 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+type writerMailer struct {
+	mu   sync.Mutex
+	from string
+	w    io.Writer
+}
+
+// NewWriterMailer writes each message, as it would be sent, to w, such as
+// os.Stdout or an append-only file. Messages are separated by a blank line.
+func NewWriterMailer(from string, w io.Writer) Mailer {
+	return &writerMailer{from: from, w: w}
+}
+
+func (s *writerMailer) Send(_ context.Context, m *Message) error {
+	msg, err := format(s.from, m, time.Now())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(msg, '\r', '\n'))
+	return err
+}
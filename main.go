@@ -1,11 +1,40 @@
 package main
 
 import (
+	"GoMastersTest/auth"
+	authHttp "GoMastersTest/auth/delivery/http"
+	authRepository "GoMastersTest/auth/repository"
+	authUsecase "GoMastersTest/auth/usecase"
+	"GoMastersTest/dbutil"
 	_ "GoMastersTest/docs"
+	"GoMastersTest/event"
+	eventHttp "GoMastersTest/event/delivery/http"
+	eventWorker "GoMastersTest/event/delivery/worker"
+	eventRepository "GoMastersTest/event/repository"
+	"GoMastersTest/event/sink"
+	eventUsecase "GoMastersTest/event/usecase"
+	groupHttp "GoMastersTest/group/delivery/http"
+	groupRepository "GoMastersTest/group/repository"
+	groupUsecase "GoMastersTest/group/usecase"
+	"GoMastersTest/mail"
 	"GoMastersTest/middleware"
+	"GoMastersTest/models"
+	orgHttp "GoMastersTest/org/delivery/http"
+	orgRepository "GoMastersTest/org/repository"
+	orgUsecase "GoMastersTest/org/usecase"
+	"GoMastersTest/user"
+	userGraphql "GoMastersTest/user/delivery/graphql"
+	userGrpc "GoMastersTest/user/delivery/grpc"
 	"GoMastersTest/user/delivery/http"
+	"GoMastersTest/user/delivery/worker"
 	"GoMastersTest/user/repository"
 	"GoMastersTest/user/usecase"
+	"GoMastersTest/webhook"
+	webhookHttp "GoMastersTest/webhook/delivery/http"
+	webhookWorker "GoMastersTest/webhook/delivery/worker"
+	webhookRepository "GoMastersTest/webhook/repository"
+	webhookUsecase "GoMastersTest/webhook/usecase"
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -13,7 +42,10 @@ import (
 	"github.com/spf13/viper"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"google.golang.org/grpc"
 	"log"
+	"net"
+	nethttp "net/http"
 	"os"
 	"time"
 )
@@ -63,14 +95,251 @@ func main() {
 	}()
 
 	r := gin.Default()
+	err = r.SetTrustedProxies(viper.GetStringSlice("server.trusted_proxies"))
+	if err != nil {
+		log.Fatal(err)
+	}
 	middL := middleware.InitMiddleware()
 	r.Use(middL.Logger())
+	r.Use(middL.RequestID())
 	userRepo := repository.NewPostgreUserRepository(dbConn)
+	historyRepo := repository.NewPostgreUserHistoryRepository(dbConn)
+	outboxRepo := eventRepository.NewPostgreOutboxRepository(dbConn)
+	transactor := dbutil.NewTransactor(dbConn)
 
 	timeoutContext := time.Duration(viper.GetInt("context.timeout")) * time.Second
-	uc := usecase.NewUserUseCase(userRepo, timeoutContext)
-	http.NewUserHandler(r, uc)
+	tokens, err := auth.NewTokenIssuer(viper.GetString("auth.signing_key"), viper.GetString("auth.issuer"),
+		viper.GetDuration("auth.access_ttl"), viper.GetDuration("auth.refresh_ttl"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	attempts, err := newLoginAttemptRepository(dbConn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	box, err := auth.NewSecretBox(viper.GetString("auth.totp.encryption_key"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	twoFactorUc := authUsecase.NewTwoFactorUseCase(userRepo, authRepository.NewPostgreTOTPRepository(dbConn),
+		authRepository.NewPostgreRecoveryCodeRepository(dbConn), authRepository.NewPostgreRefreshTokenRepository(dbConn), box,
+		viper.GetString("auth.totp.issuer"), transactor, timeoutContext)
+	authUc, err := newAuthUseCase(dbConn, userRepo, historyRepo, attempts, tokens, twoFactorUc, transactor, timeoutContext)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r.Use(middL.Authenticate(authUc))
+	orgUc := orgUsecase.NewOrgUseCase(orgRepository.NewPostgreOrganizationRepository(dbConn),
+		orgRepository.NewPostgreMembershipRepository(dbConn), transactor, timeoutContext)
+	r.Use(middL.Tenant(orgUc))
+	orgHttp.NewOrgHandler(r, orgUc, middL.RequireUser())
+	authHttp.NewAuthHandler(r, authUc, middL.RequireUser(), middL.RequireRole(auth.RoleAdmin))
+	authHttp.NewTwoFactorHandler(r, twoFactorUc, middL.RequireUser())
+	mailer, err := newMailer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	templates, err := mail.LoadTemplates(viper.GetString("mail.templates"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	resetPolicy := &auth.PasswordResetPolicy{
+		TTL:          viper.GetDuration("auth.password_reset.ttl"),
+		Window:       viper.GetDuration("auth.password_reset.window"),
+		AccountLimit: viper.GetInt("auth.password_reset.account_limit"),
+		IPLimit:      viper.GetInt("auth.password_reset.ip_limit"),
+	}
+	authHttp.NewPasswordResetHandler(r, authUsecase.NewPasswordResetUseCase(userRepo,
+		authRepository.NewPostgrePasswordResetRepository(dbConn), attempts, authUc, mailer, templates, resetPolicy,
+		viper.GetString("auth.password_reset.link"), transactor, timeoutContext))
+	statusUc := usecase.NewStatusUseCase(userRepo, historyRepo, outboxRepo, repository.NewPostgreStatusHistoryRepository(dbConn),
+		authUc, transactor, timeoutContext)
+	http.NewStatusHandler(r, statusUc, middL.RequireTenant(), middL.RequireRole(auth.RoleAdmin))
+	verificationUc := usecase.NewVerificationUseCase(userRepo, historyRepo, outboxRepo,
+		repository.NewPostgreVerificationRepository(dbConn), statusUc, mailer, templates, tokens,
+		viper.GetString("email_verification.link"), viper.GetDuration("email_verification.ttl"), transactor, timeoutContext)
+	http.NewVerificationHandler(r, verificationUc, middL.RequireTenant())
+	uc := usecase.NewUserUseCase(userRepo, historyRepo, outboxRepo, authUc, verificationUc, transactor, timeoutContext)
+	http.NewUserHandler(r, uc, viper.GetInt("batch.max_items"), middL.RequireTenant(), middL.RequireRole(auth.RoleAdmin))
+	orgHttp.NewInvitationHandler(r, orgUsecase.NewInvitationUseCase(orgRepository.NewPostgreOrganizationRepository(dbConn),
+		orgRepository.NewPostgreMembershipRepository(dbConn), orgRepository.NewPostgreInvitationRepository(dbConn), uc, authUc, mailer,
+		templates, tokens, viper.GetString("invitation.link"), viper.GetDuration("invitation.ttl"), transactor, timeoutContext),
+		middL.RequireUser())
+	groupHttp.NewGroupHandler(r, groupUsecase.NewGroupUseCase(groupRepository.NewPostgreGroupRepository(dbConn), userRepo,
+		transactor, timeoutContext), viper.GetInt("batch.max_items"), middL.RequireUser())
+	importUc := usecase.NewImportUseCase(uc, viper.GetInt("import.chunk_size"), viper.GetInt("import.max_errors"), viper.GetDuration("import.job_ttl"))
+	http.NewImportHandler(r, importUc, viper.GetInt64("import.async_threshold"), viper.GetInt64("import.max_bytes"), middL.RequireTenant())
+	searchRepo, err := newSearchRepository(dbConn, userRepo)
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.NewSearchHandler(r, usecase.NewSearchUseCase(searchRepo, transactor, timeoutContext), middL.RequireTenant())
+	err = userGraphql.NewGraphQLHandler(r, uc, viper.GetInt("graphql.max_depth"), viper.GetInt("graphql.max_complexity"), viper.GetBool("debug"),
+		middL.RequireTenant())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the workers act for the service itself, across every organization
+	ctx, cancel := context.WithCancel(models.AsSystem(context.Background()))
+	defer cancel()
+	purgeWorker := worker.NewPurgeWorker(uc, viper.GetDuration("purge.retention"), viper.GetDuration("purge.interval"))
+	go purgeWorker.Run(ctx)
+
+	subscriptionRepo := webhookRepository.NewPostgreSubscriptionRepository(dbConn)
+	deliveryRepo := webhookRepository.NewPostgreDeliveryRepository(dbConn)
+	webhookHttp.NewWebhookHandler(r, webhookUsecase.NewWebhookUseCase(subscriptionRepo, deliveryRepo, timeoutContext),
+		middL.RequireRole(auth.RoleAdmin))
+	dispatcher := webhookUsecase.NewDispatchUseCase(subscriptionRepo, deliveryRepo,
+		webhook.NewClient(viper.GetDuration("webhooks.timeout")), viper.GetInt("webhooks.batch"),
+		viper.GetInt("webhooks.max_attempts"), viper.GetDuration("webhooks.min_backoff"), viper.GetDuration("webhooks.max_backoff"),
+		viper.GetDuration("webhooks.lease"), transactor)
+	go webhookWorker.NewDispatchWorker(dispatcher, viper.GetDuration("webhooks.interval")).Run(ctx)
+
+	sinks, err := newEventSinks()
+	if err != nil {
+		log.Fatal(err)
+	}
+	stream := eventUsecase.NewStreamUseCase(viper.GetInt("stream.buffer"), viper.GetInt("stream.client_buffer"))
+	eventHttp.NewEventHandler(r, stream, viper.GetDuration("stream.heartbeat"), middL.RequireTenant())
+	sinks = append(sinks, dispatcher, stream)
+	relay := eventUsecase.NewRelayUseCase(outboxRepo, sinks, viper.GetInt("outbox.batch"),
+		viper.GetDuration("outbox.min_backoff"), viper.GetDuration("outbox.max_backoff"), viper.GetDuration("outbox.lease"), transactor)
+	go eventWorker.NewRelayWorker(relay, viper.GetDuration("outbox.interval")).Run(ctx)
+
+	lis, err := net.Listen("tcp", viper.GetString("grpc.address"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(middL.UnaryContext(), middL.UnaryAuth(authUc, orgUc)),
+		grpc.StreamInterceptor(middL.StreamAuth(authUc, orgUc)))
+	userGrpc.NewUserServer(grpcServer, uc, stream)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatal(err)
+		}
+	}()
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	r.Run(viper.GetString("server.address"))
 }
+
+// newAuthUseCase builds the login use case from the auth section of the
+// config: the password hasher ("bcrypt" or "argon2id"), the password policy
+// and the lockout policy. The roles in auth.totp.required_roles are only
+// granted to users with two-factor authentication.
+func newAuthUseCase(dbConn *sql.DB, users user.Repository, history user.HistoryRepository, attempts auth.LoginAttemptRepository,
+	tokens *auth.TokenIssuer, sf auth.SecondFactor, tx dbutil.Transactor, timeout time.Duration) (auth.UseCase, error) {
+	var hasher auth.Hasher
+	var err error
+	switch name := viper.GetString("auth.hasher"); name {
+	case auth.HasherBcrypt:
+		hasher, err = auth.NewBcryptHasher(viper.GetInt("auth.bcrypt.cost"))
+	case auth.HasherArgon2id:
+		hasher, err = auth.NewArgon2idHasher(auth.Argon2idParams{
+			Memory:      viper.GetUint32("auth.argon2id.memory"),
+			Iterations:  viper.GetUint32("auth.argon2id.iterations"),
+			Parallelism: uint8(viper.GetUint("auth.argon2id.parallelism")),
+		})
+	default:
+		err = fmt.Errorf("unknown password hasher %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	policy := auth.NewPasswordPolicy(viper.GetInt("auth.password.min_length"), viper.GetInt("auth.password.max_length"))
+	if path := viper.GetString("auth.password.breach_list"); path != "" {
+		if err := policy.LoadBreachList(path); err != nil {
+			return nil, err
+		}
+	}
+
+	lockout := &auth.LockoutPolicy{
+		AccountThreshold: viper.GetInt("auth.lockout.account_threshold"),
+		IPThreshold:      viper.GetInt("auth.lockout.ip_threshold"),
+		Window:           viper.GetDuration("auth.lockout.window"),
+		Duration:         viper.GetDuration("auth.lockout.duration"),
+		BaseDelay:        viper.GetDuration("auth.lockout.base_delay"),
+		MaxDelay:         viper.GetDuration("auth.lockout.max_delay"),
+	}
+	return authUsecase.NewAuthUseCase(users, history, authRepository.NewPostgreCredentialRepository(dbConn),
+		authRepository.NewPostgreRefreshTokenRepository(dbConn), authRepository.NewPostgreRoleRepository(dbConn),
+		attempts, hasher, policy, lockout, tokens, sf, viper.GetStringSlice("auth.totp.required_roles"), tx, timeout), nil
+}
+
+// newLoginAttemptRepository builds the store of failed logins and password
+// reset requests named by auth.lockout.store, "postgres" or "memory".
+func newLoginAttemptRepository(dbConn *sql.DB) (auth.LoginAttemptRepository, error) {
+	switch store := viper.GetString("auth.lockout.store"); store {
+	case "", "postgres":
+		return authRepository.NewPostgreLoginAttemptRepository(dbConn), nil
+	case "memory":
+		return authRepository.NewMemoryLoginAttemptRepository(), nil
+	default:
+		return nil, fmt.Errorf("unknown lockout store %q", store)
+	}
+}
+
+// newMailer builds the mailer named by mail.transport: "smtp" sends through
+// mail.smtp, "file" appends to the mail.file path, or "stdout", and "log" logs
+// each message.
+func newMailer() (mail.Mailer, error) {
+	from := viper.GetString("mail.from")
+	switch transport := viper.GetString("mail.transport"); transport {
+	case "smtp":
+		return mail.NewSMTPMailer(viper.GetString("mail.smtp.address"), from,
+			viper.GetString("mail.smtp.username"), viper.GetString("mail.smtp.password"))
+	case "file":
+		file := viper.GetString("mail.file")
+		if file == "stdout" {
+			return mail.NewWriterMailer(from, os.Stdout), nil
+		}
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return mail.NewWriterMailer(from, f), nil
+	case "", "log":
+		return mail.NewLogMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail transport %q", transport)
+	}
+}
+
+// newSearchRepository builds the user search backend named by search.backend:
+// "postgres" searches the database indexes, "memory" scans every user.
+func newSearchRepository(dbConn *sql.DB, users user.Repository) (user.SearchRepository, error) {
+	switch backend := viper.GetString("search.backend"); backend {
+	case "", "postgres":
+		return repository.NewPostgreSearchRepository(dbConn), nil
+	case "memory":
+		return repository.NewMemorySearchRepository(users), nil
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", backend)
+	}
+}
+
+// newEventSinks builds the outbox sinks enabled in the outbox section of the
+// config. "file" is a path, or "stdout"; "webhook" is a URL.
+func newEventSinks() ([]event.Sink, error) {
+	sinks := make([]event.Sink, 0)
+
+	switch file := viper.GetString("outbox.file"); file {
+	case "":
+	case "stdout":
+		sinks = append(sinks, sink.NewWriterSink(os.Stdout))
+	default:
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink.NewWriterSink(f))
+	}
+
+	if url := viper.GetString("outbox.webhook"); url != "" {
+		client := &nethttp.Client{Timeout: viper.GetDuration("outbox.webhook_timeout")}
+		sinks = append(sinks, sink.NewWebhookSink(url, client))
+	}
+	return sinks, nil
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/models"
+	"GoMastersTest/org"
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthorizationMetadata carries the bearer access token of gRPC calls.
+const AuthorizationMetadata = "authorization"
+
+// UnaryContext is the gRPC counterpart of RequestID: it reads the
+// x-request-id metadata into the request context and echoes the request ID
+// back in the response header.
+func (m *GoMiddleware) UnaryContext() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		requestID := first(md, RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID))
+		ctx = models.WithRequestID(ctx, requestID)
+		return handler(ctx, req)
+	}
+}
+
+// UnaryAuth is the gRPC counterpart of Authenticate, Tenant and
+// RequireTenant: calls need a bearer access token in the authorization
+// metadata, and act in the organization of the x-org-id metadata or the one o
+// picks, or across all of them for operators asking for none. Health checks
+// need no token.
+func (m *GoMiddleware) UnaryAuth(a auth.Authenticator, o org.UseCase) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if public(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, err := authorize(ctx, a, o)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuth is UnaryAuth for streaming calls.
+func (m *GoMiddleware) StreamAuth(a auth.Authenticator, o org.UseCase) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if public(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, err := authorize(ss.Context(), a, o)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream replaces the context of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// public reports whether method may be called without an access token.
+func public(method string) bool {
+	return strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
+
+// authorize authenticates the call of ctx and scopes it like Tenant does,
+// failing with Unauthenticated without a valid token and PermissionDenied
+// when the caller may not act in the organization, or in none.
+func authorize(ctx context.Context, a auth.Authenticator, o org.UseCase) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	header := first(md, AuthorizationMetadata)
+	if header == "" {
+		return nil, status.Error(codes.Unauthenticated, models.ErrUnauthorized.Error())
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	claims, err := a.Authenticate(ctx, token)
+	if token == header || err != nil {
+		return nil, status.Error(codes.Unauthenticated, models.ErrInvalidToken.Error())
+	}
+	ctx = withClaims(ctx, claims)
+
+	requested := uuid.Nil
+	if v := first(md, OrgHeader); v != "" {
+		if requested, err = uuid.Parse(v); err != nil {
+			return nil, status.Error(codes.InvalidArgument, models.ErrBadParamInput.Error())
+		}
+	}
+	ctx, err = scope(ctx, o, requested)
+	if err == models.ErrForbidden {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, models.ErrInternalServerError.Error())
+	}
+	if !scoped(ctx) {
+		return nil, status.Error(codes.PermissionDenied, models.ErrForbidden.Error())
+	}
+	return ctx, nil
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
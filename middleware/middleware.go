@@ -1,11 +1,25 @@
 package middleware
 
 import (
+	"GoMastersTest/auth"
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/org"
+	"context"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"log"
+	"net/http"
+	"strings"
 	"time"
 )
 
+const (
+	RequestIDHeader = "X-Request-ID"
+	OrgHeader       = "X-Org-ID"
+)
+
 type GoMiddleware struct {
 }
 
@@ -24,6 +38,165 @@ func (m *GoMiddleware) Logger() gin.HandlerFunc {
 		log.Println(status)
 	}
 }
+
+// RequestID propagates the caller's X-Request-ID, or generates one, and
+// stores it in the request context.
+func (m *GoMiddleware) RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(models.WithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+// Authenticate verifies the bearer access token of requests that send one
+// and records its user, also as the actor, along with the roles of the token.
+// Requests with an invalid token are rejected; requests without one pass
+// through unchanged.
+func (m *GoMiddleware) Authenticate(a auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		token := strings.TrimPrefix(header, "Bearer ")
+		claims, err := a.Authenticate(c.Request.Context(), token)
+		if token == header || err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			httputil.NewError(c, http.StatusUnauthorized, models.ErrInvalidToken)
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(withClaims(c.Request.Context(), claims))
+		c.Next()
+	}
+}
+
+// RequireRole rejects requests whose access token does not grant role, with
+// 401 when there is no token at all. It must run after Authenticate.
+func (m *GoMiddleware) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Header("WWW-Authenticate", "Bearer")
+			httputil.NewError(c, http.StatusUnauthorized, models.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+		if !models.HasRole(c.Request.Context(), role) {
+			httputil.NewError(c, http.StatusForbidden, models.ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUser rejects requests without an access token with 401. It must run
+// after Authenticate.
+func (m *GoMiddleware) RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := models.UserIDFromContext(c.Request.Context()); !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			httputil.NewError(c, http.StatusUnauthorized, models.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Tenant scopes the requests of authenticated users to the organization sent
+// in X-Org-ID, or the one o picks for them, so that they only reach its users.
+// Operators asking for no organization act across all of them. Anonymous
+// requests pass through unscoped, and RequireTenant keeps them away from the
+// users. It must run after Authenticate.
+func (m *GoMiddleware) Tenant(o org.UseCase) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requested := uuid.Nil
+		if header := c.GetHeader(OrgHeader); header != "" {
+			id, err := uuid.Parse(header)
+			if err != nil {
+				httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+				c.Abort()
+				return
+			}
+			requested = id
+		}
+
+		ctx, err := scope(c.Request.Context(), o, requested)
+		if err == models.ErrForbidden {
+			httputil.NewError(c, http.StatusForbidden, err)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			httputil.NewError(c, http.StatusInternalServerError, models.ErrInternalServerError)
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// RequireTenant rejects requests without an access token with 401, and with
+// 403 those acting in no organization, which only operators may. It must run
+// after Tenant.
+func (m *GoMiddleware) RequireTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if _, ok := models.UserIDFromContext(ctx); !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			httputil.NewError(c, http.StatusUnauthorized, models.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+		if !scoped(ctx) {
+			httputil.NewError(c, http.StatusForbidden, models.ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// withClaims records the user of an access token, also as the actor, and its
+// roles.
+func withClaims(ctx context.Context, claims *entity.AccessClaims) context.Context {
+	ctx = models.WithActor(ctx, claims.UserID.String())
+	ctx = models.WithUserID(ctx, claims.UserID)
+	return models.WithRoles(ctx, claims.Roles)
+}
+
+// scoped reports whether ctx acts in an organization or for the system.
+func scoped(ctx context.Context) bool {
+	_, ok := models.OrgIDFromContext(ctx)
+	return ok || models.IsSystem(ctx)
+}
+
+// scope returns ctx scoped to the organization o picks for the caller given
+// the requested one, or acting for the system when the caller is an operator
+// asking for none. Anonymous contexts are returned unchanged.
+func scope(ctx context.Context, o org.UseCase, requested uuid.UUID) (context.Context, error) {
+	orgID, scoped, err := o.Tenant(ctx, requested)
+	if err != nil {
+		return nil, err
+	}
+	if scoped {
+		return models.WithOrgID(ctx, orgID), nil
+	}
+	if models.HasRole(ctx, auth.RoleAdmin) {
+		return models.AsSystem(ctx), nil
+	}
+	return ctx, nil
+}
+
 func InitMiddleware() *GoMiddleware {
 	return &GoMiddleware{}
 }
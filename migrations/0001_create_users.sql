@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id         uuid PRIMARY KEY,
+    first_name text      NOT NULL,
+    last_name  text      NOT NULL,
+    email      text      NOT NULL,
+    age        integer   NOT NULL,
+    created    timestamp NOT NULL DEFAULT now()
+);
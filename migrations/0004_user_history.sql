@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS user_history
+(
+    id         uuid PRIMARY KEY,
+    user_id    uuid      NOT NULL,
+    operation  text      NOT NULL,
+    actor      text      NOT NULL,
+    request_id text      NOT NULL DEFAULT '',
+    before     jsonb     NULL,
+    after      jsonb     NULL,
+    changes    jsonb     NOT NULL DEFAULT '{}',
+    created    timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS user_history_user_id_created_idx ON user_history (user_id, created);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id           uuid PRIMARY KEY,
+    aggregate_id uuid      NOT NULL,
+    type         text      NOT NULL,
+    version      integer   NOT NULL,
+    payload      jsonb     NOT NULL,
+    created      timestamp NOT NULL DEFAULT now(),
+    attempts     integer   NOT NULL DEFAULT 0,
+    next_attempt timestamp NOT NULL DEFAULT now(),
+    last_error   text      NOT NULL DEFAULT '',
+    delivered_at timestamp NULL,
+    UNIQUE (aggregate_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (aggregate_id, version) WHERE delivered_at IS NULL;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions
+(
+    id          uuid PRIMARY KEY,
+    url         text      NOT NULL,
+    event_types text[]    NOT NULL,
+    secret      text      NOT NULL,
+    active      boolean   NOT NULL DEFAULT true,
+    created     timestamp NOT NULL DEFAULT now(),
+    updated     timestamp NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id               uuid PRIMARY KEY,
+    subscription_id  uuid      NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id         uuid      NOT NULL,
+    event_type       text      NOT NULL,
+    payload          jsonb     NOT NULL,
+    status           text      NOT NULL DEFAULT 'pending',
+    attempts         integer   NOT NULL DEFAULT 0,
+    next_attempt     timestamp NOT NULL DEFAULT now(),
+    last_status_code integer   NOT NULL DEFAULT 0,
+    last_error       text      NOT NULL DEFAULT '',
+    created          timestamp NOT NULL DEFAULT now(),
+    updated          timestamp NOT NULL DEFAULT now(),
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, created);
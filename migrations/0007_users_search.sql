@@ -0,0 +1,12 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS search_text text
+        GENERATED ALWAYS AS (first_name || ' ' || last_name || ' ' || email) STORED;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS search_vector tsvector
+        GENERATED ALWAYS AS (to_tsvector('simple', first_name || ' ' || last_name || ' ' || email)) STORED;
+
+CREATE INDEX IF NOT EXISTS users_search_vector_idx ON users USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS users_search_text_trgm_idx ON users USING gin (lower(search_text) gin_trgm_ops);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS user_credentials
+(
+    user_id       uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    password_hash text      NOT NULL,
+    updated       timestamp NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens
+(
+    id         uuid PRIMARY KEY,
+    user_id    uuid      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    family_id  uuid      NOT NULL,
+    token_hash text      NOT NULL UNIQUE,
+    expires_at timestamp NOT NULL,
+    used_at    timestamp,
+    revoked_at timestamp,
+    created    timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_idx ON refresh_tokens (user_id) WHERE revoked_at IS NULL;
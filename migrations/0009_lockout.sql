@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS user_roles
+(
+    user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role    text NOT NULL,
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE TABLE IF NOT EXISTS login_attempts
+(
+    scope        text      NOT NULL,
+    key          text      NOT NULL,
+    failures     integer   NOT NULL DEFAULT 0,
+    last_failure timestamp NOT NULL,
+    locked_until timestamp,
+    PRIMARY KEY (scope, key)
+);
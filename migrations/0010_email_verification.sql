@@ -0,0 +1,14 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email_verified boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS email_verifications
+(
+    id         uuid PRIMARY KEY,
+    user_id    uuid      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email      text      NOT NULL,
+    expires_at timestamp NOT NULL,
+    used_at    timestamp,
+    created    timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS email_verifications_user_idx ON email_verifications (user_id);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS password_resets
+(
+    id         uuid PRIMARY KEY,
+    user_id    uuid      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash text      NOT NULL UNIQUE,
+    expires_at timestamp NOT NULL,
+    used_at    timestamp,
+    created    timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS password_resets_user_idx ON password_resets (user_id) WHERE used_at IS NULL;
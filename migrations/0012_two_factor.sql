@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS user_totp
+(
+    user_id      uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret       bytea     NOT NULL,
+    confirmed_at timestamp,
+    last_step    bigint    NOT NULL DEFAULT 0,
+    created      timestamp NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes
+(
+    user_id   uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash text NOT NULL,
+    used_at   timestamp,
+    PRIMARY KEY (user_id, code_hash)
+);
@@ -0,0 +1,22 @@
+-- existing users are active; new ones start pending until they verify their email
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'active'
+        CHECK (status IN ('pending', 'active', 'suspended', 'deactivated'));
+ALTER TABLE users
+    ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE INDEX IF NOT EXISTS users_status_idx ON users (status);
+
+CREATE TABLE IF NOT EXISTS user_status_transitions
+(
+    id          uuid PRIMARY KEY,
+    user_id     uuid      NOT NULL,
+    from_status text      NOT NULL,
+    to_status   text      NOT NULL,
+    reason      text      NOT NULL,
+    actor       text      NOT NULL,
+    request_id  text      NOT NULL DEFAULT '',
+    created     timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS user_status_transitions_user_idx ON user_status_transitions (user_id, created);
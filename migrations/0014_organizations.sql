@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS organizations
+(
+    id      uuid PRIMARY KEY,
+    name    text      NOT NULL,
+    created timestamp NOT NULL DEFAULT now(),
+    updated timestamp NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS org_memberships
+(
+    org_id  uuid      NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id uuid      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role    text      NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    created timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS org_memberships_user_idx ON org_memberships (user_id, created);
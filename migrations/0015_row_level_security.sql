@@ -0,0 +1,113 @@
+-- Transactions set app.tenant_id, the organization of the request, and
+-- app.user_id, the caller, with set_config. The policies below then only expose
+-- the users of that organization, and the caller, whatever the queries ask
+-- for. Sessions that set neither see and change nothing: the policies deny by
+-- default. Workers and logins, which act for the service itself, switch to the
+-- app_system role, which the system_access policies let through. Superusers
+-- and roles with BYPASSRLS skip every policy, so the service must connect as a
+-- regular role; FORCE applies the policies to the owner of the tables too.
+--
+-- Run the migrations as the service role, which then owns the tables, with
+-- CREATEROLE, or create app_system beforehand and grant it to the service.
+
+DO
+$$
+    BEGIN
+        IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'app_system') THEN
+            CREATE ROLE app_system NOLOGIN;
+        END IF;
+    END
+$$;
+
+GRANT app_system TO CURRENT_USER;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO app_system;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO app_system;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO app_system;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO app_system;
+
+CREATE OR REPLACE FUNCTION app_tenant() RETURNS uuid
+    LANGUAGE sql
+    STABLE
+AS
+$$
+SELECT nullif(current_setting('app.tenant_id', true), '')::uuid
+$$;
+
+CREATE OR REPLACE FUNCTION app_user() RETURNS uuid
+    LANGUAGE sql
+    STABLE
+AS
+$$
+SELECT nullif(current_setting('app.user_id', true), '')::uuid
+$$;
+
+CREATE OR REPLACE FUNCTION app_tenant_member(member uuid) RETURNS boolean
+    LANGUAGE sql
+    STABLE
+AS
+$$
+SELECT member = app_user()
+    OR EXISTS (SELECT 1 FROM org_memberships m WHERE m.org_id = app_tenant() AND m.user_id = member)
+$$;
+
+-- memberships of new users are inserted first, so that the users are visible
+-- to their tenant as soon as they exist
+ALTER TABLE org_memberships
+    DROP CONSTRAINT IF EXISTS org_memberships_user_id_fkey,
+    ADD CONSTRAINT org_memberships_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id)
+        ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+
+ALTER TABLE org_memberships ENABLE ROW LEVEL SECURITY;
+ALTER TABLE org_memberships FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON org_memberships;
+CREATE POLICY tenant_isolation ON org_memberships
+    USING (org_id = app_tenant() OR user_id = app_user())
+    WITH CHECK (org_id = app_tenant() OR user_id = app_user());
+DROP POLICY IF EXISTS system_access ON org_memberships;
+CREATE POLICY system_access ON org_memberships TO app_system
+    USING (true)
+    WITH CHECK (true);
+
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON users;
+CREATE POLICY tenant_isolation ON users
+    USING (app_tenant_member(id))
+    WITH CHECK (app_tenant_member(id));
+DROP POLICY IF EXISTS system_access ON users;
+CREATE POLICY system_access ON users TO app_system
+    USING (true)
+    WITH CHECK (true);
+
+ALTER TABLE user_history ENABLE ROW LEVEL SECURITY;
+ALTER TABLE user_history FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON user_history;
+CREATE POLICY tenant_isolation ON user_history
+    USING (app_tenant_member(user_id))
+    WITH CHECK (app_tenant_member(user_id));
+DROP POLICY IF EXISTS system_access ON user_history;
+CREATE POLICY system_access ON user_history TO app_system
+    USING (true)
+    WITH CHECK (true);
+
+ALTER TABLE user_status_transitions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE user_status_transitions FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON user_status_transitions;
+CREATE POLICY tenant_isolation ON user_status_transitions
+    USING (app_tenant_member(user_id))
+    WITH CHECK (app_tenant_member(user_id));
+DROP POLICY IF EXISTS system_access ON user_status_transitions;
+CREATE POLICY system_access ON user_status_transitions TO app_system
+    USING (true)
+    WITH CHECK (true);
+
+ALTER TABLE email_verifications ENABLE ROW LEVEL SECURITY;
+ALTER TABLE email_verifications FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON email_verifications;
+CREATE POLICY tenant_isolation ON email_verifications
+    USING (app_tenant_member(user_id))
+    WITH CHECK (app_tenant_member(user_id));
+DROP POLICY IF EXISTS system_access ON email_verifications;
+CREATE POLICY system_access ON email_verifications TO app_system
+    USING (true)
+    WITH CHECK (true);
@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS groups
+(
+    id          uuid PRIMARY KEY,
+    org_id      uuid REFERENCES organizations (id) ON DELETE CASCADE,
+    parent_id   uuid REFERENCES groups (id) ON DELETE SET NULL,
+    name        text      NOT NULL,
+    description text      NOT NULL DEFAULT '',
+    created     timestamp NOT NULL DEFAULT now(),
+    updated     timestamp NOT NULL DEFAULT now(),
+    CHECK (parent_id <> id)
+);
+
+CREATE INDEX IF NOT EXISTS groups_org_idx ON groups (org_id, name);
+CREATE INDEX IF NOT EXISTS groups_parent_idx ON groups (parent_id);
+
+CREATE TABLE IF NOT EXISTS group_members
+(
+    group_id uuid      NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    user_id  uuid      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created  timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS group_members_user_idx ON group_members (user_id);
+
+-- isolated like the users, see 0015_row_level_security.sql
+ALTER TABLE groups ENABLE ROW LEVEL SECURITY;
+ALTER TABLE groups FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON groups;
+CREATE POLICY tenant_isolation ON groups
+    USING (org_id = app_tenant())
+    WITH CHECK (org_id = app_tenant());
+DROP POLICY IF EXISTS system_access ON groups;
+CREATE POLICY system_access ON groups TO app_system
+    USING (true)
+    WITH CHECK (true);
+
+ALTER TABLE group_members ENABLE ROW LEVEL SECURITY;
+ALTER TABLE group_members FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON group_members;
+CREATE POLICY tenant_isolation ON group_members
+    USING (app_tenant_member(user_id) AND EXISTS (SELECT 1 FROM groups g WHERE g.id = group_id))
+    WITH CHECK (app_tenant_member(user_id) AND EXISTS (SELECT 1 FROM groups g WHERE g.id = group_id));
+DROP POLICY IF EXISTS system_access ON group_members;
+CREATE POLICY system_access ON group_members TO app_system
+    USING (true)
+    WITH CHECK (true);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS invitations
+(
+    id          uuid PRIMARY KEY,
+    org_id      uuid      NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    email       text      NOT NULL,
+    role        text      NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    invited_by  uuid      NOT NULL,
+    expires_at  timestamp NOT NULL,
+    user_id     uuid      REFERENCES users (id) ON DELETE SET NULL,
+    accepted_at timestamp,
+    revoked_at  timestamp,
+    created     timestamp NOT NULL DEFAULT now()
+);
+
+-- one open invitation per address and organization; expired ones are resent
+CREATE UNIQUE INDEX IF NOT EXISTS invitations_open_idx ON invitations (org_id, lower(email))
+    WHERE accepted_at IS NULL AND revoked_at IS NULL;
+
+-- isolated like the users, see 0015_row_level_security.sql
+ALTER TABLE invitations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE invitations FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON invitations;
+CREATE POLICY tenant_isolation ON invitations
+    USING (org_id = app_tenant())
+    WITH CHECK (org_id = app_tenant());
+DROP POLICY IF EXISTS system_access ON invitations;
+CREATE POLICY system_access ON invitations TO app_system
+    USING (true)
+    WITH CHECK (true);
@@ -0,0 +1,3 @@
+-- the organization each change was made in, so that streams only show an
+-- organization its own users; NULL for changes made outside of any
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS org_id uuid;
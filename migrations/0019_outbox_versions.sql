@@ -0,0 +1,13 @@
+-- the last version given to each aggregate, bumped under a row lock so that
+-- concurrent changes to one aggregate cannot take the same version
+CREATE TABLE IF NOT EXISTS outbox_versions
+(
+    aggregate_id uuid PRIMARY KEY,
+    version      integer NOT NULL
+);
+
+INSERT INTO outbox_versions (aggregate_id, version)
+SELECT aggregate_id, max(version)
+FROM outbox
+GROUP BY aggregate_id
+ON CONFLICT (aggregate_id) DO NOTHING;
@@ -0,0 +1,8 @@
+-- callers still see their own memberships in every organization, but only
+-- add or move memberships within the organization of the request; creating
+-- an organization and accepting an invitation, which add callers to other
+-- organizations, act as app_system
+DROP POLICY IF EXISTS tenant_isolation ON org_memberships;
+CREATE POLICY tenant_isolation ON org_memberships
+    USING (org_id = app_tenant() OR user_id = app_user())
+    WITH CHECK (org_id = app_tenant());
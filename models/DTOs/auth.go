@@ -0,0 +1,41 @@
+package DTOs
+
+// swagger:model Login
+type Login struct {
+	Email    string `json:"Email" xml:"Email" yaml:"Email" validate:"required" example:"unbel1evableik@gmail.com"`
+	Password string `json:"Password" xml:"Password" yaml:"Password" validate:"required" example:"correct horse battery staple"`
+}
+
+// swagger:model RefreshToken
+type RefreshToken struct {
+	RefreshToken string `json:"RefreshToken" xml:"RefreshToken" yaml:"RefreshToken" validate:"required"`
+}
+
+// swagger:model LoginMFA
+type LoginMFA struct {
+	MFAToken string `json:"MFAToken" xml:"MFAToken" yaml:"MFAToken" validate:"required"`
+	Code     string `json:"Code" xml:"Code" yaml:"Code" validate:"required" example:"123456"`
+}
+
+// swagger:model TOTPCode
+type TOTPCode struct {
+	Code string `json:"Code" xml:"Code" yaml:"Code" validate:"required" example:"123456"`
+}
+
+// swagger:model PasswordResetRequest
+type PasswordResetRequest struct {
+	Email string `json:"Email" xml:"Email" yaml:"Email" validate:"required" example:"unbel1evableik@gmail.com"`
+}
+
+// swagger:model PasswordResetConfirm
+type PasswordResetConfirm struct {
+	Token    string `json:"Token" xml:"Token" yaml:"Token" validate:"required"`
+	Password string `json:"Password" xml:"Password" yaml:"Password" validate:"required" example:"correct horse battery staple"`
+}
+
+// swagger:model PasswordChange
+type PasswordChange struct {
+	// CurrentPassword is required unless an admin sets the password.
+	CurrentPassword string `json:"CurrentPassword" xml:"CurrentPassword" yaml:"CurrentPassword" example:"correct horse battery staple"`
+	Password        string `json:"Password" xml:"Password" yaml:"Password" validate:"required" example:"another long passphrase"`
+}
@@ -0,0 +1,40 @@
+package DTOs
+
+import "github.com/google/uuid"
+
+const (
+	// BatchModeAtomic applies every item or none of them.
+	BatchModeAtomic = "atomic"
+	// BatchModeBestEffort applies the items that can be applied and reports
+	// the others.
+	BatchModeBestEffort = "best_effort"
+)
+
+// swagger:model BatchCreateUsers
+type BatchCreateUsers struct {
+	Mode  string `json:"Mode" validate:"omitempty,oneof=atomic best_effort" example:"atomic"`
+	Users []User `json:"Users" validate:"required,min=1"`
+}
+
+// swagger:model UserUpdate
+type UserUpdate struct {
+	ID   uuid.UUID `json:"ID" swaggertype:"string" format:"uuid"`
+	User User      `json:"User"`
+}
+
+// swagger:model BatchUpdateUsers
+type BatchUpdateUsers struct {
+	Mode  string       `json:"Mode" validate:"omitempty,oneof=atomic best_effort" example:"best_effort"`
+	Users []UserUpdate `json:"Users" validate:"required,min=1"`
+}
+
+// swagger:model BatchGetUsers
+type BatchGetUsers struct {
+	IDs []uuid.UUID `json:"IDs" validate:"required,min=1" swaggertype:"array,string"`
+}
+
+// swagger:model BatchDeleteUsers
+type BatchDeleteUsers struct {
+	Mode string      `json:"Mode" validate:"omitempty,oneof=atomic best_effort" example:"atomic"`
+	IDs  []uuid.UUID `json:"IDs" validate:"required,min=1" swaggertype:"array,string"`
+}
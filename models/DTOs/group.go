@@ -0,0 +1,15 @@
+package DTOs
+
+import "github.com/google/uuid"
+
+// swagger:model Group
+type Group struct {
+	Name        string     `json:"Name" xml:"Name" yaml:"Name" validate:"required,max=200" example:"Support"`
+	Description string     `json:"Description" xml:"Description" yaml:"Description" validate:"max=1000" example:"First-line support agents"`
+	ParentID    *uuid.UUID `json:"ParentID,omitempty" xml:"ParentID,omitempty" yaml:"ParentID,omitempty" swaggertype:"string" format:"uuid"`
+}
+
+// swagger:model GroupMembers
+type GroupMembers struct {
+	UserIDs []uuid.UUID `json:"UserIDs" xml:"UserIDs" yaml:"UserIDs" validate:"required,min=1" swaggertype:"array,string"`
+}
@@ -0,0 +1,10 @@
+package DTOs
+
+// ImportOptions controls how imported rows are written.
+type ImportOptions struct {
+	// DryRun validates every row without writing anything.
+	DryRun bool
+	// Upsert updates the existing user with the same email instead of
+	// creating a new one.
+	Upsert bool
+}
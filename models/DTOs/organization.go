@@ -0,0 +1,34 @@
+package DTOs
+
+import "github.com/google/uuid"
+
+// swagger:model Organization
+type Organization struct {
+	Name string `json:"Name" xml:"Name" yaml:"Name" validate:"required,max=200" example:"Acme"`
+}
+
+// swagger:model Membership
+type Membership struct {
+	UserID uuid.UUID `json:"UserID" xml:"UserID" yaml:"UserID" validate:"required" swaggertype:"string" format:"uuid"`
+	Role   string    `json:"Role" xml:"Role" yaml:"Role" validate:"required,oneof=owner admin member" example:"member"`
+}
+
+// swagger:model MembershipRole
+type MembershipRole struct {
+	Role string `json:"Role" xml:"Role" yaml:"Role" validate:"required,oneof=owner admin member" example:"admin"`
+}
+
+// swagger:model Invitation
+type Invitation struct {
+	Email string `json:"Email" xml:"Email" yaml:"Email" validate:"required,email,max=254" example:"unbel1evableik@gmail.com"`
+	Role  string `json:"Role" xml:"Role" yaml:"Role" validate:"required,oneof=owner admin member" example:"member"`
+}
+
+// swagger:model AcceptInvitation
+type AcceptInvitation struct {
+	Token string `json:"Token" xml:"Token" yaml:"Token" validate:"required"`
+	// User is the account to create. Its Email, when set, must be the one
+	// invited.
+	User     User   `json:"User" xml:"User" yaml:"User"`
+	Password string `json:"Password" xml:"Password" yaml:"Password" validate:"required" example:"correct horse battery staple"`
+}
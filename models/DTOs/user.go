@@ -2,8 +2,17 @@ package DTOs
 
 // swagger:model User
 type User struct {
-	Firstname string `json:"Firstname" example:"Igor"`
-	Lastname  string `json:"Lastname" example:"Kormich"`
-	Email     string `json:"Email" example:"unbel1evableik@gmail.com"`
-	Age       uint   `json:"Age" example:"1" format:"uint"`
+	Firstname string `json:"Firstname" xml:"Firstname" yaml:"Firstname" example:"Igor"`
+	Lastname  string `json:"Lastname" xml:"Lastname" yaml:"Lastname" example:"Kormich"`
+	Email     string `json:"Email" xml:"Email" yaml:"Email" example:"unbel1evableik@gmail.com"`
+	Age       uint   `json:"Age" xml:"Age" yaml:"Age" example:"1" format:"uint"`
+	// Password is write-only and optional: it is hashed when the user is
+	// created and never read back. Updates refuse it; passwords change
+	// through PUT /users/{id}/password.
+	Password string `json:"Password,omitempty" xml:"Password,omitempty" yaml:"Password,omitempty" example:"correct horse battery staple"`
+}
+
+// swagger:model StatusChange
+type StatusChange struct {
+	Reason string `json:"Reason" xml:"Reason" yaml:"Reason" validate:"required,max=500" example:"Chargeback under investigation"`
 }
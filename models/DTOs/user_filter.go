@@ -0,0 +1,14 @@
+package DTOs
+
+// UserFilter narrows the users returned by list queries.
+type UserFilter struct {
+	IncludeDeleted bool
+	// Statuses keeps only the users in one of them; empty keeps them all.
+	Statuses []string
+	// Limit caps the number of users returned, in creation order; zero means
+	// no limit.
+	Limit  int
+	Offset int
+	// Fields restricts the loaded entity.User fields; empty loads them all.
+	Fields []string
+}
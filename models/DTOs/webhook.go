@@ -0,0 +1,11 @@
+package DTOs
+
+// swagger:model WebhookSubscription
+type WebhookSubscription struct {
+	URL        string   `json:"URL" validate:"required,url" example:"https://partner.example.com/hooks/users"`
+	EventTypes []string `json:"EventTypes" validate:"required,min=1,dive,oneof=UserCreated UserUpdated UserDeleted UserRestored" example:"UserCreated,UserUpdated"`
+	Secret     string   `json:"Secret" validate:"required,min=16" example:"8f1c2b7e4a9d6f3e"`
+	// Active defaults to true for new subscriptions; updates without it keep
+	// the current value.
+	Active *bool `json:"Active,omitempty" example:"true"`
+}
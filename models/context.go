@@ -0,0 +1,114 @@
+package models
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// AnonymousActor is recorded when a request carries no actor.
+const AnonymousActor = "anonymous"
+
+type actorKey struct{}
+type requestIDKey struct{}
+type clientIPKey struct{}
+type rolesKey struct{}
+type userIDKey struct{}
+type orgIDKey struct{}
+type systemKey struct{}
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns who is performing the request, or AnonymousActor.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// WithRoles records the roles of the authenticated caller.
+func WithRoles(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, rolesKey{}, roles)
+}
+
+// HasRole reports whether the authenticated caller has role.
+func HasRole(ctx context.Context, role string) bool {
+	roles, _ := ctx.Value(rolesKey{}).([]string)
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// WithUserID records the user of the access token of the request.
+func WithUserID(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext returns the authenticated user, if any.
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(uuid.UUID)
+	return userID, ok
+}
+
+// WithOrgID scopes the request to the users of an organization. uuid.Nil
+// scopes it to no organization at all.
+func WithOrgID(ctx context.Context, orgID uuid.UUID) context.Context {
+	return context.WithValue(ctx, orgIDKey{}, orgID)
+}
+
+// OrgIDFromContext returns the organization the request acts in, and false
+// when the request is not scoped to any.
+func OrgIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	orgID, ok := ctx.Value(orgIDKey{}).(uuid.UUID)
+	return orgID, ok
+}
+
+// AsSystem marks ctx as acting for the service itself, such as workers and
+// logins that look users up before anyone is authenticated, across every
+// organization.
+func AsSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+// IsSystem reports whether ctx acts for the service itself.
+func IsSystem(ctx context.Context) bool {
+	system, _ := ctx.Value(systemKey{}).(bool)
+	return system
+}
+
+// Detach returns a context that keeps who ctx acts for (actor, request ID,
+// client IP, roles, user, organization and system scope) but is not cancelled
+// with it, for work that outlives the request.
+func Detach(ctx context.Context) context.Context {
+	res := context.Background()
+	for _, key := range []interface{}{actorKey{}, requestIDKey{}, clientIPKey{}, rolesKey{}, userIDKey{}, orgIDKey{}, systemKey{}} {
+		if v := ctx.Value(key); v != nil {
+			res = context.WithValue(res, key, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,111 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Credential is the password of a user. Only its hash is stored, and it is
+// never sent to clients.
+type Credential struct {
+	UserID  uuid.UUID
+	Hash    string `json:"-"`
+	Updated time.Time
+}
+
+// RefreshToken is one refresh token of a login session. Each refresh uses the
+// token up and issues a new one in the same family; presenting a used token
+// again revokes the whole family. Only the SHA-256 of the token is stored.
+type RefreshToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	FamilyID  uuid.UUID
+	Hash      string `json:"-"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	Created   time.Time
+}
+
+// PasswordReset is a password reset requested for a user. Only the SHA-256 of
+// its token is stored; confirming any reset of a user uses them all up.
+type PasswordReset struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Hash      string `json:"-"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	Created   time.Time
+}
+
+// TokenPair is issued by a login or a refresh. ExpiresIn is the lifetime of
+// the access token in seconds.
+//
+// Users with two-factor authentication get only an MFAToken from the
+// password step, to exchange with a code for the tokens. When the user has
+// roles that require two-factor authentication but has not enabled it, those
+// roles are left out of the access token and MFAEnrollmentRequired is set.
+type TokenPair struct {
+	AccessToken           string `json:",omitempty" xml:",omitempty" yaml:"AccessToken,omitempty"`
+	RefreshToken          string `json:",omitempty" xml:",omitempty" yaml:"RefreshToken,omitempty"`
+	TokenType             string `json:",omitempty" xml:",omitempty" yaml:"TokenType,omitempty" example:"Bearer"`
+	ExpiresIn             int    `json:",omitempty" xml:",omitempty" yaml:"ExpiresIn,omitempty" example:"900"`
+	MFAToken              string `json:",omitempty" xml:",omitempty" yaml:"MFAToken,omitempty"`
+	MFAEnrollmentRequired bool   `json:",omitempty" xml:",omitempty" yaml:"MFAEnrollmentRequired,omitempty"`
+}
+
+// TOTP is the authenticator app enrolled by a user. Secret is encrypted. The
+// enrollment counts once confirmed with a first code; LastStep is the time
+// step of the last code accepted, so that codes work once.
+type TOTP struct {
+	UserID      uuid.UUID
+	Secret      []byte `json:"-"`
+	ConfirmedAt *time.Time
+	LastStep    int64
+	Created     time.Time
+}
+
+// TOTPEnrollment is what an authenticator app needs: the base32 secret, its
+// otpauth URI, and a PNG QR code of the URI.
+type TOTPEnrollment struct {
+	Secret string `yaml:"Secret"`
+	URI    string `yaml:"URI"`
+	QRCode []byte `yaml:"QRCode" swaggertype:"string" format:"base64"`
+}
+
+// RecoveryCodes replace TOTP codes once each when the authenticator is lost.
+// They are only shown when two-factor authentication is enabled.
+type RecoveryCodes struct {
+	Codes []string `yaml:"Codes"`
+}
+
+// AccessClaims identifies the user and session an access token was issued to,
+// and the roles the user had then.
+type AccessClaims struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	Roles     []string
+	ExpiresAt time.Time
+}
+
+// LoginAttempts counts the recent failed logins of an account or an IP
+// address, Key being the lower-cased email or the address.
+type LoginAttempts struct {
+	Scope       string
+	Key         string
+	Failures    int
+	LastFailure time.Time
+	LockedUntil *time.Time
+}
+
+// EmailVerification is a verification link sent to Email for a user. The link
+// carries a signed token naming the verification, which is used up when the
+// user follows it.
+type EmailVerification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	Created   time.Time
+}
@@ -0,0 +1,19 @@
+package entity
+
+import "github.com/google/uuid"
+
+// BatchItemResult reports the outcome of one item of a batch request. Index is
+// the position of the item in the request.
+type BatchItemResult struct {
+	Index  int
+	Status int
+	Error  string `json:",omitempty"`
+	User   *User  `json:",omitempty"`
+}
+
+// BatchGetResult holds the users found by a batch lookup, in request order,
+// and the requested IDs that do not exist.
+type BatchGetResult struct {
+	Users   []*User
+	Missing []uuid.UUID `swaggertype:"array,string"`
+}
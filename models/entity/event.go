@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	EventUserCreated  = "UserCreated"
+	EventUserUpdated  = "UserUpdated"
+	EventUserDeleted  = "UserDeleted"
+	EventUserRestored = "UserRestored"
+)
+
+// Event is a domain event stored in the outbox. Version increases by one for
+// every event of the same aggregate. OrgID is the organization the change was
+// made in, nil for changes made outside of any, such as purges.
+type Event struct {
+	ID          uuid.UUID
+	AggregateID uuid.UUID
+	OrgID       *uuid.UUID `swaggertype:"string" format:"uuid"`
+	Type        string
+	Version     int
+	Payload     json.RawMessage `swaggertype:"object"`
+	Created     time.Time
+	Attempts    int        `json:"-"`
+	NextAttempt time.Time  `json:"-"`
+	LastError   string     `json:"-"`
+	Delivered   *time.Time `json:"-"`
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Group gathers users to manage them together. Groups nest: the members of a
+// group are also members of every group above it.
+type Group struct {
+	ID uuid.UUID
+	// OrgID is the organization the group belongs to, nil for groups created
+	// outside of any.
+	OrgID       *uuid.UUID
+	ParentID    *uuid.UUID
+	Name        string
+	Description string
+	Created     time.Time
+	Updated     time.Time
+}
+
+// GroupMember is a user of a group, added to the group itself when Direct, or
+// else to one of its subgroups.
+type GroupMember struct {
+	User   *User
+	Direct bool
+}
+
+// UserGroup is a group of a user, who was added to it when Direct, or else to
+// one of its subgroups.
+type UserGroup struct {
+	Group  *Group
+	Direct bool
+}
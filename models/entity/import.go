@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// ImportJob reports the progress of a user import. Errors keeps the first
+// rejected rows only; Failed counts all of them.
+type ImportJob struct {
+	ID        uuid.UUID
+	Status    string
+	DryRun    bool
+	Upsert    bool
+	Processed int
+	Created   int
+	Updated   int
+	Failed    int
+	Errors    []ImportRowError
+	Error     string `json:",omitempty"`
+	Started   time.Time
+	Finished  *time.Time
+}
+
+type ImportRowError struct {
+	Row   int
+	Error string
+}
@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Roles of a user within an organization. Owners and admins manage its
+// members; only owners manage other owners or delete it.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// Organization is a customer of the service, the tenant its users belong to.
+type Organization struct {
+	ID      uuid.UUID
+	Name    string
+	Created time.Time
+	Updated time.Time
+}
+
+// Membership links a user to an organization with a role.
+type Membership struct {
+	OrgID   uuid.UUID
+	UserID  uuid.UUID
+	Role    string
+	Created time.Time
+}
+
+// OrgMember is a user listed within an organization, with their role there.
+type OrgMember struct {
+	User   *User
+	Role   string
+	Joined time.Time
+}
+
+// Invitation asks the owner of Email to join an organization with Role. The
+// invitation link carries a signed token naming it; accepting it creates the
+// user, UserID, and uses it up. Invitations neither accepted nor revoked are
+// pending until they expire.
+type Invitation struct {
+	ID         uuid.UUID
+	OrgID      uuid.UUID
+	Email      string
+	Role       string
+	InvitedBy  uuid.UUID
+	ExpiresAt  time.Time
+	UserID     *uuid.UUID
+	AcceptedAt *time.Time
+	RevokedAt  *time.Time
+	Created    time.Time
+}
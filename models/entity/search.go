@@ -0,0 +1,9 @@
+package entity
+
+// UserSearchHit is a user matching a search. Highlight is the user's names and
+// email with the matching words wrapped in <mark> tags.
+type UserSearchHit struct {
+	User      *User
+	Rank      float64
+	Highlight string
+}
@@ -2,13 +2,123 @@ package entity
 
 import (
 	"GoMastersTest/models/DTOs"
+	"encoding/xml"
 	_ "github.com/bxcodec/faker"
 	"github.com/google/uuid"
+	"sort"
+	"strings"
 	"time"
 )
 
+// User statuses. Users start pending and become active once they verify their
+// email; suspended and deactivated users cannot sign in.
+const (
+	StatusPending     = "pending"
+	StatusActive      = "active"
+	StatusSuspended   = "suspended"
+	StatusDeactivated = "deactivated"
+)
+
+// UserStatuses lists every user status.
+var UserStatuses = []string{StatusPending, StatusActive, StatusSuspended, StatusDeactivated}
+
 type User struct {
-	DTOs.User
-	Created time.Time
-	ID      uuid.UUID `faker:"UUID"`
+	DTOs.User `yaml:",inline"`
+	// EmailVerified is set once the user follows a verification link sent to
+	// Email, and cleared whenever Email changes.
+	EmailVerified bool `yaml:"EmailVerified"`
+	// Status only changes through the transitions of the status endpoints.
+	Status  string     `yaml:"Status" example:"active" enums:"pending,active,suspended,deactivated"`
+	Created time.Time  `yaml:"Created"`
+	Updated time.Time  `yaml:"Updated"`
+	Deleted *time.Time `xml:",omitempty" yaml:"Deleted"`
+	ID      uuid.UUID  `yaml:"ID" faker:"UUID"`
+}
+
+// Disabled reports whether the status of u keeps them from signing in.
+func (u *User) Disabled() bool {
+	return u.Status == StatusSuspended || u.Status == StatusDeactivated
+}
+
+// UserFields lists the names of the User fields that can be selected with
+// sparse fieldsets, in the order they are encoded.
+var UserFields = []string{"ID", "Firstname", "Lastname", "Email", "Age", "EmailVerified", "Status", "Created", "Updated", "Deleted"}
+
+// UserField returns the canonical name of the User field called name, ignoring
+// case.
+func UserField(name string) (string, bool) {
+	for _, f := range UserFields {
+		if strings.EqualFold(f, name) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// Project returns the given fields of u, named as in UserFields.
+func (u *User) Project(fields []string) PartialUser {
+	res := make(PartialUser, len(fields))
+	for _, f := range fields {
+		switch f {
+		case "ID":
+			res[f] = u.ID
+		case "Firstname":
+			res[f] = u.Firstname
+		case "Lastname":
+			res[f] = u.Lastname
+		case "Email":
+			res[f] = u.Email
+		case "Age":
+			res[f] = u.Age
+		case "EmailVerified":
+			res[f] = u.EmailVerified
+		case "Status":
+			res[f] = u.Status
+		case "Created":
+			res[f] = u.Created
+		case "Updated":
+			res[f] = u.Updated
+		case "Deleted":
+			res[f] = u.Deleted
+		}
+	}
+	return res
+}
+
+// PartialUser is a user restricted to some of its fields, plus any expanded
+// related resources.
+type PartialUser map[string]interface{}
+
+// MarshalXML encodes the user fields in UserFields order followed by the
+// other keys sorted, since encoding/xml cannot encode maps.
+func (p PartialUser) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if start.Name.Local == "PartialUser" {
+		start.Name.Local = "User"
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, key := range p.keys() {
+		if err := e.EncodeElement(p[key], xml.StartElement{Name: xml.Name{Local: key}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func (p PartialUser) keys() []string {
+	keys := make([]string, 0, len(p))
+	for _, f := range UserFields {
+		if _, ok := p[f]; ok {
+			keys = append(keys, f)
+		}
+	}
+	others := make([]string, 0)
+	for key := range p {
+		if _, ok := UserField(key); !ok {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	return append(keys, others...)
 }
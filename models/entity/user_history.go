@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"encoding/xml"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+const (
+	OperationCreate  = "create"
+	OperationUpdate  = "update"
+	OperationDelete  = "delete"
+	OperationRestore = "restore"
+	// OperationLock and OperationUnlock record login lockouts. The user is
+	// unchanged, so Before and After are the same.
+	OperationLock   = "lock"
+	OperationUnlock = "unlock"
+)
+
+// UserHistory is one audit record of a change made to a user.
+type UserHistory struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Operation string
+	Actor     string
+	RequestID string
+	Before    *User
+	After     *User
+	Changes   FieldChanges
+	Created   time.Time
+}
+
+type FieldChange struct {
+	From interface{}
+	To   interface{}
+}
+
+// FieldChanges maps the JSON name of each changed DTOs.User field, and
+// EmailVerified and Status, to its old and new value.
+type FieldChanges map[string]FieldChange
+
+// MarshalXML encodes the changes as Change elements sorted by field name,
+// since encoding/xml cannot encode maps.
+func (f FieldChanges) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	fields := make([]string, 0, len(f))
+	for field := range f {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		change := struct {
+			Field string `xml:"Field,attr"`
+			FieldChange
+		}{field, f[field]}
+		if err := e.EncodeElement(change, xml.StartElement{Name: xml.Name{Local: "Change"}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// StatusTransition records a change of the status of a user, and why.
+type StatusTransition struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	From      string `example:"active"`
+	To        string `example:"suspended"`
+	Reason    string `example:"Chargeback under investigation"`
+	Actor     string
+	RequestID string
+	Created   time.Time
+}
@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// WebhookSubscription is a partner endpoint called when matching events occur.
+// The secret signs deliveries and is never sent back to clients.
+type WebhookSubscription struct {
+	ID         uuid.UUID
+	URL        string
+	EventTypes []string
+	Secret     string `json:"-"`
+	Active     bool
+	Created    time.Time
+	Updated    time.Time
+}
+
+// WebhookDelivery is one event queued for one subscription, together with the
+// outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        json.RawMessage `json:"-"`
+	Status         string
+	Attempts       int
+	NextAttempt    time.Time
+	LastStatusCode int
+	LastError      string
+	Created        time.Time
+	Updated        time.Time
+}
@@ -8,4 +8,26 @@ var (
 	ErrConflict            = errors.New("Your Item already exist")
 	ErrBadParamInput       = errors.New("Given Param is not valid")
 	ErrEmailValid          = errors.New("Invalid Email Address")
+	ErrBatchTooLarge       = errors.New("Batch has too many items")
+	ErrBatchAborted        = errors.New("Batch was not applied because another item failed")
+	ErrUnsupportedFormat   = errors.New("Unsupported format")
+	ErrInvalidCredentials  = errors.New("Invalid email or password")
+	ErrInvalidToken        = errors.New("Invalid or expired token")
+	ErrPasswordTooShort    = errors.New("Password is too short")
+	ErrPasswordTooLong     = errors.New("Password is too long")
+	ErrPasswordBreached    = errors.New("Password appears in a list of breached passwords")
+	ErrTooManyAttempts     = errors.New("Too many failed attempts, try again later")
+	ErrAccountLocked       = errors.New("Account is temporarily locked")
+	ErrUnauthorized        = errors.New("Authentication required")
+	ErrForbidden           = errors.New("You are not allowed to do this")
+	ErrEmailVerified       = errors.New("Email address is already verified")
+	ErrTwoFactorEnabled    = errors.New("Two-factor authentication is already enabled")
+	ErrInvalidCode         = errors.New("Invalid verification code")
+	ErrInvalidTransition   = errors.New("The user cannot go to this status from their current one")
+	ErrAccountDisabled     = errors.New("This account is suspended or deactivated")
+	ErrLastOwner           = errors.New("An organization must keep at least one owner")
+	ErrGroupCycle          = errors.New("A group cannot be nested within itself or its subgroups")
+	ErrPrivateURL          = errors.New("URL must point to a public address")
+	ErrBodyTooLarge        = errors.New("Request body is too large")
+	ErrPasswordOnUpdate    = errors.New("Passwords are not changed with the user, use PUT /users/{id}/password")
 )
@@ -0,0 +1,241 @@
+package http
+
+import (
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/org"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+type InvitationHandler struct {
+	Usecase org.InvitationUseCase
+}
+
+// NewInvitationHandler registers the invitation routes. requireUser guards
+// all of them but accepting, which the invitee does before having an account.
+func NewInvitationHandler(r *gin.Engine, us org.InvitationUseCase, requireUser gin.HandlerFunc) {
+	handler := &InvitationHandler{
+		Usecase: us,
+	}
+	v1 := r.Group("/api/v1")
+	v1.POST("/invitations/accept", handler.AcceptInvitation)
+	orgs := v1.Group("/orgs/:orgID/invitations", requireUser)
+	orgs.GET("", handler.GetPendingInvitations)
+	orgs.POST("", handler.CreateInvitation)
+	orgs.POST("/:id/resend", handler.ResendInvitation)
+	orgs.DELETE("/:id", handler.RevokeInvitation)
+}
+
+// GetPendingInvitations godoc
+// @Summary      GetPendingInvitations
+// @Description  Return the invitations of an organization neither accepted, revoked nor expired, oldest first. Owners and admins of the organization only.
+// @Tags         Organizations
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        orgID   path   string  true   "Organization ID"
+// @Param        limit   query  int     false  "Page size"
+// @Param        offset  query  int     false  "Page offset"
+// @Success      200  {object}  []entity.Invitation
+// @Header       200  {int}     X-Total-Count  "Total number of pending invitations"
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /orgs/{orgID}/invitations [get]
+func (a *InvitationHandler) GetPendingInvitations(c *gin.Context) {
+	orgID, err := uuid.Parse(c.Param("orgID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	limit, offset, err := httputil.ParsePagination(c)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	invitations, total, err := a.Usecase.GetPending(ctx, orgID, limit, offset)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	httputil.Respond(c, http.StatusOK, invitations)
+}
+
+// CreateInvitation godoc
+// @Summary      CreateInvitation
+// @Description  Invite an email to join an organization with a role, mailing a link to accept. Owners and admins of the organization only; only owners invite owners. Emails with an open invitation answer 409; resend it instead.
+// @Tags         Organizations
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        orgID       path  string           true  "Organization ID"
+// @Param        Invitation  body  DTOs.Invitation  true  "Email and role"
+// @Success      201  {object}  entity.Invitation
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      409  {object}  httputil.HTTPError
+// @Router /orgs/{orgID}/invitations [post]
+func (a *InvitationHandler) CreateInvitation(c *gin.Context) {
+	orgID, err := uuid.Parse(c.Param("orgID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	var body DTOs.Invitation
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Invite(ctx, orgID, &body)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("Location", c.FullPath()+"/"+res.ID.String())
+	httputil.Respond(c, http.StatusCreated, res)
+}
+
+// ResendInvitation godoc
+// @Summary      ResendInvitation
+// @Description  Extend an invitation, expired or not, and mail a new link. Earlier links stay valid until they expire. Accepted and revoked invitations answer 409.
+// @Tags         Organizations
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        orgID  path  string  true  "Organization ID"
+// @Param        id     path  string  true  "Invitation ID"
+// @Success      200  {object}  entity.Invitation
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      409  {object}  httputil.HTTPError
+// @Router /orgs/{orgID}/invitations/{id}/resend [post]
+func (a *InvitationHandler) ResendInvitation(c *gin.Context) {
+	orgID, id, ok := parseInvitationPath(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Resend(ctx, orgID, id)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// RevokeInvitation godoc
+// @Summary      RevokeInvitation
+// @Description  Revoke an invitation, so that its links no longer work. Accepted and revoked invitations answer 409.
+// @Tags         Organizations
+// @Param        orgID  path  string  true  "Organization ID"
+// @Param        id     path  string  true  "Invitation ID"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      409  {object}  httputil.HTTPError
+// @Router /orgs/{orgID}/invitations/{id} [delete]
+func (a *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	orgID, id, ok := parseInvitationPath(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := a.Usecase.Revoke(ctx, orgID, id); err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AcceptInvitation godoc
+// @Summary      AcceptInvitation
+// @Description  Create the account of an invitation with the token of its link, joining the organization with the invited role. The user gets the invited email and needs a password. Forged, expired, used and revoked tokens answer 401.
+// @Tags         Organizations
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        AcceptInvitation  body  DTOs.AcceptInvitation  true  "Token and user"
+// @Success      201  {object}  entity.User
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Router /invitations/accept [post]
+func (a *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var body DTOs.AcceptInvitation
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Accept(ctx, body.Token, &body.User, body.Password)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("Location", "/api/v1/users/"+res.ID.String())
+	httputil.Respond(c, http.StatusCreated, res)
+}
+
+// parseInvitationPath parses the organization and invitation IDs of the path.
+// It writes the error response and returns false when one is invalid.
+func parseInvitationPath(c *gin.Context) (orgID uuid.UUID, id uuid.UUID, ok bool) {
+	orgID, err := uuid.Parse(c.Param("orgID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return uuid.Nil, uuid.Nil, false
+	}
+	id, err = uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return orgID, id, true
+}
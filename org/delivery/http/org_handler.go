@@ -0,0 +1,403 @@
+package http
+
+import (
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/org"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+type OrgHandler struct {
+	Usecase org.UseCase
+}
+
+// NewOrgHandler registers the organization routes, all guarded by
+// requireUser.
+func NewOrgHandler(r *gin.Engine, us org.UseCase, requireUser gin.HandlerFunc) {
+	handler := &OrgHandler{
+		Usecase: us,
+	}
+	v1 := r.Group("/api/v1", requireUser)
+	v1.GET("/orgs", handler.GetAllOrganizations)
+	v1.GET("/orgs/:orgID", handler.GetOrganizationByID)
+	v1.POST("/orgs", handler.CreateOrganization)
+	v1.PUT("/orgs/:orgID", handler.UpdateOrganization)
+	v1.DELETE("/orgs/:orgID", handler.DeleteOrganization)
+	v1.GET("/orgs/:orgID/users", handler.GetMembers)
+	v1.POST("/orgs/:orgID/users", handler.AddMember)
+	v1.PUT("/orgs/:orgID/users/:userID", handler.SetMemberRole)
+	v1.DELETE("/orgs/:orgID/users/:userID", handler.RemoveMember)
+}
+
+// GetAllOrganizations godoc
+// @Summary      GetAllOrganizations
+// @Description  Return the organizations of the caller, or every organization to admins
+// @Tags         Organizations
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        limit   query  int  false  "Page size"
+// @Param        offset  query  int  false  "Page offset"
+// @Success      200  {object}  []entity.Organization
+// @Header       200  {int}     X-Total-Count  "Total number of organizations"
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Router /orgs [get]
+func (a *OrgHandler) GetAllOrganizations(c *gin.Context) {
+	limit, offset, err := httputil.ParsePagination(c)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	orgs, total, err := a.Usecase.GetAll(ctx, limit, offset)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	httputil.Respond(c, http.StatusOK, orgs)
+}
+
+// GetOrganizationByID godoc
+// @Summary      GetOrganizationByID
+// @Description  Return an organization the caller is a member of
+// @Tags         Organizations
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        orgID  path  string  true  "Organization ID"
+// @Success      200  {object}  entity.Organization
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /orgs/{orgID} [get]
+func (a *OrgHandler) GetOrganizationByID(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("orgID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.GetByID(ctx, id)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// CreateOrganization godoc
+// @Summary      CreateOrganization
+// @Description  Create an organization owned by the caller
+// @Tags         Organizations
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        Organization  body  DTOs.Organization  true  "Add organization"
+// @Success      201  {object}  entity.Organization
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Router /orgs [post]
+func (a *OrgHandler) CreateOrganization(c *gin.Context) {
+	var body DTOs.Organization
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Create(ctx, &body)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("Location", c.FullPath()+"/"+res.ID.String())
+	httputil.Respond(c, http.StatusCreated, res)
+}
+
+// UpdateOrganization godoc
+// @Summary      UpdateOrganization
+// @Description  Rename an organization. Owners and admins of the organization only.
+// @Tags         Organizations
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        orgID         path  string             true  "Organization ID"
+// @Param        Organization  body  DTOs.Organization  true  "Update organization"
+// @Success      200  {object}  entity.Organization
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /orgs/{orgID} [put]
+func (a *OrgHandler) UpdateOrganization(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("orgID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	var body DTOs.Organization
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Update(ctx, id, &body)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// DeleteOrganization godoc
+// @Summary      DeleteOrganization
+// @Description  Delete an organization and its memberships, keeping its users. Owners of the organization only.
+// @Tags         Organizations
+// @Param        orgID  path  string  true  "Organization ID"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /orgs/{orgID} [delete]
+func (a *OrgHandler) DeleteOrganization(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("orgID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err = a.Usecase.Delete(ctx, id); err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetMembers godoc
+// @Summary      GetOrganizationUsers
+// @Description  Return the users of an organization with their roles, in the order they joined
+// @Tags         Organizations
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        orgID   path   string  true   "Organization ID"
+// @Param        limit   query  int     false  "Page size"
+// @Param        offset  query  int     false  "Page offset"
+// @Success      200  {object}  []entity.OrgMember
+// @Header       200  {int}     X-Total-Count  "Total number of users"
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /orgs/{orgID}/users [get]
+func (a *OrgHandler) GetMembers(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("orgID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	limit, offset, err := httputil.ParsePagination(c)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	members, total, err := a.Usecase.GetMembers(ctx, id, limit, offset)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	httputil.Respond(c, http.StatusOK, members)
+}
+
+// AddMember godoc
+// @Summary      AddOrganizationUser
+// @Description  Add an existing user to an organization with a role. Owners and admins of the organization only; only owners add owners.
+// @Tags         Organizations
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        orgID       path  string           true  "Organization ID"
+// @Param        Membership  body  DTOs.Membership  true  "User and role"
+// @Success      201  {object}  entity.Membership
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      409  {object}  httputil.HTTPError
+// @Router /orgs/{orgID}/users [post]
+func (a *OrgHandler) AddMember(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("orgID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	var body DTOs.Membership
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.AddMember(ctx, id, &body)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("Location", c.FullPath()+"/"+res.UserID.String())
+	httputil.Respond(c, http.StatusCreated, res)
+}
+
+// SetMemberRole godoc
+// @Summary      SetOrganizationUserRole
+// @Description  Change the role of a user in an organization. Owners and admins of the organization only; only owners change owners, and the last owner cannot step down.
+// @Tags         Organizations
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        orgID           path  string               true  "Organization ID"
+// @Param        userID          path  string               true  "User ID"
+// @Param        MembershipRole  body  DTOs.MembershipRole  true  "Role"
+// @Success      200  {object}  entity.Membership
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      409  {object}  httputil.HTTPError
+// @Router /orgs/{orgID}/users/{userID} [put]
+func (a *OrgHandler) SetMemberRole(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("orgID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	var body DTOs.MembershipRole
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.SetMemberRole(ctx, id, userID, body.Role)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// RemoveMember godoc
+// @Summary      RemoveOrganizationUser
+// @Description  Remove a user from an organization, keeping the user. Owners and admins of the organization only, or the user leaving; the last owner cannot leave.
+// @Tags         Organizations
+// @Param        orgID   path  string  true  "Organization ID"
+// @Param        userID  path  string  true  "User ID"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      409  {object}  httputil.HTTPError
+// @Router /orgs/{orgID}/users/{userID} [delete]
+func (a *OrgHandler) RemoveMember(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("orgID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err = a.Usecase.RemoveMember(ctx, id, userID); err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func getStatusCode(err error) int {
+	switch err {
+	case models.ErrInvalidToken:
+		logrus.Error(err)
+		return http.StatusUnauthorized
+	case models.ErrLastOwner:
+		logrus.Error(err)
+		return http.StatusConflict
+	}
+	return httputil.StatusCode(err)
+}
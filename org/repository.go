@@ -0,0 +1,53 @@
+package org
+
+import (
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type OrganizationRepository interface {
+	GetAll(ctx context.Context, limit int, offset int) ([]*entity.Organization, int, error)
+	// GetByUserID returns a page of the organizations the user is a member
+	// of, and their total count.
+	GetByUserID(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]*entity.Organization, int, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Organization, error)
+	Create(ctx context.Context, o *DTOs.Organization) (*entity.Organization, error)
+	Update(ctx context.Context, id uuid.UUID, o *DTOs.Organization) (*entity.Organization, error)
+	// Delete removes the organization along with its memberships. Its users
+	// are kept.
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type MembershipRepository interface {
+	Get(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) (*entity.Membership, error)
+	// GetByUserID returns the memberships of the user, oldest first.
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]*entity.Membership, error)
+	// GetMembers returns a page of the users of the organization that are
+	// not deleted, in the order they joined, and their total count.
+	GetMembers(ctx context.Context, orgID uuid.UUID, limit int, offset int) ([]*entity.OrgMember, int, error)
+	// CountOwners locks the owners of the organization and counts them.
+	CountOwners(ctx context.Context, orgID uuid.UUID) (int, error)
+	// Add fails with ErrConflict when the user is already a member, and
+	// ErrNotFound when the user does not exist.
+	Add(ctx context.Context, m *entity.Membership) error
+	SetRole(ctx context.Context, orgID uuid.UUID, userID uuid.UUID, role string) (*entity.Membership, error)
+	Remove(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) error
+}
+
+type InvitationRepository interface {
+	// Create fails with ErrConflict when an invitation of the same email to
+	// the organization is neither accepted nor revoked, even if expired.
+	Create(ctx context.Context, i *entity.Invitation) error
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Invitation, error)
+	// GetPending returns a page of the pending invitations of the
+	// organization, oldest first, and their total count.
+	GetPending(ctx context.Context, orgID uuid.UUID, limit int, offset int) ([]*entity.Invitation, int, error)
+	// Renew, Revoke and MarkAccepted fail with ErrNotFound when the
+	// invitation is already accepted or revoked.
+	Renew(ctx context.Context, id uuid.UUID, expiresAt time.Time) (*entity.Invitation, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+	MarkAccepted(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+}
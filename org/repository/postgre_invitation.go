@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/org"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type postgreInvitationRepository struct {
+	Conn       *sql.DB
+	transactor dbutil.Transactor
+}
+
+// NewPostgreInvitationRepository runs its queries in transactions, so that
+// row-level security applies to them.
+func NewPostgreInvitationRepository(Conn *sql.DB) org.InvitationRepository {
+	return &postgreInvitationRepository{Conn, dbutil.NewTransactor(Conn)}
+}
+
+func scanInvitation(row rowScanner) (*entity.Invitation, error) {
+	t := new(entity.Invitation)
+	err := row.Scan(
+		&t.ID,
+		&t.OrgID,
+		&t.Email,
+		&t.Role,
+		&t.InvitedBy,
+		&t.ExpiresAt,
+		&t.UserID,
+		&t.AcceptedAt,
+		&t.RevokedAt,
+		&t.Created,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *postgreInvitationRepository) Create(ctx context.Context, i *entity.Invitation) error {
+	query := `INSERT INTO invitations (id, org_id, email, role, invited_by, expires_at, created) VALUES ($1, $2, $3, $4, $5, $6, now())
+						ON CONFLICT (org_id, lower(email)) WHERE accepted_at IS NULL AND revoked_at IS NULL DO NOTHING
+						RETURNING created`
+
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		return dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query,
+			i.ID.String(), i.OrgID.String(), i.Email, i.Role, i.InvitedBy.String(), i.ExpiresAt).Scan(&i.Created)
+	})
+	if err == sql.ErrNoRows {
+		return models.ErrConflict
+	}
+	return err
+}
+
+func (m *postgreInvitationRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Invitation, error) {
+	query := `select id, org_id, email, role, invited_by, expires_at, user_id, accepted_at, revoked_at, created
+						from invitations where id = $1`
+
+	return m.queryRow(ctx, query, id.String())
+}
+
+func (m *postgreInvitationRepository) GetPending(ctx context.Context, orgID uuid.UUID, limit int, offset int) ([]*entity.Invitation, int, error) {
+	pending := ` from invitations
+						where org_id = $1 and accepted_at is null and revoked_at is null and expires_at > now()`
+
+	var result []*entity.Invitation
+	var total int
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		conn := dbutil.Conn(ctx, m.Conn)
+		err := conn.QueryRowContext(ctx, `select count(*)`+pending, orgID.String()).Scan(&total)
+		if err != nil {
+			return err
+		}
+
+		rows, err := conn.QueryContext(ctx, `select id, org_id, email, role, invited_by, expires_at, user_id, accepted_at, revoked_at, created`+
+			pending+` order by created, id limit $2 offset $3`, orgID.String(), limit, offset)
+		if err != nil {
+			logrus.Error(err)
+			return err
+		}
+
+		defer func() {
+			err := rows.Close()
+			if err != nil {
+				logrus.Error(err)
+			}
+		}()
+
+		result = make([]*entity.Invitation, 0)
+		for rows.Next() {
+			t, err := scanInvitation(rows)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+			result = append(result, t)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
+}
+
+func (m *postgreInvitationRepository) Renew(ctx context.Context, id uuid.UUID, expiresAt time.Time) (*entity.Invitation, error) {
+	query := `UPDATE invitations SET expires_at = $2 WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+						RETURNING id, org_id, email, role, invited_by, expires_at, user_id, accepted_at, revoked_at, created`
+
+	return m.queryRow(ctx, query, id.String(), expiresAt)
+}
+
+func (m *postgreInvitationRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE invitations SET revoked_at = now() WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL`
+
+	return m.close(ctx, query, id.String())
+}
+
+func (m *postgreInvitationRepository) MarkAccepted(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	query := `UPDATE invitations SET accepted_at = now(), user_id = $2 WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL`
+
+	return m.close(ctx, query, id.String(), userID.String())
+}
+
+// close runs a statement ending an open invitation, ErrNotFound when it is
+// not open.
+func (m *postgreInvitationRepository) close(ctx context.Context, query string, args ...interface{}) error {
+	var ra int64
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		res, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		ra, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if ra == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+// queryRow runs a query returning one invitation, ErrNotFound when it returns
+// none.
+func (m *postgreInvitationRepository) queryRow(ctx context.Context, query string, args ...interface{}) (*entity.Invitation, error) {
+	var res *entity.Invitation
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		var err error
+		res, err = scanInvitation(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, args...))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	return res, err
+}
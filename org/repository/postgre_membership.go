@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/org"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type postgreMembershipRepository struct {
+	Conn       *sql.DB
+	transactor dbutil.Transactor
+}
+
+// NewPostgreMembershipRepository runs its queries in transactions, so that
+// row-level security applies to them.
+func NewPostgreMembershipRepository(Conn *sql.DB) org.MembershipRepository {
+	return &postgreMembershipRepository{Conn, dbutil.NewTransactor(Conn)}
+}
+
+// queryRow runs a query returning one membership, ErrNotFound when it returns
+// none.
+func (m *postgreMembershipRepository) queryRow(ctx context.Context, query string, args ...interface{}) (*entity.Membership, error) {
+	var res *entity.Membership
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		var err error
+		res, err = scanMembership(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, args...))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	return res, err
+}
+
+func scanMembership(row rowScanner) (*entity.Membership, error) {
+	t := new(entity.Membership)
+	err := row.Scan(
+		&t.OrgID,
+		&t.UserID,
+		&t.Role,
+		&t.Created,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *postgreMembershipRepository) Get(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) (*entity.Membership, error) {
+	query := `select org_id, user_id, role, created from org_memberships where org_id = $1 and user_id = $2`
+
+	return m.queryRow(ctx, query, orgID.String(), userID.String())
+}
+
+func (m *postgreMembershipRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*entity.Membership, error) {
+	query := `select org_id, user_id, role, created from org_memberships where user_id = $1 order by created, org_id`
+
+	var result []*entity.Membership
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, userID.String())
+		if err != nil {
+			logrus.Error(err)
+			return err
+		}
+
+		defer func() {
+			err := rows.Close()
+			if err != nil {
+				logrus.Error(err)
+			}
+		}()
+
+		result = make([]*entity.Membership, 0)
+		for rows.Next() {
+			t, err := scanMembership(rows)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+			result = append(result, t)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (m *postgreMembershipRepository) GetMembers(ctx context.Context, orgID uuid.UUID, limit int, offset int) ([]*entity.OrgMember, int, error) {
+	var result []*entity.OrgMember
+	var total int
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		conn := dbutil.Conn(ctx, m.Conn)
+
+		err := conn.QueryRowContext(ctx, `select count(*) from org_memberships m join users u on u.id = m.user_id
+						where m.org_id = $1 and u.deleted_at is null`, orgID.String()).Scan(&total)
+		if err != nil {
+			return err
+		}
+
+		rows, err := conn.QueryContext(ctx, `select u.id, u.first_name, u.last_name, u.email, u.age, u.created, u.updated, u.deleted_at,
+							u.email_verified, u.status, m.role, m.created
+						from org_memberships m join users u on u.id = m.user_id
+						where m.org_id = $1 and u.deleted_at is null
+						order by m.created, u.id limit $2 offset $3`, orgID.String(), limit, offset)
+		if err != nil {
+			logrus.Error(err)
+			return err
+		}
+
+		defer func() {
+			err := rows.Close()
+			if err != nil {
+				logrus.Error(err)
+			}
+		}()
+
+		result = make([]*entity.OrgMember, 0)
+		for rows.Next() {
+			t := new(entity.User)
+			member := &entity.OrgMember{User: t}
+			err = rows.Scan(&t.ID, &t.Firstname, &t.Lastname, &t.Email, &t.Age, &t.Created, &t.Updated, &t.Deleted, &t.EmailVerified, &t.Status,
+				&member.Role, &member.Joined)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+			result = append(result, member)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
+}
+
+func (m *postgreMembershipRepository) CountOwners(ctx context.Context, orgID uuid.UUID) (int, error) {
+	query := `select user_id from org_memberships where org_id = $1 and role = $2 for update`
+
+	count := 0
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, orgID.String(), entity.OrgRoleOwner)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			err := rows.Close()
+			if err != nil {
+				logrus.Error(err)
+			}
+		}()
+
+		for rows.Next() {
+			count++
+		}
+		return rows.Err()
+	})
+	return count, err
+}
+
+func (m *postgreMembershipRepository) Add(ctx context.Context, t *entity.Membership) error {
+	query := `INSERT INTO org_memberships (org_id, user_id, role, created)
+						SELECT $1, id, $3, now() FROM users WHERE id = $2 AND deleted_at IS NULL
+						ON CONFLICT (org_id, user_id) DO NOTHING
+						RETURNING created`
+
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		return dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, t.OrgID.String(), t.UserID.String(), t.Role).Scan(&t.Created)
+	})
+	if err != sql.ErrNoRows {
+		return err
+	}
+	if _, err = m.Get(ctx, t.OrgID, t.UserID); err == nil {
+		return models.ErrConflict
+	}
+	return err
+}
+
+func (m *postgreMembershipRepository) SetRole(ctx context.Context, orgID uuid.UUID, userID uuid.UUID, role string) (*entity.Membership, error) {
+	query := `UPDATE org_memberships SET role = $3 WHERE org_id = $1 AND user_id = $2
+						RETURNING org_id, user_id, role, created`
+
+	return m.queryRow(ctx, query, orgID.String(), userID.String(), role)
+}
+
+func (m *postgreMembershipRepository) Remove(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) error {
+	var ra int64
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		res, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, `DELETE FROM org_memberships WHERE org_id = $1 AND user_id = $2`,
+			orgID.String(), userID.String())
+		if err != nil {
+			return err
+		}
+		ra, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if ra == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
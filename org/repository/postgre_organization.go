@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/org"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type postgreOrganizationRepository struct {
+	Conn       *sql.DB
+	transactor dbutil.Transactor
+}
+
+// NewPostgreOrganizationRepository runs the queries joining memberships in
+// transactions, so that row-level security applies to them.
+func NewPostgreOrganizationRepository(Conn *sql.DB) org.OrganizationRepository {
+	return &postgreOrganizationRepository{Conn, dbutil.NewTransactor(Conn)}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOrganization(row rowScanner) (*entity.Organization, error) {
+	t := new(entity.Organization)
+	err := row.Scan(
+		&t.ID,
+		&t.Name,
+		&t.Created,
+		&t.Updated,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *postgreOrganizationRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]*entity.Organization, error) {
+	rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	result := make([]*entity.Organization, 0)
+	for rows.Next() {
+		t, err := scanOrganization(rows)
+		if err != nil {
+			logrus.Error(err)
+			return nil, err
+		}
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
+
+func (m *postgreOrganizationRepository) GetAll(ctx context.Context, limit int, offset int) ([]*entity.Organization, int, error) {
+	var total int
+	err := dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, `select count(*) from organizations`).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `select id, name, created, updated from organizations order by created, id limit $1 offset $2`
+
+	list, err := m.fetch(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+func (m *postgreOrganizationRepository) GetByUserID(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]*entity.Organization, int, error) {
+	var total int
+	var list []*entity.Organization
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		err := dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, `select count(*) from org_memberships where user_id = $1`,
+			userID.String()).Scan(&total)
+		if err != nil {
+			return err
+		}
+
+		query := `select o.id, o.name, o.created, o.updated
+						from organizations o join org_memberships m on m.org_id = o.id
+						where m.user_id = $1 order by o.created, o.id limit $2 offset $3`
+
+		list, err = m.fetch(ctx, query, userID.String(), limit, offset)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+func (m *postgreOrganizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Organization, error) {
+	query := `select id, name, created, updated from organizations where id = $1`
+
+	res, err := scanOrganization(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	return res, err
+}
+
+func (m *postgreOrganizationRepository) Create(ctx context.Context, o *DTOs.Organization) (*entity.Organization, error) {
+	query := `INSERT INTO organizations (id, name, created, updated) VALUES ($1, $2, $3, $3)
+						RETURNING id, name, created, updated`
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOrganization(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, id.String(), o.Name, time.Now()))
+}
+
+func (m *postgreOrganizationRepository) Update(ctx context.Context, id uuid.UUID, o *DTOs.Organization) (*entity.Organization, error) {
+	query := `UPDATE organizations SET name=$1, updated=now() WHERE id = $2
+						RETURNING id, name, created, updated`
+
+	res, err := scanOrganization(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, o.Name, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	return res, err
+}
+
+func (m *postgreOrganizationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, `DELETE FROM organizations WHERE id = $1`, id.String())
+	if err != nil {
+		return err
+	}
+
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if ra == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
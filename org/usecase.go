@@ -0,0 +1,58 @@
+package org
+
+import (
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+)
+
+// UseCase manages organizations on behalf of the caller of the request.
+// Operators with auth.RoleAdmin manage every organization; other users see
+// the organizations they belong to and manage those they own or administer.
+// Operations on one organization act within it, whatever the organization of
+// the request, for the row-level security policies of its memberships.
+type UseCase interface {
+	// GetAll returns every organization to operators, and the organizations
+	// of the caller to the others.
+	GetAll(ctx context.Context, limit int, offset int) ([]*entity.Organization, int, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Organization, error)
+	// Create makes the caller the owner of the new organization.
+	Create(ctx context.Context, o *DTOs.Organization) (*entity.Organization, error)
+	Update(ctx context.Context, id uuid.UUID, o *DTOs.Organization) (*entity.Organization, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetMembers(ctx context.Context, orgID uuid.UUID, limit int, offset int) ([]*entity.OrgMember, int, error)
+	AddMember(ctx context.Context, orgID uuid.UUID, m *DTOs.Membership) (*entity.Membership, error)
+	// SetMemberRole and RemoveMember fail with ErrLastOwner rather than leave
+	// the organization without owners.
+	SetMemberRole(ctx context.Context, orgID uuid.UUID, userID uuid.UUID, role string) (*entity.Membership, error)
+	RemoveMember(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) error
+	// Tenant returns the organization the caller acts in, given the one they
+	// asked for or uuid.Nil, and false when their requests are not scoped.
+	// Anonymous requests and operators asking for none are not scoped; other
+	// users default to their oldest organization, or uuid.Nil when they have
+	// none. Asking for an organization the caller is not a member of fails
+	// with ErrForbidden.
+	Tenant(ctx context.Context, requested uuid.UUID) (uuid.UUID, bool, error)
+}
+
+// InvitationUseCase invites people to organizations by email. Owners and
+// admins of an organization manage its invitations; only owners invite owners.
+type InvitationUseCase interface {
+	// Invite mails a link to accept the invitation. It fails with ErrConflict
+	// when the email already has an open invitation to the organization,
+	// which can be resent instead.
+	Invite(ctx context.Context, orgID uuid.UUID, i *DTOs.Invitation) (*entity.Invitation, error)
+	GetPending(ctx context.Context, orgID uuid.UUID, limit int, offset int) ([]*entity.Invitation, int, error)
+	// Resend extends the invitation and mails a new link. Earlier links stay
+	// valid until they expire. Resend and Revoke fail with ErrConflict when
+	// the invitation is already accepted or revoked.
+	Resend(ctx context.Context, orgID uuid.UUID, id uuid.UUID) (*entity.Invitation, error)
+	Revoke(ctx context.Context, orgID uuid.UUID, id uuid.UUID) error
+	// Accept creates the user of an invitation, with the invited email, and
+	// makes them a member of the organization with the invited role. It fails
+	// with ErrInvalidToken when the token is forged or expired, or the
+	// invitation accepted or revoked, with ErrBadParamInput when u has
+	// another email, and with ErrPasswordTooShort when password is empty.
+	Accept(ctx context.Context, token string, u *DTOs.User, password string) (*entity.User, error)
+}
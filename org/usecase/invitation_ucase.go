@@ -0,0 +1,243 @@
+package usecase
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/mail"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/org"
+	"GoMastersTest/user"
+	"context"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+type invitationUseCase struct {
+	orgs                 *orgUseCases
+	invitationRepository org.InvitationRepository
+	users                user.UseCase
+	passwords            auth.PasswordUseCase
+	mailer               mail.Mailer
+	templates            *mail.Templates
+	tokens               *auth.TokenIssuer
+	link                 string
+	ttl                  time.Duration
+}
+
+// NewInvitationUseCase mails links to link, with the token of the invitation
+// in their token query parameter. Invitations expire after ttl. Accepted
+// invitations create their user through u, audited like the others, and set
+// their password through p.
+func NewInvitationUseCase(o org.OrganizationRepository, m org.MembershipRepository, i org.InvitationRepository, u user.UseCase,
+	p auth.PasswordUseCase, ml mail.Mailer, tpl *mail.Templates, t *auth.TokenIssuer, link string, ttl time.Duration, tx dbutil.Transactor,
+	timeout time.Duration) org.InvitationUseCase {
+	return &invitationUseCase{
+		orgs: &orgUseCases{
+			organizationRepository: o,
+			membershipRepository:   m,
+			transactor:             tx,
+			contextTimeout:         timeout,
+		},
+		invitationRepository: i,
+		users:                u,
+		passwords:            p,
+		mailer:               ml,
+		templates:            tpl,
+		tokens:               t,
+		link:                 link,
+		ttl:                  ttl,
+	}
+}
+
+func (a *invitationUseCase) Invite(c context.Context, orgID uuid.UUID, i *DTOs.Invitation) (*entity.Invitation, error) {
+	ctx, cancel := context.WithTimeout(models.AsSystem(models.WithOrgID(c, orgID)), a.orgs.contextTimeout)
+	defer cancel()
+
+	caller, err := a.orgs.authorize(ctx, orgID, entity.OrgRoleOwner, entity.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !mayGrant(ctx, caller, i.Role) {
+		return nil, models.ErrForbidden
+	}
+
+	userID, _ := models.UserIDFromContext(ctx)
+	res := &entity.Invitation{
+		ID:        uuid.New(),
+		OrgID:     orgID,
+		Email:     i.Email,
+		Role:      i.Role,
+		InvitedBy: userID,
+		ExpiresAt: time.Now().Add(a.ttl),
+	}
+	err = a.orgs.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := a.invitationRepository.Create(ctx, res); err != nil {
+			return err
+		}
+		return a.send(ctx, res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// send mails the link of the invitation.
+func (a *invitationUseCase) send(ctx context.Context, i *entity.Invitation) error {
+	o, err := a.orgs.organizationRepository.GetByID(ctx, i.OrgID)
+	if err != nil {
+		return err
+	}
+	token, err := a.tokens.SignAction(auth.PurposeInvitation, i.ID, i.OrgID, i.ExpiresAt, time.Now())
+	if err != nil {
+		return err
+	}
+	link, err := mail.LinkWithToken(a.link, token)
+	if err != nil {
+		return err
+	}
+	msg, err := a.templates.Render(mail.TemplateInvitation, i.Email,
+		mail.InvitationData{Organization: o, Role: i.Role, Link: link, ExpiresAt: i.ExpiresAt})
+	if err != nil {
+		return err
+	}
+	return a.mailer.Send(ctx, msg)
+}
+
+func (a *invitationUseCase) GetPending(c context.Context, orgID uuid.UUID, limit int, offset int) ([]*entity.Invitation, int, error) {
+	ctx, cancel := context.WithTimeout(models.AsSystem(models.WithOrgID(c, orgID)), a.orgs.contextTimeout)
+	defer cancel()
+
+	if _, err := a.orgs.authorize(ctx, orgID, entity.OrgRoleOwner, entity.OrgRoleAdmin); err != nil {
+		return nil, 0, err
+	}
+	return a.invitationRepository.GetPending(ctx, orgID, limit, offset)
+}
+
+// open loads an invitation of the organization that the caller may manage,
+// and checks that it is still open.
+func (a *invitationUseCase) open(ctx context.Context, orgID uuid.UUID, id uuid.UUID) (*entity.Invitation, error) {
+	caller, err := a.orgs.authorize(ctx, orgID, entity.OrgRoleOwner, entity.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	i, err := a.invitationRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if i.OrgID != orgID {
+		return nil, models.ErrNotFound
+	}
+	if !mayGrant(ctx, caller, i.Role) {
+		return nil, models.ErrForbidden
+	}
+	if i.AcceptedAt != nil || i.RevokedAt != nil {
+		return nil, models.ErrConflict
+	}
+	return i, nil
+}
+
+func (a *invitationUseCase) Resend(c context.Context, orgID uuid.UUID, id uuid.UUID) (*entity.Invitation, error) {
+	ctx, cancel := context.WithTimeout(models.AsSystem(models.WithOrgID(c, orgID)), a.orgs.contextTimeout)
+	defer cancel()
+
+	var res *entity.Invitation
+	err := a.orgs.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		_, err := a.open(ctx, orgID, id)
+		if err != nil {
+			return err
+		}
+		res, err = a.invitationRepository.Renew(ctx, id, time.Now().Add(a.ttl))
+		if err == models.ErrNotFound {
+			return models.ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+		return a.send(ctx, res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a *invitationUseCase) Revoke(c context.Context, orgID uuid.UUID, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(models.AsSystem(models.WithOrgID(c, orgID)), a.orgs.contextTimeout)
+	defer cancel()
+
+	return a.orgs.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if _, err := a.open(ctx, orgID, id); err != nil {
+			return err
+		}
+		err := a.invitationRepository.Revoke(ctx, id)
+		if err == models.ErrNotFound {
+			return models.ErrConflict
+		}
+		return err
+	})
+}
+
+// Accept acts for the system within the organization of the invitation,
+// whatever the one of the request, so that creating the user makes them a
+// member of it. The membership starts with the member role, which is then
+// changed to the invited one.
+func (a *invitationUseCase) Accept(c context.Context, token string, u *DTOs.User, password string) (*entity.User, error) {
+	id, orgID, err := a.tokens.ParseAction(auth.PurposeInvitation, token)
+	if err != nil {
+		return nil, err
+	}
+	if password == "" {
+		return nil, models.ErrPasswordTooShort
+	}
+
+	ctx, cancel := context.WithTimeout(models.AsSystem(models.WithOrgID(c, orgID)), a.orgs.contextTimeout)
+	defer cancel()
+
+	var res *entity.User
+	err = a.orgs.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		i, err := a.invitationRepository.GetByID(ctx, id)
+		if err == models.ErrNotFound {
+			return models.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		if i.OrgID != orgID || i.AcceptedAt != nil || i.RevokedAt != nil || time.Now().After(i.ExpiresAt) {
+			return models.ErrInvalidToken
+		}
+		if u.Email != "" && !strings.EqualFold(u.Email, i.Email) {
+			return models.ErrBadParamInput
+		}
+
+		created := *u
+		created.Email = i.Email
+		// the password of the invitation is set below, whatever User carries
+		created.Password = ""
+		res, err = a.users.Create(ctx, &created)
+		if err != nil {
+			return err
+		}
+		if err = a.passwords.SetPassword(ctx, res.ID, password); err != nil {
+			return err
+		}
+		if i.Role != entity.OrgRoleMember {
+			_, err = a.orgs.membershipRepository.SetRole(ctx, orgID, res.ID, i.Role)
+			if err != nil {
+				return err
+			}
+		}
+		err = a.invitationRepository.MarkAccepted(ctx, i.ID, res.ID)
+		if err == models.ErrNotFound {
+			return models.ErrInvalidToken
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,236 @@
+package usecase_test
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/mail"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/org"
+	"GoMastersTest/org/usecase"
+	"GoMastersTest/user"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeInvitations map[uuid.UUID]*entity.Invitation
+
+func (f fakeInvitations) Create(ctx context.Context, i *entity.Invitation) error {
+	for _, other := range f {
+		if other.OrgID == i.OrgID && strings.EqualFold(other.Email, i.Email) && other.AcceptedAt == nil && other.RevokedAt == nil {
+			return models.ErrConflict
+		}
+	}
+	i.Created = time.Now()
+	f[i.ID] = i
+	return nil
+}
+
+func (f fakeInvitations) GetByID(ctx context.Context, id uuid.UUID) (*entity.Invitation, error) {
+	i, ok := f[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	res := *i
+	return &res, nil
+}
+
+func (f fakeInvitations) GetPending(ctx context.Context, orgID uuid.UUID, limit int, offset int) ([]*entity.Invitation, int, error) {
+	res := make([]*entity.Invitation, 0)
+	for _, i := range f {
+		if i.OrgID == orgID && i.AcceptedAt == nil && i.RevokedAt == nil && time.Now().Before(i.ExpiresAt) {
+			res = append(res, i)
+		}
+	}
+	return res, len(res), nil
+}
+
+func (f fakeInvitations) Renew(ctx context.Context, id uuid.UUID, expiresAt time.Time) (*entity.Invitation, error) {
+	f[id].ExpiresAt = expiresAt
+	return f.GetByID(ctx, id)
+}
+
+func (f fakeInvitations) Revoke(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	f[id].RevokedAt = &now
+	return nil
+}
+
+func (f fakeInvitations) MarkAccepted(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	if f[id].AcceptedAt != nil {
+		return models.ErrNotFound
+	}
+	now := time.Now()
+	f[id].AcceptedAt, f[id].UserID = &now, &userID
+	return nil
+}
+
+// fakeUserUseCase creates users within the organization of the request, as
+// the user repository does.
+type fakeUserUseCase struct {
+	user.UseCase
+	memberships *fakeMemberships
+	created     []*entity.User
+}
+
+func (f *fakeUserUseCase) Create(ctx context.Context, u *DTOs.User) (*entity.User, error) {
+	res := &entity.User{ID: uuid.New(), User: *u, Status: entity.StatusPending}
+	if orgID, ok := models.OrgIDFromContext(ctx); ok {
+		f.memberships.memberships = append(f.memberships.memberships,
+			&entity.Membership{OrgID: orgID, UserID: res.ID, Role: entity.OrgRoleMember})
+	}
+	f.created = append(f.created, res)
+	return res, nil
+}
+
+type fakePasswords map[uuid.UUID]string
+
+func (f fakePasswords) SetPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	f[userID] = password
+	return nil
+}
+
+type fakeMailer struct {
+	sent []*mail.Message
+}
+
+func (f *fakeMailer) Send(ctx context.Context, m *mail.Message) error {
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+var invitationToken = regexp.MustCompile(`token=(\S+)`)
+
+func lastToken(t *testing.T, mailer *fakeMailer) string {
+	require.NotEmpty(t, mailer.sent)
+	match := invitationToken.FindStringSubmatch(mailer.sent[len(mailer.sent)-1].Body)
+	require.NotNil(t, match)
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+type invitationFixture struct {
+	owner, admin uuid.UUID
+	org          *entity.Organization
+	memberships  *fakeMemberships
+	users        *fakeUserUseCase
+	passwords    fakePasswords
+	mailer       *fakeMailer
+	tokens       *auth.TokenIssuer
+	invitations  org.InvitationUseCase
+}
+
+func newInvitationFixture(t *testing.T, ttl time.Duration) *invitationFixture {
+	orgs := &fakeOrgs{orgs: make(map[uuid.UUID]*entity.Organization)}
+	f := &invitationFixture{owner: uuid.New(), admin: uuid.New(), memberships: new(fakeMemberships), mailer: new(fakeMailer)}
+	f.users = &fakeUserUseCase{memberships: f.memberships}
+	f.passwords = make(fakePasswords)
+
+	var err error
+	f.org, err = usecase.NewOrgUseCase(orgs, f.memberships, noTransactor{}, time.Second).
+		Create(as(f.owner), &DTOs.Organization{Name: "Acme"})
+	require.NoError(t, err)
+	require.NoError(t, f.memberships.Add(context.Background(),
+		&entity.Membership{OrgID: f.org.ID, UserID: f.admin, Role: entity.OrgRoleAdmin}))
+
+	templates, err := mail.LoadTemplates("")
+	require.NoError(t, err)
+	f.tokens, err = auth.NewTokenIssuer(strings.Repeat("k", 32), "test", time.Minute, time.Hour)
+	require.NoError(t, err)
+	f.invitations = usecase.NewInvitationUseCase(orgs, f.memberships, make(fakeInvitations), f.users, f.passwords, f.mailer, templates,
+		f.tokens, "http://localhost/accept-invitation", ttl, noTransactor{}, time.Second)
+	return f
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	f := newInvitationFixture(t, time.Hour)
+
+	inv, err := f.invitations.Invite(as(f.admin), f.org.ID, &DTOs.Invitation{Email: "igor@example.com", Role: entity.OrgRoleAdmin})
+	require.NoError(t, err)
+	assert.Equal(t, f.admin, inv.InvitedBy)
+	require.Len(t, f.mailer.sent, 1)
+	assert.Equal(t, "igor@example.com", f.mailer.sent[0].To)
+	assert.Equal(t, "You are invited to join Acme", f.mailer.sent[0].Subject)
+	token := lastToken(t, f.mailer)
+
+	pending, total, err := f.invitations.GetPending(as(f.admin), f.org.ID, 20, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, inv.ID, pending[0].ID)
+
+	_, err = f.invitations.Accept(context.Background(), token, &DTOs.User{Firstname: "Igor", Email: "other@example.com"},
+		"a brand new passphrase")
+	assert.Equal(t, models.ErrBadParamInput, err, "invitations are for the invited email")
+	_, err = f.invitations.Accept(context.Background(), token, &DTOs.User{Firstname: "Igor"}, "")
+	assert.Equal(t, models.ErrPasswordTooShort, err)
+
+	res, err := f.invitations.Accept(context.Background(), token, &DTOs.User{Firstname: "Igor"}, "a brand new passphrase")
+	require.NoError(t, err)
+	assert.Equal(t, "igor@example.com", res.Email)
+	assert.Equal(t, "a brand new passphrase", f.passwords[res.ID])
+	m, err := f.memberships.Get(context.Background(), f.org.ID, res.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.OrgRoleAdmin, m.Role)
+
+	_, err = f.invitations.Accept(context.Background(), token, &DTOs.User{Firstname: "Igor"}, "a brand new passphrase")
+	assert.Equal(t, models.ErrInvalidToken, err, "invitations work once")
+	assert.Len(t, f.users.created, 1)
+	_, err = f.invitations.Resend(as(f.admin), f.org.ID, inv.ID)
+	assert.Equal(t, models.ErrConflict, err)
+}
+
+func TestManageInvitations(t *testing.T) {
+	f := newInvitationFixture(t, time.Hour)
+	outsider := uuid.New()
+
+	_, err := f.invitations.Invite(as(f.admin), f.org.ID, &DTOs.Invitation{Email: "boss@example.com", Role: entity.OrgRoleOwner})
+	assert.Equal(t, models.ErrForbidden, err, "only owners invite owners")
+	_, err = f.invitations.Invite(as(outsider), f.org.ID, &DTOs.Invitation{Email: "igor@example.com", Role: entity.OrgRoleMember})
+	assert.Equal(t, models.ErrNotFound, err)
+
+	inv, err := f.invitations.Invite(as(f.owner), f.org.ID, &DTOs.Invitation{Email: "igor@example.com", Role: entity.OrgRoleMember})
+	require.NoError(t, err)
+	_, err = f.invitations.Invite(as(f.owner), f.org.ID, &DTOs.Invitation{Email: "IGOR@example.com", Role: entity.OrgRoleMember})
+	assert.Equal(t, models.ErrConflict, err)
+
+	resent, err := f.invitations.Resend(as(f.admin), f.org.ID, inv.ID)
+	require.NoError(t, err)
+	assert.False(t, resent.ExpiresAt.Before(inv.ExpiresAt))
+	require.Len(t, f.mailer.sent, 2)
+	token := lastToken(t, f.mailer)
+
+	_, err = f.invitations.Resend(as(f.admin), uuid.New(), inv.ID)
+	assert.Equal(t, models.ErrNotFound, err)
+	require.NoError(t, f.invitations.Revoke(as(f.admin), f.org.ID, inv.ID))
+	assert.Equal(t, models.ErrConflict, f.invitations.Revoke(as(f.admin), f.org.ID, inv.ID))
+
+	_, total, err := f.invitations.GetPending(as(f.owner), f.org.ID, 20, 0)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	_, err = f.invitations.Accept(context.Background(), token, &DTOs.User{Firstname: "Igor"}, "a brand new passphrase")
+	assert.Equal(t, models.ErrInvalidToken, err, "revoked invitations cannot be accepted")
+}
+
+func TestInvitationTokens(t *testing.T) {
+	f := newInvitationFixture(t, -time.Second)
+	u := &DTOs.User{Firstname: "Igor"}
+
+	_, err := f.invitations.Invite(as(f.owner), f.org.ID, &DTOs.Invitation{Email: "igor@example.com", Role: entity.OrgRoleMember})
+	require.NoError(t, err)
+	_, err = f.invitations.Accept(context.Background(), lastToken(t, f.mailer), u, "a brand new passphrase")
+	assert.Equal(t, models.ErrInvalidToken, err, "invitations expire")
+
+	forged, err := f.tokens.SignAction(auth.PurposeVerifyEmail, uuid.New(), f.org.ID, time.Now().Add(time.Hour), time.Now())
+	require.NoError(t, err)
+	_, err = f.invitations.Accept(context.Background(), forged, u, "a brand new passphrase")
+	assert.Equal(t, models.ErrInvalidToken, err)
+	assert.Empty(t, f.users.created)
+}
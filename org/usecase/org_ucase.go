@@ -0,0 +1,292 @@
+package usecase
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/org"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type orgUseCases struct {
+	organizationRepository org.OrganizationRepository
+	membershipRepository   org.MembershipRepository
+	transactor             dbutil.Transactor
+	contextTimeout         time.Duration
+}
+
+func NewOrgUseCase(o org.OrganizationRepository, m org.MembershipRepository, tx dbutil.Transactor, timeout time.Duration) org.UseCase {
+	return &orgUseCases{
+		organizationRepository: o,
+		membershipRepository:   m,
+		transactor:             tx,
+		contextTimeout:         timeout,
+	}
+}
+
+// isOperator reports whether the caller manages every organization.
+func isOperator(ctx context.Context) bool {
+	return models.HasRole(ctx, auth.RoleAdmin)
+}
+
+// authorize checks that the caller may act on the organization with one of
+// roles, any role when there is none. Non-members get ErrNotFound, keeping
+// other organizations hidden; members without the roles get ErrForbidden.
+// The membership of the caller is nil for operators outside the organization.
+func (a *orgUseCases) authorize(ctx context.Context, orgID uuid.UUID, roles ...string) (*entity.Membership, error) {
+	userID, ok := models.UserIDFromContext(ctx)
+	if !ok {
+		return nil, models.ErrUnauthorized
+	}
+	m, err := a.membershipRepository.Get(ctx, orgID, userID)
+	if err == models.ErrNotFound && isOperator(ctx) {
+		_, err = a.organizationRepository.GetByID(ctx, orgID)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isOperator(ctx) || len(roles) == 0 {
+		return m, nil
+	}
+	for _, r := range roles {
+		if m.Role == r {
+			return m, nil
+		}
+	}
+	return nil, models.ErrForbidden
+}
+
+func (a *orgUseCases) GetAll(c context.Context, limit int, offset int) ([]*entity.Organization, int, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	if isOperator(ctx) {
+		return a.organizationRepository.GetAll(ctx, limit, offset)
+	}
+	userID, ok := models.UserIDFromContext(ctx)
+	if !ok {
+		return nil, 0, models.ErrUnauthorized
+	}
+	return a.organizationRepository.GetByUserID(ctx, userID, limit, offset)
+}
+
+func (a *orgUseCases) GetByID(c context.Context, id uuid.UUID) (*entity.Organization, error) {
+	ctx, cancel := context.WithTimeout(models.WithOrgID(c, id), a.contextTimeout)
+	defer cancel()
+
+	if _, err := a.authorize(ctx, id); err != nil {
+		return nil, err
+	}
+	return a.organizationRepository.GetByID(ctx, id)
+}
+
+func (a *orgUseCases) Create(c context.Context, o *DTOs.Organization) (*entity.Organization, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	userID, ok := models.UserIDFromContext(ctx)
+	if !ok {
+		return nil, models.ErrUnauthorized
+	}
+
+	// the caller joins an organization other than the one of the request,
+	// which only the system may do
+	var res *entity.Organization
+	err := a.transactor.WithinTransaction(models.AsSystem(ctx), func(ctx context.Context) error {
+		var err error
+		res, err = a.organizationRepository.Create(ctx, o)
+		if err != nil {
+			return err
+		}
+		return a.membershipRepository.Add(ctx, &entity.Membership{OrgID: res.ID, UserID: userID, Role: entity.OrgRoleOwner})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a *orgUseCases) Update(c context.Context, id uuid.UUID, o *DTOs.Organization) (*entity.Organization, error) {
+	ctx, cancel := context.WithTimeout(models.WithOrgID(c, id), a.contextTimeout)
+	defer cancel()
+
+	if _, err := a.authorize(ctx, id, entity.OrgRoleOwner, entity.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.organizationRepository.Update(ctx, id, o)
+}
+
+func (a *orgUseCases) Delete(c context.Context, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(models.WithOrgID(c, id), a.contextTimeout)
+	defer cancel()
+
+	if _, err := a.authorize(ctx, id, entity.OrgRoleOwner); err != nil {
+		return err
+	}
+	return a.organizationRepository.Delete(ctx, id)
+}
+
+func (a *orgUseCases) GetMembers(c context.Context, orgID uuid.UUID, limit int, offset int) ([]*entity.OrgMember, int, error) {
+	ctx, cancel := context.WithTimeout(models.WithOrgID(c, orgID), a.contextTimeout)
+	defer cancel()
+
+	if _, err := a.authorize(ctx, orgID); err != nil {
+		return nil, 0, err
+	}
+	return a.membershipRepository.GetMembers(ctx, orgID, limit, offset)
+}
+
+// AddMember lets admins of the organization add members and admins; only
+// owners add owners.
+func (a *orgUseCases) AddMember(c context.Context, orgID uuid.UUID, m *DTOs.Membership) (*entity.Membership, error) {
+	ctx, cancel := context.WithTimeout(models.WithOrgID(c, orgID), a.contextTimeout)
+	defer cancel()
+
+	caller, err := a.authorize(ctx, orgID, entity.OrgRoleOwner, entity.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !mayGrant(ctx, caller, m.Role) {
+		return nil, models.ErrForbidden
+	}
+
+	res := &entity.Membership{OrgID: orgID, UserID: m.UserID, Role: m.Role}
+	if err = a.membershipRepository.Add(ctx, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a *orgUseCases) SetMemberRole(c context.Context, orgID uuid.UUID, userID uuid.UUID, role string) (*entity.Membership, error) {
+	ctx, cancel := context.WithTimeout(models.WithOrgID(c, orgID), a.contextTimeout)
+	defer cancel()
+
+	var res *entity.Membership
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		current, err := a.changeMember(ctx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		if !mayGrant(ctx, current.caller, role) {
+			return models.ErrForbidden
+		}
+		if current.member.Role == entity.OrgRoleOwner && role != entity.OrgRoleOwner {
+			if err = a.keepOwner(ctx, orgID); err != nil {
+				return err
+			}
+		}
+		res, err = a.membershipRepository.SetRole(ctx, orgID, userID, role)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RemoveMember also lets members leave the organization by themselves.
+func (a *orgUseCases) RemoveMember(c context.Context, orgID uuid.UUID, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(models.WithOrgID(c, orgID), a.contextTimeout)
+	defer cancel()
+
+	return a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var member *entity.Membership
+		if callerID, _ := models.UserIDFromContext(ctx); callerID == userID {
+			m, err := a.authorize(ctx, orgID)
+			if err != nil {
+				return err
+			}
+			member = m
+		} else {
+			current, err := a.changeMember(ctx, orgID, userID)
+			if err != nil {
+				return err
+			}
+			member = current.member
+		}
+		if member != nil && member.Role == entity.OrgRoleOwner {
+			if err := a.keepOwner(ctx, orgID); err != nil {
+				return err
+			}
+		}
+		return a.membershipRepository.Remove(ctx, orgID, userID)
+	})
+}
+
+type memberChange struct {
+	caller *entity.Membership
+	member *entity.Membership
+}
+
+// changeMember checks that the caller may change the membership of the user,
+// and loads both. Only owners change owners.
+func (a *orgUseCases) changeMember(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) (*memberChange, error) {
+	caller, err := a.authorize(ctx, orgID, entity.OrgRoleOwner, entity.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	member, err := a.membershipRepository.Get(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !mayGrant(ctx, caller, member.Role) {
+		return nil, models.ErrForbidden
+	}
+	return &memberChange{caller: caller, member: member}, nil
+}
+
+// mayGrant reports whether the caller, with their membership, may hand out
+// or take away role.
+func mayGrant(ctx context.Context, caller *entity.Membership, role string) bool {
+	return isOperator(ctx) || role != entity.OrgRoleOwner || caller.Role == entity.OrgRoleOwner
+}
+
+// keepOwner fails with ErrLastOwner when the organization has a single owner
+// left, who is about to go.
+func (a *orgUseCases) keepOwner(ctx context.Context, orgID uuid.UUID) error {
+	owners, err := a.membershipRepository.CountOwners(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return models.ErrLastOwner
+	}
+	return nil
+}
+
+func (a *orgUseCases) Tenant(c context.Context, requested uuid.UUID) (uuid.UUID, bool, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	userID, ok := models.UserIDFromContext(ctx)
+	if !ok {
+		return uuid.Nil, false, nil
+	}
+	if requested != uuid.Nil {
+		_, err := a.authorize(ctx, requested)
+		if err == models.ErrNotFound {
+			return uuid.Nil, false, models.ErrForbidden
+		}
+		if err != nil {
+			return uuid.Nil, false, err
+		}
+		return requested, true, nil
+	}
+	if isOperator(ctx) {
+		return uuid.Nil, false, nil
+	}
+
+	memberships, err := a.membershipRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	if len(memberships) == 0 {
+		return uuid.Nil, true, nil
+	}
+	return memberships[0].OrgID, true, nil
+}
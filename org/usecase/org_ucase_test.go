@@ -0,0 +1,199 @@
+package usecase_test
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/org"
+	"GoMastersTest/org/usecase"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type fakeOrgs struct {
+	org.OrganizationRepository
+	orgs map[uuid.UUID]*entity.Organization
+}
+
+func (f *fakeOrgs) GetByID(ctx context.Context, id uuid.UUID) (*entity.Organization, error) {
+	o, ok := f.orgs[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	return o, nil
+}
+
+func (f *fakeOrgs) Create(ctx context.Context, o *DTOs.Organization) (*entity.Organization, error) {
+	res := &entity.Organization{ID: uuid.New(), Name: o.Name, Created: time.Now()}
+	f.orgs[res.ID] = res
+	return res, nil
+}
+
+type fakeMemberships struct {
+	org.MembershipRepository
+	memberships []*entity.Membership
+}
+
+func (f *fakeMemberships) Get(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) (*entity.Membership, error) {
+	for _, m := range f.memberships {
+		if m.OrgID == orgID && m.UserID == userID {
+			return m, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (f *fakeMemberships) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*entity.Membership, error) {
+	res := make([]*entity.Membership, 0)
+	for _, m := range f.memberships {
+		if m.UserID == userID {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeMemberships) CountOwners(ctx context.Context, orgID uuid.UUID) (int, error) {
+	count := 0
+	for _, m := range f.memberships {
+		if m.OrgID == orgID && m.Role == entity.OrgRoleOwner {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeMemberships) Add(ctx context.Context, m *entity.Membership) error {
+	if _, err := f.Get(ctx, m.OrgID, m.UserID); err == nil {
+		return models.ErrConflict
+	}
+	m.Created = time.Now()
+	f.memberships = append(f.memberships, m)
+	return nil
+}
+
+func (f *fakeMemberships) SetRole(ctx context.Context, orgID uuid.UUID, userID uuid.UUID, role string) (*entity.Membership, error) {
+	m, err := f.Get(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	m.Role = role
+	return m, nil
+}
+
+func (f *fakeMemberships) Remove(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) error {
+	for i, m := range f.memberships {
+		if m.OrgID == orgID && m.UserID == userID {
+			f.memberships = append(f.memberships[:i], f.memberships[i+1:]...)
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+type noTransactor struct{}
+
+func (noTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// as returns the context of a request by the user, with roles.
+func as(userID uuid.UUID, roles ...string) context.Context {
+	return models.WithRoles(models.WithUserID(context.Background(), userID), roles)
+}
+
+func newOrgUseCase() (org.UseCase, *fakeMemberships) {
+	memberships := new(fakeMemberships)
+	return usecase.NewOrgUseCase(&fakeOrgs{orgs: make(map[uuid.UUID]*entity.Organization)}, memberships,
+		noTransactor{}, time.Second), memberships
+}
+
+func TestOrganizationRoles(t *testing.T) {
+	uc, _ := newOrgUseCase()
+	owner, admin, member, outsider := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	acme, err := uc.Create(as(owner), &DTOs.Organization{Name: "Acme"})
+	require.NoError(t, err)
+	_, err = uc.AddMember(as(owner), acme.ID, &DTOs.Membership{UserID: admin, Role: entity.OrgRoleAdmin})
+	require.NoError(t, err)
+	_, err = uc.AddMember(as(admin), acme.ID, &DTOs.Membership{UserID: member, Role: entity.OrgRoleMember})
+	require.NoError(t, err)
+
+	_, err = uc.AddMember(as(admin), acme.ID, &DTOs.Membership{UserID: outsider, Role: entity.OrgRoleOwner})
+	assert.Equal(t, models.ErrForbidden, err, "only owners add owners")
+	_, err = uc.AddMember(as(member), acme.ID, &DTOs.Membership{UserID: outsider, Role: entity.OrgRoleMember})
+	assert.Equal(t, models.ErrForbidden, err)
+	_, err = uc.AddMember(as(owner), acme.ID, &DTOs.Membership{UserID: member, Role: entity.OrgRoleMember})
+	assert.Equal(t, models.ErrConflict, err)
+
+	_, err = uc.GetByID(as(member), acme.ID)
+	assert.NoError(t, err)
+	_, err = uc.GetByID(as(outsider), acme.ID)
+	assert.Equal(t, models.ErrNotFound, err, "other organizations stay hidden")
+	_, err = uc.GetByID(as(outsider, auth.RoleAdmin), acme.ID)
+	assert.NoError(t, err, "operators see every organization")
+
+	_, err = uc.SetMemberRole(as(admin), acme.ID, owner, entity.OrgRoleMember)
+	assert.Equal(t, models.ErrForbidden, err, "only owners change owners")
+	assert.Equal(t, models.ErrForbidden, uc.Delete(as(admin), acme.ID))
+}
+
+func TestOrganizationKeepsAnOwner(t *testing.T) {
+	uc, _ := newOrgUseCase()
+	owner, other := uuid.New(), uuid.New()
+
+	acme, err := uc.Create(as(owner), &DTOs.Organization{Name: "Acme"})
+	require.NoError(t, err)
+
+	_, err = uc.SetMemberRole(as(owner), acme.ID, owner, entity.OrgRoleAdmin)
+	assert.Equal(t, models.ErrLastOwner, err)
+	assert.Equal(t, models.ErrLastOwner, uc.RemoveMember(as(owner), acme.ID, owner))
+
+	_, err = uc.AddMember(as(owner), acme.ID, &DTOs.Membership{UserID: other, Role: entity.OrgRoleOwner})
+	require.NoError(t, err)
+	require.NoError(t, uc.RemoveMember(as(owner), acme.ID, owner), "owners leave once another remains")
+	assert.Equal(t, models.ErrLastOwner, uc.RemoveMember(as(other), acme.ID, other))
+}
+
+func TestTenant(t *testing.T) {
+	uc, _ := newOrgUseCase()
+	user, loner := uuid.New(), uuid.New()
+
+	first, err := uc.Create(as(user), &DTOs.Organization{Name: "First"})
+	require.NoError(t, err)
+	second, err := uc.Create(as(user), &DTOs.Organization{Name: "Second"})
+	require.NoError(t, err)
+
+	_, scoped, err := uc.Tenant(context.Background(), uuid.Nil)
+	require.NoError(t, err)
+	assert.False(t, scoped, "anonymous requests are not scoped")
+
+	orgID, scoped, err := uc.Tenant(as(user), uuid.Nil)
+	require.NoError(t, err)
+	assert.True(t, scoped)
+	assert.Equal(t, first.ID, orgID, "users default to their oldest organization")
+
+	orgID, _, err = uc.Tenant(as(user), second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, orgID)
+
+	_, _, err = uc.Tenant(as(loner), first.ID)
+	assert.Equal(t, models.ErrForbidden, err)
+	orgID, scoped, err = uc.Tenant(as(loner), uuid.Nil)
+	require.NoError(t, err)
+	assert.True(t, scoped)
+	assert.Equal(t, uuid.Nil, orgID, "users without organizations reach no one else")
+
+	_, scoped, err = uc.Tenant(as(loner, auth.RoleAdmin), uuid.Nil)
+	require.NoError(t, err)
+	assert.False(t, scoped, "operators are not scoped unless they ask")
+	orgID, scoped, err = uc.Tenant(as(loner, auth.RoleAdmin), first.ID)
+	require.NoError(t, err)
+	assert.True(t, scoped)
+	assert.Equal(t, first.ID, orgID)
+}
@@ -0,0 +1,16 @@
+package retry
+
+import "time"
+
+// Backoff returns the delay before the given retry attempt, starting at min
+// for the first attempt and doubling each time up to max.
+func Backoff(attempt int, min time.Duration, max time.Duration) time.Duration {
+	d := min
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
@@ -0,0 +1,179 @@
+package graphql
+
+import (
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// Request is a GraphQL request as sent by POST /graphql.
+type Request struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type GraphQLHandler struct {
+	Usecase       user.UseCase
+	MaxDepth      int
+	MaxComplexity int
+	schema        graphql.Schema
+}
+
+// NewGraphQLHandler serves the user schema on /api/v1/graphql, guarded by
+// requireTenant, and GraphiQL on GET /api/v1/graphql when playground is set.
+func NewGraphQLHandler(r *gin.Engine, us user.UseCase, maxDepth int, maxComplexity int, playground bool,
+	requireTenant gin.HandlerFunc) error {
+	schema, err := newSchema(us)
+	if err != nil {
+		return err
+	}
+	handler := &GraphQLHandler{
+		Usecase:       us,
+		MaxDepth:      maxDepth,
+		MaxComplexity: maxComplexity,
+		schema:        schema,
+	}
+	v1 := r.Group("/api/v1")
+	v1.POST("/graphql", requireTenant, handler.Query)
+	if playground {
+		v1.GET("/graphql", handler.Playground)
+	}
+	return nil
+}
+
+// Query godoc
+// @Summary      GraphQL
+// @Description  Run a GraphQL query or mutation against the user schema. Errors carry the REST status code in extensions.code.
+// @Tags         GraphQL
+// @Accept       json
+// @Produce      json
+// @Param        Request  body     Request  true  "GraphQL request"
+// @Success      200  {object}  object
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /graphql [post]
+func (a *GraphQLHandler) Query(c *gin.Context) {
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.checkLimits(&req); err != nil {
+		c.JSON(http.StatusOK, &graphql.Result{Errors: withCodes(gqlerrors.FormatErrors(err))})
+		return
+	}
+
+	ctx := withLoader(c.Request.Context(), newUserLoader(a.Usecase))
+	res := graphql.Do(graphql.Params{
+		Schema:         a.schema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        ctx,
+	})
+	res.Errors = withCodes(res.Errors)
+	c.JSON(http.StatusOK, res)
+}
+
+// checkLimits rejects queries deeper or costlier than configured. Syntax
+// errors are left to graphql.Do to report.
+func (a *GraphQLHandler) checkLimits(req *Request) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		return nil
+	}
+	depth, complexity := measure(doc, req.Variables)
+	if a.MaxDepth > 0 && depth > a.MaxDepth {
+		return ErrQueryTooDeep
+	}
+	if a.MaxComplexity > 0 && complexity > a.MaxComplexity {
+		return ErrQueryTooComplex
+	}
+	return nil
+}
+
+// Playground serves GraphiQL.
+func (a *GraphQLHandler) Playground(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(playgroundHTML))
+}
+
+// withCodes sets extensions.code of every error to the status the REST API
+// answers for the same failure. Errors without a resolver cause, such as
+// syntax or validation errors, are bad requests.
+func withCodes(errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	for i, e := range errs {
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]interface{})
+		}
+		code := http.StatusBadRequest
+		if err := cause(e); err != nil {
+			code = getStatusCode(err)
+		}
+		e.Extensions["code"] = code
+		errs[i] = e
+	}
+	return errs
+}
+
+// cause unwraps the errors graphql-go wraps resolver errors in. It returns
+// nil for errors raised by graphql-go itself.
+func cause(err error) error {
+	for {
+		switch e := err.(type) {
+		case gqlerrors.FormattedError:
+			err = e.OriginalError()
+		case *gqlerrors.Error:
+			err = e.OriginalError
+		default:
+			return err
+		}
+		if err == nil {
+			return nil
+		}
+	}
+}
+
+func getStatusCode(err error) int {
+	switch err {
+	case models.ErrEmailValid, models.ErrInvalidToken, ErrQueryTooDeep, ErrQueryTooComplex:
+		logrus.Error(err)
+		return http.StatusBadRequest
+	case models.ErrEmailVerified, models.ErrInvalidTransition:
+		logrus.Error(err)
+		return http.StatusConflict
+	}
+	if _, ok := err.(validator.ValidationErrors); ok {
+		return http.StatusBadRequest
+	}
+	return httputil.StatusCode(err)
+}
+
+const playgroundHTML = `<!DOCTYPE html>
+<html>
+<head>
+  <title>GraphiQL</title>
+  <link rel="stylesheet" href="https://unpkg.com/graphiql@2/graphiql.min.css" />
+</head>
+<body style="margin: 0;">
+  <div id="graphiql" style="height: 100vh;"></div>
+  <script crossorigin src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/graphiql@2/graphiql.min.js"></script>
+  <script>
+    const fetcher = GraphiQL.createFetcher({ url: window.location.pathname });
+    ReactDOM.createRoot(document.getElementById('graphiql')).render(React.createElement(GraphiQL, { fetcher }));
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,199 @@
+package graphql_test
+
+import (
+	"GoMastersTest/middleware"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	userGraphql "GoMastersTest/user/delivery/graphql"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUseCase keeps users in memory and counts lookups. Methods the schema
+// does not call are left to the embedded nil interface.
+type fakeUseCase struct {
+	user.UseCase
+	users     map[uuid.UUID]*entity.User
+	lookups   [][]uuid.UUID
+	updateErr error
+}
+
+func (f *fakeUseCase) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*entity.User, error) {
+	f.lookups = append(f.lookups, ids)
+	res := make([]*entity.User, len(ids))
+	for i, id := range ids {
+		res[i] = f.users[id]
+	}
+	return res, nil
+}
+
+func (f *fakeUseCase) GetAllUsers(ctx context.Context, filter *DTOs.UserFilter) ([]*entity.User, error) {
+	res := make([]*entity.User, 0)
+	for _, u := range f.users {
+		res = append(res, u)
+	}
+	if filter.Limit > 0 && len(res) > filter.Limit {
+		res = res[:filter.Limit]
+	}
+	return res, nil
+}
+
+func (f *fakeUseCase) Create(ctx context.Context, u *DTOs.User) (*entity.User, error) {
+	res := &entity.User{User: *u, ID: uuid.New(), Created: time.Now(), Updated: time.Now()}
+	f.users[res.ID] = res
+	return res, nil
+}
+
+func (f *fakeUseCase) Update(ctx context.Context, id uuid.UUID, u *DTOs.User) (*entity.User, error) {
+	return nil, f.updateErr
+}
+
+type response struct {
+	Data   map[string]interface{}
+	Errors []struct {
+		Message    string
+		Extensions map[string]interface{}
+	}
+}
+
+// newServer serves the schema to a caller signed in to an organization.
+func newServer(t *testing.T, us user.UseCase, maxDepth int, maxComplexity int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		ctx := models.WithOrgID(models.WithUserID(c.Request.Context(), uuid.New()), uuid.New())
+		c.Request = c.Request.WithContext(ctx)
+	})
+	require.NoError(t, userGraphql.NewGraphQLHandler(r, us, maxDepth, maxComplexity, false,
+		middleware.InitMiddleware().RequireTenant()))
+	return r
+}
+
+func do(t *testing.T, r *gin.Engine, query string, variables map[string]interface{}) *response {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/graphql", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var res response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return &res
+}
+
+func TestBatchedLookups(t *testing.T) {
+	a := &entity.User{User: DTOs.User{Firstname: "Igor"}, ID: uuid.New()}
+	b := &entity.User{User: DTOs.User{Firstname: "Anna"}, ID: uuid.New()}
+	us := &fakeUseCase{users: map[uuid.UUID]*entity.User{a.ID: a, b.ID: b}}
+	r := newServer(t, us, 0, 0)
+
+	res := do(t, r, `query($a: ID!, $b: ID!) {
+		first: user(id: $a) { firstname }
+		again: user(id: $a) { id }
+		second: user(id: $b) { firstname }
+	}`, map[string]interface{}{"a": a.ID.String(), "b": b.ID.String()})
+
+	require.Empty(t, res.Errors)
+	assert.Equal(t, "Igor", res.Data["first"].(map[string]interface{})["firstname"])
+	assert.Equal(t, a.ID.String(), res.Data["again"].(map[string]interface{})["id"])
+	assert.Equal(t, "Anna", res.Data["second"].(map[string]interface{})["firstname"])
+	require.Len(t, us.lookups, 1)
+	assert.ElementsMatch(t, []uuid.UUID{a.ID, b.ID}, us.lookups[0])
+}
+
+func TestErrorCodes(t *testing.T) {
+	r := newServer(t, &fakeUseCase{users: map[uuid.UUID]*entity.User{}}, 0, 0)
+
+	cases := map[string]struct {
+		query string
+		code  float64
+	}{
+		"not found":     {`{ user(id: "` + uuid.NewString() + `") { id } }`, http.StatusNotFound},
+		"bad id":        {`{ user(id: "nope") { id } }`, http.StatusBadRequest},
+		"invalid email": {`mutation { createUser(input: {firstname: "a", lastname: "b", email: "nope", age: 1}) { id } }`, http.StatusBadRequest},
+		"unknown field": {`{ user(id: "x") { password } }`, http.StatusBadRequest},
+		"deleted users": {`{ users(includeDeleted: true) { items { id } } }`, http.StatusForbidden},
+		"delete":        {`mutation { deleteUser(id: "` + uuid.NewString() + `") }`, http.StatusForbidden},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res := do(t, r, tc.query, nil)
+			require.Len(t, res.Errors, 1)
+			assert.Equal(t, tc.code, res.Errors[0].Extensions["code"])
+		})
+	}
+}
+
+func TestUseCaseErrorCodes(t *testing.T) {
+	cases := map[error]float64{
+		models.ErrUnauthorized:        http.StatusUnauthorized,
+		models.ErrInvalidTransition:   http.StatusConflict,
+		models.ErrConflict:            http.StatusConflict,
+		models.ErrPasswordTooShort:    http.StatusBadRequest,
+		models.ErrInternalServerError: http.StatusInternalServerError,
+	}
+	for err, code := range cases {
+		t.Run(err.Error(), func(t *testing.T) {
+			r := newServer(t, &fakeUseCase{updateErr: err}, 0, 0)
+			res := do(t, r, `mutation { updateUser(id: "`+uuid.NewString()+`", input: {firstname: "a", lastname: "b", email: "a@example.com", age: 1}) { id } }`, nil)
+			require.Len(t, res.Errors, 1)
+			assert.Equal(t, code, res.Errors[0].Extensions["code"])
+		})
+	}
+}
+
+func TestMutationAndList(t *testing.T) {
+	r := newServer(t, &fakeUseCase{users: map[uuid.UUID]*entity.User{}}, 0, 0)
+
+	res := do(t, r, `mutation { createUser(input: {firstname: "Igor", lastname: "K", email: "igor@example.com", age: 30}) { id email } }`, nil)
+	require.Empty(t, res.Errors)
+	assert.Equal(t, "igor@example.com", res.Data["createUser"].(map[string]interface{})["email"])
+
+	res = do(t, r, `{ users(limit: 1) { items { email } hasMore } }`, nil)
+	require.Empty(t, res.Errors)
+	page := res.Data["users"].(map[string]interface{})
+	assert.Len(t, page["items"], 1)
+	assert.Equal(t, false, page["hasMore"])
+}
+
+func TestLimits(t *testing.T) {
+	r := newServer(t, &fakeUseCase{users: map[uuid.UUID]*entity.User{}}, 3, 40)
+
+	res := do(t, r, `{ users { items { id } } }`, nil)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, userGraphql.ErrQueryTooComplex.Error(), res.Errors[0].Message)
+
+	res = do(t, r, `query($n: Int) { users(limit: $n) { items { id } } }`, map[string]interface{}{"n": 10})
+	assert.Empty(t, res.Errors)
+
+	res = do(t, r, `{ users(limit: 1) { ...page } } fragment page on UserPage { items { id } }`, nil)
+	assert.Empty(t, res.Errors)
+
+	r = newServer(t, &fakeUseCase{users: map[uuid.UUID]*entity.User{}}, 2, 0)
+	res = do(t, r, `{ users(limit: 1) { items { id } } }`, nil)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, userGraphql.ErrQueryTooDeep.Error(), res.Errors[0].Message)
+	assert.Equal(t, float64(http.StatusBadRequest), res.Errors[0].Extensions["code"])
+}
+
+func TestRequiresCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	require.NoError(t, userGraphql.NewGraphQLHandler(r, &fakeUseCase{}, 0, 0, false, middleware.InitMiddleware().RequireTenant()))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/graphql", bytes.NewReader([]byte(`{"query":"{ users { id } }"}`))))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
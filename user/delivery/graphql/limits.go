@@ -0,0 +1,106 @@
+package graphql
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+var (
+	ErrQueryTooDeep    = errors.New("Query is nested too deeply")
+	ErrQueryTooComplex = errors.New("Query is too complex")
+)
+
+// measure returns the depth and complexity of the operations in doc. Every
+// field costs one, and the selections under a field taking a limit argument
+// count once per item it may return. Introspection fields are free so that
+// GraphiQL keeps working.
+func measure(doc *ast.Document, variables map[string]interface{}) (depth int, complexity int) {
+	m := &measurer{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		visiting:  make(map[string]bool),
+	}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			m.fragments[f.Name.Value] = f
+		}
+	}
+	for _, def := range doc.Definitions {
+		if op, ok := def.(*ast.OperationDefinition); ok {
+			d, c := m.selectionSet(op.SelectionSet)
+			if d > depth {
+				depth = d
+			}
+			complexity += c
+		}
+	}
+	return depth, complexity
+}
+
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	// visiting guards against fragment cycles, which validation rejects later
+	visiting map[string]bool
+}
+
+func (m *measurer) selectionSet(set *ast.SelectionSet) (depth int, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, selection := range set.Selections {
+		var d, c int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+			d, c = m.selectionSet(s.SelectionSet)
+			d, c = d+1, 1+c*m.multiplier(s)
+		case *ast.InlineFragment:
+			d, c = m.selectionSet(s.SelectionSet)
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			f, ok := m.fragments[name]
+			if !ok || m.visiting[name] {
+				continue
+			}
+			m.visiting[name] = true
+			d, c = m.selectionSet(f.SelectionSet)
+			m.visiting[name] = false
+		}
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+	}
+	return depth, complexity
+}
+
+// multiplier is the number of items a field may return, from its limit
+// argument.
+func (m *measurer) multiplier(f *ast.Field) int {
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			if n, ok := m.variables[v.Name.Value].(float64); ok && n > 0 {
+				return int(n)
+			}
+		}
+		return maxPageLimit
+	}
+	if f.Name.Value == "users" {
+		return defaultPageLimit
+	}
+	return 1
+}
@@ -0,0 +1,86 @@
+package graphql
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type loaderKey struct{}
+
+type loadResult struct {
+	user *entity.User
+	err  error
+}
+
+// userLoader collects the user lookups of one request and resolves them with
+// a single GetByIDs the first time one of their results is needed, so sibling
+// fields such as aliased user lookups cost one query instead of one each.
+// Results are cached for the rest of the request.
+type userLoader struct {
+	usecase user.UseCase
+
+	mu      sync.Mutex
+	pending []uuid.UUID
+	results map[uuid.UUID]*loadResult
+}
+
+func newUserLoader(us user.UseCase) *userLoader {
+	return &userLoader{
+		usecase: us,
+		results: make(map[uuid.UUID]*loadResult),
+	}
+}
+
+func withLoader(ctx context.Context, l *userLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, l)
+}
+
+func loaderFromContext(ctx context.Context) *userLoader {
+	return ctx.Value(loaderKey{}).(*userLoader)
+}
+
+// Load queues id and returns a thunk that the executor calls once every
+// sibling field has been resolved.
+func (l *userLoader) Load(ctx context.Context, id uuid.UUID) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, ok := l.results[id]; !ok {
+		l.results[id] = nil
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.dispatch(ctx)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		r := l.results[id]
+		return r.user, r.err
+	}
+}
+
+func (l *userLoader) dispatch(ctx context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.pending) == 0 {
+		return
+	}
+	ids := l.pending
+	l.pending = nil
+
+	users, err := l.usecase.GetByIDs(ctx, ids)
+	for i, id := range ids {
+		switch {
+		case err != nil:
+			l.results[id] = &loadResult{err: err}
+		case users[i] == nil:
+			l.results[id] = &loadResult{err: models.ErrNotFound}
+		default:
+			l.results[id] = &loadResult{user: users[i]}
+		}
+	}
+}
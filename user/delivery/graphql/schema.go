@@ -0,0 +1,252 @@
+package graphql
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// userPage is one page of the users query.
+type userPage struct {
+	Items   []*entity.User
+	Limit   int
+	Offset  int
+	HasMore bool
+}
+
+var userStatusType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "UserStatus",
+	Values: graphql.EnumValueConfigMap{
+		"PENDING":     &graphql.EnumValueConfig{Value: entity.StatusPending},
+		"ACTIVE":      &graphql.EnumValueConfig{Value: entity.StatusActive},
+		"SUSPENDED":   &graphql.EnumValueConfig{Value: entity.StatusSuspended},
+		"DEACTIVATED": &graphql.EnumValueConfig{Value: entity.StatusDeactivated},
+	},
+})
+
+var userType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "User",
+	Fields: graphql.Fields{
+		"id":        userField(graphql.NewNonNull(graphql.ID), func(u *entity.User) interface{} { return u.ID.String() }),
+		"firstname": userField(graphql.NewNonNull(graphql.String), func(u *entity.User) interface{} { return u.Firstname }),
+		"lastname":  userField(graphql.NewNonNull(graphql.String), func(u *entity.User) interface{} { return u.Lastname }),
+		"email":     userField(graphql.NewNonNull(graphql.String), func(u *entity.User) interface{} { return u.Email }),
+		"age":       userField(graphql.NewNonNull(graphql.Int), func(u *entity.User) interface{} { return int(u.Age) }),
+		"status":    userField(graphql.NewNonNull(userStatusType), func(u *entity.User) interface{} { return u.Status }),
+		"created":   userField(graphql.NewNonNull(graphql.DateTime), func(u *entity.User) interface{} { return u.Created }),
+		"updated":   userField(graphql.NewNonNull(graphql.DateTime), func(u *entity.User) interface{} { return u.Updated }),
+		"deleted": userField(graphql.DateTime, func(u *entity.User) interface{} {
+			if u.Deleted == nil {
+				return nil
+			}
+			return *u.Deleted
+		}),
+	},
+})
+
+var userPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "UserPage",
+	Fields: graphql.Fields{
+		"items":   &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType)))},
+		"limit":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"offset":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"hasMore": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+})
+
+var userInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "UserInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"firstname": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"lastname":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"email":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"age":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+func userField(t graphql.Output, get func(u *entity.User) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: t,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*entity.User)), nil
+		},
+	}
+}
+
+// newSchema builds the schema resolving against us.
+func newSchema(us user.UseCase) (graphql.Schema, error) {
+	r := &resolver{usecase: us}
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"asOf": &graphql.ArgumentConfig{Type: graphql.DateTime, Description: "Return the user as it was at this time"},
+				},
+				Resolve: r.user,
+			},
+			"users": &graphql.Field{
+				Type: graphql.NewNonNull(userPageType),
+				Args: graphql.FieldConfigArgument{
+					"limit":          &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageLimit},
+					"offset":         &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+					"includeDeleted": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					"status": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(userStatusType)),
+						Description: "Keep only the users in one of these statuses"},
+				},
+				Resolve: r.users,
+			},
+		},
+	})
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(userInputType)},
+				},
+				Resolve: r.createUser,
+			},
+			"updateUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(userInputType)},
+				},
+				Resolve: r.updateUser,
+			},
+			"deleteUser": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.deleteUser,
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+type resolver struct {
+	usecase user.UseCase
+}
+
+func (r *resolver) user(p graphql.ResolveParams) (interface{}, error) {
+	id, err := uuid.Parse(p.Args["id"].(string))
+	if err != nil {
+		return nil, models.ErrBadParamInput
+	}
+	if at, ok := p.Args["asOf"].(time.Time); ok {
+		return r.usecase.GetAsOf(p.Context, id, at)
+	}
+	return loaderFromContext(p.Context).Load(p.Context, id), nil
+}
+
+func (r *resolver) users(p graphql.ResolveParams) (interface{}, error) {
+	limit := p.Args["limit"].(int)
+	offset := p.Args["offset"].(int)
+	if limit <= 0 || limit > maxPageLimit || offset < 0 {
+		return nil, models.ErrBadParamInput
+	}
+
+	includeDeleted := p.Args["includeDeleted"].(bool)
+	if includeDeleted && !models.HasRole(p.Context, auth.RoleAdmin) {
+		return nil, models.ErrForbidden
+	}
+
+	// one extra user tells whether there is a next page
+	filter := &DTOs.UserFilter{IncludeDeleted: includeDeleted, Limit: limit + 1, Offset: offset}
+	if statuses, ok := p.Args["status"].([]interface{}); ok {
+		for _, status := range statuses {
+			filter.Statuses = append(filter.Statuses, status.(string))
+		}
+	}
+	users, err := r.usecase.GetAllUsers(p.Context, filter)
+	if err != nil && err != models.ErrNotFound {
+		return nil, err
+	}
+
+	page := &userPage{Items: make([]*entity.User, 0), Limit: limit, Offset: offset}
+	if len(users) > limit {
+		users = users[:limit]
+		page.HasMore = true
+	}
+	page.Items = append(page.Items, users...)
+	return page, nil
+}
+
+func (r *resolver) createUser(p graphql.ResolveParams) (interface{}, error) {
+	u, err := userInput(p.Args["input"])
+	if err != nil {
+		return nil, err
+	}
+	return r.usecase.Create(p.Context, u)
+}
+
+func (r *resolver) updateUser(p graphql.ResolveParams) (interface{}, error) {
+	id, err := uuid.Parse(p.Args["id"].(string))
+	if err != nil {
+		return nil, models.ErrBadParamInput
+	}
+	u, err := userInput(p.Args["input"])
+	if err != nil {
+		return nil, err
+	}
+	return r.usecase.Update(p.Context, id, u)
+}
+
+func (r *resolver) deleteUser(p graphql.ResolveParams) (interface{}, error) {
+	if !models.HasRole(p.Context, auth.RoleAdmin) {
+		return nil, models.ErrForbidden
+	}
+	id, err := uuid.Parse(p.Args["id"].(string))
+	if err != nil {
+		return nil, models.ErrBadParamInput
+	}
+	if err = r.usecase.Delete(p.Context, id); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+// userInput converts and validates a UserInput argument.
+func userInput(arg interface{}) (*DTOs.User, error) {
+	in := arg.(map[string]interface{})
+	age := in["age"].(int)
+	if age < 0 {
+		return nil, models.ErrBadParamInput
+	}
+	u := &DTOs.User{
+		Firstname: in["firstname"].(string),
+		Lastname:  in["lastname"].(string),
+		Email:     in["email"].(string),
+		Age:       uint(age),
+	}
+	if err := validator.New().Struct(u); err != nil {
+		return nil, err
+	}
+	ok, err := regexp.MatchString("^[\\w-\\.]+@([\\w-]+\\.)+[\\w-]{2,4}$", u.Email)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, models.ErrEmailValid
+	}
+	return u, nil
+}
@@ -0,0 +1,284 @@
+package grpc
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/event"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"GoMastersTest/user/delivery/grpc/pb"
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var userEventTypes = map[string]bool{
+	entity.EventUserCreated:  true,
+	entity.EventUserUpdated:  true,
+	entity.EventUserDeleted:  true,
+	entity.EventUserRestored: true,
+}
+
+type UserServer struct {
+	pb.UnimplementedUserServiceServer
+	Usecase user.UseCase
+	Stream  event.StreamUseCase
+}
+
+// NewUserServer registers the user service on s, along with a health service
+// reporting it as serving.
+func NewUserServer(s *grpc.Server, us user.UseCase, stream event.StreamUseCase) {
+	server := &UserServer{
+		Usecase: us,
+		Stream:  stream,
+	}
+	pb.RegisterUserServiceServer(s, server)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(pb.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+}
+
+func (a *UserServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, models.ErrBadParamInput.Error())
+	}
+
+	var res *entity.User
+	if req.GetAsOf() != nil {
+		res, err = a.Usecase.GetAsOf(ctx, id, req.GetAsOf().AsTime())
+	} else {
+		res, err = a.Usecase.GetByID(ctx, id)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(res), nil
+}
+
+// ListUsers pages through users in creation order. The page token is the
+// offset of the next page.
+func (a *UserServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	size := int(req.GetPageSize())
+	if size < 0 {
+		return nil, status.Error(codes.InvalidArgument, models.ErrBadParamInput.Error())
+	}
+	if size == 0 {
+		size = defaultPageSize
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	offset := 0
+	if token := req.GetPageToken(); token != "" {
+		var err error
+		offset, err = strconv.Atoi(token)
+		if err != nil || offset < 0 {
+			return nil, status.Error(codes.InvalidArgument, models.ErrBadParamInput.Error())
+		}
+	}
+
+	if req.GetIncludeDeleted() && !models.HasRole(ctx, auth.RoleAdmin) {
+		return nil, toStatus(models.ErrForbidden)
+	}
+
+	// one extra user tells whether there is a next page
+	filter := &DTOs.UserFilter{IncludeDeleted: req.GetIncludeDeleted(), Limit: size + 1, Offset: offset}
+	users, err := a.Usecase.GetAllUsers(ctx, filter)
+	if err != nil && err != models.ErrNotFound {
+		return nil, toStatus(err)
+	}
+
+	res := &pb.ListUsersResponse{}
+	if len(users) > size {
+		users = users[:size]
+		res.NextPageToken = strconv.Itoa(offset + size)
+	}
+	res.Users = make([]*pb.User, len(users))
+	for i, u := range users {
+		res.Users[i] = toProto(u)
+	}
+	return res, nil
+}
+
+func (a *UserServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error) {
+	u := fromProto(req.GetUser())
+	if err := validateUser(u); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	res, err := a.Usecase.Create(ctx, u)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(res), nil
+}
+
+func (a *UserServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.User, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, models.ErrBadParamInput.Error())
+	}
+	u := fromProto(req.GetUser())
+	if err := validateUser(u); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	res, err := a.Usecase.Update(ctx, id, u)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(res), nil
+}
+
+func (a *UserServer) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
+	if !models.HasRole(ctx, auth.RoleAdmin) {
+		return nil, toStatus(models.ErrForbidden)
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, models.ErrBadParamInput.Error())
+	}
+
+	if err = a.Usecase.Delete(ctx, id); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// WatchUsers replays the buffered events after last_event_id and then streams
+// new ones until the client goes away, limited to the organization of the
+// call like the HTTP stream. Clients that fall too far behind get
+// ResourceExhausted and can resume with the last event ID they received.
+func (a *UserServer) WatchUsers(req *pb.WatchUsersRequest, srv pb.UserService_WatchUsersServer) error {
+	for _, t := range req.GetTypes() {
+		if !userEventTypes[t] {
+			return status.Error(codes.InvalidArgument, models.ErrBadParamInput.Error())
+		}
+	}
+
+	ctx := srv.Context()
+	replay, subscription, err := a.Stream.Subscribe(ctx, req.GetLastEventId(), req.GetTypes())
+	if err != nil {
+		return toStatus(err)
+	}
+	defer a.Stream.Unsubscribe(subscription)
+
+	for _, e := range replay {
+		if err := sendEvent(srv, e); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-subscription.Events:
+			if !ok {
+				logrus.Warn("closing slow user watch stream")
+				return status.Error(codes.ResourceExhausted, "watcher fell too far behind")
+			}
+			if err := sendEvent(srv, e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func sendEvent(srv pb.UserService_WatchUsersServer, e *entity.Event) error {
+	var u entity.User
+	if err := json.Unmarshal(e.Payload, &u); err != nil {
+		logrus.Error(err)
+		return status.Error(codes.Internal, models.ErrInternalServerError.Error())
+	}
+	return srv.Send(&pb.UserEvent{
+		Id:      e.ID.String(),
+		Type:    e.Type,
+		Version: int64(e.Version),
+		User:    toProto(&u),
+		Created: timestamppb.New(e.Created),
+	})
+}
+
+func toProto(u *entity.User) *pb.User {
+	res := &pb.User{
+		Id:        u.ID.String(),
+		FirstName: u.Firstname,
+		LastName:  u.Lastname,
+		Email:     u.Email,
+		Age:       uint32(u.Age),
+		Created:   timestamppb.New(u.Created),
+		Updated:   timestamppb.New(u.Updated),
+	}
+	if u.Deleted != nil {
+		res.Deleted = timestamppb.New(*u.Deleted)
+	}
+	return res
+}
+
+func fromProto(u *pb.UserInput) *DTOs.User {
+	return &DTOs.User{
+		Firstname: u.GetFirstName(),
+		Lastname:  u.GetLastName(),
+		Email:     u.GetEmail(),
+		Age:       uint(u.GetAge()),
+	}
+}
+
+// toStatus maps usecase errors to gRPC status codes, as getStatusCode does
+// for HTTP.
+func toStatus(err error) error {
+	logrus.Error(err)
+	var code codes.Code
+	switch err {
+	case models.ErrNotFound:
+		code = codes.NotFound
+	case models.ErrConflict:
+		code = codes.AlreadyExists
+	case models.ErrBadParamInput, models.ErrEmailValid:
+		code = codes.InvalidArgument
+	case models.ErrForbidden:
+		code = codes.PermissionDenied
+	case context.DeadlineExceeded:
+		code = codes.DeadlineExceeded
+	case context.Canceled:
+		code = codes.Canceled
+	default:
+		code = codes.Internal
+	}
+	return status.Error(code, err.Error())
+}
+
+func validateUser(m *DTOs.User) error {
+	if err := validator.New().Struct(m); err != nil {
+		return err
+	}
+	ok, err := regexp.MatchString("^[\\w-\\.]+@([\\w-]+\\.)+[\\w-]{2,4}$", m.Email)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return models.ErrEmailValid
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package grpc_test
+
+import (
+	"GoMastersTest/auth"
+	eventUsecase "GoMastersTest/event/usecase"
+	"GoMastersTest/middleware"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/org"
+	"GoMastersTest/user"
+	userGrpc "GoMastersTest/user/delivery/grpc"
+	"GoMastersTest/user/delivery/grpc/pb"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeUseCase keeps users in memory in creation order. Methods the server
+// does not call are left to the embedded nil interface.
+type fakeUseCase struct {
+	user.UseCase
+	users  []*entity.User
+	actors []string
+}
+
+func (f *fakeUseCase) find(id uuid.UUID) *entity.User {
+	for _, u := range f.users {
+		if u.ID == id {
+			return u
+		}
+	}
+	return nil
+}
+
+func (f *fakeUseCase) GetByID(ctx context.Context, id uuid.UUID, fields ...string) (*entity.User, error) {
+	if u := f.find(id); u != nil && u.Deleted == nil {
+		return u, nil
+	}
+	return nil, models.ErrNotFound
+}
+
+func (f *fakeUseCase) GetAllUsers(ctx context.Context, filter *DTOs.UserFilter) ([]*entity.User, error) {
+	res := make([]*entity.User, 0)
+	for _, u := range f.users {
+		if u.Deleted == nil || filter.IncludeDeleted {
+			res = append(res, u)
+		}
+	}
+	if filter.Offset >= len(res) {
+		return nil, models.ErrNotFound
+	}
+	res = res[filter.Offset:]
+	if filter.Limit > 0 && len(res) > filter.Limit {
+		res = res[:filter.Limit]
+	}
+	return res, nil
+}
+
+func (f *fakeUseCase) Create(ctx context.Context, u *DTOs.User) (*entity.User, error) {
+	f.actors = append(f.actors, models.ActorFromContext(ctx))
+	res := &entity.User{User: *u, ID: uuid.New(), Created: time.Now(), Updated: time.Now()}
+	f.users = append(f.users, res)
+	return res, nil
+}
+
+func (f *fakeUseCase) Update(ctx context.Context, id uuid.UUID, u *DTOs.User) (*entity.User, error) {
+	res, err := f.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	res.User = *u
+	return res, nil
+}
+
+func (f *fakeUseCase) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := f.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	res.Deleted = &now
+	return nil
+}
+
+// Tokens accepted by fakeAuthenticator: the caller is a member of callerOrg,
+// the operator an admin of no organization.
+const (
+	callerToken   = "caller"
+	operatorToken = "operator"
+)
+
+var (
+	caller    = uuid.New()
+	callerOrg = uuid.New()
+)
+
+type fakeAuthenticator struct{}
+
+func (fakeAuthenticator) Authenticate(ctx context.Context, accessToken string) (*entity.AccessClaims, error) {
+	switch accessToken {
+	case callerToken:
+		return &entity.AccessClaims{UserID: caller}, nil
+	case operatorToken:
+		return &entity.AccessClaims{UserID: uuid.New(), Roles: []string{auth.RoleAdmin}}, nil
+	}
+	return nil, models.ErrInvalidToken
+}
+
+// fakeOrgs scopes the caller to callerOrg, the only organization there is.
+type fakeOrgs struct {
+	org.UseCase
+}
+
+func (fakeOrgs) Tenant(ctx context.Context, requested uuid.UUID) (uuid.UUID, bool, error) {
+	if requested != uuid.Nil && requested != callerOrg {
+		return uuid.Nil, false, models.ErrForbidden
+	}
+	if requested == uuid.Nil && models.HasRole(ctx, auth.RoleAdmin) {
+		return uuid.Nil, false, nil
+	}
+	return callerOrg, true, nil
+}
+
+// bearer sends an access token with every call, none when empty.
+type bearer string
+
+func (b bearer) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	if b == "" {
+		return nil, nil
+	}
+	return map[string]string{middleware.AuthorizationMetadata: "Bearer " + string(b)}, nil
+}
+
+func (b bearer) RequireTransportSecurity() bool {
+	return false
+}
+
+// dial connects to a server of us as the caller.
+func dial(t *testing.T, us user.UseCase) (*grpc.ClientConn, func(e *entity.Event)) {
+	return dialAs(t, us, callerToken)
+}
+
+func dialAs(t *testing.T, us user.UseCase, token string) (*grpc.ClientConn, func(e *entity.Event)) {
+	t.Helper()
+	stream := eventUsecase.NewStreamUseCase(10, 10)
+	lis := bufconn.Listen(1024 * 1024)
+	m := middleware.InitMiddleware()
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(m.UnaryContext(), m.UnaryAuth(fakeAuthenticator{}, fakeOrgs{})),
+		grpc.StreamInterceptor(m.StreamAuth(fakeAuthenticator{}, fakeOrgs{})))
+	userGrpc.NewUserServer(s, us, stream)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(bearer(token)))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	publish := func(e *entity.Event) {
+		require.NoError(t, stream.Deliver(context.Background(), e))
+	}
+	return conn, publish
+}
+
+func TestUserService(t *testing.T) {
+	us := &fakeUseCase{}
+	conn, _ := dial(t, us)
+	client := pb.NewUserServiceClient(conn)
+
+	ctx := context.Background()
+	created, err := client.CreateUser(ctx, &pb.CreateUserRequest{User: &pb.UserInput{
+		FirstName: "Igor", LastName: "Kormich", Email: "igor@example.com", Age: 30,
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "Igor", created.FirstName)
+	assert.Equal(t, []string{caller.String()}, us.actors, "the token names the actor")
+
+	got, err := client.GetUser(ctx, &pb.GetUserRequest{Id: created.Id})
+	require.NoError(t, err)
+	assert.Equal(t, created.Email, got.Email)
+
+	updated, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: created.Id, User: &pb.UserInput{
+		FirstName: "Igor", LastName: "K", Email: "igor@example.com", Age: 31,
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(31), updated.Age)
+
+	_, err = client.DeleteUser(ctx, &pb.DeleteUserRequest{Id: created.Id})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "only operators delete users")
+	_, err = client.ListUsers(ctx, &pb.ListUsersRequest{PageSize: 10, IncludeDeleted: true})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "only operators list deleted users")
+
+	operator, _ := dialAs(t, us, operatorToken)
+	_, err = pb.NewUserServiceClient(operator).DeleteUser(ctx, &pb.DeleteUserRequest{Id: created.Id})
+	require.NoError(t, err)
+
+	_, err = client.GetUser(ctx, &pb.GetUserRequest{Id: created.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUserServiceErrors(t *testing.T) {
+	conn, _ := dial(t, &fakeUseCase{})
+	client := pb.NewUserServiceClient(conn)
+	ctx := context.Background()
+
+	_, err := client.GetUser(ctx, &pb.GetUserRequest{Id: "not-a-uuid"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.GetUser(ctx, &pb.GetUserRequest{Id: uuid.NewString()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.CreateUser(ctx, &pb.CreateUserRequest{User: &pb.UserInput{Email: "not an email"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.ListUsers(ctx, &pb.ListUsersRequest{PageToken: "x"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestListUsersPages(t *testing.T) {
+	us := &fakeUseCase{}
+	for i := 0; i < 5; i++ {
+		_, _ = us.Create(context.Background(), &DTOs.User{Email: "a@example.com"})
+	}
+	conn, _ := dial(t, us)
+	client := pb.NewUserServiceClient(conn)
+
+	ids := make([]string, 0)
+	req := &pb.ListUsersRequest{PageSize: 2}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		res, err := client.ListUsers(context.Background(), req)
+		require.NoError(t, err)
+		for _, u := range res.Users {
+			ids = append(ids, u.Id)
+		}
+		if res.NextPageToken == "" {
+			break
+		}
+		req.PageToken = res.NextPageToken
+	}
+
+	require.Len(t, ids, 5)
+	for i, u := range us.users {
+		assert.Equal(t, u.ID.String(), ids[i])
+	}
+}
+
+func TestWatchUsers(t *testing.T) {
+	conn, publish := dial(t, &fakeUseCase{})
+	client := pb.NewUserServiceClient(conn)
+
+	u := &entity.User{User: DTOs.User{Firstname: "Igor"}, ID: uuid.New()}
+	payload, err := json.Marshal(u)
+	require.NoError(t, err)
+	otherOrg := uuid.New()
+	seen := &entity.Event{ID: uuid.New(), AggregateID: u.ID, OrgID: &callerOrg, Type: entity.EventUserCreated, Version: 1, Payload: payload}
+	updated := &entity.Event{ID: uuid.New(), AggregateID: u.ID, OrgID: &callerOrg, Type: entity.EventUserUpdated, Version: 2, Payload: payload}
+	hidden := &entity.Event{ID: uuid.New(), AggregateID: uuid.New(), OrgID: &otherOrg, Type: entity.EventUserDeleted, Version: 1, Payload: payload}
+	deleted := &entity.Event{ID: uuid.New(), AggregateID: u.ID, OrgID: &callerOrg, Type: entity.EventUserDeleted, Version: 3, Payload: payload}
+	publish(seen)
+	publish(updated)
+	publish(hidden)
+	publish(deleted)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch, err := client.WatchUsers(ctx, &pb.WatchUsersRequest{
+		Types:       []string{entity.EventUserDeleted},
+		LastEventId: seen.ID.String(),
+	})
+	require.NoError(t, err)
+
+	// the deletion made in another organization is not streamed
+	e, err := watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, deleted.ID.String(), e.Id)
+	assert.Equal(t, int64(3), e.Version)
+	assert.Equal(t, "Igor", e.User.FirstName)
+
+	watch, err = client.WatchUsers(ctx, &pb.WatchUsersRequest{Types: []string{"Unknown"}})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestAuthentication(t *testing.T) {
+	for name, tc := range map[string]struct {
+		token string
+		org   string
+		code  codes.Code
+	}{
+		"anonymous":             {"", "", codes.Unauthenticated},
+		"forged token":          {"forged", "", codes.Unauthenticated},
+		"caller":                {callerToken, "", codes.NotFound},
+		"caller in their org":   {callerToken, callerOrg.String(), codes.NotFound},
+		"caller in another org": {callerToken, uuid.NewString(), codes.PermissionDenied},
+		"operator":              {operatorToken, "", codes.NotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn, _ := dialAs(t, &fakeUseCase{}, tc.token)
+			client := pb.NewUserServiceClient(conn)
+			ctx := context.Background()
+			if tc.org != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, middleware.OrgHeader, tc.org)
+			}
+
+			_, err := client.GetUser(ctx, &pb.GetUserRequest{Id: uuid.NewString()})
+			assert.Equal(t, tc.code, status.Code(err))
+
+			watch, err := client.WatchUsers(ctx, &pb.WatchUsersRequest{Types: []string{"Unknown"}})
+			require.NoError(t, err)
+			_, err = watch.Recv()
+			if tc.code == codes.NotFound {
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			} else {
+				assert.Equal(t, tc.code, status.Code(err))
+			}
+		})
+	}
+}
+
+func TestHealth(t *testing.T) {
+	// health checks need no token
+	conn, _ := dialAs(t, &fakeUseCase{}, "")
+
+	res, err := healthpb.NewHealthClient(conn).Check(context.Background(),
+		&healthpb.HealthCheckRequest{Service: pb.UserService_ServiceDesc.ServiceName})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+}
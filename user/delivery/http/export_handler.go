@@ -0,0 +1,171 @@
+package http
+
+import (
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// exportFlushRows is how many rows are buffered before being sent to the client.
+const exportFlushRows = 100
+
+var exportContentTypes = map[string]string{
+	formatCSV:    "text/csv",
+	formatNDJSON: "application/x-ndjson",
+}
+
+// ExportUsers godoc
+// @Summary      ExportUsers
+// @Description  Stream all users as CSV or NDJSON, chosen by the format parameter or the Accept header. Admins only.
+// @Tags         Users
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        format           query  string  false  "csv or ndjson"
+// @Param        include_deleted  query  bool    false  "Include soft-deleted users (admin only)"
+// @Param        status           query  string  false  "Comma separated statuses to keep, e.g. suspended,deactivated"
+// @Success      200  {string}  string
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      406  {object}  httputil.HTTPError
+// @Router /users/export [get]
+func (a *UserHandler) ExportUsers(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format = c.NegotiateFormat(exportContentTypes[formatCSV], exportContentTypes[formatNDJSON])
+		format = formatFromContentType(format)
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		httputil.NewError(c, http.StatusNotAcceptable, models.ErrUnsupportedFormat)
+		return
+	}
+
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var write func(u *entity.User) error
+	var flush func() error
+	if format == formatCSV {
+		w := csv.NewWriter(c.Writer)
+		write = func(u *entity.User) error {
+			return w.Write(csvRecord(u))
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	} else {
+		enc := json.NewEncoder(c.Writer)
+		write = func(u *entity.User) error {
+			return enc.Encode(u)
+		}
+		flush = func() error {
+			return nil
+		}
+	}
+
+	// headers are sent with the first row, so errors raised before it can
+	// still be answered with a proper status
+	started := false
+	start := func() error {
+		started = true
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="users-%s.%s"`, time.Now().UTC().Format("20060102T150405Z"), format))
+		c.Status(http.StatusOK)
+		if format == formatCSV {
+			return write(nil)
+		}
+		return nil
+	}
+
+	rows := 0
+	err = a.Usecase.Export(ctx, filter, func(u *entity.User) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := write(u); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err != nil && !started {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		// the status line has already been sent, all that is left is to
+		// stop writing
+		logrus.Error(err)
+		c.Abort()
+	}
+}
+
+// csvFormulaPrefixes start the cells that spreadsheets evaluate as formulas.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvText keeps spreadsheets from evaluating s as a formula by quoting it with
+// a leading apostrophe.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune(csvFormulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// csvRecord returns the CSV columns of u, or the header line when u is nil.
+// The fields users fill in go through csvText.
+func csvRecord(u *entity.User) []string {
+	if u == nil {
+		return []string{"ID", "Firstname", "Lastname", "Email", "Age", "Created", "Updated", "Deleted"}
+	}
+
+	deleted := ""
+	if u.Deleted != nil {
+		deleted = u.Deleted.Format(time.RFC3339)
+	}
+	return []string{
+		u.ID.String(),
+		csvText(u.Firstname),
+		csvText(u.Lastname),
+		csvText(u.Email),
+		strconv.FormatUint(uint64(u.Age), 10),
+		u.Created.Format(time.RFC3339),
+		u.Updated.Format(time.RFC3339),
+		deleted,
+	}
+}
@@ -0,0 +1,136 @@
+package http_test
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/middleware"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	httpHandler "GoMastersTest/user/delivery/http"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exportedUsers streams a fixed list of users.
+type exportedUsers struct {
+	user.UseCase
+	users []*entity.User
+}
+
+func (f *exportedUsers) Export(ctx context.Context, filter *DTOs.UserFilter, fn func(*entity.User) error) error {
+	for _, u := range f.users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportUsers(t *testing.T, users []*entity.User, admin bool, query string, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		ctx := models.WithUserID(c.Request.Context(), uuid.New())
+		if admin {
+			ctx = models.WithRoles(ctx, []string{auth.RoleAdmin})
+		}
+		c.Request = c.Request.WithContext(ctx)
+	})
+	httpHandler.NewUserHandler(r, &exportedUsers{users: users}, 10, func(c *gin.Context) { c.Next() },
+		middleware.InitMiddleware().RequireRole(auth.RoleAdmin))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/export"+query, nil)
+	req.Header.Set("Authorization", "Bearer token")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func exportFixture() []*entity.User {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deleted := created.Add(time.Hour)
+	return []*entity.User{
+		{User: DTOs.User{Firstname: "Igor", Lastname: "Kormich", Email: "igor@example.com", Age: 30},
+			ID: uuid.New(), Created: created, Updated: created},
+		{User: DTOs.User{Firstname: `Smith, "Jr"`, Lastname: "Line\nbreak", Email: "=HYPERLINK(\"http://evil\")", Age: 41},
+			ID: uuid.New(), Created: created, Updated: created, Deleted: &deleted},
+		{User: DTOs.User{Firstname: "+1", Lastname: "-2", Email: "@sum", Age: 5},
+			ID: uuid.New(), Created: created, Updated: created},
+	}
+}
+
+func TestExportRequiresAdmin(t *testing.T) {
+	rec := exportUsers(t, exportFixture(), false, "?format=csv", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestExportCSV(t *testing.T) {
+	users := exportFixture()
+	rec := exportUsers(t, users, true, "", "text/csv")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), ".csv")
+
+	records, err := csv.NewReader(strings.NewReader(rec.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, []string{"ID", "Firstname", "Lastname", "Email", "Age", "Created", "Updated", "Deleted"}, records[0])
+	assert.Equal(t, []string{users[0].ID.String(), "Igor", "Kormich", "igor@example.com", "30",
+		"2024-01-02T03:04:05Z", "2024-01-02T03:04:05Z", ""}, records[1])
+
+	// separators, quotes and line breaks stay inside their cell, and cells
+	// that would start a formula are prefixed
+	assert.Equal(t, []string{users[1].ID.String(), `Smith, "Jr"`, "Line\nbreak", "'=HYPERLINK(\"http://evil\")", "41",
+		"2024-01-02T03:04:05Z", "2024-01-02T03:04:05Z", "2024-01-02T04:04:05Z"}, records[2])
+	assert.Equal(t, []string{"'+1", "'-2", "'@sum"}, records[3][1:4])
+}
+
+func TestExportNDJSON(t *testing.T) {
+	users := exportFixture()
+	rec := exportUsers(t, users, true, "?format=ndjson", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+	s := bufio.NewScanner(strings.NewReader(rec.Body.String()))
+	got := make([]*entity.User, 0)
+	for s.Scan() {
+		u := new(entity.User)
+		require.NoError(t, json.Unmarshal(s.Bytes(), u))
+		got = append(got, u)
+	}
+	require.NoError(t, s.Err())
+	require.Len(t, got, len(users))
+	for i, u := range users {
+		assert.Equal(t, u.ID, got[i].ID)
+		assert.Equal(t, u.User, got[i].User, "NDJSON keeps the values as they are")
+		assert.True(t, u.Created.Equal(got[i].Created))
+	}
+	require.NotNil(t, got[1].Deleted)
+	assert.True(t, users[1].Deleted.Equal(*got[1].Deleted))
+}
+
+func TestExportEmpty(t *testing.T) {
+	rec := exportUsers(t, nil, true, "?format=csv", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ID,Firstname,Lastname,Email,Age,Created,Updated,Deleted\n", rec.Body.String())
+
+	rec = exportUsers(t, nil, true, "?format=xml", "")
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+}
@@ -0,0 +1,237 @@
+package http
+
+import (
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/user"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+type ImportHandler struct {
+	Usecase user.ImportUseCase
+	// AsyncThreshold is the body size in bytes above which imports run in the
+	// background even when async is not requested.
+	AsyncThreshold int64
+	// MaxBytes caps the size of an import body; 0 means no limit.
+	MaxBytes int64
+}
+
+// NewImportHandler registers the import routes, guarded by requireTenant.
+func NewImportHandler(r *gin.Engine, us user.ImportUseCase, asyncThreshold int64, maxBytes int64, requireTenant gin.HandlerFunc) {
+	handler := &ImportHandler{
+		Usecase:        us,
+		AsyncThreshold: asyncThreshold,
+		MaxBytes:       maxBytes,
+	}
+	v1 := r.Group("/api/v1", requireTenant)
+	v1.POST("/users/import", handler.ImportUsers)
+	v1.GET("/users/import/:jobID", handler.GetImportJob)
+}
+
+// ImportUsers godoc
+// @Summary      ImportUsers
+// @Description  Import users from CSV or NDJSON. Small imports answer with the finished report; large or async ones answer 202 with a job to poll.
+// @Tags         Users
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        format   query  string  false  "csv or ndjson, defaults to the Content-Type"
+// @Param        mapping  query  string  false  "CSV column mapping, e.g. Firstname:given_name,Email:mail"
+// @Param        dry_run  query  bool    false  "Validate rows without writing"
+// @Param        upsert   query  bool    false  "Update users whose email already exists"
+// @Param        async    query  bool    false  "Run the import in the background"
+// @Success      200  {object}  entity.ImportJob
+// @Success      202  {object}  entity.ImportJob
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      413  {object}  httputil.HTTPError
+// @Failure      415  {object}  httputil.HTTPError
+// @Router /users/import [post]
+func (a *ImportHandler) ImportUsers(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format = formatFromContentType(c.GetHeader("Content-Type"))
+	}
+	if format != formatCSV && format != formatNDJSON {
+		httputil.NewError(c, http.StatusUnsupportedMediaType, models.ErrUnsupportedFormat)
+		return
+	}
+
+	mapping, err := parseColumnMapping(c.Query("mapping"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var opts DTOs.ImportOptions
+	var async bool
+	for name, target := range map[string]*bool{"dry_run": &opts.DryRun, "upsert": &opts.Upsert, "async": &async} {
+		if v, ok := c.GetQuery(name); ok {
+			if *target, err = strconv.ParseBool(v); err != nil {
+				httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+				return
+			}
+		}
+	}
+
+	size := c.Request.ContentLength
+	if a.MaxBytes > 0 {
+		if size > a.MaxBytes {
+			httputil.NewError(c, http.StatusRequestEntityTooLarge, models.ErrBodyTooLarge)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, a.MaxBytes)
+	}
+
+	// chunked bodies have no length up front, so they are spooled and
+	// measured before picking a mode
+	if async || size < 0 || size > a.AsyncThreshold {
+		a.spoolImport(c, format, mapping, opts, async)
+		return
+	}
+	a.importNow(c, c.Request.Body, format, mapping, opts)
+}
+
+// importNow imports the rows of r and answers with the finished report.
+func (a *ImportHandler) importNow(c *gin.Context, r io.Reader, format string, mapping map[string]string, opts DTOs.ImportOptions) {
+	rows, err := newRowReader(r, format, mapping)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	job, err := a.Usecase.Import(ctx, rows, opts)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, job)
+}
+
+// spoolImport copies the body to a temporary file, so the request can finish
+// while the import runs. Unless async is requested, bodies that turn out to be
+// no larger than AsyncThreshold are still imported before answering.
+func (a *ImportHandler) spoolImport(c *gin.Context, format string, mapping map[string]string, opts DTOs.ImportOptions, async bool) {
+	f, err := ioutil.TempFile("", "user-import-*")
+	if err != nil {
+		httputil.NewError(c, http.StatusInternalServerError, models.ErrInternalServerError)
+		return
+	}
+	cleanup := func() {
+		if err := f.Close(); err != nil {
+			logrus.Error(err)
+		}
+		if err := os.Remove(f.Name()); err != nil {
+			logrus.Error(err)
+		}
+	}
+
+	n, err := io.Copy(f, c.Request.Body)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil && a.MaxBytes > 0 && n >= a.MaxBytes {
+		cleanup()
+		httputil.NewError(c, http.StatusRequestEntityTooLarge, models.ErrBodyTooLarge)
+		return
+	}
+	if err != nil {
+		cleanup()
+		httputil.NewError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if !async && n <= a.AsyncThreshold {
+		defer cleanup()
+		a.importNow(c, f, format, mapping, opts)
+		return
+	}
+
+	rows, err := newRowReader(f, format, mapping)
+	if err != nil {
+		cleanup()
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	job := a.Usecase.Start(ctx, rows, opts, cleanup)
+	c.Header("Location", c.FullPath()+"/"+job.ID.String())
+	httputil.Respond(c, http.StatusAccepted, job)
+}
+
+// GetImportJob godoc
+// @Summary      GetImportJob
+// @Description  Return the progress of a background import
+// @Tags         Users
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        jobID  path  string  true  "Import job ID"
+// @Success      200  {object}  entity.ImportJob
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /users/import/{jobID} [get]
+func (a *ImportHandler) GetImportJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("jobID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	job, err := a.Usecase.GetJob(ctx, id)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, job)
+}
+
+func newRowReader(r io.Reader, format string, mapping map[string]string) (user.RowReader, error) {
+	if format == formatCSV {
+		return newCSVRowReader(r, mapping)
+	}
+	return newNDJSONRowReader(r), nil
+}
+
+func formatFromContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return formatCSV
+	case "application/x-ndjson", "application/ndjson":
+		return formatNDJSON
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+package http_test
+
+import (
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	httpHandler "GoMastersTest/user/delivery/http"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingImports reads every row it is given so the tests can check how
+// the body was parsed.
+type recordingImports struct {
+	user.ImportUseCase
+	rows    []*user.ImportRow
+	opts    DTOs.ImportOptions
+	started bool
+}
+
+func (f *recordingImports) read(rows user.RowReader, opts DTOs.ImportOptions) (*entity.ImportJob, error) {
+	f.opts = opts
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			return &entity.ImportJob{ID: uuid.New(), Processed: len(f.rows)}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		f.rows = append(f.rows, row)
+	}
+}
+
+func (f *recordingImports) Import(ctx context.Context, rows user.RowReader, opts DTOs.ImportOptions) (*entity.ImportJob, error) {
+	return f.read(rows, opts)
+}
+
+func (f *recordingImports) Start(ctx context.Context, rows user.RowReader, opts DTOs.ImportOptions, done func()) *entity.ImportJob {
+	defer done()
+	f.started = true
+	job, _ := f.read(rows, opts)
+	return job
+}
+
+func newImportRouter(imports user.ImportUseCase, asyncThreshold int64, maxBytes int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	httpHandler.NewImportHandler(r, imports, asyncThreshold, maxBytes, func(c *gin.Context) { c.Next() })
+	return r
+}
+
+func postImport(r *gin.Engine, query string, contentType string, body string, chunked bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if chunked {
+		req.ContentLength = -1
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestImportCSV(t *testing.T) {
+	imports := &recordingImports{}
+	r := newImportRouter(imports, 1<<20, 0)
+
+	body := "given_name,Lastname,mail,AGE\n" +
+		"Igor,Petrov,igor@example.com,30\n" +
+		"\"Smith, Jr\",\"O\"\"Neil\",smith@example.com, 41\n" +
+		"Ann,Lee,ann@example.com,old\n" +
+		"Bob,Ray,not-an-email,20\n"
+	rec := postImport(r, "?mapping=firstname:given_name,Email:mail&dry_run=true", "text/csv", body, false)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.True(t, imports.opts.DryRun)
+	require.Len(t, imports.rows, 4)
+	assert.Equal(t, 2, imports.rows[0].Row)
+	assert.NoError(t, imports.rows[0].Err)
+	assert.Equal(t, &DTOs.User{Firstname: "Igor", Lastname: "Petrov", Email: "igor@example.com", Age: 30}, imports.rows[0].User)
+	assert.NoError(t, imports.rows[1].Err)
+	assert.Equal(t, &DTOs.User{Firstname: "Smith, Jr", Lastname: "O\"Neil", Email: "smith@example.com", Age: 41}, imports.rows[1].User)
+	assert.EqualError(t, imports.rows[2].Err, `invalid Age "old"`)
+	assert.Error(t, imports.rows[3].Err)
+	assert.Equal(t, 5, imports.rows[3].Row)
+}
+
+func TestImportCSVRejectsBadMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping string
+		header  string
+	}{
+		{name: "unknown field", mapping: "?mapping=Nickname:nick", header: "Firstname,Lastname,Email,Age"},
+		{name: "malformed pair", mapping: "?mapping=Email", header: "Firstname,Lastname,Email,Age"},
+		{name: "empty column", mapping: "?mapping=Email:", header: "Firstname,Lastname,Email,Age"},
+		{name: "missing column", mapping: "?mapping=Email:mail", header: "Firstname,Lastname,Email,Age"},
+		{name: "missing default column", header: "Firstname,Lastname,Email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imports := &recordingImports{}
+			r := newImportRouter(imports, 1<<20, 0)
+
+			rec := postImport(r, tt.mapping, "text/csv", tt.header+"\n", false)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Empty(t, imports.rows)
+		})
+	}
+}
+
+func TestImportNDJSON(t *testing.T) {
+	imports := &recordingImports{}
+	r := newImportRouter(imports, 1<<20, 0)
+
+	body := `{"Firstname":"Igor","Lastname":"Petrov","Email":"igor@example.com","Age":30}` + "\n" +
+		"\n" +
+		`{"Firstname":"Ann",` + "\n" +
+		`{"Firstname":"Bob","Lastname":"Ray","Email":"bob","Age":20}` + "\n"
+	rec := postImport(r, "?upsert=true", "application/x-ndjson; charset=utf-8", body, false)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.True(t, imports.opts.Upsert)
+	require.Len(t, imports.rows, 3)
+	assert.NoError(t, imports.rows[0].Err)
+	assert.Equal(t, "igor@example.com", imports.rows[0].User.Email)
+	assert.Equal(t, 3, imports.rows[1].Row)
+	assert.Error(t, imports.rows[1].Err)
+	assert.Nil(t, imports.rows[1].User)
+	assert.Equal(t, 4, imports.rows[2].Row)
+	assert.Error(t, imports.rows[2].Err)
+}
+
+func TestImportPicksMode(t *testing.T) {
+	small := "Firstname,Lastname,Email,Age\nIgor,Petrov,igor@example.com,30\n"
+	large := small + strings.Repeat("Ann,Lee,ann@example.com,20\n", 10)
+
+	tests := []struct {
+		name    string
+		query   string
+		body    string
+		chunked bool
+		code    int
+	}{
+		{name: "small", body: small, code: http.StatusOK},
+		{name: "large", body: large, code: http.StatusAccepted},
+		{name: "async requested", query: "?async=true", body: small, code: http.StatusAccepted},
+		{name: "small chunked", body: small, chunked: true, code: http.StatusOK},
+		{name: "large chunked", body: large, chunked: true, code: http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imports := &recordingImports{}
+			r := newImportRouter(imports, int64(len(small)), 0)
+
+			rec := postImport(r, tt.query, "text/csv", tt.body, tt.chunked)
+			require.Equal(t, tt.code, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.code == http.StatusAccepted, imports.started)
+			assert.Len(t, imports.rows, strings.Count(tt.body, "\n")-1)
+		})
+	}
+}
+
+func TestImportRefusesLargeBodies(t *testing.T) {
+	body := "Firstname,Lastname,Email,Age\n" + strings.Repeat("Ann,Lee,ann@example.com,20\n", 10)
+
+	for _, chunked := range []bool{false, true} {
+		imports := &recordingImports{}
+		r := newImportRouter(imports, 1<<20, 64)
+
+		rec := postImport(r, "", "text/csv", body, chunked)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, "chunked=%v", chunked)
+		assert.Empty(t, imports.rows)
+	}
+}
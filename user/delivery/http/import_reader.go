@@ -0,0 +1,160 @@
+package http
+
+import (
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/user"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxNDJSONLine bounds the size of one NDJSON record.
+const maxNDJSONLine = 1 << 20
+
+// importFields are the DTOs.User fields read from import files.
+var importFields = []string{"Firstname", "Lastname", "Email", "Age"}
+
+type csvRowReader struct {
+	r       *csv.Reader
+	columns map[string]int
+	row     int
+}
+
+// newCSVRowReader reads the header line of r and locates every user field.
+// mapping renames fields to the column headers used in the file; fields
+// without a mapping are looked up by their own name. Headers match ignoring
+// case.
+func newCSVRowReader(r io.Reader, mapping map[string]string) (user.RowReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	positions := make(map[string]int, len(header))
+	for i, h := range header {
+		positions[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	columns := make(map[string]int, len(importFields))
+	for _, field := range importFields {
+		name := field
+		if mapped, ok := mapping[field]; ok {
+			name = mapped
+		}
+		i, ok := positions[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("CSV column %q for %s is missing", name, field)
+		}
+		columns[field] = i
+	}
+
+	return &csvRowReader{r: cr, columns: columns, row: 1}, nil
+}
+
+func (r *csvRowReader) Next() (*user.ImportRow, error) {
+	record, err := r.r.Read()
+	r.row++
+	if err == io.EOF {
+		return nil, err
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &user.ImportRow{Row: r.row, Err: err}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	get := func(field string) string {
+		if i := r.columns[field]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row := &user.ImportRow{Row: r.row}
+	age, err := strconv.ParseUint(get("Age"), 10, 0)
+	if err != nil {
+		row.Err = fmt.Errorf("invalid Age %q", get("Age"))
+		return row, nil
+	}
+	row.User = &DTOs.User{
+		Firstname: get("Firstname"),
+		Lastname:  get("Lastname"),
+		Email:     get("Email"),
+		Age:       uint(age),
+	}
+	row.Err = validateUser(row.User)
+	return row, nil
+}
+
+type ndjsonRowReader struct {
+	s   *bufio.Scanner
+	row int
+}
+
+// newNDJSONRowReader reads one JSON encoded DTOs.User per line. Blank lines
+// are skipped.
+func newNDJSONRowReader(r io.Reader) user.RowReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxNDJSONLine)
+	return &ndjsonRowReader{s: s}
+}
+
+func (r *ndjsonRowReader) Next() (*user.ImportRow, error) {
+	for r.s.Scan() {
+		r.row++
+		line := strings.TrimSpace(r.s.Text())
+		if line == "" {
+			continue
+		}
+
+		row := &user.ImportRow{Row: r.row, User: new(DTOs.User)}
+		if err := json.Unmarshal([]byte(line), row.User); err != nil {
+			row.User = nil
+			row.Err = err
+			return row, nil
+		}
+		row.Err = validateUser(row.User)
+		return row, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// parseColumnMapping parses "Field:column,Field:column" into a map of user
+// fields to CSV headers.
+func parseColumnMapping(v string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if v == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(v, ",") {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid column mapping %q", pair)
+		}
+		field := ""
+		for _, f := range importFields {
+			if strings.EqualFold(f, parts[0]) {
+				field = f
+			}
+		}
+		if field == "" {
+			return nil, fmt.Errorf("unknown field %q in column mapping", parts[0])
+		}
+		mapping[field] = parts[1]
+	}
+	return mapping, nil
+}
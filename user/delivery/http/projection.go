@@ -0,0 +1,87 @@
+package http
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// expander loads a resource related to each of users, aligned with users.
+type expander func(ctx context.Context, us user.UseCase, users []*entity.User) ([]interface{}, error)
+
+// userExpansions are the related resources expand= can embed in user
+// responses, keyed by the name they are embedded under.
+var userExpansions = map[string]expander{}
+
+// projection is the fields= and expand= selection of a user response.
+type projection struct {
+	fields []string
+	expand []string
+}
+
+// parseProjection reads comma separated fields and expand query parameters,
+// rejecting names that are not in entity.UserFields or userExpansions.
+func parseProjection(c *gin.Context) (*projection, error) {
+	p := new(projection)
+	seen := make(map[string]bool)
+	for _, name := range splitList(c.Query("fields")) {
+		field, ok := entity.UserField(name)
+		if !ok {
+			return nil, models.ErrBadParamInput
+		}
+		if !seen[field] {
+			seen[field] = true
+			p.fields = append(p.fields, field)
+		}
+	}
+	for _, name := range splitList(c.Query("expand")) {
+		if _, ok := userExpansions[name]; !ok {
+			return nil, models.ErrBadParamInput
+		}
+		if !seen[name] {
+			seen[name] = true
+			p.expand = append(p.expand, name)
+		}
+	}
+	return p, nil
+}
+
+func splitList(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// empty reports whether the full entity.User is wanted.
+func (p *projection) empty() bool {
+	return len(p.fields) == 0 && len(p.expand) == 0
+}
+
+// apply restricts users to the selected fields, all of them when none was
+// selected, and embeds the expanded resources.
+func (p *projection) apply(ctx context.Context, us user.UseCase, users []*entity.User) ([]entity.PartialUser, error) {
+	fields := p.fields
+	if len(fields) == 0 {
+		fields = entity.UserFields
+	}
+	res := make([]entity.PartialUser, len(users))
+	for i, u := range users {
+		res[i] = u.Project(fields)
+	}
+
+	for _, name := range p.expand {
+		related, err := userExpansions[name](ctx, us, users)
+		if err != nil {
+			return nil, err
+		}
+		for i := range res {
+			res[i][name] = related[i]
+		}
+	}
+	return res, nil
+}
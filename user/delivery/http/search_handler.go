@@ -0,0 +1,61 @@
+package http
+
+import (
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/user"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	Usecase user.SearchUseCase
+}
+
+// NewSearchHandler registers the search route, guarded by requireTenant.
+func NewSearchHandler(r *gin.Engine, us user.SearchUseCase, requireTenant gin.HandlerFunc) {
+	handler := &SearchHandler{
+		Usecase: us,
+	}
+	v1 := r.Group("/api/v1", requireTenant)
+	v1.GET("/users/search", handler.SearchUsers)
+}
+
+// SearchUsers godoc
+// @Summary      SearchUsers
+// @Description  Search users by name and email, tolerating typos and partial words. Best matches come first.
+// @Tags         Users
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        q       query  string  true   "Search terms"
+// @Param        limit   query  int     false  "Page size"
+// @Param        offset  query  int     false  "Page offset"
+// @Success      200  {object}  []entity.UserSearchHit
+// @Header       200  {int}     X-Total-Count  "Total number of matches"
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /users/search [get]
+func (a *SearchHandler) SearchUsers(c *gin.Context) {
+	limit, offset, err := httputil.ParsePagination(c)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	hits, total, err := a.Usecase.Search(ctx, c.Query("q"), limit, offset)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	httputil.Respond(c, http.StatusOK, hits)
+}
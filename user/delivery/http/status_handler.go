@@ -0,0 +1,135 @@
+package http
+
+import (
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/user"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+type StatusHandler struct {
+	Usecase user.StatusUseCase
+}
+
+// NewStatusHandler registers the status routes, guarded by requireTenant.
+// requireAdmin also guards the transitions.
+func NewStatusHandler(r *gin.Engine, us user.StatusUseCase, requireTenant gin.HandlerFunc, requireAdmin gin.HandlerFunc) {
+	handler := &StatusHandler{
+		Usecase: us,
+	}
+	v1 := r.Group("/api/v1", requireTenant)
+	v1.POST("/users/:id", requireAdmin, handler.StatusAction)
+	v1.GET("/users/:id/status-history", handler.GetStatusHistory)
+}
+
+// StatusAction routes POST /users/{id}:<action> to Transition, gin reading
+// the action as part of the id.
+func (a *StatusHandler) StatusAction(c *gin.Context) {
+	param := c.Param("id")
+	i := strings.LastIndex(param, ":")
+	if i < 0 {
+		httputil.NewError(c, http.StatusNotFound, models.ErrNotFound)
+		return
+	}
+	switch action := param[i+1:]; action {
+	case user.ActionActivate, user.ActionSuspend, user.ActionReactivate, user.ActionDeactivate:
+		a.Transition(c, param[:i], action)
+	default:
+		httputil.NewError(c, http.StatusNotFound, models.ErrNotFound)
+	}
+}
+
+// Transition godoc
+// @Summary      ChangeUserStatus
+// @Description  Move a user to another status: activate (pending to active), suspend (pending or active to suspended), reactivate (suspended or deactivated to active) or deactivate. Other transitions answer 409. Suspending or deactivating ends the sessions of the user. Admins only.
+// @Tags         Users
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        id            path  string             true  "User ID"
+// @Param        action        path  string             true  "activate, suspend, reactivate or deactivate"
+// @Param        StatusChange  body  DTOs.StatusChange  true  "Reason"
+// @Success      200  {object}  entity.User
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      409  {object}  httputil.HTTPError
+// @Router /users/{id}:{action} [post]
+func (a *StatusHandler) Transition(c *gin.Context, idParam string, action string) {
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+	var body DTOs.StatusChange
+	if err := httputil.Bind(c, &body); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return
+	}
+	if err := validator.New().Struct(&body); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Transition(ctx, id, action, body.Reason)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// GetStatusHistory godoc
+// @Summary      GetUserStatusHistory
+// @Description  Return the status transitions of a user and their reasons, newest first
+// @Tags         Users
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        id      path   string  true   "User ID"
+// @Param        limit   query  int     false  "Page size"
+// @Param        offset  query  int     false  "Page offset"
+// @Success      200  {object}  []entity.StatusTransition
+// @Header       200  {int}     X-Total-Count  "Total number of transitions"
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /users/{id}/status-history [get]
+func (a *StatusHandler) GetStatusHistory(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	limit, offset, err := httputil.ParsePagination(c)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	history, total, err := a.Usecase.GetHistory(ctx, id, limit, offset)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	httputil.Respond(c, http.StatusOK, history)
+}
@@ -1,19 +1,22 @@
 package http
 
 import (
+	"GoMastersTest/auth"
 	"GoMastersTest/httputil"
 	_ "GoMastersTest/httputil"
 	"GoMastersTest/models"
 	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
 	"GoMastersTest/user"
 	"context"
-	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"io/ioutil"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gopkg.in/go-playground/validator.v9"
@@ -24,54 +27,113 @@ type ResponseError struct {
 }
 
 type UserHandler struct {
-	Usecase user.UseCase
+	Usecase       user.UseCase
+	MaxBatchItems int
+
+	requireAdmin gin.HandlerFunc
 }
 
-func NewUserHandler(r *gin.Engine, us user.UseCase) {
+// NewUserHandler registers the user routes, all guarded by requireTenant;
+// exporting, deleting and restoring users, one at a time or in batches, also
+// need requireAdmin. Batches over maxBatchItems are rejected; 0 disables the limit.
+func NewUserHandler(r *gin.Engine, us user.UseCase, maxBatchItems int, requireTenant gin.HandlerFunc, requireAdmin gin.HandlerFunc) {
 	handler := &UserHandler{
-		Usecase: us,
+		Usecase:       us,
+		MaxBatchItems: maxBatchItems,
+		requireAdmin:  requireAdmin,
 	}
-	v1 := r.Group("/api/v1")
+	v1 := r.Group("/api/v1", requireTenant)
 	v1.GET("/users", handler.GetAllUsers)
+	v1.GET("/users/export", requireAdmin, handler.ExportUsers)
 	v1.GET("/users/:id", handler.GetUserByID)
 	v1.POST("/users", handler.CreateUser)
 	v1.PUT("/users/:id", handler.UpdateUser)
-	v1.DELETE("/users/:id", handler.DeleteUser)
-
+	v1.DELETE("/users/:id", requireAdmin, handler.DeleteUser)
+	v1.POST("/users/:id/restore", requireAdmin, handler.RestoreUser)
+	v1.GET("/users/:id/history", handler.GetUserHistory)
+	v1.POST("/users:action", handler.BatchAction)
 }
 
 // GetAllUsers godoc
 // @Summary      Get All Users
 // @Description  Return All Users
 // @Tags         Users
-// @Produce      json
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        include_deleted query bool false "Include soft-deleted users (admin only)"
+// @Param        status query string false "Comma separated statuses to keep, e.g. suspended,deactivated"
+// @Param        ids query string false "Comma separated user IDs; answers with a BatchGetResult instead"
+// @Param        fields query string false "Comma separated fields to return, e.g. ID,Firstname"
+// @Param        expand query string false "Comma separated related resources to embed"
 // @Success      200  {object}  []entity.User
 // @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
 // @Failure      404  {object}  httputil.HTTPError
+// @Failure      413  {object}  httputil.HTTPError
 // @Router /users [get]
 func (a *UserHandler) GetAllUsers(c *gin.Context) {
+	if v, ok := c.GetQuery("ids"); ok {
+		ids := make([]uuid.UUID, 0)
+		for _, s := range strings.Split(v, ",") {
+			id, err := uuid.Parse(s)
+			if err != nil {
+				httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+				return
+			}
+			ids = append(ids, id)
+		}
+		a.getUsersByIDs(c, ids)
+		return
+	}
+
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	proj, err := parseProjection(c)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+	filter.Fields = proj.fields
+
 	ctx := c.Request.Context()
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	users, err := a.Usecase.GetAllUsers(ctx)
+	users, err := a.Usecase.GetAllUsers(ctx, filter)
 	if err != nil {
 		httputil.NewError(c, getStatusCode(err), err)
 		return
 	}
-	c.JSON(http.StatusOK, users)
+	if proj.empty() {
+		httputil.Respond(c, http.StatusOK, users)
+		return
+	}
+	partial, err := proj.apply(ctx, a.Usecase, users)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, partial)
 }
 
 // GetUserByID godoc
 // @Summary      Get User By id
 // @Description  Return User By ID
 // @Tags         Users
-// @Accept       json
-// @Produce      json
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
 // @Param        id path string  true  "User Id"
+// @Param        as_of query string false "Return the user as it was at this RFC 3339 time"
+// @Param        fields query string false "Comma separated fields to return, e.g. ID,Firstname"
+// @Param        expand query string false "Comma separated related resources to embed"
 // @Success      200  {object}  entity.User
 // @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
 // @Failure      404  {object}  httputil.HTTPError
 // @Router /users/{id} [get]
 func (a *UserHandler) GetUserByID(c *gin.Context) {
@@ -85,6 +147,13 @@ func (a *UserHandler) GetUserByID(c *gin.Context) {
 
 	if err != nil {
 		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	proj, err := parseProjection(c)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
 	}
 
 	ctx := c.Request.Context()
@@ -92,37 +161,53 @@ func (a *UserHandler) GetUserByID(c *gin.Context) {
 		ctx = context.Background()
 	}
 
-	user, err := a.Usecase.GetByID(ctx, id)
+	var user *entity.User
+	if asOf, ok := c.GetQuery("as_of"); ok {
+		at, err := time.Parse(time.RFC3339, asOf)
+		if err != nil {
+			httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+			return
+		}
+		user, err = a.Usecase.GetAsOf(ctx, id, at)
+	} else {
+		user, err = a.Usecase.GetByID(ctx, id, proj.fields...)
+	}
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	if proj.empty() {
+		httputil.Respond(c, http.StatusOK, user)
+		return
+	}
+	partial, err := proj.apply(ctx, a.Usecase, []*entity.User{user})
 	if err != nil {
 		httputil.NewError(c, getStatusCode(err), err)
 		return
 	}
-	c.JSON(http.StatusOK, user)
+	httputil.Respond(c, http.StatusOK, partial[0])
 }
 
 // CreateUser godoc
 // @Summary CreateUser
-// @Description  Return Created User
+// @Description  Return Created User. An optional Password is checked and set along with the user.
 // @Tags         Users
-// @Accept       json
-// @Produce      json
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
 // @Param        User  body     DTOs.User  true  "Add User"
-// @Success      201  {object}  string
+// @Success      201  {object}  entity.User
+// @Header       201  {string}  Location  "URL of the created user"
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      415  {object}  httputil.HTTPError
 // @Failure      422  {object}  httputil.HTTPError
 // @Failure      404  {object}  httputil.HTTPError
 // @Router /users [post]
 func (a *UserHandler) CreateUser(c *gin.Context) {
 	var user DTOs.User
 
-	jsonDataBytes, err := ioutil.ReadAll(c.Request.Body)
-	if err != nil {
-		httputil.NewError(c, http.StatusUnprocessableEntity, err)
-		return
-	}
-
-	err = json.Unmarshal(jsonDataBytes, &user)
-	if err != nil {
-		httputil.NewError(c, http.StatusUnprocessableEntity, err)
+	if err := httputil.Bind(c, &user); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
 		return
 	}
 
@@ -144,58 +229,65 @@ func (a *UserHandler) CreateUser(c *gin.Context) {
 		ctx = context.Background()
 	}
 
-	id, err := a.Usecase.Create(ctx, &user)
+	res, err := a.Usecase.Create(ctx, &user)
 
 	if err != nil {
 		httputil.NewError(c, getStatusCode(err), err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, id.String())
+	c.Header("Location", fmt.Sprintf("%s/%s", c.FullPath(), res.ID))
+	httputil.Respond(c, http.StatusCreated, res)
 }
 
 // UpdateUser godoc
 // @Summary      UpdateUser
-// @Description  UpdateUser
+// @Description  UpdateUser. Changing the email clears EmailVerified and mails a verification link to the new address. Passwords are refused with 400; they change through PUT /users/{id}/password.
 // @Tags         Users
-// @Accept       json
-// @Produce      json
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
 // @Param        id    path     string  true  "User ID"
 // @Param        User  body     DTOs.User  true  "Update user"
 // @Success      200  {object}  entity.User
 // @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      415  {object}  httputil.HTTPError
 // @Failure      422  {object}  httputil.HTTPError
 // @Router       /users/{id} [put]
 func (a *UserHandler) UpdateUser(c *gin.Context) {
 	var user DTOs.User
 	idP := c.Param("id")
-	if strings.Count(idP, "") == 0 {
+	if strings.Count(idP, "")-1 == 0 {
 		httputil.NewError(c, http.StatusNotFound, models.ErrNotFound)
+		return
 	}
 
 	id, err := uuid.Parse(idP)
 
 	if err != nil {
 		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
-	}
-
-	jsonDataBytes, err := ioutil.ReadAll(c.Request.Body)
-	if err != nil {
-		httputil.NewError(c, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	err = json.Unmarshal(jsonDataBytes, &user)
-	if err != nil {
-		httputil.NewError(c, http.StatusUnprocessableEntity, err)
+	if err := httputil.Bind(c, &user); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
 		return
 	}
 
 	if ok, err := isRequestValid(&user); !ok {
 		httputil.NewError(c, http.StatusBadRequest, err)
+		return
 	}
 	if ok, err := validateEmail(&user); !ok {
-		httputil.NewError(c, http.StatusBadRequest, err)
+		if err != nil {
+			httputil.NewError(c, http.StatusBadRequest, err)
+			return
+		} else {
+			httputil.NewError(c, http.StatusBadRequest, models.ErrEmailValid)
+			return
+		}
 	}
 	ctx := c.Request.Context()
 	if ctx == nil {
@@ -208,65 +300,449 @@ func (a *UserHandler) UpdateUser(c *gin.Context) {
 		httputil.NewError(c, getStatusCode(err), err)
 		return
 	}
-	c.JSON(http.StatusOK, res)
+	httputil.Respond(c, http.StatusOK, res)
 }
 
 // DeleteUser godoc
 // @Summary 	 DeleteUser
 // @Description  DeleteUser
 // @Tags         Users
-// @Accept       json
-// @Produce      json
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
 // @Param        id   path      string  true  "User ID"
 // @Success      204  {object}  string
 // @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
 // @Failure      404  {object}  httputil.HTTPError
 // @Router /users/{id} [delete]
 func (a *UserHandler) DeleteUser(c *gin.Context) {
-	idP := c.Param("id")
-	if strings.Count(idP, "") == 0 {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	err = a.Usecase.Delete(ctx, id)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	httputil.Respond(c, http.StatusNoContent, "Success")
+}
+
+// RestoreUser godoc
+// @Summary      RestoreUser
+// @Description  Restore a soft-deleted user
+// @Tags         Users
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  entity.User
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Router /users/{id}/restore [post]
+func (a *UserHandler) RestoreUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Restore(ctx, id)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// BatchAction routes POST /users:<action> to the batch endpoints. The actions
+// share one route, so batch deletes run the admin guard here.
+func (a *UserHandler) BatchAction(c *gin.Context) {
+	switch c.Param("action") {
+	case ":batchCreate":
+		a.BatchCreateUsers(c)
+	case ":batchUpdate":
+		a.BatchUpdateUsers(c)
+	case ":batchDelete":
+		if a.requireAdmin(c); c.IsAborted() {
+			return
+		}
+		a.BatchDeleteUsers(c)
+	case ":batchGet":
+		a.BatchGetUsers(c)
+	default:
 		httputil.NewError(c, http.StatusNotFound, models.ErrNotFound)
 	}
+}
 
-	id, err := uuid.Parse(idP)
+// BatchCreateUsers godoc
+// @Summary      BatchCreateUsers
+// @Description  Create many users. Atomic batches create all users or none; best-effort batches create the valid ones.
+// @Tags         Users
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        Batch  body  DTOs.BatchCreateUsers  true  "Users to create"
+// @Success      200  {object}  []entity.BatchItemResult
+// @Success      207  {object}  []entity.BatchItemResult
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      422  {object}  []entity.BatchItemResult
+// @Router       /users:batchCreate [post]
+func (a *UserHandler) BatchCreateUsers(c *gin.Context) {
+	var batch DTOs.BatchCreateUsers
+	if !bindBatch(c, &batch) || !a.checkBatchSize(c, len(batch.Users)) {
+		return
+	}
+	atomic := batch.Mode != DTOs.BatchModeBestEffort
+
+	results := make([]*entity.BatchItemResult, len(batch.Users))
+	valid := make([]*DTOs.User, 0, len(batch.Users))
+	positions := make([]int, 0, len(batch.Users))
+	for i := range batch.Users {
+		if err := validateUser(&batch.Users[i]); err != nil {
+			results[i] = &entity.BatchItemResult{Index: i, Status: http.StatusBadRequest, Error: err.Error()}
+			continue
+		}
+		valid = append(valid, &batch.Users[i])
+		positions = append(positions, i)
+	}
+	if atomic && len(valid) < len(batch.Users) {
+		writeBatchResults(c, atomic, failDependents(results))
+		return
+	}
 
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	created, err := a.Usecase.CreateMany(ctx, valid)
+	for k, i := range positions {
+		if err != nil {
+			results[i] = &entity.BatchItemResult{Index: i, Status: getStatusCode(err), Error: err.Error()}
+			continue
+		}
+		results[i] = &entity.BatchItemResult{Index: i, Status: http.StatusCreated, User: created[k]}
+	}
+	writeBatchResults(c, atomic, results)
+}
+
+// BatchUpdateUsers godoc
+// @Summary      BatchUpdateUsers
+// @Description  Update many users. Atomic batches update all users or none; best-effort batches update the ones that are valid and exist.
+// @Tags         Users
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        Batch  body  DTOs.BatchUpdateUsers  true  "Users to update"
+// @Success      200  {object}  []entity.BatchItemResult
+// @Success      207  {object}  []entity.BatchItemResult
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      422  {object}  []entity.BatchItemResult
+// @Router       /users:batchUpdate [post]
+func (a *UserHandler) BatchUpdateUsers(c *gin.Context) {
+	var batch DTOs.BatchUpdateUsers
+	if !bindBatch(c, &batch) || !a.checkBatchSize(c, len(batch.Users)) {
+		return
+	}
+	atomic := batch.Mode != DTOs.BatchModeBestEffort
+
+	results := make([]*entity.BatchItemResult, len(batch.Users))
+	valid := make([]*DTOs.UserUpdate, 0, len(batch.Users))
+	positions := make([]int, 0, len(batch.Users))
+	for i := range batch.Users {
+		if err := validateUser(&batch.Users[i].User); err != nil {
+			results[i] = &entity.BatchItemResult{Index: i, Status: http.StatusBadRequest, Error: err.Error()}
+			continue
+		}
+		valid = append(valid, &batch.Users[i])
+		positions = append(positions, i)
+	}
+	if atomic && len(valid) < len(batch.Users) {
+		writeBatchResults(c, atomic, failDependents(results))
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	updated, err := a.Usecase.UpdateMany(ctx, valid, atomic)
+	if err != nil && updated == nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	for k, i := range positions {
+		results[i] = itemResult(i, updated[k], http.StatusOK)
+	}
+	if err != nil {
+		results = failDependents(results)
+	}
+	writeBatchResults(c, atomic, results)
+}
+
+// BatchDeleteUsers godoc
+// @Summary      BatchDeleteUsers
+// @Description  Delete many users, for admins only. Atomic batches delete all users or none; best-effort batches delete the ones that exist.
+// @Tags         Users
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        Batch  body  DTOs.BatchDeleteUsers  true  "Users to delete"
+// @Success      200  {object}  []entity.BatchItemResult
+// @Success      207  {object}  []entity.BatchItemResult
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      422  {object}  []entity.BatchItemResult
+// @Router       /users:batchDelete [post]
+func (a *UserHandler) BatchDeleteUsers(c *gin.Context) {
+	var batch DTOs.BatchDeleteUsers
+	if !bindBatch(c, &batch) || !a.checkBatchSize(c, len(batch.IDs)) {
+		return
+	}
+	atomic := batch.Mode != DTOs.BatchModeBestEffort
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	deleted, err := a.Usecase.DeleteMany(ctx, batch.IDs, atomic)
+	if err != nil && deleted == nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	results := make([]*entity.BatchItemResult, len(batch.IDs))
+	for i := range batch.IDs {
+		results[i] = itemResult(i, deleted[i], http.StatusOK)
+	}
+	if err != nil {
+		results = failDependents(results)
+	}
+	writeBatchResults(c, atomic, results)
+}
+
+// BatchGetUsers godoc
+// @Summary      BatchGetUsers
+// @Description  Look many users up at once. Users come back in request order; IDs that do not exist are listed in Missing.
+// @Tags         Users
+// @Accept       json,xml,application/x-yaml,application/x-msgpack
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        Batch  body  DTOs.BatchGetUsers  true  "IDs to look up"
+// @Success      200  {object}  entity.BatchGetResult
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      413  {object}  httputil.HTTPError
+// @Router       /users:batchGet [post]
+func (a *UserHandler) BatchGetUsers(c *gin.Context) {
+	var batch DTOs.BatchGetUsers
+	if !bindBatch(c, &batch) {
+		return
+	}
+	a.getUsersByIDs(c, batch.IDs)
+}
+
+func (a *UserHandler) getUsersByIDs(c *gin.Context, ids []uuid.UUID) {
+	if !a.checkBatchSize(c, len(ids)) {
+		return
+	}
+
+	users, err := a.Usecase.GetByIDs(c.Request.Context(), ids)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	res := &entity.BatchGetResult{
+		Users:   make([]*entity.User, 0, len(users)),
+		Missing: make([]uuid.UUID, 0),
+	}
+	for i, u := range users {
+		if u == nil {
+			res.Missing = append(res.Missing, ids[i])
+		} else {
+			res.Users = append(res.Users, u)
+		}
+	}
+	httputil.Respond(c, http.StatusOK, res)
+}
+
+// bindBatch decodes and validates a batch request. It writes the error
+// response and returns false when the request is unusable.
+func bindBatch(c *gin.Context, batch interface{}) bool {
+	if err := httputil.Bind(c, batch); err != nil {
+		httputil.NewError(c, httputil.BindStatus(err), err)
+		return false
+	}
+	if err := validator.New().Struct(batch); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+func (a *UserHandler) checkBatchSize(c *gin.Context, size int) bool {
+	if a.MaxBatchItems > 0 && size > a.MaxBatchItems {
+		httputil.NewError(c, http.StatusRequestEntityTooLarge, models.ErrBatchTooLarge)
+		return false
+	}
+	return true
+}
+
+func itemResult(index int, u *entity.User, status int) *entity.BatchItemResult {
+	if u == nil {
+		return &entity.BatchItemResult{Index: index, Status: http.StatusNotFound, Error: models.ErrNotFound.Error()}
+	}
+	return &entity.BatchItemResult{Index: index, Status: status, User: u}
+}
+
+// failDependents marks the items that succeeded on their own as failed
+// because the atomic batch they belong to was not applied.
+func failDependents(results []*entity.BatchItemResult) []*entity.BatchItemResult {
+	for i, r := range results {
+		if r == nil || r.Status < http.StatusBadRequest {
+			results[i] = &entity.BatchItemResult{Index: i, Status: http.StatusFailedDependency, Error: models.ErrBatchAborted.Error()}
+		}
+	}
+	return results
+}
+
+// writeBatchResults answers 200 for applied atomic batches, 422 for rejected
+// ones and 207 for best-effort batches.
+func writeBatchResults(c *gin.Context, atomic bool, results []*entity.BatchItemResult) {
+	status := http.StatusMultiStatus
+	if atomic {
+		status = http.StatusOK
+		for _, r := range results {
+			if r.Status >= http.StatusBadRequest {
+				status = http.StatusUnprocessableEntity
+				break
+			}
+		}
+	}
+	httputil.Respond(c, status, results)
+}
+
+// GetUserHistory godoc
+// @Summary      GetUserHistory
+// @Description  Return the audit trail of a user, newest first
+// @Tags         Users
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        id      path   string  true   "User ID"
+// @Param        limit   query  int     false  "Page size"
+// @Param        offset  query  int     false  "Page offset"
+// @Success      200  {object}  []entity.UserHistory
+// @Header       200  {int}     X-Total-Count  "Total number of records"
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /users/{id}/history [get]
+func (a *UserHandler) GetUserHistory(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
 	}
 
+	limit, offset, err := httputil.ParsePagination(c)
 	if err != nil {
 		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
 	}
+
 	ctx := c.Request.Context()
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	err = a.Usecase.Delete(ctx, id)
+	history, total, err := a.Usecase.GetHistory(ctx, id, limit, offset)
 	if err != nil {
 		httputil.NewError(c, getStatusCode(err), err)
 		return
 	}
 
-	c.JSON(http.StatusNoContent, "Success")
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	httputil.Respond(c, http.StatusOK, history)
 }
 
-func getStatusCode(err error) int {
-	if err == nil {
-		return http.StatusOK
+// parseUserFilter reads the list filters of the query. Only admins may see
+// deleted users.
+func parseUserFilter(c *gin.Context) (*DTOs.UserFilter, error) {
+	filter := new(DTOs.UserFilter)
+	if v, ok := c.GetQuery("include_deleted"); ok {
+		includeDeleted, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, models.ErrBadParamInput
+		}
+		if includeDeleted && !models.HasRole(c.Request.Context(), auth.RoleAdmin) {
+			return nil, models.ErrForbidden
+		}
+		filter.IncludeDeleted = includeDeleted
+	}
+	if v, ok := c.GetQuery("status"); ok {
+		for _, status := range strings.Split(v, ",") {
+			if !validStatus(status) {
+				return nil, models.ErrBadParamInput
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
 	}
-	logrus.Error(err)
+	return filter, nil
+}
+
+func validStatus(status string) bool {
+	for _, s := range entity.UserStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func getStatusCode(err error) int {
 	switch err {
-	case models.ErrInternalServerError:
-		return http.StatusInternalServerError
-	case models.ErrNotFound:
-		return http.StatusNotFound
-	case models.ErrConflict:
+	case models.ErrEmailVerified, models.ErrInvalidTransition:
+		logrus.Error(err)
 		return http.StatusConflict
-	default:
-		return http.StatusInternalServerError
+	case models.ErrInvalidToken:
+		logrus.Error(err)
+		return http.StatusBadRequest
 	}
+	return httputil.StatusCode(err)
 }
+func validateUser(m *DTOs.User) error {
+	if ok, err := isRequestValid(m); !ok {
+		return err
+	}
+	ok, err := validateEmail(m)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return models.ErrEmailValid
+	}
+	return nil
+}
+
 func isRequestValid(m *DTOs.User) (bool, error) {
 	validate := validator.New()
 	err := validate.Struct(m)
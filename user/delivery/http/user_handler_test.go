@@ -1,12 +1,21 @@
 package http_test
 
 import (
+	"GoMastersTest/auth"
+	authRepository "GoMastersTest/auth/repository"
+	authUsecase "GoMastersTest/auth/usecase"
+	"GoMastersTest/dbutil"
+	eventRepository "GoMastersTest/event/repository"
+	"GoMastersTest/mail"
+	"GoMastersTest/middleware"
+	"GoMastersTest/models"
 	"GoMastersTest/models/DTOs"
 	"GoMastersTest/models/entity"
 	"GoMastersTest/user"
 	httpHandler "GoMastersTest/user/delivery/http"
 	"GoMastersTest/user/repository"
 	"GoMastersTest/user/usecase"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -17,6 +26,8 @@ import (
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
@@ -58,7 +69,38 @@ func InitRepoAndUseCase() (*user.UseCase, error) {
 
 	timeoutContext := 60 * time.Second
 	repo := repository.NewPostgreUserRepository(dbConn)
-	useCase := usecase.NewUserUseCase(repo, timeoutContext)
+	historyRepo := repository.NewPostgreUserHistoryRepository(dbConn)
+	outboxRepo := eventRepository.NewPostgreOutboxRepository(dbConn)
+	transactor := dbutil.NewTransactor(dbConn)
+	hasher, err := auth.NewBcryptHasher(bcrypt.MinCost)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := auth.NewTokenIssuer(viper.GetString("auth.signing_key"), "test", time.Minute, time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	box, err := auth.NewSecretBox(strings.Repeat("k", 32))
+	if err != nil {
+		return nil, err
+	}
+	twoFactorUseCase := authUsecase.NewTwoFactorUseCase(repo, authRepository.NewPostgreTOTPRepository(dbConn),
+		authRepository.NewPostgreRecoveryCodeRepository(dbConn), authRepository.NewPostgreRefreshTokenRepository(dbConn), box,
+		"test", transactor, timeoutContext)
+	authUseCase := authUsecase.NewAuthUseCase(repo, historyRepo, authRepository.NewPostgreCredentialRepository(dbConn),
+		authRepository.NewPostgreRefreshTokenRepository(dbConn), authRepository.NewPostgreRoleRepository(dbConn),
+		authRepository.NewMemoryLoginAttemptRepository(), hasher, auth.NewPasswordPolicy(0, 0), &auth.LockoutPolicy{},
+		tokens, twoFactorUseCase, nil, transactor, timeoutContext)
+	templates, err := mail.LoadTemplates("")
+	if err != nil {
+		return nil, err
+	}
+	statusUseCase := usecase.NewStatusUseCase(repo, historyRepo, outboxRepo, repository.NewPostgreStatusHistoryRepository(dbConn),
+		authUseCase, transactor, timeoutContext)
+	verificationUseCase := usecase.NewVerificationUseCase(repo, historyRepo, outboxRepo,
+		repository.NewPostgreVerificationRepository(dbConn), statusUseCase, mail.NewWriterMailer("test@example.com", io.Discard), templates, tokens,
+		"http://localhost/verify-email", time.Hour, transactor, timeoutContext)
+	useCase := usecase.NewUserUseCase(repo, historyRepo, outboxRepo, authUseCase, verificationUseCase, transactor, timeoutContext)
 
 	return &useCase, nil
 }
@@ -317,3 +359,92 @@ func TestUpdate(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 }
+
+// adminOnlyUsers records the calls that got through the admin guards.
+type adminOnlyUsers struct {
+	user.UseCase
+	calls []string
+}
+
+func (f *adminOnlyUsers) GetAllUsers(ctx context.Context, filter *DTOs.UserFilter) ([]*entity.User, error) {
+	f.calls = append(f.calls, fmt.Sprintf("list deleted=%v", filter.IncludeDeleted))
+	return []*entity.User{}, nil
+}
+
+func (f *adminOnlyUsers) Delete(ctx context.Context, id uuid.UUID) error {
+	f.calls = append(f.calls, "delete")
+	return nil
+}
+
+func (f *adminOnlyUsers) DeleteMany(ctx context.Context, ids []uuid.UUID, atomic bool) ([]*entity.User, error) {
+	f.calls = append(f.calls, "batch delete")
+	res := make([]*entity.User, len(ids))
+	for i, id := range ids {
+		res[i] = &entity.User{ID: id}
+	}
+	return res, nil
+}
+
+func (f *adminOnlyUsers) Restore(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	f.calls = append(f.calls, "restore")
+	return &entity.User{ID: id}, nil
+}
+
+func TestAdminOnlyOperations(t *testing.T) {
+	id := uuid.NewString()
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		admin  bool
+		code   int
+		calls  []string
+	}{
+		{name: "list", method: http.MethodGet, target: "/api/v1/users", code: http.StatusOK, calls: []string{"list deleted=false"}},
+		{name: "list deleted", method: http.MethodGet, target: "/api/v1/users?include_deleted=true", code: http.StatusForbidden},
+		{name: "list deleted as admin", method: http.MethodGet, target: "/api/v1/users?include_deleted=true", admin: true,
+			code: http.StatusOK, calls: []string{"list deleted=true"}},
+		{name: "bad include_deleted", method: http.MethodGet, target: "/api/v1/users?include_deleted=maybe", admin: true, code: http.StatusBadRequest},
+		{name: "export deleted", method: http.MethodGet, target: "/api/v1/users/export?format=csv&include_deleted=true", code: http.StatusForbidden},
+		{name: "delete", method: http.MethodDelete, target: "/api/v1/users/" + id, code: http.StatusForbidden},
+		{name: "delete as admin", method: http.MethodDelete, target: "/api/v1/users/" + id, admin: true,
+			code: http.StatusNoContent, calls: []string{"delete"}},
+		{name: "delete a bad ID", method: http.MethodDelete, target: "/api/v1/users/not-a-uuid", admin: true, code: http.StatusBadRequest},
+		{name: "restore", method: http.MethodPost, target: "/api/v1/users/" + id + "/restore", code: http.StatusForbidden},
+		{name: "restore as admin", method: http.MethodPost, target: "/api/v1/users/" + id + "/restore", admin: true,
+			code: http.StatusOK, calls: []string{"restore"}},
+		{name: "batch delete", method: http.MethodPost, target: "/api/v1/users:batchDelete", body: `{"IDs":["` + id + `"]}`,
+			code: http.StatusForbidden},
+		{name: "batch delete as admin", method: http.MethodPost, target: "/api/v1/users:batchDelete", body: `{"IDs":["` + id + `"]}`,
+			admin: true, code: http.StatusOK, calls: []string{"batch delete"}},
+		{name: "unknown batch action", method: http.MethodPost, target: "/api/v1/users:batchPurge", body: `{}`, admin: true,
+			code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				ctx := models.WithUserID(c.Request.Context(), uuid.New())
+				if tt.admin {
+					ctx = models.WithRoles(ctx, []string{auth.RoleAdmin})
+				}
+				c.Request = c.Request.WithContext(ctx)
+			})
+			us := &adminOnlyUsers{}
+			middL := middleware.InitMiddleware()
+			httpHandler.NewUserHandler(r, us, 10, func(c *gin.Context) { c.Next() }, middL.RequireRole(auth.RoleAdmin))
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer token")
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.calls, us.calls)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/user"
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type VerificationHandler struct {
+	Usecase user.VerificationUseCase
+}
+
+// NewVerificationHandler registers the verification routes. requireTenant
+// guards sending; the link is followed without an access token.
+func NewVerificationHandler(r *gin.Engine, us user.VerificationUseCase, requireTenant gin.HandlerFunc) {
+	handler := &VerificationHandler{
+		Usecase: us,
+	}
+	v1 := r.Group("/api/v1")
+	v1.POST("/users/:id/verify-email/send", requireTenant, handler.SendVerification)
+	v1.GET("/verify-email", handler.VerifyEmail)
+}
+
+// SendVerification godoc
+// @Summary      SendVerification
+// @Description  Mail a link to verify the current email of a user. Each link works once and expires.
+// @Tags         Users
+// @Param        id  path  string  true  "User ID"
+// @Success      202
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      409  {object}  httputil.HTTPError
+// @Router /users/{id}/verify-email/send [post]
+func (a *VerificationHandler) SendVerification(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	err = a.Usecase.Send(ctx, id)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// VerifyEmail godoc
+// @Summary      VerifyEmail
+// @Description  Follow a verification link, marking the email it was sent to verified
+// @Tags         Users
+// @Produce      json,xml,application/x-yaml,application/x-msgpack
+// @Param        token  query  string  true  "Token of the link"
+// @Success      200  {object}  entity.User
+// @Failure      400  {object}  httputil.HTTPError
+// @Router /verify-email [get]
+func (a *VerificationHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Verify(ctx, token)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	httputil.Respond(c, http.StatusOK, res)
+}
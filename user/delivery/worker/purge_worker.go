@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"GoMastersTest/user"
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type PurgeWorker struct {
+	Usecase   user.UseCase
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+func NewPurgeWorker(us user.UseCase, retention time.Duration, interval time.Duration) *PurgeWorker {
+	return &PurgeWorker{
+		Usecase:   us,
+		Retention: retention,
+		Interval:  interval,
+	}
+}
+
+// Run hard-deletes users soft-deleted longer than Retention ago, once per
+// Interval, until ctx is cancelled. A non-positive Interval disables purging,
+// and so does a non-positive Retention, which would purge users as soon as
+// they are deleted, leaving no time to restore them.
+func (w *PurgeWorker) Run(ctx context.Context) {
+	if w.Interval <= 0 {
+		logrus.Warnf("purge interval is %v, soft-deleted users will not be purged", w.Interval)
+		return
+	}
+	if w.Retention <= 0 {
+		logrus.Warnf("purge retention is %v, soft-deleted users will not be purged", w.Retention)
+		return
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := w.Usecase.Purge(ctx, w.Retention)
+		if err != nil {
+			logrus.Error(err)
+		} else if purged > 0 {
+			logrus.Infof("purged %d soft-deleted users", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package worker_test
+
+import (
+	"GoMastersTest/user"
+	"GoMastersTest/user/delivery/worker"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingPurges struct {
+	user.UseCase
+	calls int
+}
+
+func (f *countingPurges) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	f.calls++
+	return 0, nil
+}
+
+func TestPurgeWorkerRunsUntilCancelled(t *testing.T) {
+	us := &countingPurges{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	worker.NewPurgeWorker(us, time.Hour, time.Minute).Run(ctx)
+	assert.Equal(t, 1, us.calls)
+}
+
+func TestPurgeWorkerDisabledWithoutInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		us := &countingPurges{}
+		worker.NewPurgeWorker(us, time.Hour, interval).Run(context.Background())
+		assert.Zero(t, us.calls, "interval %v", interval)
+	}
+}
+
+func TestPurgeWorkerDisabledWithoutRetention(t *testing.T) {
+	for _, retention := range []time.Duration{0, -time.Hour} {
+		us := &countingPurges{}
+		worker.NewPurgeWorker(us, retention, time.Minute).Run(context.Background())
+		assert.Zero(t, us.calls, "retention %v", retention)
+	}
+}
@@ -0,0 +1,32 @@
+package user
+
+import (
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+)
+
+// ImportRow is one parsed row of an import file. Err is set when the row
+// could not be parsed or is not a valid user.
+type ImportRow struct {
+	Row  int
+	User *DTOs.User
+	Err  error
+}
+
+// RowReader streams the rows of an import file. Next returns io.EOF after the
+// last row; any other error means the rest of the file cannot be read.
+type RowReader interface {
+	Next() (*ImportRow, error)
+}
+
+type ImportUseCase interface {
+	// Import reads every row and returns the finished report.
+	Import(ctx context.Context, rows RowReader, opts DTOs.ImportOptions) (*entity.ImportJob, error)
+	// Start imports in the background and returns the running job. The import
+	// acts for the same caller and organization as ctx but outlives it. done is
+	// called once the rows have been consumed.
+	Start(ctx context.Context, rows RowReader, opts DTOs.ImportOptions, done func()) *entity.ImportJob
+	GetJob(ctx context.Context, id uuid.UUID) (*entity.ImportJob, error)
+}
@@ -5,12 +5,41 @@ import (
 	"GoMastersTest/models/entity"
 	"context"
 	"github.com/google/uuid"
+	"time"
 )
 
+// Repository stores users. Requests scoped to an organization with
+// models.WithOrgID only reach its members and the caller, and the users they
+// create join it.
 type Repository interface {
-	GetAllUsers(ctx context.Context) ([]*entity.User, error)
-	GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error)
-	Create(ctx context.Context, user *DTOs.User) (id uuid.UUID, err error)
+	GetAllUsers(ctx context.Context, filter *DTOs.UserFilter) ([]*entity.User, error)
+	// GetByID loads only the given entity.UserFields when fields are passed.
+	GetByID(ctx context.Context, id uuid.UUID, fields ...string) (*entity.User, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*entity.User, error)
+	Stream(ctx context.Context, filter *DTOs.UserFilter, fn func(*entity.User) error) error
+	GetByEmails(ctx context.Context, emails []string) ([]*entity.User, error)
+	Create(ctx context.Context, user *DTOs.User) (*entity.User, error)
+	// Update clears EmailVerified when the email changes, ignoring case.
 	Update(ctx context.Context, id uuid.UUID, user *DTOs.User) (*entity.User, error)
+	// SetEmailVerified marks the user verified if their email is still email,
+	// ignoring case, and fails with ErrNotFound otherwise.
+	SetEmailVerified(ctx context.Context, id uuid.UUID, email string) (*entity.User, error)
+	// SetStatus moves the user from status from to status to, and fails with
+	// ErrConflict when the user is no longer in status from.
+	SetStatus(ctx context.Context, id uuid.UUID, from string, to string) (*entity.User, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	// Restore returns the user as they were while deleted and once restored.
+	Restore(ctx context.Context, id uuid.UUID) (before *entity.User, after *entity.User, err error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	CreateMany(ctx context.Context, users []*DTOs.User) ([]*entity.User, error)
+	// UpdateMany clears EmailVerified like Update.
+	UpdateMany(ctx context.Context, updates []*DTOs.UserUpdate) (before []*entity.User, after []*entity.User, err error)
+	DeleteMany(ctx context.Context, ids []uuid.UUID) ([]*entity.User, error)
+}
+
+type HistoryRepository interface {
+	Store(ctx context.Context, record *entity.UserHistory) error
+	StoreMany(ctx context.Context, records []*entity.UserHistory) error
+	GetByUserID(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]*entity.UserHistory, int, error)
+	GetAsOf(ctx context.Context, userID uuid.UUID, at time.Time) (*entity.UserHistory, error)
 }
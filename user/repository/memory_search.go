@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TrigramThreshold is the minimum trigram similarity for a word to match a
+// misspelt search term.
+const TrigramThreshold = 0.3
+
+type memorySearchRepository struct {
+	users user.Repository
+}
+
+// NewMemorySearchRepository searches the users of users in memory, with the
+// same matching rules as the Postgres search kept simple: every search term
+// must equal, start, appear in or be similar to a word of the user's names or
+// email.
+func NewMemorySearchRepository(users user.Repository) user.SearchRepository {
+	return &memorySearchRepository{users}
+}
+
+func (m *memorySearchRepository) Search(ctx context.Context, query string, limit int, offset int) ([]*entity.UserSearchHit, int, error) {
+	terms := words(query)
+	all, err := m.users.GetAllUsers(ctx, nil)
+	if err != nil && err != models.ErrNotFound {
+		return nil, 0, err
+	}
+
+	hits := make([]*entity.UserSearchHit, 0)
+	for _, u := range all {
+		text := u.Firstname + " " + u.Lastname + " " + u.Email
+		if hit := match(terms, text); hit != nil {
+			hit.User = u
+			hits = append(hits, hit)
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return hits[i].User.Created.Before(hits[j].User.Created)
+	})
+
+	total := len(hits)
+	if offset > total {
+		offset = total
+	}
+	hits = hits[offset:]
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, total, nil
+}
+
+// match ranks text against terms by their average best word score, or
+// returns nil when a term matches no word.
+func match(terms []string, text string) *entity.UserSearchHit {
+	if len(terms) == 0 {
+		return nil
+	}
+	candidates := words(text)
+	matched := make(map[string]bool)
+	var rank float64
+	for _, term := range terms {
+		best, bestWord := 0.0, ""
+		for _, w := range candidates {
+			if s := wordScore(term, w); s > best {
+				best, bestWord = s, w
+			}
+		}
+		if best < TrigramThreshold {
+			return nil
+		}
+		rank += best
+		matched[bestWord] = true
+	}
+	return &entity.UserSearchHit{Rank: rank / float64(len(terms)), Highlight: highlight(text, matched)}
+}
+
+func wordScore(term string, word string) float64 {
+	switch {
+	case term == word:
+		return 1
+	case strings.HasPrefix(word, term):
+		return 0.9
+	case strings.Contains(word, term):
+		return 0.8
+	}
+	return similarity(term, word)
+}
+
+// similarity is the pg_trgm similarity of two words: the share of their
+// trigrams, padded with two leading and one trailing space, they have in
+// common.
+func similarity(a string, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	union := len(ta) + len(tb) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+func trigrams(word string) map[string]bool {
+	padded := []rune("  " + word + " ")
+	res := make(map[string]bool)
+	for i := 0; i+3 <= len(padded); i++ {
+		res[string(padded[i:i+3])] = true
+	}
+	return res
+}
+
+// words splits s into lower-case runs of letters and digits, so that emails
+// match by their parts.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), isSeparator)
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// highlight wraps the words of text found in matched in <mark> tags.
+func highlight(text string, matched map[string]bool) string {
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if isSeparator(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && !isSeparator(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		if matched[strings.ToLower(word)] {
+			b.WriteString("<mark>" + word + "</mark>")
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package repository_test
+
+import (
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"GoMastersTest/user/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUsers struct {
+	user.Repository
+	users []*entity.User
+}
+
+func (f *fakeUsers) GetAllUsers(ctx context.Context, filter *DTOs.UserFilter) ([]*entity.User, error) {
+	return f.users, nil
+}
+
+func newUsers(names ...[3]string) *fakeUsers {
+	f := new(fakeUsers)
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, n := range names {
+		f.users = append(f.users, &entity.User{
+			User:    DTOs.User{Firstname: n[0], Lastname: n[1], Email: n[2]},
+			ID:      uuid.New(),
+			Created: created.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	return f
+}
+
+func TestMemorySearch(t *testing.T) {
+	users := newUsers(
+		[3]string{"Igor", "Kormich", "igor@example.com"},
+		[3]string{"Anna", "Kormicheva", "anna@example.com"},
+		[3]string{"Boris", "Petrov", "boris@example.com"},
+	)
+	repo := repository.NewMemorySearchRepository(users)
+	ctx := context.Background()
+
+	t.Run("exact match ranks first", func(t *testing.T) {
+		hits, total, err := repo.Search(ctx, "kormich", 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+		require.Len(t, hits, 2)
+		assert.Equal(t, "Igor", hits[0].User.Firstname)
+		assert.Equal(t, "Anna", hits[1].User.Firstname)
+		assert.Greater(t, hits[0].Rank, hits[1].Rank)
+	})
+
+	t.Run("typo", func(t *testing.T) {
+		hits, _, err := repo.Search(ctx, "Kormick", 10, 0)
+		require.NoError(t, err)
+		require.NotEmpty(t, hits)
+		assert.Equal(t, "Igor", hits[0].User.Firstname)
+	})
+
+	t.Run("partial word", func(t *testing.T) {
+		hits, _, err := repo.Search(ctx, "pet", 10, 0)
+		require.NoError(t, err)
+		require.Len(t, hits, 1)
+		assert.Equal(t, "Boris", hits[0].User.Firstname)
+	})
+
+	t.Run("every term must match", func(t *testing.T) {
+		hits, total, err := repo.Search(ctx, "igor petrov", 10, 0)
+		require.NoError(t, err)
+		assert.Zero(t, total)
+		assert.Empty(t, hits)
+	})
+
+	t.Run("highlight", func(t *testing.T) {
+		hits, _, err := repo.Search(ctx, "igor kormich", 10, 0)
+		require.NoError(t, err)
+		require.NotEmpty(t, hits)
+		assert.Equal(t, "<mark>Igor</mark> <mark>Kormich</mark> <mark>igor</mark>@example.com", hits[0].Highlight)
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		hits, total, err := repo.Search(ctx, "example", 2, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		require.Len(t, hits, 2)
+		assert.Equal(t, "Anna", hits[0].User.Firstname)
+		assert.Equal(t, "Boris", hits[1].User.Firstname)
+	})
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// searchMatch selects users whose names or email contain the words of $1, or
+// words close to them for typos and partial words.
+const searchMatch = `deleted_at is null and (search_vector @@ websearch_to_tsquery('simple', $1)
+						or lower($1) <% lower(search_text))`
+
+type postgreSearchRepository struct {
+	Conn *sql.DB
+}
+
+// NewPostgreSearchRepository searches with full-text search and pg_trgm word
+// similarity over the users.search_text and search_vector columns. Search
+// must run in a transaction for TrigramThreshold to apply. Requests scoped to an
+// organization only find its users.
+func NewPostgreSearchRepository(Conn *sql.DB) user.SearchRepository {
+	return &postgreSearchRepository{Conn}
+}
+
+func (m *postgreSearchRepository) Search(ctx context.Context, query string, limit int, offset int) ([]*entity.UserSearchHit, int, error) {
+	conn := dbutil.Conn(ctx, m.Conn)
+
+	_, err := conn.ExecContext(ctx, `select set_config('pg_trgm.word_similarity_threshold', $1, true)`,
+		strconv.FormatFloat(TrigramThreshold, 'f', -1, 64))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tenant, args := andTenant(ctx, "id", []interface{}{query})
+	var total int
+	err = conn.QueryRowContext(ctx, `select count(*) from users where `+searchMatch+tenant, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := conn.QueryContext(ctx, `select id, first_name, last_name, email, age, created, updated, deleted_at, email_verified, status,
+						ts_rank(search_vector, websearch_to_tsquery('simple', $1)) + word_similarity(lower($1), lower(search_text)),
+						ts_headline('simple', search_text, websearch_to_tsquery('simple', $1), 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')
+						from users where `+searchMatch+tenant+fmt.Sprintf(`
+						order by 11 desc, created, id limit $%d offset $%d`, len(args)+1, len(args)+2), append(args, limit, offset)...)
+	if err != nil {
+		logrus.Error(err)
+		return nil, 0, err
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	result := make([]*entity.UserSearchHit, 0)
+	for rows.Next() {
+		t := new(entity.User)
+		hit := &entity.UserSearchHit{User: t}
+		err = rows.Scan(&t.ID, &t.Firstname, &t.Lastname, &t.Email, &t.Age, &t.Created, &t.Updated, &t.Deleted, &t.EmailVerified, &t.Status,
+			&hit.Rank, &hit.Highlight)
+		if err != nil {
+			logrus.Error(err)
+			return nil, 0, err
+		}
+		result = append(result, hit)
+	}
+	return result, total, rows.Err()
+}
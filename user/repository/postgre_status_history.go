@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type postgreStatusHistoryRepository struct {
+	Conn       *sql.DB
+	transactor dbutil.Transactor
+}
+
+// NewPostgreStatusHistoryRepository runs its queries in transactions, as
+// NewPostgreUserRepository does, so that row-level security applies to them.
+func NewPostgreStatusHistoryRepository(Conn *sql.DB) user.StatusHistoryRepository {
+	return &postgreStatusHistoryRepository{Conn, dbutil.NewTransactor(Conn)}
+}
+
+// Store fills in the ID and Created of t.
+func (m *postgreStatusHistoryRepository) Store(ctx context.Context, t *entity.StatusTransition) error {
+	query := `INSERT INTO user_status_transitions (id, user_id, from_status, to_status, reason, actor, request_id)
+						VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created`
+
+	t.ID = uuid.New()
+	return dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		return dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, t.ID.String(), t.UserID.String(), t.From, t.To, t.Reason,
+			t.Actor, t.RequestID).Scan(&t.Created)
+	})
+}
+
+func (m *postgreStatusHistoryRepository) GetByUserID(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]*entity.StatusTransition, int, error) {
+	var result []*entity.StatusTransition
+	var total int
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		conn := dbutil.Conn(ctx, m.Conn)
+		err := conn.QueryRowContext(ctx, `select count(*) from user_status_transitions where user_id = $1`, userID.String()).Scan(&total)
+		if err != nil {
+			return err
+		}
+
+		rows, err := conn.QueryContext(ctx, `select id, user_id, from_status, to_status, reason, actor, request_id, created
+						from user_status_transitions where user_id = $1 order by created desc, id limit $2 offset $3`,
+			userID.String(), limit, offset)
+		if err != nil {
+			logrus.Error(err)
+			return err
+		}
+
+		defer func() {
+			err := rows.Close()
+			if err != nil {
+				logrus.Error(err)
+			}
+		}()
+
+		result = make([]*entity.StatusTransition, 0)
+		for rows.Next() {
+			t := new(entity.StatusTransition)
+			err = rows.Scan(&t.ID, &t.UserID, &t.From, &t.To, &t.Reason, &t.Actor, &t.RequestID, &t.Created)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+			result = append(result, t)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
+}
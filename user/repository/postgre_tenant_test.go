@@ -0,0 +1,166 @@
+package repository_test
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	orgRepository "GoMastersTest/org/repository"
+	"GoMastersTest/user/repository"
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantRole is switched to when the configured database user skips
+// row-level security, as superusers do.
+const tenantRole = "users_rls_test"
+
+// openTenantDB connects to the database of config.json through a single
+// connection subject to row-level security, skipping the test when the
+// database is unreachable or not migrated.
+func openTenantDB(t *testing.T) *sql.DB {
+	viper.SetConfigFile(`../../config.json`)
+	require.NoError(t, viper.ReadInConfig())
+	db, err := sql.Open(`postgres`, fmt.Sprintf("host=%v port=%v dbname=%v user=%v password=%v sslmode=disable",
+		viper.GetString(`database.host`), viper.GetString(`database.port`), viper.GetString(`database.name`),
+		viper.GetString(`database.user`), viper.GetString(`database.pass`)))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	db.SetMaxOpenConns(1)
+
+	if err = db.Ping(); err != nil {
+		t.Skip("no database:", err)
+	}
+	var migrated bool
+	require.NoError(t, db.QueryRow(`select to_regproc('app_tenant_member') is not null`).Scan(&migrated))
+	if !migrated {
+		t.Skip("the database lacks migration 0015")
+	}
+
+	var bypass bool
+	err = db.QueryRow(`select rolsuper or rolbypassrls from pg_roles where rolname = current_user`).Scan(&bypass)
+	require.NoError(t, err)
+	if bypass {
+		_, err = db.Exec(`DO $$ BEGIN
+				IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = '` + tenantRole + `') THEN
+					CREATE ROLE ` + tenantRole + ` NOLOGIN;
+				END IF;
+			END $$`)
+		require.NoError(t, err)
+		_, err = db.Exec(`GRANT SELECT, INSERT, UPDATE, DELETE ON users, organizations, org_memberships, user_history TO ` + tenantRole)
+		require.NoError(t, err)
+		_, err = db.Exec(`SET ROLE ` + tenantRole)
+		require.NoError(t, err)
+	}
+	return db
+}
+
+// scopedTo returns the context of a request by caller within orgID.
+func scopedTo(orgID uuid.UUID, caller uuid.UUID) context.Context {
+	return models.WithOrgID(models.WithUserID(context.Background(), caller), orgID)
+}
+
+func TestRowLevelSecurity(t *testing.T) {
+	db := openTenantDB(t)
+	users := repository.NewPostgreUserRepository(db)
+	orgs := orgRepository.NewPostgreOrganizationRepository(db)
+	transactor := dbutil.NewTransactor(db)
+
+	acme, err := orgs.Create(context.Background(), &DTOs.Organization{Name: "Acme"})
+	require.NoError(t, err)
+	globex, err := orgs.Create(context.Background(), &DTOs.Organization{Name: "Globex"})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = transactor.WithinTransaction(models.AsSystem(context.Background()), func(ctx context.Context) error {
+			_, err := dbutil.Conn(ctx, db).ExecContext(ctx, `DELETE FROM users WHERE id IN (SELECT user_id FROM org_memberships WHERE org_id = ANY($1::uuid[]))`,
+				"{"+acme.ID.String()+","+globex.ID.String()+"}")
+			return err
+		})
+		_ = orgs.Delete(context.Background(), acme.ID)
+		_ = orgs.Delete(context.Background(), globex.ID)
+	})
+
+	// users created within a tenant join it
+	alice, err := users.Create(scopedTo(acme.ID, uuid.Nil), &DTOs.User{Firstname: "Alice", Lastname: "Acme", Email: "alice@acme.test", Age: 30})
+	require.NoError(t, err)
+	bob, err := users.Create(scopedTo(globex.ID, uuid.Nil), &DTOs.User{Firstname: "Bob", Lastname: "Globex", Email: "bob@globex.test", Age: 40})
+	require.NoError(t, err)
+
+	_, err = users.GetByID(scopedTo(acme.ID, alice.ID), bob.ID)
+	assert.Equal(t, models.ErrNotFound, err)
+
+	// the queries below leave out the tenant filter of the repository on purpose
+	ctx := scopedTo(acme.ID, alice.ID)
+	err = transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		conn := dbutil.Conn(ctx, db)
+
+		var count int
+		require.NoError(t, conn.QueryRowContext(ctx, `select count(*) from users where id = ANY($1::uuid[])`,
+			"{"+alice.ID.String()+","+bob.ID.String()+"}").Scan(&count))
+		assert.Equal(t, 1, count, "other tenants' users are invisible")
+
+		res, err := conn.ExecContext(ctx, `update users set first_name = 'Mallory' where id = $1`, bob.ID.String())
+		require.NoError(t, err)
+		affected, err := res.RowsAffected()
+		require.NoError(t, err)
+		assert.Zero(t, affected, "other tenants' users cannot be changed")
+
+		res, err = conn.ExecContext(ctx, `delete from users where id = $1`, bob.ID.String())
+		require.NoError(t, err)
+		affected, err = res.RowsAffected()
+		require.NoError(t, err)
+		assert.Zero(t, affected, "other tenants' users cannot be deleted")
+
+		require.NoError(t, conn.QueryRowContext(ctx, `select count(*) from org_memberships where org_id = $1`,
+			globex.ID.String()).Scan(&count))
+		assert.Zero(t, count, "other tenants' memberships are invisible")
+		return nil
+	})
+	require.NoError(t, err)
+
+	// rows that would leave the tenant are rejected, failing the transaction
+	for name, query := range map[string]string{
+		"join another tenant": `insert into org_memberships (org_id, user_id, role) values ('` + globex.ID.String() + `', '` + alice.ID.String() + `', 'owner')`,
+		"move a user away":    `update users set id = '` + uuid.NewString() + `' where id = '` + alice.ID.String() + `'`,
+		"audit another user":  `insert into user_history (id, user_id, operation, actor) values ('` + uuid.NewString() + `', '` + bob.ID.String() + `', 'update', 'test')`,
+	} {
+		err = transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			_, err := dbutil.Conn(ctx, db).ExecContext(ctx, query)
+			return err
+		})
+		assert.Error(t, err, name)
+	}
+
+	// sessions that name no organization, caller or system see no one
+	ids := "{" + alice.ID.String() + "," + bob.ID.String() + "}"
+	var count int
+	require.NoError(t, db.QueryRow(`select count(*) from users where id = ANY($1::uuid[])`, ids).Scan(&count))
+	assert.Zero(t, count)
+	res, err := db.Exec(`update users set first_name = 'Mallory' where id = ANY($1::uuid[])`, ids)
+	require.NoError(t, err)
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Zero(t, affected)
+
+	// the system role, used by workers, sees everyone
+	err = transactor.WithinTransaction(models.AsSystem(context.Background()), func(ctx context.Context) error {
+		return dbutil.Conn(ctx, db).QueryRowContext(ctx, `select count(*) from users where id = ANY($1::uuid[])`, ids).Scan(&count)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// and adds members to any organization, as creating one does
+	err = transactor.WithinTransaction(models.AsSystem(context.Background()), func(ctx context.Context) error {
+		_, err := dbutil.Conn(ctx, db).ExecContext(ctx, `insert into org_memberships (org_id, user_id, role) values ($1, $2, 'member')`,
+			globex.ID.String(), alice.ID.String())
+		return err
+	})
+	assert.NoError(t, err)
+}
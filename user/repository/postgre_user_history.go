@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+type postgreUserHistoryRepository struct {
+	Conn       *sql.DB
+	transactor dbutil.Transactor
+}
+
+// NewPostgreUserHistoryRepository runs its queries in transactions, as
+// NewPostgreUserRepository does, so that row-level security applies to them.
+func NewPostgreUserHistoryRepository(Conn *sql.DB) user.HistoryRepository {
+	return &postgreUserHistoryRepository{Conn, dbutil.NewTransactor(Conn)}
+}
+
+func (m *postgreUserHistoryRepository) Store(ctx context.Context, record *entity.UserHistory) error {
+	return m.StoreMany(ctx, []*entity.UserHistory{record})
+}
+
+// StoreMany inserts the records with one multi-row INSERT per chunk and fills
+// in their ID and Created.
+func (m *postgreUserHistoryRepository) StoreMany(ctx context.Context, records []*entity.UserHistory) error {
+	return dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		for start := 0; start < len(records); start += batchChunkSize {
+			end := start + batchChunkSize
+			if end > len(records) {
+				end = len(records)
+			}
+			if err := m.storeChunk(ctx, records[start:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *postgreUserHistoryRepository) storeChunk(ctx context.Context, records []*entity.UserHistory) error {
+	values := make([]string, len(records))
+	args := make([]interface{}, 0, len(records)*8)
+	byID := make(map[uuid.UUID]*entity.UserHistory, len(records))
+	for i, record := range records {
+		id, err := uuid.NewUUID()
+		if err != nil {
+			return err
+		}
+		before, err := marshalNullable(record.Before)
+		if err != nil {
+			return err
+		}
+		after, err := marshalNullable(record.After)
+		if err != nil {
+			return err
+		}
+		changes, err := json.Marshal(record.Changes)
+		if err != nil {
+			return err
+		}
+
+		record.ID = id
+		byID[id] = record
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8)
+		args = append(args, id.String(), record.UserID.String(), record.Operation, record.Actor, record.RequestID, before, after, changes)
+	}
+
+	query := `INSERT INTO user_history (id, user_id, operation, actor, request_id, before, after, changes)
+						VALUES ` + strings.Join(values, ", ") + ` RETURNING id, created`
+
+	rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var created time.Time
+		if err = rows.Scan(&id, &created); err != nil {
+			return err
+		}
+		if record, ok := byID[id]; ok {
+			record.Created = created
+		}
+	}
+	return rows.Err()
+}
+
+func (m *postgreUserHistoryRepository) GetByUserID(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]*entity.UserHistory, int, error) {
+	var total int
+	var list []*entity.UserHistory
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		err := dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, `select count(*) from user_history where user_id = $1`, userID.String()).Scan(&total)
+		if err != nil {
+			return err
+		}
+
+		query := `select id, user_id, operation, actor, request_id, before, after, changes, created
+						from user_history where user_id = $1 order by created desc, id limit $2 offset $3`
+
+		list, err = m.fetch(ctx, query, userID.String(), limit, offset)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+func (m *postgreUserHistoryRepository) GetAsOf(ctx context.Context, userID uuid.UUID, at time.Time) (*entity.UserHistory, error) {
+	query := `select id, user_id, operation, actor, request_id, before, after, changes, created
+						from user_history where user_id = $1 and created <= $2 order by created desc limit 1`
+
+	list, err := m.fetch(ctx, query, userID.String(), at)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list[0], nil
+}
+
+func (m *postgreUserHistoryRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]*entity.UserHistory, error) {
+	var result []*entity.UserHistory
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, args...)
+		if err != nil {
+			logrus.Error(err)
+			return err
+		}
+		result, err = scanHistory(rows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func scanHistory(rows *sql.Rows) ([]*entity.UserHistory, error) {
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	result := make([]*entity.UserHistory, 0)
+	for rows.Next() {
+		t := new(entity.UserHistory)
+		var before, after, changes []byte
+		err := rows.Scan(
+			&t.ID,
+			&t.UserID,
+			&t.Operation,
+			&t.Actor,
+			&t.RequestID,
+			&before,
+			&after,
+			&changes,
+			&t.Created,
+		)
+		if err != nil {
+			logrus.Error(err)
+			return nil, err
+		}
+
+		if before != nil {
+			t.Before = new(entity.User)
+			if err = json.Unmarshal(before, t.Before); err != nil {
+				return nil, err
+			}
+		}
+		if after != nil {
+			t.After = new(entity.User)
+			if err = json.Unmarshal(after, t.After); err != nil {
+				return nil, err
+			}
+		}
+		if err = json.Unmarshal(changes, &t.Changes); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
+
+func marshalNullable(u *entity.User) ([]byte, error) {
+	if u == nil {
+		return nil, nil
+	}
+	return json.Marshal(u)
+}
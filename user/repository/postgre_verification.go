@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+)
+
+type postgreVerificationRepository struct {
+	Conn       *sql.DB
+	transactor dbutil.Transactor
+}
+
+// NewPostgreVerificationRepository runs its queries in transactions, as
+// NewPostgreUserRepository does, so that row-level security applies to them.
+func NewPostgreVerificationRepository(Conn *sql.DB) user.VerificationRepository {
+	return &postgreVerificationRepository{Conn, dbutil.NewTransactor(Conn)}
+}
+
+func (m *postgreVerificationRepository) Create(ctx context.Context, v *entity.EmailVerification) error {
+	query := `INSERT INTO email_verifications (id, user_id, email, expires_at, created) VALUES ($1, $2, $3, $4, now())`
+
+	return dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query, v.ID.String(), v.UserID.String(), v.Email, v.ExpiresAt)
+		return err
+	})
+}
+
+func (m *postgreVerificationRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.EmailVerification, error) {
+	query := `select id, user_id, email, expires_at, used_at, created from email_verifications where id = $1 for update`
+
+	v := new(entity.EmailVerification)
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		return dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, id.String()).Scan(
+			&v.ID,
+			&v.UserID,
+			&v.Email,
+			&v.ExpiresAt,
+			&v.UsedAt,
+			&v.Created,
+		)
+	})
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (m *postgreVerificationRepository) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	return dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx,
+			`UPDATE email_verifications SET used_at = now() WHERE id = $1 AND used_at IS NULL`, id.String())
+		return err
+	})
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"GoMastersTest/dbutil"
 	"GoMastersTest/models"
 	"GoMastersTest/models/DTOs"
 	"GoMastersTest/models/entity"
@@ -9,59 +10,181 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
+	"strings"
 	"time"
 )
 
 type postgreUserRepository struct {
-	Conn *sql.DB
+	Conn       *sql.DB
+	transactor dbutil.Transactor
 }
 
+// NewPostgreUserRepository runs the queries of requests scoped to an
+// organization in transactions, so that row-level security backs the tenant
+// filters of the queries.
 func NewPostgreUserRepository(Conn *sql.DB) user.Repository {
-	return &postgreUserRepository{Conn}
+	return &postgreUserRepository{Conn, dbutil.NewTransactor(Conn)}
 }
 
-func (m *postgreUserRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]*entity.User, error) {
-	rows, err := m.Conn.QueryContext(ctx, query, args...)
-	if err != nil {
-		logrus.Error(err)
-		return nil, err
+// userColumns maps the entity.User fields to their columns, in the order
+// scanUser reads them.
+var userColumns = []struct {
+	field  string
+	column string
+}{
+	{"ID", "id"},
+	{"Firstname", "first_name"},
+	{"Lastname", "last_name"},
+	{"Email", "email"},
+	{"Age", "age"},
+	{"Created", "created"},
+	{"Updated", "updated"},
+	{"Deleted", "deleted_at"},
+	{"EmailVerified", "email_verified"},
+	{"Status", "status"},
+}
+
+// projection returns the select list of fields, all columns when fields is
+// empty, and the fields it reads in order.
+func projection(fields []string) (string, []string) {
+	wanted := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		wanted[f] = true
 	}
+	columns := make([]string, 0, len(userColumns))
+	selected := make([]string, 0, len(userColumns))
+	for _, c := range userColumns {
+		if len(fields) == 0 || wanted[c.field] {
+			columns = append(columns, c.column)
+			selected = append(selected, c.field)
+		}
+	}
+	return strings.Join(columns, ", "), selected
+}
+
+func (m *postgreUserRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]*entity.User, error) {
+	return m.fetchFields(ctx, nil, query, args...)
+}
 
-	defer func() {
-		err := rows.Close()
+// fetchFields runs a query selecting the columns of fields, as returned by
+// projection.
+func (m *postgreUserRepository) fetchFields(ctx context.Context, fields []string, query string, args ...interface{}) ([]*entity.User, error) {
+	var result []*entity.User
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, args...)
 		if err != nil {
 			logrus.Error(err)
+			return err
 		}
-	}()
-
-	result := make([]*entity.User, 0)
-	for rows.Next() {
-		t := new(entity.User)
-		err = rows.Scan(
-			&t.ID,
-			&t.Firstname,
-			&t.Lastname,
-			&t.Email,
-			&t.Age,
-			&t.Created,
-		)
 
-		if err != nil {
-			logrus.Error(err)
-			return nil, err
+		defer func() {
+			err := rows.Close()
+			if err != nil {
+				logrus.Error(err)
+			}
+		}()
+
+		result = make([]*entity.User, 0)
+		for rows.Next() {
+			t, err := scanFields(rows, fields)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+			result = append(result, t)
 		}
-		result = append(result, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return result, nil
 }
 
-func (m *postgreUserRepository) GetAllUsers(ctx context.Context) (res []*entity.User, err error) {
-	query := `select id, first_name, last_name, email, age, created
-						from users`
+// queryRow runs a query returning one user, ErrNotFound when it returns none.
+func (m *postgreUserRepository) queryRow(ctx context.Context, query string, args ...interface{}) (*entity.User, error) {
+	var res *entity.User
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		var err error
+		res, err = scanUser(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, args...))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// exec runs a statement and returns the number of rows it affected.
+func (m *postgreUserRepository) exec(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	var ra int64
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		res, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		ra, err = res.RowsAffected()
+		return err
+	})
+	return ra, err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*entity.User, error) {
+	return scanFields(row, nil)
+}
+
+// scanFields reads a row holding the columns of fields in userColumns order,
+// or every column when fields is nil.
+func scanFields(row rowScanner, fields []string) (*entity.User, error) {
+	t := new(entity.User)
+	targets := map[string]interface{}{
+		"ID":            &t.ID,
+		"Firstname":     &t.Firstname,
+		"Lastname":      &t.Lastname,
+		"Email":         &t.Email,
+		"Age":           &t.Age,
+		"Created":       &t.Created,
+		"Updated":       &t.Updated,
+		"Deleted":       &t.Deleted,
+		"EmailVerified": &t.EmailVerified,
+		"Status":        &t.Status,
+	}
+	if fields == nil {
+		_, fields = projection(nil)
+	}
+	dest := make([]interface{}, len(fields))
+	for i, f := range fields {
+		dest[i] = targets[f]
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *postgreUserRepository) GetAllUsers(ctx context.Context, filter *DTOs.UserFilter) (res []*entity.User, err error) {
+	var fields []string
+	if filter != nil {
+		fields = filter.Fields
+	}
+	columns, fields := projection(fields)
+	where, args := filterClause(ctx, filter)
+	query := `select ` + columns + ` from users` + where + ` order by created, id`
+	if filter != nil && filter.Limit > 0 {
+		query += fmt.Sprintf(" limit %d offset %d", filter.Limit, filter.Offset)
+	}
 
-	list, err := m.fetch(ctx, query)
+	list, err := m.fetchFields(ctx, fields, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -75,11 +198,116 @@ func (m *postgreUserRepository) GetAllUsers(ctx context.Context) (res []*entity.
 	return
 }
 
-func (m *postgreUserRepository) GetByID(ctx context.Context, id uuid.UUID) (res *entity.User, err error) {
-	query := `select id, first_name, last_name, email, age, created
-						from users where id = $1`
+// Stream passes every user matching filter to fn, in creation order, straight
+// from the database cursor. It stops at the first error returned by fn.
+func (m *postgreUserRepository) Stream(ctx context.Context, filter *DTOs.UserFilter, fn func(*entity.User) error) error {
+	where, args := filterClause(ctx, filter)
+	query := `select id, first_name, last_name, email, age, created, updated, deleted_at, email_verified, status
+						from users` + where + ` order by created, id`
+
+	return dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, args...)
+		if err != nil {
+			logrus.Error(err)
+			return err
+		}
+
+		defer func() {
+			err := rows.Close()
+			if err != nil {
+				logrus.Error(err)
+			}
+		}()
+
+		for rows.Next() {
+			t, err := scanUser(rows)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+			if err = fn(t); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
+
+// filterClause translates filter, and the tenant of ctx, into a where clause
+// and its arguments.
+func filterClause(ctx context.Context, filter *DTOs.UserFilter) (string, []interface{}) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	if tenant, tenantArgs := tenantCondition(ctx, "id", args); tenant != "" {
+		conditions = append(conditions, tenant)
+		args = tenantArgs
+	}
+	if filter == nil || !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at is null")
+	}
+	if filter != nil && len(filter.Statuses) > 0 {
+		args = append(args, pq.Array(filter.Statuses))
+		conditions = append(conditions, fmt.Sprintf("status = ANY($%d::text[])", len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " where " + strings.Join(conditions, " and "), args
+}
+
+// tenantCondition restricts users to the members of the organization the
+// request acts in, and to the caller, appending its arguments to args. It is
+// empty when the request is not scoped to an organization.
+func tenantCondition(ctx context.Context, column string, args []interface{}) (string, []interface{}) {
+	orgID, ok := models.OrgIDFromContext(ctx)
+	if !ok {
+		return "", args
+	}
+	userID, _ := models.UserIDFromContext(ctx)
+	args = append(args, orgID.String(), userID.String())
+	n := len(args)
+	return fmt.Sprintf("(%s = $%d or %s in (select user_id from org_memberships where org_id = $%d))",
+		column, n, column, n-1), args
+}
+
+// andTenant is tenantCondition for queries that already have a where clause.
+func andTenant(ctx context.Context, column string, args []interface{}) (string, []interface{}) {
+	tenant, args := tenantCondition(ctx, column, args)
+	if tenant == "" {
+		return "", args
+	}
+	return " and " + tenant, args
+}
 
-	list, err := m.fetch(ctx, query, id.String())
+// addToTenant makes the users about to be created members of the
+// organization the request acts in, so that it sees them. It runs before the
+// users are inserted, their memberships checking them at commit. Requests
+// scoped to no organization cannot create users.
+func (m *postgreUserRepository) addToTenant(ctx context.Context, ids []uuid.UUID) error {
+	orgID, ok := models.OrgIDFromContext(ctx)
+	if !ok || len(ids) == 0 {
+		return nil
+	}
+	if orgID == uuid.Nil {
+		return models.ErrForbidden
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+	query := `INSERT INTO org_memberships (org_id, user_id, role, created)
+						SELECT $1, unnest($2::uuid[]), $3, now()`
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query, orgID.String(), pq.Array(keys), entity.OrgRoleMember)
+	return err
+}
+
+func (m *postgreUserRepository) GetByID(ctx context.Context, id uuid.UUID, fields ...string) (res *entity.User, err error) {
+	columns, fields := projection(fields)
+	tenant, args := andTenant(ctx, "id", []interface{}{id.String()})
+	query := `select ` + columns + ` from users where id = $1 and deleted_at is null` + tenant
+
+	list, err := m.fetchFields(ctx, fields, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -92,88 +320,332 @@ func (m *postgreUserRepository) GetByID(ctx context.Context, id uuid.UUID) (res
 
 	return
 }
-func (m *postgreUserRepository) Create(ctx context.Context, user *DTOs.User) (id uuid.UUID, err error) {
-	query := `INSERT INTO users (id, first_name, last_name, email, age, created) VALUES ($1, $2, $3, $4, $5, $6)`
-	stmt, err := m.Conn.PrepareContext(ctx, query)
-	if err != nil {
-		return uuid.NullUUID{}.UUID, err
-	}
 
-	var usEn entity.User
+// GetByIDs returns the users among ids that exist and are not deleted, in no
+// particular order.
+func (m *postgreUserRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*entity.User, error) {
+	query := `select id, first_name, last_name, email, age, created, updated, deleted_at, email_verified, status
+						from users where id = ANY($1::uuid[]) and deleted_at is null`
 
-	usEn.User = *user
-	usEn.ID, err = uuid.NewUUID()
-	usEn.Created = time.Now()
-	if err != nil {
-		return uuid.NullUUID{}.UUID, err
+	params := make([]string, len(ids))
+	for i, id := range ids {
+		params[i] = id.String()
 	}
-	res, err := stmt.ExecContext(ctx, usEn.ID.String(), usEn.Firstname, usEn.Lastname, usEn.Email, usEn.Age, usEn.Created)
-	if err != nil {
-		return uuid.NullUUID{}.UUID, err
+	tenant, args := andTenant(ctx, "id", []interface{}{pq.Array(params)})
+	return m.fetch(ctx, query+tenant, args...)
+}
+
+// GetByEmails returns the users whose email matches one of emails, ignoring
+// case.
+func (m *postgreUserRepository) GetByEmails(ctx context.Context, emails []string) ([]*entity.User, error) {
+	lowered := make([]string, len(emails))
+	for i, e := range emails {
+		lowered[i] = strings.ToLower(e)
 	}
+	tenant, args := andTenant(ctx, "id", []interface{}{pq.Array(lowered)})
+	query := `select id, first_name, last_name, email, age, created, updated, deleted_at, email_verified, status
+						from users where lower(email) = ANY($1::text[]) and deleted_at is null` + tenant + ` order by created`
+
+	return m.fetch(ctx, query, args...)
+}
 
-	ra, err := res.RowsAffected()
+func (m *postgreUserRepository) Create(ctx context.Context, user *DTOs.User) (*entity.User, error) {
+	query := `INSERT INTO users (id, first_name, last_name, email, age, created, updated) VALUES ($1, $2, $3, $4, $5, $6, $6)
+						RETURNING id, first_name, last_name, email, age, created, updated, deleted_at, email_verified, status`
+
+	id, err := uuid.NewUUID()
 	if err != nil {
-		return uuid.NullUUID{}.UUID, err
+		return nil, err
 	}
 
-	if ra != 0 {
-		return usEn.ID, nil
-	} else {
-		return uuid.NullUUID{}.UUID, models.ErrInternalServerError
+	var res *entity.User
+	err = dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		if err := m.addToTenant(ctx, []uuid.UUID{id}); err != nil {
+			return err
+		}
+		res, err = scanUser(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query,
+			id.String(), user.Firstname, user.Lastname, user.Email, user.Age, time.Now()))
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	return res, nil
 }
 func (m *postgreUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM users WHERE id = $1`
-	stmt, err := m.Conn.PrepareContext(ctx, query)
+	tenant, args := andTenant(ctx, "id", []interface{}{id.String()})
+	query := `UPDATE users SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL` + tenant
+
+	ra, err := m.exec(ctx, query, args...)
 	if err != nil {
 		return err
 	}
 
-	if err != nil {
-		return err
+	if ra != 0 {
+		return nil
+	} else {
+		return models.ErrNotFound
 	}
+}
+
+// Restore undeletes the user and returns their soft-deleted state along with
+// the restored one.
+func (m *postgreUserRepository) Restore(ctx context.Context, id uuid.UUID) (*entity.User, *entity.User, error) {
+	tenant, args := andTenant(ctx, "id", []interface{}{id.String()})
+	query := `WITH before AS (
+							SELECT id, first_name, last_name, email, age, created, updated, deleted_at, email_verified, status
+							FROM users WHERE id = $1 AND deleted_at IS NOT NULL` + tenant + ` FOR UPDATE
+						)
+						UPDATE users u SET deleted_at = NULL, updated = now() FROM before b WHERE u.id = b.id
+						RETURNING b.id, b.first_name, b.last_name, b.email, b.age, b.created, b.updated, b.deleted_at, b.email_verified, b.status,
+							u.id, u.first_name, u.last_name, u.email, u.age, u.created, u.updated, u.deleted_at, u.email_verified, u.status`
 
-	res, err := stmt.ExecContext(ctx, id.String())
+	b, a := new(entity.User), new(entity.User)
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		return dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, args...).Scan(
+			&b.ID, &b.Firstname, &b.Lastname, &b.Email, &b.Age, &b.Created, &b.Updated, &b.Deleted, &b.EmailVerified, &b.Status,
+			&a.ID, &a.Firstname, &a.Lastname, &a.Email, &a.Age, &a.Created, &a.Updated, &a.Deleted, &a.EmailVerified, &a.Status,
+		)
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil, models.ErrNotFound
+	}
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	return b, a, nil
+}
+func (m *postgreUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tenant, args := andTenant(ctx, "id", []interface{}{deletedBefore})
+	query := `DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1` + tenant
 
-	ra, err := res.RowsAffected()
-	if err != nil {
-		return err
+	return m.exec(ctx, query, args...)
+}
+func (m *postgreUserRepository) Update(ctx context.Context, id uuid.UUID, user *DTOs.User) (*entity.User, error) {
+	tenant, args := andTenant(ctx, "id", []interface{}{user.Firstname, user.Lastname, user.Email, user.Age, id.String()})
+	query := `UPDATE users SET first_name=$1, last_name=$2, email=$3, age=$4, updated=now(),
+						email_verified = email_verified AND lower(email) = lower($3) WHERE id = $5 AND deleted_at IS NULL` + tenant + `
+						RETURNING id, first_name, last_name, email, age, created, updated, deleted_at, email_verified, status`
+
+	return m.queryRow(ctx, query, args...)
+}
+
+func (m *postgreUserRepository) SetEmailVerified(ctx context.Context, id uuid.UUID, email string) (*entity.User, error) {
+	tenant, args := andTenant(ctx, "id", []interface{}{id.String(), email})
+	query := `UPDATE users SET email_verified = true, updated = now()
+						WHERE id = $1 AND lower(email) = lower($2) AND deleted_at IS NULL` + tenant + `
+						RETURNING id, first_name, last_name, email, age, created, updated, deleted_at, email_verified, status`
+
+	return m.queryRow(ctx, query, args...)
+}
+
+func (m *postgreUserRepository) SetStatus(ctx context.Context, id uuid.UUID, from string, to string) (*entity.User, error) {
+	tenant, args := andTenant(ctx, "id", []interface{}{id.String(), from, to})
+	query := `UPDATE users SET status = $3, updated = now()
+						WHERE id = $1 AND status = $2 AND deleted_at IS NULL` + tenant + `
+						RETURNING id, first_name, last_name, email, age, created, updated, deleted_at, email_verified, status`
+
+	res, err := m.queryRow(ctx, query, args...)
+	if err == models.ErrNotFound {
+		return nil, models.ErrConflict
 	}
+	return res, err
+}
 
-	if ra != 0 {
-		return nil
-	} else {
-		return models.ErrInternalServerError
+// batchChunkSize bounds the rows sent in one statement, keeping every batch
+// query well below the Postgres limit of 65535 parameters.
+const batchChunkSize = 1000
+
+// CreateMany inserts users with one multi-row INSERT per chunk and returns
+// them in input order.
+func (m *postgreUserRepository) CreateMany(ctx context.Context, users []*DTOs.User) ([]*entity.User, error) {
+	result := make([]*entity.User, 0, len(users))
+	for start := 0; start < len(users); start += batchChunkSize {
+		end := start + batchChunkSize
+		if end > len(users) {
+			end = len(users)
+		}
+
+		chunk, err := m.createChunk(ctx, users[start:end])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, chunk...)
 	}
+	return result, nil
 }
-func (m *postgreUserRepository) Update(ctx context.Context, id uuid.UUID, user *DTOs.User) (*entity.User, error) {
-	query := `UPDATE users SET first_name=$1, last_name=$2, email=$3, age=$4 WHERE id = $5`
 
-	stmt, err := m.Conn.PrepareContext(ctx, query)
-	if err != nil {
-		return nil, err
+func (m *postgreUserRepository) createChunk(ctx context.Context, users []*DTOs.User) ([]*entity.User, error) {
+	now := time.Now()
+	ids := make([]uuid.UUID, len(users))
+	values := make([]string, len(users))
+	args := make([]interface{}, 0, len(users)*6)
+	for i, u := range users {
+		id, err := uuid.NewUUID()
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+6)
+		args = append(args, id.String(), u.Firstname, u.Lastname, u.Email, u.Age, now)
 	}
 
-	res, err := stmt.ExecContext(ctx, user.Firstname, user.Lastname, user.Email, user.Age, id.String())
+	query := `INSERT INTO users (id, first_name, last_name, email, age, created, updated) VALUES ` + strings.Join(values, ", ") + `
+						RETURNING id, first_name, last_name, email, age, created, updated, deleted_at, email_verified, status`
+
+	var list []*entity.User
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		if err := m.addToTenant(ctx, ids); err != nil {
+			return err
+		}
+		var err error
+		list, err = m.fetch(ctx, query, args...)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	affect, err := res.RowsAffected()
+
+	byID := make(map[uuid.UUID]*entity.User, len(list))
+	for _, u := range list {
+		byID[u.ID] = u
+	}
+	result := make([]*entity.User, len(ids))
+	for i, id := range ids {
+		if result[i] = byID[id]; result[i] == nil {
+			return nil, models.ErrInternalServerError
+		}
+	}
+	return result, nil
+}
+
+// UpdateMany applies every update with one UPDATE ... FROM (VALUES ...) per
+// chunk. The returned slices are aligned with updates and hold nil for users
+// that do not exist or are deleted.
+func (m *postgreUserRepository) UpdateMany(ctx context.Context, updates []*DTOs.UserUpdate) ([]*entity.User, []*entity.User, error) {
+	before := make([]*entity.User, 0, len(updates))
+	after := make([]*entity.User, 0, len(updates))
+	for start := 0; start < len(updates); start += batchChunkSize {
+		end := start + batchChunkSize
+		if end > len(updates) {
+			end = len(updates)
+		}
+
+		b, a, err := m.updateChunk(ctx, updates[start:end])
+		if err != nil {
+			return nil, nil, err
+		}
+		before = append(before, b...)
+		after = append(after, a...)
+	}
+	return before, after, nil
+}
+
+func (m *postgreUserRepository) updateChunk(ctx context.Context, updates []*DTOs.UserUpdate) ([]*entity.User, []*entity.User, error) {
+	values := make([]string, len(updates))
+	args := make([]interface{}, 0, len(updates)*5)
+	for i, u := range updates {
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d::uuid, $%d::text, $%d::text, $%d::text, $%d::integer)", n+1, n+2, n+3, n+4, n+5)
+		args = append(args, u.ID.String(), u.User.Firstname, u.User.Lastname, u.User.Email, u.User.Age)
+	}
+	tenant, args := andTenant(ctx, "u.id", args)
+
+	query := `WITH input (id, first_name, last_name, email, age) AS (VALUES ` + strings.Join(values, ", ") + `),
+						before AS (
+							SELECT u.id, u.first_name, u.last_name, u.email, u.age, u.created, u.updated, u.deleted_at, u.email_verified, u.status
+							FROM users u JOIN input i ON i.id = u.id WHERE u.deleted_at IS NULL` + tenant + ` FOR UPDATE OF u
+						)
+						UPDATE users u SET first_name = i.first_name, last_name = i.last_name, email = i.email, age = i.age,
+							email_verified = u.email_verified AND lower(u.email) = lower(i.email), updated = now()
+						FROM input i JOIN before b ON b.id = i.id
+						WHERE u.id = i.id
+						RETURNING b.id, b.first_name, b.last_name, b.email, b.age, b.created, b.updated, b.deleted_at, b.email_verified, b.status,
+							u.id, u.first_name, u.last_name, u.email, u.age, u.created, u.updated, u.deleted_at, u.email_verified, u.status`
+
+	beforeByID := make(map[uuid.UUID]*entity.User)
+	afterByID := make(map[uuid.UUID]*entity.User)
+	err := dbutil.InTenant(ctx, m.transactor, func(ctx context.Context) error {
+		rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, args...)
+		if err != nil {
+			logrus.Error(err)
+			return err
+		}
+
+		defer func() {
+			err := rows.Close()
+			if err != nil {
+				logrus.Error(err)
+			}
+		}()
+
+		for rows.Next() {
+			b, a := new(entity.User), new(entity.User)
+			err = rows.Scan(
+				&b.ID, &b.Firstname, &b.Lastname, &b.Email, &b.Age, &b.Created, &b.Updated, &b.Deleted, &b.EmailVerified, &b.Status,
+				&a.ID, &a.Firstname, &a.Lastname, &a.Email, &a.Age, &a.Created, &a.Updated, &a.Deleted, &a.EmailVerified, &a.Status,
+			)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+			beforeByID[b.ID] = b
+			afterByID[a.ID] = a
+		}
+		return rows.Err()
+	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if affect != 1 {
-		err = fmt.Errorf("Weird  Behaviour. Total Affected: %d", affect)
 
-		return nil, err
+	before := make([]*entity.User, len(updates))
+	after := make([]*entity.User, len(updates))
+	for i, u := range updates {
+		before[i] = beforeByID[u.ID]
+		after[i] = afterByID[u.ID]
 	}
-	var usEn entity.User
+	return before, after, nil
+}
 
-	usEn.User = *user
-	return &usEn, nil
+// DeleteMany soft-deletes the users with one statement per chunk and returns
+// their state before deletion, aligned with ids. Users that do not exist or
+// are already deleted are nil.
+func (m *postgreUserRepository) DeleteMany(ctx context.Context, ids []uuid.UUID) ([]*entity.User, error) {
+	result := make([]*entity.User, 0, len(ids))
+	for start := 0; start < len(ids); start += batchChunkSize {
+		end := start + batchChunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		keys := make([]string, end-start)
+		for i, id := range ids[start:end] {
+			keys[i] = id.String()
+		}
+
+		tenant, args := andTenant(ctx, "id", []interface{}{pq.Array(keys)})
+		query := `WITH before AS (
+							SELECT id, first_name, last_name, email, age, created, updated, deleted_at, email_verified, status
+							FROM users WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL` + tenant + ` FOR UPDATE
+						)
+						UPDATE users u SET deleted_at = now() FROM before b WHERE u.id = b.id
+						RETURNING b.id, b.first_name, b.last_name, b.email, b.age, b.created, b.updated, b.deleted_at, b.email_verified, b.status`
+
+		list, err := m.fetch(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		byID := make(map[uuid.UUID]*entity.User, len(list))
+		for _, u := range list {
+			byID[u.ID] = u
+		}
+		for _, id := range ids[start:end] {
+			result = append(result, byID[id])
+		}
+	}
+	return result, nil
 }
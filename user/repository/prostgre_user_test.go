@@ -0,0 +1,74 @@
+package repository_test
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	orgRepository "GoMastersTest/org/repository"
+	"GoMastersTest/user/repository"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scopedOrg creates an organization for the test and returns the context of
+// a request within it. The organization and the users that joined it are
+// removed once the test is over.
+func scopedOrg(t *testing.T, db *sql.DB) context.Context {
+	orgs := orgRepository.NewPostgreOrganizationRepository(db)
+	transactor := dbutil.NewTransactor(db)
+
+	o, err := orgs.Create(context.Background(), &DTOs.Organization{Name: "Repository " + uuid.NewString()})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = transactor.WithinTransaction(models.AsSystem(context.Background()), func(ctx context.Context) error {
+			_, err := dbutil.Conn(ctx, db).ExecContext(ctx, `DELETE FROM users WHERE id IN (SELECT user_id FROM org_memberships WHERE org_id = $1)`,
+				o.ID.String())
+			return err
+		})
+		_ = orgs.Delete(context.Background(), o.ID)
+	})
+	return scopedTo(o.ID, uuid.Nil)
+}
+
+func TestCreateAndUpdateReturnTheStoredRow(t *testing.T) {
+	db := openTenantDB(t)
+	ctx := scopedOrg(t, db)
+	users := repository.NewPostgreUserRepository(db)
+
+	created, err := users.Create(ctx, &DTOs.User{Firstname: "Igor", Lastname: "Kormich", Email: "igor@repo.test", Age: 30})
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, created.ID)
+	assert.False(t, created.Created.IsZero())
+	assert.False(t, created.Updated.IsZero())
+
+	updated, err := users.Update(ctx, created.ID, &DTOs.User{Firstname: "Igor", Lastname: "K", Email: "igor@repo.test", Age: 31})
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, updated.ID)
+	assert.True(t, created.Created.Equal(updated.Created))
+	assert.False(t, updated.Updated.Before(created.Updated))
+	assert.Equal(t, "K", updated.Lastname)
+	assert.Equal(t, uint(31), updated.Age)
+}
+
+func TestMissingUserIsNotFound(t *testing.T) {
+	db := openTenantDB(t)
+	ctx := scopedOrg(t, db)
+	users := repository.NewPostgreUserRepository(db)
+
+	_, err := users.Update(ctx, uuid.New(), &DTOs.User{Firstname: "Igor", Lastname: "Kormich", Email: "igor@repo.test", Age: 30})
+	assert.Equal(t, models.ErrNotFound, err)
+	assert.Equal(t, models.ErrNotFound, users.Delete(ctx, uuid.New()))
+
+	// deleted users are missing too
+	u, err := users.Create(ctx, &DTOs.User{Firstname: "Anna", Lastname: "Deleted", Email: "anna@repo.test", Age: 25})
+	require.NoError(t, err)
+	require.NoError(t, users.Delete(ctx, u.ID))
+	assert.Equal(t, models.ErrNotFound, users.Delete(ctx, u.ID))
+	_, err = users.Update(ctx, u.ID, &DTOs.User{Firstname: "Anna", Lastname: "Back", Email: "anna@repo.test", Age: 25})
+	assert.Equal(t, models.ErrNotFound, err)
+}
@@ -0,0 +1,16 @@
+package user
+
+import (
+	"GoMastersTest/models/entity"
+	"context"
+)
+
+// SearchRepository finds users by their names and email. Results are ordered
+// by decreasing rank, along with the total number of matches.
+type SearchRepository interface {
+	Search(ctx context.Context, query string, limit int, offset int) ([]*entity.UserSearchHit, int, error)
+}
+
+type SearchUseCase interface {
+	Search(ctx context.Context, query string, limit int, offset int) ([]*entity.UserSearchHit, int, error)
+}
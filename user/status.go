@@ -0,0 +1,31 @@
+package user
+
+import (
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+)
+
+// Status actions, each moving users to one status from a few others.
+const (
+	ActionActivate   = "activate"
+	ActionSuspend    = "suspend"
+	ActionReactivate = "reactivate"
+	ActionDeactivate = "deactivate"
+)
+
+// StatusHistoryRepository stores the status transitions of users.
+type StatusHistoryRepository interface {
+	Store(ctx context.Context, t *entity.StatusTransition) error
+	// GetByUserID returns a page of the transitions of the user, newest first,
+	// and their total count.
+	GetByUserID(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]*entity.StatusTransition, int, error)
+}
+
+// StatusUseCase moves users through their lifecycle.
+type StatusUseCase interface {
+	// Transition applies action to the user, recording reason. Actions the
+	// current status does not allow fail with ErrInvalidTransition.
+	Transition(ctx context.Context, id uuid.UUID, action string, reason string) (*entity.User, error)
+	GetHistory(ctx context.Context, id uuid.UUID, limit int, offset int) ([]*entity.StatusTransition, int, error)
+}
@@ -5,12 +5,23 @@ import (
 	"GoMastersTest/models/entity"
 	"context"
 	"github.com/google/uuid"
+	"time"
 )
 
 type UseCase interface {
-	GetAllUsers(ctx context.Context) ([]*entity.User, error)
-	GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error)
-	Create(ctx context.Context, user *DTOs.User) (id uuid.UUID, err error)
+	GetAllUsers(ctx context.Context, filter *DTOs.UserFilter) ([]*entity.User, error)
+	GetByID(ctx context.Context, id uuid.UUID, fields ...string) (*entity.User, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*entity.User, error)
+	Export(ctx context.Context, filter *DTOs.UserFilter, fn func(*entity.User) error) error
+	Create(ctx context.Context, user *DTOs.User) (*entity.User, error)
 	Update(ctx context.Context, id uuid.UUID, user *DTOs.User) (*entity.User, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	Restore(ctx context.Context, id uuid.UUID) (*entity.User, error)
+	Purge(ctx context.Context, retention time.Duration) (int64, error)
+	GetHistory(ctx context.Context, id uuid.UUID, limit int, offset int) ([]*entity.UserHistory, int, error)
+	GetAsOf(ctx context.Context, id uuid.UUID, at time.Time) (*entity.User, error)
+	CreateMany(ctx context.Context, users []*DTOs.User) ([]*entity.User, error)
+	UpdateMany(ctx context.Context, updates []*DTOs.UserUpdate, atomic bool) ([]*entity.User, error)
+	DeleteMany(ctx context.Context, ids []uuid.UUID, atomic bool) ([]*entity.User, error)
+	UpsertManyByEmail(ctx context.Context, users []*DTOs.User) (created []*entity.User, updated []*entity.User, err error)
 }
@@ -0,0 +1,206 @@
+package usecase
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type importUseCase struct {
+	userUseCase user.UseCase
+	chunkSize   int
+	maxErrors   int
+	jobTTL      time.Duration
+
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*entity.ImportJob
+}
+
+// NewImportUseCase writes imported rows through us, chunkSize rows per
+// transaction, and keeps at most maxErrors row errors per job. Jobs are kept in
+// memory for jobTTL after they finish, so background imports are only visible
+// on the instance running them.
+func NewImportUseCase(us user.UseCase, chunkSize int, maxErrors int, jobTTL time.Duration) user.ImportUseCase {
+	return &importUseCase{
+		userUseCase: us,
+		chunkSize:   chunkSize,
+		maxErrors:   maxErrors,
+		jobTTL:      jobTTL,
+		jobs:        make(map[uuid.UUID]*entity.ImportJob),
+	}
+}
+
+func (a *importUseCase) Import(ctx context.Context, rows user.RowReader, opts DTOs.ImportOptions) (*entity.ImportJob, error) {
+	job := a.newJob(opts)
+	err := a.run(ctx, job, rows, opts)
+	if err != nil {
+		return nil, err
+	}
+	return a.snapshot(job), nil
+}
+
+func (a *importUseCase) Start(ctx context.Context, rows user.RowReader, opts DTOs.ImportOptions, done func()) *entity.ImportJob {
+	job := a.newJob(opts)
+	ctx = models.Detach(ctx)
+	go func() {
+		defer done()
+		if err := a.run(ctx, job, rows, opts); err != nil {
+			logrus.Errorf("import %s failed: %v", job.ID, err)
+		}
+	}()
+	return a.snapshot(job)
+}
+
+func (a *importUseCase) GetJob(ctx context.Context, id uuid.UUID) (*entity.ImportJob, error) {
+	a.mu.Lock()
+	job, ok := a.jobs[id]
+	a.mu.Unlock()
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	return a.snapshot(job), nil
+}
+
+func (a *importUseCase) newJob(opts DTOs.ImportOptions) *entity.ImportJob {
+	job := &entity.ImportJob{
+		ID:      uuid.New(),
+		Status:  entity.ImportRunning,
+		DryRun:  opts.DryRun,
+		Upsert:  opts.Upsert,
+		Errors:  make([]entity.ImportRowError, 0),
+		Started: time.Now(),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, j := range a.jobs {
+		if j.Finished != nil && time.Since(*j.Finished) > a.jobTTL {
+			delete(a.jobs, id)
+		}
+	}
+	a.jobs[job.ID] = job
+	return job
+}
+
+// snapshot copies job so callers can read it while the import goes on.
+func (a *importUseCase) snapshot(job *entity.ImportJob) *entity.ImportJob {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	res := *job
+	res.Errors = append([]entity.ImportRowError(nil), job.Errors...)
+	return &res
+}
+
+// run reads rows until EOF, writing valid ones a chunk at a time. A chunk that
+// fails to write marks all its rows failed; the import continues with the
+// next chunk.
+func (a *importUseCase) run(ctx context.Context, job *entity.ImportJob, rows user.RowReader, opts DTOs.ImportOptions) error {
+	chunk := make([]*user.ImportRow, 0, a.chunkSize)
+	emails := make(map[string]bool)
+
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			a.finish(job, err)
+			return err
+		}
+
+		if row.Err == nil && opts.Upsert {
+			// one chunk must not update the same user twice
+			email := strings.ToLower(row.User.Email)
+			if emails[email] {
+				a.flush(ctx, job, chunk, opts)
+				chunk = chunk[:0]
+				emails = make(map[string]bool)
+			}
+			emails[email] = true
+		}
+
+		chunk = append(chunk, row)
+		if len(chunk) == a.chunkSize {
+			a.flush(ctx, job, chunk, opts)
+			chunk = chunk[:0]
+			emails = make(map[string]bool)
+		}
+	}
+	a.flush(ctx, job, chunk, opts)
+	a.finish(job, nil)
+	return nil
+}
+
+func (a *importUseCase) flush(ctx context.Context, job *entity.ImportJob, chunk []*user.ImportRow, opts DTOs.ImportOptions) {
+	valid := make([]*user.ImportRow, 0, len(chunk))
+	for _, row := range chunk {
+		if row.Err != nil {
+			a.fail(job, row.Row, row.Err)
+			continue
+		}
+		valid = append(valid, row)
+	}
+
+	var created, updated int
+	var err error
+	if !opts.DryRun && len(valid) > 0 {
+		users := make([]*DTOs.User, len(valid))
+		for i, row := range valid {
+			users[i] = row.User
+		}
+
+		if opts.Upsert {
+			var c, u []*entity.User
+			c, u, err = a.userUseCase.UpsertManyByEmail(ctx, users)
+			created, updated = len(c), len(u)
+		} else {
+			var c []*entity.User
+			c, err = a.userUseCase.CreateMany(ctx, users)
+			created = len(c)
+		}
+	}
+	if err != nil {
+		for _, row := range valid {
+			a.fail(job, row.Row, err)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	job.Processed += len(chunk)
+	job.Created += created
+	job.Updated += updated
+}
+
+func (a *importUseCase) fail(job *entity.ImportJob, row int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	job.Failed++
+	if len(job.Errors) < a.maxErrors {
+		job.Errors = append(job.Errors, entity.ImportRowError{Row: row, Error: err.Error()})
+	}
+}
+
+func (a *importUseCase) finish(job *entity.ImportJob, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	job.Finished = &now
+	job.Status = entity.ImportCompleted
+	if err != nil {
+		job.Status = entity.ImportFailed
+		job.Error = err.Error()
+	}
+}
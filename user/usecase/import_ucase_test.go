@@ -0,0 +1,214 @@
+package usecase_test
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"GoMastersTest/user/usecase"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+// importWriter records the chunks the import writes.
+type importWriter struct {
+	user.UseCase
+	chunks  [][]string
+	fail    map[string]bool
+	updated map[string]bool
+	ctx     context.Context
+}
+
+func (w *importWriter) write(ctx context.Context, users []*DTOs.User) error {
+	w.ctx = ctx
+	emails := make([]string, len(users))
+	for i, u := range users {
+		emails[i] = u.Email
+		if w.fail[u.Email] {
+			return models.ErrConflict
+		}
+	}
+	w.chunks = append(w.chunks, emails)
+	return nil
+}
+
+func (w *importWriter) CreateMany(ctx context.Context, users []*DTOs.User) ([]*entity.User, error) {
+	if err := w.write(ctx, users); err != nil {
+		return nil, err
+	}
+	return make([]*entity.User, len(users)), nil
+}
+
+func (w *importWriter) UpsertManyByEmail(ctx context.Context, users []*DTOs.User) ([]*entity.User, []*entity.User, error) {
+	if err := w.write(ctx, users); err != nil {
+		return nil, nil, err
+	}
+	var created, updated []*entity.User
+	for _, u := range users {
+		if w.updated[u.Email] {
+			updated = append(updated, &entity.User{})
+		} else {
+			created = append(created, &entity.User{})
+		}
+	}
+	return created, updated, nil
+}
+
+type sliceRows struct {
+	rows []*user.ImportRow
+	err  error
+}
+
+func (s *sliceRows) Next() (*user.ImportRow, error) {
+	if len(s.rows) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	row := s.rows[0]
+	s.rows = s.rows[1:]
+	return row, nil
+}
+
+func importRows(emails ...string) *sliceRows {
+	rows := &sliceRows{}
+	for i, email := range emails {
+		row := &user.ImportRow{Row: i + 2, User: &DTOs.User{Firstname: "A", Lastname: "B", Email: email, Age: 30}}
+		if email == "" {
+			row.User, row.Err = nil, models.ErrEmailValid
+		}
+		rows.rows = append(rows.rows, row)
+	}
+	return rows
+}
+
+func TestImportChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    DTOs.ImportOptions
+		emails  []string
+		fail    []string
+		chunks  [][]string
+		created int
+		updated int
+		failed  int
+	}{
+		{
+			name:    "chunk size",
+			emails:  []string{"a@x.io", "b@x.io", "c@x.io", "d@x.io", "e@x.io"},
+			chunks:  [][]string{{"a@x.io", "b@x.io"}, {"c@x.io", "d@x.io"}, {"e@x.io"}},
+			created: 5,
+		},
+		{
+			name:    "invalid rows are reported and skipped",
+			emails:  []string{"a@x.io", "", "c@x.io"},
+			chunks:  [][]string{{"a@x.io"}, {"c@x.io"}},
+			created: 2,
+			failed:  1,
+		},
+		{
+			name:    "dry run writes nothing",
+			opts:    DTOs.ImportOptions{DryRun: true},
+			emails:  []string{"a@x.io", "", "c@x.io"},
+			failed:  1,
+			created: 0,
+		},
+		{
+			name:    "upsert starts a new chunk on a repeated email",
+			opts:    DTOs.ImportOptions{Upsert: true},
+			emails:  []string{"a@x.io", "A@x.io", "b@x.io"},
+			chunks:  [][]string{{"a@x.io"}, {"A@x.io", "b@x.io"}},
+			created: 2,
+			updated: 1,
+		},
+		{
+			name:    "a failed chunk fails its rows only",
+			emails:  []string{"a@x.io", "b@x.io", "c@x.io"},
+			fail:    []string{"b@x.io"},
+			chunks:  [][]string{{"c@x.io"}},
+			created: 1,
+			failed:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &importWriter{fail: make(map[string]bool), updated: map[string]bool{"A@x.io": true}}
+			for _, email := range tt.fail {
+				w.fail[email] = true
+			}
+			uc := usecase.NewImportUseCase(w, 2, 10, time.Hour)
+
+			job, err := uc.Import(context.Background(), importRows(tt.emails...), tt.opts)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.chunks, w.chunks)
+			assert.Equal(t, entity.ImportCompleted, job.Status)
+			assert.Equal(t, len(tt.emails), job.Processed)
+			assert.Equal(t, tt.created, job.Created)
+			assert.Equal(t, tt.updated, job.Updated)
+			assert.Equal(t, tt.failed, job.Failed)
+			assert.Len(t, job.Errors, tt.failed)
+		})
+	}
+}
+
+func TestImportKeepsMaxErrors(t *testing.T) {
+	uc := usecase.NewImportUseCase(&importWriter{}, 10, 2, time.Hour)
+
+	job, err := uc.Import(context.Background(), importRows("", "", ""), DTOs.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, job.Failed)
+	assert.Len(t, job.Errors, 2)
+}
+
+func TestImportFailsOnUnreadableInput(t *testing.T) {
+	uc := usecase.NewImportUseCase(&importWriter{}, 10, 10, time.Hour)
+	rows := importRows("a@x.io")
+	rows.err = errors.New("connection reset")
+
+	_, err := uc.Import(context.Background(), rows, DTOs.ImportOptions{})
+	assert.EqualError(t, err, "connection reset")
+}
+
+func TestStartActsForTheCaller(t *testing.T) {
+	w := &importWriter{}
+	uc := usecase.NewImportUseCase(w, 10, 10, time.Hour)
+
+	orgID, userID := uuid.New(), uuid.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = models.WithOrgID(models.WithUserID(models.WithActor(ctx, userID.String()), userID), orgID)
+	ctx = models.WithRequestID(models.WithRoles(ctx, []string{"admin"}), "req-1")
+
+	done := make(chan struct{})
+	cancel()
+	job := uc.Start(ctx, importRows("a@x.io"), DTOs.ImportOptions{}, func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("import did not finish")
+	}
+
+	require.NoError(t, w.ctx.Err())
+	gotOrg, ok := models.OrgIDFromContext(w.ctx)
+	assert.True(t, ok)
+	assert.Equal(t, orgID, gotOrg)
+	gotUser, _ := models.UserIDFromContext(w.ctx)
+	assert.Equal(t, userID, gotUser)
+	assert.Equal(t, userID.String(), models.ActorFromContext(w.ctx))
+	assert.Equal(t, "req-1", models.RequestIDFromContext(w.ctx))
+	assert.True(t, models.HasRole(w.ctx, "admin"))
+	assert.False(t, models.IsSystem(w.ctx))
+
+	got, err := uc.GetJob(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ImportCompleted, got.Status)
+	assert.Equal(t, 1, got.Created)
+}
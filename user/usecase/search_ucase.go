@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"strings"
+	"time"
+)
+
+type searchUseCase struct {
+	searchRepository user.SearchRepository
+	transactor       dbutil.Transactor
+	contextTimeout   time.Duration
+}
+
+func NewSearchUseCase(s user.SearchRepository, tx dbutil.Transactor, timeout time.Duration) user.SearchUseCase {
+	return &searchUseCase{
+		searchRepository: s,
+		transactor:       tx,
+		contextTimeout:   timeout,
+	}
+}
+
+// Search runs in a transaction so the repository can tune its matching for
+// the duration of the query.
+func (a *searchUseCase) Search(c context.Context, query string, limit int, offset int) (hits []*entity.UserSearchHit, total int, err error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, 0, models.ErrBadParamInput
+	}
+
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	err = a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		hits, total, err = a.searchRepository.Search(ctx, query, limit, offset)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return hits, total, nil
+}
@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/event"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+// transition is an edge of the status state machine: an action moves users in
+// one of the from statuses to the to status.
+type transition struct {
+	from []string
+	to   string
+}
+
+// statusTransitions is the state machine of user statuses. Deactivated users
+// can only be reactivated; suspension is for users who can sign in.
+var statusTransitions = map[string]transition{
+	user.ActionActivate:   {from: []string{entity.StatusPending}, to: entity.StatusActive},
+	user.ActionSuspend:    {from: []string{entity.StatusPending, entity.StatusActive}, to: entity.StatusSuspended},
+	user.ActionReactivate: {from: []string{entity.StatusSuspended, entity.StatusDeactivated}, to: entity.StatusActive},
+	user.ActionDeactivate: {from: []string{entity.StatusPending, entity.StatusActive, entity.StatusSuspended}, to: entity.StatusDeactivated},
+}
+
+func (t transition) allows(status string) bool {
+	for _, from := range t.from {
+		if from == status {
+			return true
+		}
+	}
+	return false
+}
+
+type statusUseCase struct {
+	userRepository    user.Repository
+	historyRepository user.HistoryRepository
+	outboxRepository  event.OutboxRepository
+	statusRepository  user.StatusHistoryRepository
+	sessions          auth.SessionRevoker
+	transactor        dbutil.Transactor
+	contextTimeout    time.Duration
+}
+
+// NewStatusUseCase ends the sessions of users through s when they are
+// suspended or deactivated.
+func NewStatusUseCase(u user.Repository, h user.HistoryRepository, o event.OutboxRepository, sh user.StatusHistoryRepository,
+	s auth.SessionRevoker, tx dbutil.Transactor, timeout time.Duration) user.StatusUseCase {
+	return &statusUseCase{
+		userRepository:    u,
+		historyRepository: h,
+		outboxRepository:  o,
+		statusRepository:  sh,
+		sessions:          s,
+		transactor:        tx,
+		contextTimeout:    timeout,
+	}
+}
+
+// Transition fails with ErrBadParamInput for unknown actions. Besides its
+// status transition, the change is audited and published like other updates.
+func (a *statusUseCase) Transition(c context.Context, id uuid.UUID, action string, reason string) (*entity.User, error) {
+	t, ok := statusTransitions[action]
+	if !ok {
+		return nil, models.ErrBadParamInput
+	}
+
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	var res *entity.User
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		before, err := a.userRepository.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !t.allows(before.Status) {
+			return models.ErrInvalidTransition
+		}
+		res, err = a.userRepository.SetStatus(ctx, id, before.Status, t.to)
+		if err != nil {
+			return err
+		}
+
+		err = a.statusRepository.Store(ctx, &entity.StatusTransition{
+			UserID:    id,
+			From:      before.Status,
+			To:        res.Status,
+			Reason:    reason,
+			Actor:     models.ActorFromContext(ctx),
+			RequestID: models.RequestIDFromContext(ctx),
+		})
+		if err != nil {
+			return err
+		}
+		err = storeChanges(ctx, a.historyRepository, a.outboxRepository, entity.OperationUpdate,
+			[]change{{ID: id, Before: before, After: res}})
+		if err != nil || !res.Disabled() {
+			return err
+		}
+		return a.sessions.RevokeSessions(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a *statusUseCase) GetHistory(c context.Context, id uuid.UUID, limit int, offset int) ([]*entity.StatusTransition, int, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.statusRepository.GetByUserID(ctx, id, limit, offset)
+}
@@ -0,0 +1,95 @@
+package usecase_test
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"GoMastersTest/user/usecase"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newStatusUseCase(status string) (user.StatusUseCase, *entity.User, *fakeStatusHistory, *fakeSessions, *fakeHistory) {
+	u := &entity.User{User: DTOs.User{Firstname: "Igor", Email: "igor@example.com"}, Status: status, ID: uuid.New()}
+	transitions := new(fakeStatusHistory)
+	sessions := new(fakeSessions)
+	history := new(fakeHistory)
+	uc := usecase.NewStatusUseCase(&fakeUsers{users: map[uuid.UUID]*entity.User{u.ID: u}}, history, fakeOutbox{},
+		transitions, sessions, noTransactor{}, time.Second)
+	return uc, u, transitions, sessions, history
+}
+
+func TestStatusTransitions(t *testing.T) {
+	cases := []struct {
+		from   string
+		action string
+		to     string
+	}{
+		{entity.StatusPending, user.ActionActivate, entity.StatusActive},
+		{entity.StatusPending, user.ActionSuspend, entity.StatusSuspended},
+		{entity.StatusPending, user.ActionReactivate, ""},
+		{entity.StatusPending, user.ActionDeactivate, entity.StatusDeactivated},
+		{entity.StatusActive, user.ActionActivate, ""},
+		{entity.StatusActive, user.ActionSuspend, entity.StatusSuspended},
+		{entity.StatusActive, user.ActionReactivate, ""},
+		{entity.StatusActive, user.ActionDeactivate, entity.StatusDeactivated},
+		{entity.StatusSuspended, user.ActionActivate, ""},
+		{entity.StatusSuspended, user.ActionSuspend, ""},
+		{entity.StatusSuspended, user.ActionReactivate, entity.StatusActive},
+		{entity.StatusSuspended, user.ActionDeactivate, entity.StatusDeactivated},
+		{entity.StatusDeactivated, user.ActionActivate, ""},
+		{entity.StatusDeactivated, user.ActionSuspend, ""},
+		{entity.StatusDeactivated, user.ActionReactivate, entity.StatusActive},
+		{entity.StatusDeactivated, user.ActionDeactivate, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.from+" "+tc.action, func(t *testing.T) {
+			uc, u, transitions, _, history := newStatusUseCase(tc.from)
+
+			res, err := uc.Transition(context.Background(), u.ID, tc.action, "because")
+			if tc.to == "" {
+				assert.Equal(t, models.ErrInvalidTransition, err)
+				assert.Equal(t, tc.from, u.Status)
+				assert.Empty(t, transitions.transitions)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.to, res.Status)
+			require.Len(t, transitions.transitions, 1)
+			assert.Equal(t, tc.from, transitions.transitions[0].From)
+			assert.Equal(t, tc.to, transitions.transitions[0].To)
+			require.Len(t, history.records, 1)
+			assert.Equal(t, entity.FieldChanges{"Status": {From: tc.from, To: tc.to}}, history.records[0].Changes)
+		})
+	}
+}
+
+func TestSuspend(t *testing.T) {
+	uc, u, _, sessions, _ := newStatusUseCase(entity.StatusActive)
+	ctx := models.WithActor(context.Background(), "admin")
+
+	_, err := uc.Transition(ctx, u.ID, user.ActionSuspend, "Chargeback under investigation")
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{u.ID}, sessions.revoked, "suspending ends the sessions")
+
+	_, err = uc.Transition(ctx, u.ID, user.ActionReactivate, "Chargeback settled")
+	require.NoError(t, err)
+	assert.Len(t, sessions.revoked, 1)
+
+	history, total, err := uc.GetHistory(ctx, u.ID, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, "Chargeback settled", history[0].Reason)
+	assert.Equal(t, entity.StatusSuspended, history[1].To)
+	assert.Equal(t, "admin", history[1].Actor)
+
+	_, err = uc.Transition(ctx, u.ID, "promote", "")
+	assert.Equal(t, models.ErrBadParamInput, err)
+	_, err = uc.Transition(ctx, uuid.New(), user.ActionSuspend, "")
+	assert.Equal(t, models.ErrNotFound, err)
+}
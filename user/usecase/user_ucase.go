@@ -1,79 +1,580 @@
 package usecase
 
 import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/event"
 	"GoMastersTest/models"
 	"GoMastersTest/models/DTOs"
 	"GoMastersTest/models/entity"
 	"GoMastersTest/user"
 	"context"
+	"encoding/json"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"reflect"
+	"strings"
 	"time"
 )
 
 type userUseCases struct {
-	userRepository user.Repository
-	contextTimeout time.Duration
+	userRepository    user.Repository
+	historyRepository user.HistoryRepository
+	outboxRepository  event.OutboxRepository
+	passwords         auth.PasswordUseCase
+	verifications     user.VerificationUseCase
+	transactor        dbutil.Transactor
+	contextTimeout    time.Duration
 }
 
-func NewUserUseCase(a user.Repository, timeout time.Duration) user.UseCase {
+// NewUserUseCase sets the passwords given to new users through p, and sends a
+// verification link through v whenever an update changes the email of a user.
+func NewUserUseCase(a user.Repository, h user.HistoryRepository, o event.OutboxRepository, p auth.PasswordUseCase,
+	v user.VerificationUseCase, tx dbutil.Transactor, timeout time.Duration) user.UseCase {
 	return &userUseCases{
-		userRepository: a,
-		contextTimeout: timeout,
+		userRepository:    a,
+		historyRepository: h,
+		outboxRepository:  o,
+		passwords:         p,
+		verifications:     v,
+		transactor:        tx,
+		contextTimeout:    timeout,
 	}
 }
 
-func (a *userUseCases) GetAllUsers(c context.Context) ([]*entity.User, error) {
+func (a *userUseCases) GetAllUsers(c context.Context, filter *DTOs.UserFilter) ([]*entity.User, error) {
 	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
 	defer cancel()
 
-	res, err := a.userRepository.GetAllUsers(ctx)
+	res, err := a.userRepository.GetAllUsers(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-func (a *userUseCases) GetByID(c context.Context, id uuid.UUID) (*entity.User, error) {
+// Export streams users to fn. It runs under the caller's context only: exports
+// of large tables take longer than the usual timeout.
+func (a *userUseCases) Export(c context.Context, filter *DTOs.UserFilter, fn func(*entity.User) error) error {
+	return a.userRepository.Stream(c, filter, fn)
+}
+
+func (a *userUseCases) GetByID(c context.Context, id uuid.UUID, fields ...string) (*entity.User, error) {
 
 	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
 	defer cancel()
 
-	res, err := a.userRepository.GetByID(ctx, id)
+	res, err := a.userRepository.GetByID(ctx, id, fields...)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// GetByIDs looks ids up in one query. The result is aligned with ids, with nil
+// for the users that do not exist.
+func (a *userUseCases) GetByIDs(c context.Context, ids []uuid.UUID) ([]*entity.User, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	found, err := a.userRepository.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]*entity.User, len(found))
+	for _, u := range found {
+		byID[u.ID] = u
+	}
+	res := make([]*entity.User, len(ids))
+	for i, id := range ids {
+		res[i] = byID[id]
+	}
+	return res, nil
+}
+
 func (a *userUseCases) Update(c context.Context, id uuid.UUID, m *DTOs.User) (*entity.User, error) {
+	if m.Password != "" {
+		return nil, models.ErrPasswordOnUpdate
+	}
 
 	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
 	defer cancel()
 
-	return a.userRepository.Update(ctx, id, m)
+	var before, res *entity.User
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		before, err = a.userRepository.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		res, err = a.userRepository.Update(ctx, id, m)
+		if err != nil {
+			return err
+		}
+		return a.recordChange(ctx, entity.OperationUpdate, id, before, res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.reverify(c, []*entity.User{before}, []*entity.User{res})
+	return res, nil
 }
 
-func (a *userUseCases) Create(c context.Context, m *DTOs.User) (uuid.UUID, error) {
+func (a *userUseCases) Create(c context.Context, m *DTOs.User) (*entity.User, error) {
 
 	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
 	defer cancel()
 
-	id, err := a.userRepository.Create(ctx, m)
+	var res *entity.User
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = a.userRepository.Create(ctx, m)
+		if err != nil {
+			return err
+		}
+		err = a.setPasswords(ctx, []*DTOs.User{m}, []*entity.User{res})
+		if err != nil {
+			return err
+		}
+		return a.recordChange(ctx, entity.OperationCreate, res.ID, nil, res)
+	})
 	if err != nil {
-		return uuid.NullUUID{}.UUID, err
+		return nil, err
 	}
-	return id, nil
+	return res, nil
 }
 
 func (a *userUseCases) Delete(c context.Context, id uuid.UUID) error {
 	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
 	defer cancel()
-	existedUser, err := a.userRepository.GetByID(ctx, id)
+
+	return a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		existedUser, err := a.userRepository.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if existedUser == nil {
+			return models.ErrNotFound
+		}
+
+		err = a.userRepository.Delete(ctx, id)
+		if err != nil {
+			return err
+		}
+		return a.recordChange(ctx, entity.OperationDelete, id, existedUser, nil)
+	})
+}
+
+func (a *userUseCases) Restore(c context.Context, id uuid.UUID) (*entity.User, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	var res *entity.User
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		before, after, err := a.userRepository.Restore(ctx, id)
+		if err != nil {
+			return err
+		}
+		res = after
+		return a.recordChange(ctx, entity.OperationRestore, id, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a *userUseCases) Purge(c context.Context, retention time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.userRepository.Purge(ctx, time.Now().Add(-retention))
+}
+
+func (a *userUseCases) CreateMany(c context.Context, m []*DTOs.User) ([]*entity.User, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	var res []*entity.User
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = a.userRepository.CreateMany(ctx, m)
+		if err != nil {
+			return err
+		}
+		err = a.setPasswords(ctx, m, res)
+		if err != nil {
+			return err
+		}
+
+		changes := make([]change, len(res))
+		for i, u := range res {
+			changes[i] = change{ID: u.ID, After: u}
+		}
+		return a.recordChanges(ctx, entity.OperationCreate, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateMany returns the updated users aligned with updates, with nil for
+// users that were not found. When atomic is set, any missing user rolls the
+// whole batch back and the aligned result is returned with ErrNotFound.
+func (a *userUseCases) UpdateMany(c context.Context, updates []*DTOs.UserUpdate, atomic bool) ([]*entity.User, error) {
+	if err := validateUpdates(updates); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	var before, res []*entity.User
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		before, res, err = a.updateMany(ctx, updates, atomic)
+		return err
+	})
+	if err == models.ErrNotFound && res != nil {
+		return res, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.reverify(c, before, res)
+	return res, nil
+}
+
+// validateUpdates refuses batches that update a user twice or carry
+// passwords.
+func validateUpdates(updates []*DTOs.UserUpdate) error {
+	ids := make([]uuid.UUID, len(updates))
+	for i, u := range updates {
+		if u.User.Password != "" {
+			return models.ErrPasswordOnUpdate
+		}
+		ids[i] = u.ID
+	}
+	if hasDuplicates(ids) {
+		return models.ErrBadParamInput
+	}
+	return nil
+}
+
+// updateMany applies and records updates within the transaction of ctx and
+// returns the users before and after them. Verification links are left to
+// the caller, to send once the transaction committed.
+func (a *userUseCases) updateMany(ctx context.Context, updates []*DTOs.UserUpdate, atomic bool) ([]*entity.User, []*entity.User, error) {
+	before, after, err := a.userRepository.UpdateMany(ctx, updates)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := make([]change, 0, len(after))
+	for i, u := range after {
+		if u == nil {
+			if atomic {
+				return before, after, models.ErrNotFound
+			}
+			continue
+		}
+		changes = append(changes, change{ID: u.ID, Before: before[i], After: u})
+	}
+	return before, after, a.recordChanges(ctx, entity.OperationUpdate, changes)
+}
+
+// reverify sends a verification link to the users whose email changed
+// between before and after. The changes are committed by then, so failures
+// are only logged.
+func (a *userUseCases) reverify(ctx context.Context, before []*entity.User, after []*entity.User) {
+	for i, u := range after {
+		if u == nil || before[i] == nil || strings.EqualFold(before[i].Email, u.Email) {
+			continue
+		}
+		if err := a.verifications.Send(ctx, u.ID); err != nil {
+			logrus.WithField("user_id", u.ID).Errorf("sending email verification: %v", err)
+		}
+	}
+}
+
+// DeleteMany soft-deletes the users and returns their last state aligned with
+// ids, with nil for users that were not found. Atomic batches behave as in
+// UpdateMany.
+func (a *userUseCases) DeleteMany(c context.Context, ids []uuid.UUID, atomic bool) ([]*entity.User, error) {
+	if hasDuplicates(ids) {
+		return nil, models.ErrBadParamInput
+	}
+
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	var res []*entity.User
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		deleted, err := a.userRepository.DeleteMany(ctx, ids)
+		if err != nil {
+			return err
+		}
+		res = deleted
+
+		changes := make([]change, 0, len(deleted))
+		for _, u := range deleted {
+			if u == nil {
+				if atomic {
+					return models.ErrNotFound
+				}
+				continue
+			}
+			changes = append(changes, change{ID: u.ID, Before: u})
+		}
+		return a.recordChanges(ctx, entity.OperationDelete, changes)
+	})
+	if err == models.ErrNotFound && res != nil {
+		return res, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpsertManyByEmail updates the users whose email already exists and creates
+// the others, in one transaction. Emails must be unique within users. The
+// verification links of changed emails are sent once the transaction
+// committed.
+func (a *userUseCases) UpsertManyByEmail(c context.Context, users []*DTOs.User) ([]*entity.User, []*entity.User, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	emails := make([]string, len(users))
+	for i, u := range users {
+		emails[i] = u.Email
+	}
+
+	var created, before, updated []*entity.User
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		existing, err := a.userRepository.GetByEmails(ctx, emails)
+		if err != nil {
+			return err
+		}
+		byEmail := make(map[string]uuid.UUID, len(existing))
+		for _, u := range existing {
+			if _, ok := byEmail[strings.ToLower(u.Email)]; !ok {
+				byEmail[strings.ToLower(u.Email)] = u.ID
+			}
+		}
+
+		creates := make([]*DTOs.User, 0, len(users))
+		updates := make([]*DTOs.UserUpdate, 0, len(users))
+		for _, u := range users {
+			if id, ok := byEmail[strings.ToLower(u.Email)]; ok {
+				updates = append(updates, &DTOs.UserUpdate{ID: id, User: *u})
+			} else {
+				creates = append(creates, u)
+			}
+		}
+
+		if len(creates) > 0 {
+			created, err = a.CreateMany(ctx, creates)
+			if err != nil {
+				return err
+			}
+		}
+		if len(updates) > 0 {
+			if err = validateUpdates(updates); err != nil {
+				return err
+			}
+			before, updated, err = a.updateMany(ctx, updates, true)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	a.reverify(c, before, updated)
+	return created, updated, nil
+}
+
+// setPasswords sets the passwords given with users on the matching users of
+// res, skipping empty passwords.
+func (a *userUseCases) setPasswords(ctx context.Context, users []*DTOs.User, res []*entity.User) error {
+	for i, u := range users {
+		if u.Password == "" {
+			continue
+		}
+		err := a.passwords.SetPassword(ctx, res[i].ID, u.Password)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasDuplicates(ids []uuid.UUID) bool {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return true
+		}
+		seen[id] = true
+	}
+	return false
+}
+
+func (a *userUseCases) GetHistory(c context.Context, id uuid.UUID, limit int, offset int) ([]*entity.UserHistory, int, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.historyRepository.GetByUserID(ctx, id, limit, offset)
+}
+
+// GetAsOf rebuilds the user from the last audit record written at or before at.
+func (a *userUseCases) GetAsOf(c context.Context, id uuid.UUID, at time.Time) (*entity.User, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	record, err := a.historyRepository.GetAsOf(ctx, id, at)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.After == nil {
+		return nil, models.ErrNotFound
+	}
+	return record.After, nil
+}
+
+var operationEvents = map[string]string{
+	entity.OperationCreate:  entity.EventUserCreated,
+	entity.OperationUpdate:  entity.EventUserUpdated,
+	entity.OperationDelete:  entity.EventUserDeleted,
+	entity.OperationRestore: entity.EventUserRestored,
+}
+
+// change is the state of one user before and after an operation. Before is
+// nil for creations and After is nil for deletions.
+type change struct {
+	ID     uuid.UUID
+	Before *entity.User
+	After  *entity.User
+}
+
+// recordChange writes the audit record and the outbox event of a change. It
+// must be called inside the transaction that made the change.
+func (a *userUseCases) recordChange(ctx context.Context, operation string, id uuid.UUID, before *entity.User, after *entity.User) error {
+	return a.recordChanges(ctx, operation, []change{{ID: id, Before: before, After: after}})
+}
+
+// recordChanges writes the audit records and outbox events of changes made by
+// the same operation, one statement each.
+func (a *userUseCases) recordChanges(ctx context.Context, operation string, changes []change) error {
+	return storeChanges(ctx, a.historyRepository, a.outboxRepository, operation, changes)
+}
+
+// storeChanges is recordChanges for the other use cases that change users.
+func storeChanges(ctx context.Context, h user.HistoryRepository, o event.OutboxRepository, operation string, changes []change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var orgID *uuid.UUID
+	if id, ok := models.OrgIDFromContext(ctx); ok {
+		orgID = &id
+	}
+	records := make([]*entity.UserHistory, len(changes))
+	events := make([]*entity.Event, len(changes))
+	for i, ch := range changes {
+		records[i] = &entity.UserHistory{
+			UserID:    ch.ID,
+			Operation: operation,
+			Actor:     models.ActorFromContext(ctx),
+			RequestID: models.RequestIDFromContext(ctx),
+			Before:    ch.Before,
+			After:     ch.After,
+			Changes:   diffUser(ch.Before, ch.After),
+		}
+
+		// the payload is the user after the change, or before it for deletions
+		state := ch.After
+		if state == nil {
+			state = ch.Before
+		}
+		payload, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		events[i] = &entity.Event{
+			AggregateID: ch.ID,
+			OrgID:       orgID,
+			Type:        operationEvents[operation],
+			Payload:     payload,
+		}
+	}
+
+	err := h.StoreMany(ctx, records)
 	if err != nil {
 		return err
 	}
-	if existedUser == nil {
-		return models.ErrNotFound
+	return o.StoreMany(ctx, events)
+}
+
+// unaudited are the DTOs.User fields that are never stored with users, and so
+// never differ.
+var unaudited = map[string]bool{"Password": true}
+
+// diffUser lists the DTOs.User fields, EmailVerified and Status whose values
+// differ between before and after, keyed by their JSON name. A nil side contributes nil values.
+func diffUser(before *entity.User, after *entity.User) map[string]entity.FieldChange {
+	changes := make(map[string]entity.FieldChange)
+
+	var from, to reflect.Value
+	if before != nil {
+		from = reflect.ValueOf(before.User)
+	}
+	if after != nil {
+		to = reflect.ValueOf(after.User)
+	}
+
+	t := reflect.TypeOf(DTOs.User{})
+	for i := 0; i < t.NumField(); i++ {
+		if unaudited[t.Field(i).Name] {
+			continue
+		}
+		var fromValue, toValue interface{}
+		if from.IsValid() {
+			fromValue = from.Field(i).Interface()
+		}
+		if to.IsValid() {
+			toValue = to.Field(i).Interface()
+		}
+		if reflect.DeepEqual(fromValue, toValue) {
+			continue
+		}
+
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		changes[name] = entity.FieldChange{From: fromValue, To: toValue}
+	}
+
+	// EmailVerified follows Email, and Status only changes through transitions
+	var fromVerified, toVerified, fromStatus, toStatus interface{}
+	if before != nil {
+		fromVerified, fromStatus = before.EmailVerified, before.Status
+	}
+	if after != nil {
+		toVerified, toStatus = after.EmailVerified, after.Status
+	}
+	if fromVerified != toVerified {
+		changes["EmailVerified"] = entity.FieldChange{From: fromVerified, To: toVerified}
+	}
+	if fromStatus != toStatus {
+		changes["Status"] = entity.FieldChange{From: fromStatus, To: toStatus}
 	}
-	return a.userRepository.Delete(ctx, id)
+	return changes
 }
@@ -0,0 +1,354 @@
+package usecase_test
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user/usecase"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (f *fakeUsers) Delete(ctx context.Context, id uuid.UUID) error {
+	u, ok := f.users[id]
+	if !ok || u.Deleted != nil {
+		return models.ErrNotFound
+	}
+	now := time.Now()
+	u.Deleted = &now
+	return nil
+}
+
+func (f *fakeUsers) Restore(ctx context.Context, id uuid.UUID) (*entity.User, *entity.User, error) {
+	u, ok := f.users[id]
+	if !ok || u.Deleted == nil {
+		return nil, nil, models.ErrNotFound
+	}
+	before := *u
+	u.Deleted = nil
+	after := *u
+	return &before, &after, nil
+}
+
+// GetAsOf returns the last record of the user created at or before at.
+func (f *fakeHistory) GetAsOf(ctx context.Context, userID uuid.UUID, at time.Time) (*entity.UserHistory, error) {
+	var res *entity.UserHistory
+	for _, r := range f.records {
+		if r.UserID == userID && !r.Created.After(at) && (res == nil || r.Created.After(res.Created)) {
+			res = r
+		}
+	}
+	return res, nil
+}
+
+func TestAuditRecordsChanges(t *testing.T) {
+	f := newVerificationFixture(t)
+	f.user.EmailVerified = true
+	f.user.Age = 30
+	ctx := models.WithRequestID(models.WithActor(context.Background(), "admin"), "req-1")
+
+	_, err := f.userUseCase.Update(ctx, f.user.ID, &DTOs.User{Firstname: "Igor", Lastname: "Kormich", Email: "igor@example.org", Age: 30})
+	require.NoError(t, err)
+	require.NoError(t, f.userUseCase.Delete(ctx, f.user.ID))
+	_, err = f.userUseCase.Restore(ctx, f.user.ID)
+	require.NoError(t, err)
+
+	require.Len(t, f.history.records, 3)
+	update, del, restore := f.history.records[0], f.history.records[1], f.history.records[2]
+
+	assert.Equal(t, entity.OperationUpdate, update.Operation)
+	assert.Equal(t, "admin", update.Actor)
+	assert.Equal(t, "req-1", update.RequestID)
+	assert.Equal(t, entity.FieldChanges{
+		"Lastname":      {From: "", To: "Kormich"},
+		"Email":         {From: "igor@example.com", To: "igor@example.org"},
+		"EmailVerified": {From: true, To: false},
+	}, update.Changes, "unchanged fields are left out")
+
+	assert.Equal(t, entity.OperationDelete, del.Operation)
+	assert.Nil(t, del.After)
+	assert.Equal(t, entity.FieldChanges{
+		"Firstname":     {From: "Igor", To: nil},
+		"Lastname":      {From: "Kormich", To: nil},
+		"Email":         {From: "igor@example.org", To: nil},
+		"Age":           {From: uint(30), To: nil},
+		"EmailVerified": {From: false, To: nil},
+		"Status":        {From: entity.StatusPending, To: nil},
+	}, del.Changes)
+
+	assert.Equal(t, entity.OperationRestore, restore.Operation)
+	require.NotNil(t, restore.Before, "restores record the soft-deleted state")
+	assert.NotNil(t, restore.Before.Deleted)
+	require.NotNil(t, restore.After)
+	assert.Nil(t, restore.After.Deleted)
+	assert.Empty(t, restore.Changes)
+}
+
+func TestGetAsOf(t *testing.T) {
+	id := uuid.New()
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	v1 := &entity.User{User: DTOs.User{Firstname: "Igor"}, ID: id}
+	v2 := &entity.User{User: DTOs.User{Firstname: "Igor", Lastname: "Kormich"}, ID: id}
+	history := &fakeHistory{records: []*entity.UserHistory{
+		{UserID: id, Operation: entity.OperationCreate, After: v1, Created: t0},
+		{UserID: id, Operation: entity.OperationUpdate, Before: v1, After: v2, Created: t0.Add(time.Hour)},
+		{UserID: id, Operation: entity.OperationDelete, Before: v2, Created: t0.Add(2 * time.Hour)},
+		{UserID: uuid.New(), Operation: entity.OperationCreate, After: &entity.User{}, Created: t0.Add(3 * time.Hour)},
+	}}
+	uc := usecase.NewUserUseCase(&fakeUsers{}, history, fakeOutbox{}, nil, nil, noTransactor{}, time.Second)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want *entity.User
+		err  error
+	}{
+		{name: "before creation", at: t0.Add(-time.Second), err: models.ErrNotFound},
+		{name: "at creation", at: t0, want: v1},
+		{name: "between changes", at: t0.Add(90 * time.Minute), want: v2},
+		{name: "after deletion", at: t0.Add(4 * time.Hour), err: models.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := uc.GetAsOf(context.Background(), id, tt.at)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func (f *fakeUsers) UpdateMany(ctx context.Context, updates []*DTOs.UserUpdate) ([]*entity.User, []*entity.User, error) {
+	before := make([]*entity.User, len(updates))
+	after := make([]*entity.User, len(updates))
+	for i, up := range updates {
+		u, ok := f.users[up.ID]
+		if !ok || u.Deleted != nil {
+			continue
+		}
+		before[i], _ = f.GetByID(ctx, up.ID)
+		u.User = up.User
+		after[i], _ = f.GetByID(ctx, up.ID)
+	}
+	return before, after, nil
+}
+
+func (f *fakeUsers) DeleteMany(ctx context.Context, ids []uuid.UUID) ([]*entity.User, error) {
+	res := make([]*entity.User, len(ids))
+	for i, id := range ids {
+		u, ok := f.users[id]
+		if !ok || u.Deleted != nil {
+			continue
+		}
+		res[i], _ = f.GetByID(ctx, id)
+		now := time.Now()
+		u.Deleted = &now
+	}
+	return res, nil
+}
+
+// rollbackTransactor restores the users and history of the fakes when the
+// transaction fails, like a database would.
+type rollbackTransactor struct {
+	users   *fakeUsers
+	history *fakeHistory
+}
+
+func (tx rollbackTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	users := make(map[uuid.UUID]entity.User, len(tx.users.users))
+	for id, u := range tx.users.users {
+		users[id] = *u
+	}
+	records := len(tx.history.records)
+
+	err := fn(ctx)
+	if err != nil {
+		for id, u := range users {
+			*tx.users.users[id] = u
+		}
+		tx.history.records = tx.history.records[:records]
+	}
+	return err
+}
+
+func TestBatchChanges(t *testing.T) {
+	a, b, missing := uuid.New(), uuid.New(), uuid.New()
+	update := func(ids ...uuid.UUID) []*DTOs.UserUpdate {
+		res := make([]*DTOs.UserUpdate, len(ids))
+		for i, id := range ids {
+			res[i] = &DTOs.UserUpdate{ID: id, User: DTOs.User{Firstname: "Renamed", Email: "user@example.com"}}
+		}
+		return res
+	}
+
+	tests := []struct {
+		name    string
+		delete  bool
+		ids     []uuid.UUID
+		atomic  bool
+		err     error
+		found   []bool // nil when the batch is refused before it runs
+		changed []uuid.UUID
+	}{
+		{name: "update", ids: []uuid.UUID{a, b}, atomic: true, found: []bool{true, true}, changed: []uuid.UUID{a, b}},
+		{name: "atomic update rolls back", ids: []uuid.UUID{a, missing, b}, atomic: true, err: models.ErrNotFound,
+			found: []bool{true, false, true}},
+		{name: "best effort update", ids: []uuid.UUID{missing, b}, found: []bool{false, true}, changed: []uuid.UUID{b}},
+		{name: "update duplicates", ids: []uuid.UUID{a, a}, atomic: true, err: models.ErrBadParamInput},
+		{name: "delete", delete: true, ids: []uuid.UUID{a, b}, atomic: true, found: []bool{true, true}, changed: []uuid.UUID{a, b}},
+		{name: "atomic delete rolls back", delete: true, ids: []uuid.UUID{a, missing}, atomic: true, err: models.ErrNotFound,
+			found: []bool{true, false}},
+		{name: "best effort delete", delete: true, ids: []uuid.UUID{a, missing}, found: []bool{true, false}, changed: []uuid.UUID{a}},
+		{name: "delete duplicates", delete: true, ids: []uuid.UUID{b, b}, err: models.ErrBadParamInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeUsers{users: map[uuid.UUID]*entity.User{
+				a: {User: DTOs.User{Firstname: "Igor", Email: "user@example.com"}, ID: a},
+				b: {User: DTOs.User{Firstname: "Anna", Email: "user@example.com"}, ID: b},
+			}}
+			history := new(fakeHistory)
+			uc := usecase.NewUserUseCase(users, history, fakeOutbox{}, nil, nil, rollbackTransactor{users, history}, time.Second)
+
+			var res []*entity.User
+			var err error
+			if tt.delete {
+				res, err = uc.DeleteMany(context.Background(), tt.ids, tt.atomic)
+			} else {
+				res, err = uc.UpdateMany(context.Background(), update(tt.ids...), tt.atomic)
+			}
+			assert.Equal(t, tt.err, err)
+
+			if tt.found == nil {
+				assert.Nil(t, res)
+			} else {
+				require.Len(t, res, len(tt.ids), "results are aligned with the request")
+				for i, found := range tt.found {
+					if !found {
+						assert.Nil(t, res[i], "item %d", i)
+						continue
+					}
+					require.NotNil(t, res[i], "item %d", i)
+					assert.Equal(t, tt.ids[i], res[i].ID)
+				}
+			}
+
+			changed := make([]uuid.UUID, 0)
+			for _, r := range history.records {
+				changed = append(changed, r.UserID)
+			}
+			assert.ElementsMatch(t, tt.changed, changed)
+			for id, u := range users.users {
+				touched := u.Firstname == "Renamed" || u.Deleted != nil
+				assert.Equal(t, contains(tt.changed, id), touched, "user %s", id)
+			}
+		})
+	}
+}
+
+func contains(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeUsers) Create(ctx context.Context, m *DTOs.User) (*entity.User, error) {
+	res, err := f.CreateMany(ctx, []*DTOs.User{m})
+	if err != nil {
+		return nil, err
+	}
+	return res[0], nil
+}
+
+// CreateMany stores the users without their passwords, like the database.
+func (f *fakeUsers) CreateMany(ctx context.Context, m []*DTOs.User) ([]*entity.User, error) {
+	res := make([]*entity.User, len(m))
+	for i, u := range m {
+		stored := &entity.User{User: *u, ID: uuid.New()}
+		stored.Password = ""
+		f.users[stored.ID] = stored
+		res[i], _ = f.GetByID(ctx, stored.ID)
+	}
+	return res, nil
+}
+
+// fakePasswords records the passwords set for each user.
+type fakePasswords map[uuid.UUID]string
+
+func (f fakePasswords) SetPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	f[userID] = password
+	return nil
+}
+
+func TestPasswordsAreSetOnCreateOnly(t *testing.T) {
+	users := &fakeUsers{users: make(map[uuid.UUID]*entity.User)}
+	history := new(fakeHistory)
+	passwords := make(fakePasswords)
+	uc := usecase.NewUserUseCase(users, history, fakeOutbox{}, passwords, nil, noTransactor{}, time.Second)
+	ctx := context.Background()
+
+	withPassword, err := uc.Create(ctx, &DTOs.User{Firstname: "Igor", Email: "igor@example.com", Password: "correct horse battery staple"})
+	require.NoError(t, err)
+	assert.Empty(t, withPassword.Password, "passwords are never read back")
+	assert.Equal(t, "correct horse battery staple", passwords[withPassword.ID])
+	require.Len(t, history.records, 1)
+	assert.NotContains(t, history.records[0].Changes, "Password")
+
+	batch, err := uc.CreateMany(ctx, []*DTOs.User{
+		{Firstname: "Anna", Email: "anna@example.com"},
+		{Firstname: "Oleg", Email: "oleg@example.com", Password: "another long passphrase"},
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, passwords, batch[0].ID, "passwords are optional")
+	assert.Equal(t, "another long passphrase", passwords[batch[1].ID])
+
+	_, err = uc.Update(ctx, withPassword.ID, &DTOs.User{Firstname: "Igor", Email: "igor@example.com", Password: "a new passphrase"})
+	assert.Equal(t, models.ErrPasswordOnUpdate, err)
+	_, err = uc.UpdateMany(ctx, []*DTOs.UserUpdate{{ID: withPassword.ID, User: DTOs.User{Password: "a new passphrase"}}}, false)
+	assert.Equal(t, models.ErrPasswordOnUpdate, err)
+	assert.Equal(t, "correct horse battery staple", passwords[withPassword.ID])
+}
+
+func (f *fakeUsers) GetByEmails(ctx context.Context, emails []string) ([]*entity.User, error) {
+	res := make([]*entity.User, 0)
+	for _, u := range f.users {
+		for _, email := range emails {
+			if u.Deleted == nil && strings.EqualFold(u.Email, email) {
+				res = append(res, u)
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+func TestUpsertManyByEmail(t *testing.T) {
+	id := uuid.New()
+	users := &fakeUsers{users: map[uuid.UUID]*entity.User{
+		id: {User: DTOs.User{Firstname: "Igor", Email: "IGOR@example.com"}, ID: id, EmailVerified: true},
+	}}
+	uc := usecase.NewUserUseCase(users, new(fakeHistory), fakeOutbox{}, nil, nil, noTransactor{}, time.Second)
+
+	created, updated, err := uc.UpsertManyByEmail(context.Background(), []*DTOs.User{
+		{Firstname: "Igor", Lastname: "Kormich", Email: "igor@example.com"},
+		{Firstname: "Anna", Email: "anna@example.com"},
+	})
+	require.NoError(t, err)
+	require.Len(t, created, 1)
+	assert.Equal(t, "anna@example.com", created[0].Email)
+	require.Len(t, updated, 1)
+	assert.Equal(t, id, updated[0].ID)
+	assert.Equal(t, "Kormich", updated[0].Lastname)
+	assert.True(t, updated[0].EmailVerified, "emails matched by case are not verified again")
+
+	_, _, err = uc.UpsertManyByEmail(context.Background(), []*DTOs.User{{Firstname: "Igor", Email: "igor@example.com", Password: "a new passphrase"}})
+	assert.Equal(t, models.ErrPasswordOnUpdate, err, "existing users keep their passwords")
+}
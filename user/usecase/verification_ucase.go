@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/dbutil"
+	"GoMastersTest/event"
+	"GoMastersTest/mail"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type verificationUseCase struct {
+	userRepository         user.Repository
+	historyRepository      user.HistoryRepository
+	outboxRepository       event.OutboxRepository
+	verificationRepository user.VerificationRepository
+	statuses               user.StatusUseCase
+	mailer                 mail.Mailer
+	templates              *mail.Templates
+	tokens                 *auth.TokenIssuer
+	link                   string
+	ttl                    time.Duration
+	transactor             dbutil.Transactor
+	contextTimeout         time.Duration
+}
+
+// NewVerificationUseCase mails links to link, with the token of the
+// verification in their token query parameter. Links expire after ttl.
+// Pending users are activated through s once verified.
+func NewVerificationUseCase(u user.Repository, h user.HistoryRepository, o event.OutboxRepository, v user.VerificationRepository,
+	s user.StatusUseCase, m mail.Mailer, tpl *mail.Templates, t *auth.TokenIssuer, link string, ttl time.Duration, tx dbutil.Transactor,
+	timeout time.Duration) user.VerificationUseCase {
+	return &verificationUseCase{
+		userRepository:         u,
+		historyRepository:      h,
+		outboxRepository:       o,
+		verificationRepository: v,
+		statuses:               s,
+		mailer:                 m,
+		templates:              tpl,
+		tokens:                 t,
+		link:                   link,
+		ttl:                    ttl,
+		transactor:             tx,
+		contextTimeout:         timeout,
+	}
+}
+
+// Send fails with ErrEmailVerified when there is nothing to verify. Earlier
+// links stay valid until they expire or the email changes.
+func (a *verificationUseCase) Send(c context.Context, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	u, err := a.userRepository.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u.EmailVerified {
+		return models.ErrEmailVerified
+	}
+
+	now := time.Now()
+	v := &entity.EmailVerification{
+		ID:        uuid.New(),
+		UserID:    u.ID,
+		Email:     u.Email,
+		ExpiresAt: now.Add(a.ttl),
+	}
+	token, err := a.tokens.SignAction(auth.PurposeVerifyEmail, v.ID, u.ID, v.ExpiresAt, now)
+	if err != nil {
+		return err
+	}
+	link, err := mail.LinkWithToken(a.link, token)
+	if err != nil {
+		return err
+	}
+	msg, err := a.templates.Render(mail.TemplateVerifyEmail, u.Email, mail.LinkData{User: u, Link: link, ExpiresAt: v.ExpiresAt})
+	if err != nil {
+		return err
+	}
+
+	if err = a.verificationRepository.Create(ctx, v); err != nil {
+		return err
+	}
+	return a.mailer.Send(ctx, msg)
+}
+
+// Verify fails with ErrInvalidToken when the token is forged, expired or used,
+// or when the email of the user is no longer the one it was sent to.
+// Verifying a user is an update, audited and published like the others, and
+// so is activating them when they were pending.
+func (a *verificationUseCase) Verify(c context.Context, token string) (*entity.User, error) {
+	id, userID, err := a.tokens.ParseAction(auth.PurposeVerifyEmail, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// the token, not the caller, identifies the user
+	ctx, cancel := context.WithTimeout(models.AsSystem(c), a.contextTimeout)
+	defer cancel()
+
+	var res *entity.User
+	err = a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		v, err := a.verificationRepository.GetByID(ctx, id)
+		if err == models.ErrNotFound {
+			return models.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		if v.UserID != userID || v.UsedAt != nil || time.Now().After(v.ExpiresAt) {
+			return models.ErrInvalidToken
+		}
+
+		before, err := a.userRepository.GetByID(ctx, v.UserID)
+		if err == models.ErrNotFound {
+			return models.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		res, err = a.userRepository.SetEmailVerified(ctx, v.UserID, v.Email)
+		if err == models.ErrNotFound {
+			return models.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = a.verificationRepository.MarkUsed(ctx, v.ID); err != nil {
+			return err
+		}
+		if !before.EmailVerified {
+			err = storeChanges(ctx, a.historyRepository, a.outboxRepository, entity.OperationUpdate,
+				[]change{{ID: res.ID, Before: before, After: res}})
+			if err != nil {
+				return err
+			}
+		}
+		if res.Status != entity.StatusPending {
+			return nil
+		}
+		res, err = a.statuses.Transition(ctx, res.ID, user.ActionActivate, "Email verified")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
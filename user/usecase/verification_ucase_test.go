@@ -0,0 +1,272 @@
+package usecase_test
+
+import (
+	"GoMastersTest/auth"
+	"GoMastersTest/event"
+	"GoMastersTest/mail"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/user"
+	"GoMastersTest/user/usecase"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeUsers struct {
+	user.Repository
+	users map[uuid.UUID]*entity.User
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, id uuid.UUID, fields ...string) (*entity.User, error) {
+	u, ok := f.users[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	res := *u
+	return &res, nil
+}
+
+func (f *fakeUsers) Update(ctx context.Context, id uuid.UUID, m *DTOs.User) (*entity.User, error) {
+	u, ok := f.users[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	u.EmailVerified = u.EmailVerified && strings.EqualFold(u.Email, m.Email)
+	u.User = *m
+	return f.GetByID(ctx, id)
+}
+
+func (f *fakeUsers) SetEmailVerified(ctx context.Context, id uuid.UUID, email string) (*entity.User, error) {
+	u, ok := f.users[id]
+	if !ok || !strings.EqualFold(u.Email, email) {
+		return nil, models.ErrNotFound
+	}
+	u.EmailVerified = true
+	return f.GetByID(ctx, id)
+}
+
+func (f *fakeUsers) SetStatus(ctx context.Context, id uuid.UUID, from string, to string) (*entity.User, error) {
+	u, ok := f.users[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	if u.Status != from {
+		return nil, models.ErrConflict
+	}
+	u.Status = to
+	return f.GetByID(ctx, id)
+}
+
+type fakeVerifications map[uuid.UUID]*entity.EmailVerification
+
+func (f fakeVerifications) Create(ctx context.Context, v *entity.EmailVerification) error {
+	f[v.ID] = v
+	return nil
+}
+
+func (f fakeVerifications) GetByID(ctx context.Context, id uuid.UUID) (*entity.EmailVerification, error) {
+	v, ok := f[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	return v, nil
+}
+
+func (f fakeVerifications) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	f[id].UsedAt = &now
+	return nil
+}
+
+type fakeHistory struct {
+	user.HistoryRepository
+	records []*entity.UserHistory
+}
+
+func (f *fakeHistory) StoreMany(ctx context.Context, records []*entity.UserHistory) error {
+	f.records = append(f.records, records...)
+	return nil
+}
+
+type fakeOutbox struct {
+	event.OutboxRepository
+}
+
+func (fakeOutbox) StoreMany(ctx context.Context, events []*entity.Event) error {
+	return nil
+}
+
+type fakeStatusHistory struct {
+	transitions []*entity.StatusTransition
+}
+
+func (f *fakeStatusHistory) Store(ctx context.Context, t *entity.StatusTransition) error {
+	f.transitions = append(f.transitions, t)
+	return nil
+}
+
+func (f *fakeStatusHistory) GetByUserID(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]*entity.StatusTransition, int, error) {
+	res := make([]*entity.StatusTransition, 0)
+	for i := len(f.transitions) - 1; i >= 0; i-- {
+		if f.transitions[i].UserID == userID {
+			res = append(res, f.transitions[i])
+		}
+	}
+	return res, len(res), nil
+}
+
+// fakeSessions records the users whose sessions were revoked.
+type fakeSessions struct {
+	revoked []uuid.UUID
+}
+
+func (f *fakeSessions) RevokeSessions(ctx context.Context, userID uuid.UUID) error {
+	f.revoked = append(f.revoked, userID)
+	return nil
+}
+
+type fakeMailer struct {
+	sent []*mail.Message
+}
+
+func (f *fakeMailer) Send(ctx context.Context, m *mail.Message) error {
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+type noTransactor struct{}
+
+func (noTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+var linkToken = regexp.MustCompile(`token=(\S+)`)
+
+// lastToken returns the token of the last link mailed.
+func lastToken(t *testing.T, mailer *fakeMailer) string {
+	require.NotEmpty(t, mailer.sent)
+	match := linkToken.FindStringSubmatch(mailer.sent[len(mailer.sent)-1].Body)
+	require.NotNil(t, match)
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+type verificationFixture struct {
+	user          *entity.User
+	users         *fakeUsers
+	history       *fakeHistory
+	mailer        *fakeMailer
+	tokens        *auth.TokenIssuer
+	verifications user.VerificationUseCase
+	userUseCase   user.UseCase
+}
+
+func newVerificationFixture(t *testing.T) *verificationFixture {
+	u := &entity.User{User: DTOs.User{Firstname: "Igor", Email: "igor@example.com"}, Status: entity.StatusPending, ID: uuid.New()}
+	f := &verificationFixture{
+		user:    u,
+		users:   &fakeUsers{users: map[uuid.UUID]*entity.User{u.ID: u}},
+		history: new(fakeHistory),
+		mailer:  new(fakeMailer),
+	}
+	templates, err := mail.LoadTemplates("")
+	require.NoError(t, err)
+	f.tokens, err = auth.NewTokenIssuer(strings.Repeat("k", 32), "test", time.Minute, time.Hour)
+	require.NoError(t, err)
+
+	statuses := usecase.NewStatusUseCase(f.users, f.history, fakeOutbox{}, new(fakeStatusHistory), new(fakeSessions),
+		noTransactor{}, time.Second)
+	f.verifications = usecase.NewVerificationUseCase(f.users, f.history, fakeOutbox{}, make(fakeVerifications), statuses, f.mailer,
+		templates, f.tokens, "http://localhost/verify-email", time.Hour, noTransactor{}, time.Second)
+	f.userUseCase = usecase.NewUserUseCase(f.users, f.history, fakeOutbox{}, nil, f.verifications, noTransactor{}, time.Second)
+	return f
+}
+
+func TestVerifyEmail(t *testing.T) {
+	f := newVerificationFixture(t)
+	ctx := context.Background()
+
+	require.NoError(t, f.verifications.Send(ctx, f.user.ID))
+	require.Len(t, f.mailer.sent, 1)
+	assert.Equal(t, "igor@example.com", f.mailer.sent[0].To)
+	token := lastToken(t, f.mailer)
+
+	res, err := f.verifications.Verify(ctx, token)
+	require.NoError(t, err)
+	assert.True(t, res.EmailVerified)
+	assert.Equal(t, entity.StatusActive, res.Status, "verifying activates pending users")
+	require.Len(t, f.history.records, 2)
+	assert.Equal(t, entity.OperationUpdate, f.history.records[0].Operation)
+	assert.Equal(t, entity.FieldChanges{"EmailVerified": {From: false, To: true}}, f.history.records[0].Changes)
+	assert.Equal(t, entity.FieldChanges{"Status": {From: entity.StatusPending, To: entity.StatusActive}},
+		f.history.records[1].Changes)
+
+	// links work once, and verified users get no more
+	_, err = f.verifications.Verify(ctx, token)
+	assert.Equal(t, models.ErrInvalidToken, err)
+	assert.Equal(t, models.ErrEmailVerified, f.verifications.Send(ctx, f.user.ID))
+}
+
+func TestVerifyEmailRejectsForeignTokens(t *testing.T) {
+	f := newVerificationFixture(t)
+	ctx := context.Background()
+
+	require.NoError(t, f.verifications.Send(ctx, f.user.ID))
+	token := lastToken(t, f.mailer)
+
+	access, err := f.tokens.Sign(f.user.ID, uuid.New(), nil, time.Now())
+	require.NoError(t, err)
+	_, err = f.verifications.Verify(ctx, access)
+	assert.Equal(t, models.ErrInvalidToken, err)
+
+	_, err = f.tokens.Parse(token)
+	assert.Equal(t, models.ErrInvalidToken, err, "verification tokens are not access tokens")
+
+	expired, err := f.tokens.SignAction(auth.PurposeVerifyEmail, uuid.New(), f.user.ID, time.Now().Add(-time.Second), time.Now())
+	require.NoError(t, err)
+	_, err = f.verifications.Verify(ctx, expired)
+	assert.Equal(t, models.ErrInvalidToken, err)
+
+	_, err = f.verifications.Verify(ctx, token[:len(token)-2])
+	assert.Equal(t, models.ErrInvalidToken, err)
+}
+
+func TestEmailChangeRequiresVerification(t *testing.T) {
+	f := newVerificationFixture(t)
+	ctx := context.Background()
+
+	require.NoError(t, f.verifications.Send(ctx, f.user.ID))
+	stale := lastToken(t, f.mailer)
+	require.NoError(t, f.verifications.Send(ctx, f.user.ID))
+	_, err := f.verifications.Verify(ctx, lastToken(t, f.mailer))
+	require.NoError(t, err)
+
+	// changing only the case of the email keeps it verified and sends nothing
+	res, err := f.userUseCase.Update(ctx, f.user.ID, &DTOs.User{Firstname: "Igor", Email: "IGOR@example.com"})
+	require.NoError(t, err)
+	assert.True(t, res.EmailVerified)
+	assert.Len(t, f.mailer.sent, 2)
+
+	res, err = f.userUseCase.Update(ctx, f.user.ID, &DTOs.User{Firstname: "Igor", Email: "igor@example.org"})
+	require.NoError(t, err)
+	assert.False(t, res.EmailVerified)
+	require.Len(t, f.mailer.sent, 3)
+	assert.Equal(t, "igor@example.org", f.mailer.sent[2].To)
+
+	// links to the old address no longer verify anything
+	_, err = f.verifications.Verify(ctx, stale)
+	assert.Equal(t, models.ErrInvalidToken, err)
+
+	res, err = f.verifications.Verify(ctx, lastToken(t, f.mailer))
+	require.NoError(t, err)
+	assert.True(t, res.EmailVerified)
+}
@@ -0,0 +1,24 @@
+package user
+
+import (
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+)
+
+// VerificationRepository stores the email verification links sent to users.
+type VerificationRepository interface {
+	Create(ctx context.Context, v *entity.EmailVerification) error
+	// GetByID locks the verification until the end of the transaction.
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.EmailVerification, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) error
+}
+
+// VerificationUseCase checks that users own their email address.
+type VerificationUseCase interface {
+	// Send mails a verification link to the current email of the user.
+	Send(ctx context.Context, userID uuid.UUID) error
+	// Verify uses up the token of a link and marks the email it was sent to
+	// verified.
+	Verify(ctx context.Context, token string) (*entity.User, error)
+}
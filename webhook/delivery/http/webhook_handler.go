@@ -0,0 +1,303 @@
+package http
+
+import (
+	"GoMastersTest/httputil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/webhook"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+type WebhookHandler struct {
+	Usecase webhook.UseCase
+}
+
+// NewWebhookHandler registers the webhook routes, reserved to admins by
+// requireAdmin.
+func NewWebhookHandler(r *gin.Engine, us webhook.UseCase, requireAdmin gin.HandlerFunc) {
+	handler := &WebhookHandler{
+		Usecase: us,
+	}
+	v1 := r.Group("/api/v1", requireAdmin)
+	v1.GET("/webhooks", handler.GetAllSubscriptions)
+	v1.GET("/webhooks/:id", handler.GetSubscriptionByID)
+	v1.POST("/webhooks", handler.CreateSubscription)
+	v1.PUT("/webhooks/:id", handler.UpdateSubscription)
+	v1.DELETE("/webhooks/:id", handler.DeleteSubscription)
+	v1.GET("/webhooks/:id/deliveries", handler.GetDeliveries)
+	v1.POST("/webhooks/:id/deliveries/:deliveryID/redeliver", handler.Redeliver)
+}
+
+// GetAllSubscriptions godoc
+// @Summary      Get All Webhook Subscriptions
+// @Description  Return all webhook subscriptions. Admins only.
+// @Tags         Webhooks
+// @Produce      json
+// @Success      200  {object}  []entity.WebhookSubscription
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /webhooks [get]
+func (a *WebhookHandler) GetAllSubscriptions(c *gin.Context) {
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	subscriptions, err := a.Usecase.GetAll(ctx)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// GetSubscriptionByID godoc
+// @Summary      Get Webhook Subscription By id
+// @Description  Return a webhook subscription. Admins only.
+// @Tags         Webhooks
+// @Produce      json
+// @Param        id path string  true  "Subscription ID"
+// @Success      200  {object}  entity.WebhookSubscription
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /webhooks/{id} [get]
+func (a *WebhookHandler) GetSubscriptionByID(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	subscription, err := a.Usecase.GetByID(ctx, id)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// CreateSubscription godoc
+// @Summary      Create Webhook Subscription
+// @Description  Subscribe a URL to user events. The URL must resolve to public addresses only. Admins only.
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Subscription  body  DTOs.WebhookSubscription  true  "Add subscription"
+// @Success      201  {object}  entity.WebhookSubscription
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      422  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /webhooks [post]
+func (a *WebhookHandler) CreateSubscription(c *gin.Context) {
+	var subscription DTOs.WebhookSubscription
+	if err := c.ShouldBindJSON(&subscription); err != nil {
+		httputil.NewError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if ok, err := isRequestValid(&subscription); !ok {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Create(ctx, &subscription)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("Location", c.FullPath()+"/"+res.ID.String())
+	c.JSON(http.StatusCreated, res)
+}
+
+// UpdateSubscription godoc
+// @Summary      Update Webhook Subscription
+// @Description  Replace a webhook subscription. The URL must resolve to public addresses only. Admins only.
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id            path  string                    true  "Subscription ID"
+// @Param        Subscription  body  DTOs.WebhookSubscription  true  "Update subscription"
+// @Success      200  {object}  entity.WebhookSubscription
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      422  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /webhooks/{id} [put]
+func (a *WebhookHandler) UpdateSubscription(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	var subscription DTOs.WebhookSubscription
+	if err := c.ShouldBindJSON(&subscription); err != nil {
+		httputil.NewError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if ok, err := isRequestValid(&subscription); !ok {
+		httputil.NewError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Update(ctx, id, &subscription)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// DeleteSubscription godoc
+// @Summary      Delete Webhook Subscription
+// @Description  Delete a webhook subscription and its delivery log. Admins only.
+// @Tags         Webhooks
+// @Param        id   path      string  true  "Subscription ID"
+// @Success      204
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /webhooks/{id} [delete]
+func (a *WebhookHandler) DeleteSubscription(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err = a.Usecase.Delete(ctx, id); err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetDeliveries godoc
+// @Summary      Get Webhook Deliveries
+// @Description  Return the delivery log of a subscription, newest first. Admins only.
+// @Tags         Webhooks
+// @Produce      json
+// @Param        id      path   string  true   "Subscription ID"
+// @Param        limit   query  int     false  "Page size"
+// @Param        offset  query  int     false  "Page offset"
+// @Success      200  {object}  []entity.WebhookDelivery
+// @Header       200  {int}     X-Total-Count  "Total number of deliveries"
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /webhooks/{id}/deliveries [get]
+func (a *WebhookHandler) GetDeliveries(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	limit, offset, err := httputil.ParsePagination(c)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	deliveries, total, err := a.Usecase.GetDeliveries(ctx, id, limit, offset)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Redeliver godoc
+// @Summary      Redeliver Webhook
+// @Description  Queue a delivery again, including dead ones. Admins only.
+// @Tags         Webhooks
+// @Produce      json
+// @Param        id          path  string  true  "Subscription ID"
+// @Param        deliveryID  path  string  true  "Delivery ID"
+// @Success      202  {object}  entity.WebhookDelivery
+// @Failure      400  {object}  httputil.HTTPError
+// @Failure      404  {object}  httputil.HTTPError
+// @Failure      401  {object}  httputil.HTTPError
+// @Failure      403  {object}  httputil.HTTPError
+// @Router /webhooks/{id}/deliveries/{deliveryID}/redeliver [post]
+func (a *WebhookHandler) Redeliver(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+	deliveryID, err := uuid.Parse(c.Param("deliveryID"))
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, models.ErrBadParamInput)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res, err := a.Usecase.Redeliver(ctx, id, deliveryID)
+	if err != nil {
+		httputil.NewError(c, getStatusCode(err), err)
+		return
+	}
+	c.JSON(http.StatusAccepted, res)
+}
+
+func getStatusCode(err error) int {
+	if err == models.ErrPrivateURL {
+		logrus.Error(err)
+		return http.StatusBadRequest
+	}
+	return httputil.StatusCode(err)
+}
+
+func isRequestValid(m *DTOs.WebhookSubscription) (bool, error) {
+	validate := validator.New()
+	err := validate.Struct(m)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
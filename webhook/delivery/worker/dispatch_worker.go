@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"GoMastersTest/webhook"
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type DispatchWorker struct {
+	Usecase  webhook.DispatchUseCase
+	Interval time.Duration
+}
+
+func NewDispatchWorker(us webhook.DispatchUseCase, interval time.Duration) *DispatchWorker {
+	return &DispatchWorker{
+		Usecase:  us,
+		Interval: interval,
+	}
+}
+
+// Run sends due webhook deliveries until ctx is cancelled. A batch that sent
+// anything is followed immediately by the next one; otherwise the worker
+// waits for Interval. A non-positive Interval disables dispatching.
+func (w *DispatchWorker) Run(ctx context.Context) {
+	if w.Interval <= 0 {
+		logrus.Warnf("webhooks interval is %v, webhook deliveries will not be sent", w.Interval)
+		return
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		sent, err := w.Usecase.DispatchPending(ctx)
+		if err != nil {
+			logrus.Error(err)
+		}
+		if err == nil && sent > 0 && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
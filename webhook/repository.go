@@ -0,0 +1,33 @@
+package webhook
+
+import (
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type SubscriptionRepository interface {
+	GetAll(ctx context.Context) ([]*entity.WebhookSubscription, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.WebhookSubscription, error)
+	GetActiveByEventType(ctx context.Context, eventType string) ([]*entity.WebhookSubscription, error)
+	Create(ctx context.Context, s *DTOs.WebhookSubscription) (*entity.WebhookSubscription, error)
+	Update(ctx context.Context, id uuid.UUID, s *DTOs.WebhookSubscription) (*entity.WebhookSubscription, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type DeliveryRepository interface {
+	// Enqueue stores d, setting its ID, and reports whether it did: d is not
+	// stored when the subscription already has a delivery for its event.
+	Enqueue(ctx context.Context, d *entity.WebhookDelivery) (bool, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.WebhookDelivery, error)
+	GetBySubscriptionID(ctx context.Context, subscriptionID uuid.UUID, limit int, offset int) ([]*entity.WebhookDelivery, int, error)
+	// FetchDue locks the deliveries it returns until the end of the
+	// transaction, skipping those another transaction locked.
+	FetchDue(ctx context.Context, limit int) ([]*entity.WebhookDelivery, error)
+	// Claim postpones the next attempt of deliveries to until, so that
+	// dispatchers leave them alone while they are sent.
+	Claim(ctx context.Context, ids []uuid.UUID, until time.Time) error
+	Update(ctx context.Context, d *entity.WebhookDelivery) error
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/webhook"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type postgreDeliveryRepository struct {
+	Conn *sql.DB
+}
+
+func NewPostgreDeliveryRepository(Conn *sql.DB) webhook.DeliveryRepository {
+	return &postgreDeliveryRepository{Conn}
+}
+
+func scanDelivery(row rowScanner) (*entity.WebhookDelivery, error) {
+	t := new(entity.WebhookDelivery)
+	var payload []byte
+	err := row.Scan(
+		&t.ID,
+		&t.SubscriptionID,
+		&t.EventID,
+		&t.EventType,
+		&payload,
+		&t.Status,
+		&t.Attempts,
+		&t.NextAttempt,
+		&t.LastStatusCode,
+		&t.LastError,
+		&t.Created,
+		&t.Updated,
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.Payload = payload
+	return t, nil
+}
+
+func (m *postgreDeliveryRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]*entity.WebhookDelivery, error) {
+	rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	result := make([]*entity.WebhookDelivery, 0)
+	for rows.Next() {
+		t, err := scanDelivery(rows)
+		if err != nil {
+			logrus.Error(err)
+			return nil, err
+		}
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
+
+// Enqueue stores d unless the subscription already has a delivery for the
+// same event, which happens when the outbox relay retries an event.
+func (m *postgreDeliveryRepository) Enqueue(ctx context.Context, d *entity.WebhookDelivery) (bool, error) {
+	query := `INSERT INTO webhook_deliveries (id, subscription_id, event_id, event_type, payload)
+						VALUES ($1, $2, $3, $4, $5)
+						ON CONFLICT (subscription_id, event_id) DO NOTHING
+						RETURNING id`
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return false, err
+	}
+
+	var stored string
+	err = dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query,
+		id.String(), d.SubscriptionID.String(), d.EventID.String(), d.EventType, []byte(d.Payload)).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	d.ID = id
+	return true, nil
+}
+
+func (m *postgreDeliveryRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.WebhookDelivery, error) {
+	query := `select id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt, last_status_code, last_error, created, updated
+						from webhook_deliveries where id = $1`
+
+	res, err := scanDelivery(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	return res, err
+}
+
+func (m *postgreDeliveryRepository) GetBySubscriptionID(ctx context.Context, subscriptionID uuid.UUID, limit int, offset int) ([]*entity.WebhookDelivery, int, error) {
+	var total int
+	err := dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, `select count(*) from webhook_deliveries where subscription_id = $1`, subscriptionID.String()).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `select id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt, last_status_code, last_error, created, updated
+						from webhook_deliveries where subscription_id = $1 order by created desc, id limit $2 offset $3`
+
+	list, err := m.fetch(ctx, query, subscriptionID.String(), limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// FetchDue returns pending deliveries of active subscriptions whose next
+// attempt is due. The deliveries are locked until the end of the transaction,
+// and deliveries locked by another dispatcher are skipped.
+func (m *postgreDeliveryRepository) FetchDue(ctx context.Context, limit int) ([]*entity.WebhookDelivery, error) {
+	query := `select d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt, d.last_status_code, d.last_error, d.created, d.updated
+						from webhook_deliveries d join webhook_subscriptions s on s.id = d.subscription_id
+						where d.status = 'pending' and d.next_attempt <= now() and s.active
+						order by d.next_attempt limit $1
+						for update of d skip locked`
+
+	return m.fetch(ctx, query, limit)
+}
+
+func (m *postgreDeliveryRepository) Claim(ctx context.Context, ids []uuid.UUID, until time.Time) error {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+	query := `UPDATE webhook_deliveries SET next_attempt = $2 WHERE id = ANY($1::uuid[])`
+	_, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, query, pq.Array(keys), until)
+	return err
+}
+
+func (m *postgreDeliveryRepository) Update(ctx context.Context, d *entity.WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries SET status=$1, attempts=$2, next_attempt=$3, last_status_code=$4, last_error=$5, updated=now()
+						WHERE id = $6 RETURNING updated`
+
+	err := dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query,
+		d.Status, d.Attempts, d.NextAttempt, d.LastStatusCode, d.LastError, d.ID.String()).Scan(&d.Updated)
+	if err == sql.ErrNoRows {
+		return models.ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/webhook"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type postgreSubscriptionRepository struct {
+	Conn *sql.DB
+}
+
+func NewPostgreSubscriptionRepository(Conn *sql.DB) webhook.SubscriptionRepository {
+	return &postgreSubscriptionRepository{Conn}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubscription(row rowScanner) (*entity.WebhookSubscription, error) {
+	t := new(entity.WebhookSubscription)
+	err := row.Scan(
+		&t.ID,
+		&t.URL,
+		pq.Array(&t.EventTypes),
+		&t.Secret,
+		&t.Active,
+		&t.Created,
+		&t.Updated,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *postgreSubscriptionRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]*entity.WebhookSubscription, error) {
+	rows, err := dbutil.Conn(ctx, m.Conn).QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	result := make([]*entity.WebhookSubscription, 0)
+	for rows.Next() {
+		t, err := scanSubscription(rows)
+		if err != nil {
+			logrus.Error(err)
+			return nil, err
+		}
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
+
+func (m *postgreSubscriptionRepository) GetAll(ctx context.Context) ([]*entity.WebhookSubscription, error) {
+	query := `select id, url, event_types, secret, active, created, updated
+						from webhook_subscriptions order by created`
+
+	return m.fetch(ctx, query)
+}
+
+func (m *postgreSubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.WebhookSubscription, error) {
+	query := `select id, url, event_types, secret, active, created, updated
+						from webhook_subscriptions where id = $1`
+
+	res, err := scanSubscription(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	return res, err
+}
+
+func (m *postgreSubscriptionRepository) GetActiveByEventType(ctx context.Context, eventType string) ([]*entity.WebhookSubscription, error) {
+	query := `select id, url, event_types, secret, active, created, updated
+						from webhook_subscriptions where active and $1 = ANY(event_types)`
+
+	return m.fetch(ctx, query, eventType)
+}
+
+func (m *postgreSubscriptionRepository) Create(ctx context.Context, s *DTOs.WebhookSubscription) (*entity.WebhookSubscription, error) {
+	query := `INSERT INTO webhook_subscriptions (id, url, event_types, secret, active, created, updated)
+						VALUES ($1, $2, $3, $4, coalesce($5, true), $6, $6)
+						RETURNING id, url, event_types, secret, active, created, updated`
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSubscription(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query,
+		id.String(), s.URL, pq.Array(s.EventTypes), s.Secret, s.Active, time.Now()))
+}
+
+func (m *postgreSubscriptionRepository) Update(ctx context.Context, id uuid.UUID, s *DTOs.WebhookSubscription) (*entity.WebhookSubscription, error) {
+	query := `UPDATE webhook_subscriptions SET url=$1, event_types=$2, secret=$3, active=coalesce($4, active), updated=now() WHERE id = $5
+						RETURNING id, url, event_types, secret, active, created, updated`
+
+	res, err := scanSubscription(dbutil.Conn(ctx, m.Conn).QueryRowContext(ctx, query,
+		s.URL, pq.Array(s.EventTypes), s.Secret, s.Active, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	return res, err
+}
+
+func (m *postgreSubscriptionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := dbutil.Conn(ctx, m.Conn).ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id.String())
+	if err != nil {
+		return err
+	}
+
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if ra == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
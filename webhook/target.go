@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"GoMastersTest/models"
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// sharedNet is the carrier-grade NAT range, internal to providers like the
+// private ones.
+var sharedNet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// PublicIP reports whether webhooks may be sent to ip. Loopback, private,
+// shared, link-local (which holds cloud metadata services), multicast and
+// unspecified addresses are refused.
+func PublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || sharedNet.Contains(ip) || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// ValidateURL checks that rawURL is an http or https URL whose host only
+// resolves to public addresses. It fails with ErrBadParamInput for other URLs
+// and unknown hosts, and with ErrPrivateURL for internal ones.
+func ValidateURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Hostname() == "" {
+		return models.ErrBadParamInput
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return models.ErrBadParamInput
+	}
+	for _, addr := range addrs {
+		if !PublicIP(addr.IP) {
+			return models.ErrPrivateURL
+		}
+	}
+	return nil
+}
+
+// NewClient returns a client for sending webhooks that times out after
+// timeout. It refuses to connect to the addresses PublicIP refuses, whatever
+// a host resolves to by the time it is sent to and wherever it redirects, and
+// ignores proxy settings, which would hide the address connected to.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+				return models.ErrPrivateURL
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,58 @@
+package webhook_test
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/webhook"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.public, webhook.PublicIP(net.ParseIP(tt.ip)), tt.ip)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	ctx := context.Background()
+	assert.NoError(t, webhook.ValidateURL(ctx, "https://93.184.216.34/hooks"))
+	assert.Equal(t, models.ErrPrivateURL, webhook.ValidateURL(ctx, "http://127.0.0.1:8080/hooks"))
+	assert.Equal(t, models.ErrPrivateURL, webhook.ValidateURL(ctx, "http://[::1]/hooks"))
+	assert.Equal(t, models.ErrPrivateURL, webhook.ValidateURL(ctx, "http://169.254.169.254/latest/meta-data"))
+	assert.Equal(t, models.ErrBadParamInput, webhook.ValidateURL(ctx, "ftp://93.184.216.34/hooks"))
+	assert.Equal(t, models.ErrBadParamInput, webhook.ValidateURL(ctx, "/hooks"))
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := webhook.NewClient(time.Second).Get(server.URL)
+	assert.True(t, errors.Is(err, models.ErrPrivateURL), "%v", err)
+}
@@ -0,0 +1,26 @@
+package webhook
+
+import (
+	"GoMastersTest/event"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"context"
+	"github.com/google/uuid"
+)
+
+type UseCase interface {
+	GetAll(ctx context.Context) ([]*entity.WebhookSubscription, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.WebhookSubscription, error)
+	Create(ctx context.Context, s *DTOs.WebhookSubscription) (*entity.WebhookSubscription, error)
+	Update(ctx context.Context, id uuid.UUID, s *DTOs.WebhookSubscription) (*entity.WebhookSubscription, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetDeliveries(ctx context.Context, id uuid.UUID, limit int, offset int) ([]*entity.WebhookDelivery, int, error)
+	Redeliver(ctx context.Context, id uuid.UUID, deliveryID uuid.UUID) (*entity.WebhookDelivery, error)
+}
+
+// DispatchUseCase fans outbox events out to subscriptions, as an event.Sink,
+// and sends the resulting deliveries.
+type DispatchUseCase interface {
+	event.Sink
+	DispatchPending(ctx context.Context) (int, error)
+}
@@ -0,0 +1,188 @@
+package usecase
+
+import (
+	"GoMastersTest/dbutil"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/retry"
+	"GoMastersTest/webhook"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type dispatchUseCase struct {
+	subscriptionRepository webhook.SubscriptionRepository
+	deliveryRepository     webhook.DeliveryRepository
+	client                 *http.Client
+	batchSize              int
+	maxAttempts            int
+	minBackoff             time.Duration
+	maxBackoff             time.Duration
+	lease                  time.Duration
+	transactor             dbutil.Transactor
+}
+
+// NewDispatchUseCase claims the deliveries of a batch for lease, which must
+// outlast the sending of a whole batch: deliveries still pending when it runs
+// out, such as those of a dispatcher that stopped, are sent again.
+func NewDispatchUseCase(s webhook.SubscriptionRepository, d webhook.DeliveryRepository, client *http.Client, batchSize int, maxAttempts int,
+	minBackoff time.Duration, maxBackoff time.Duration, lease time.Duration, tx dbutil.Transactor) webhook.DispatchUseCase {
+	return &dispatchUseCase{
+		subscriptionRepository: s,
+		deliveryRepository:     d,
+		client:                 client,
+		batchSize:              batchSize,
+		maxAttempts:            maxAttempts,
+		minBackoff:             minBackoff,
+		maxBackoff:             maxBackoff,
+		lease:                  lease,
+		transactor:             tx,
+	}
+}
+
+// Deliver queues e for every active subscription to its type, once per
+// subscription however many times it is delivered.
+func (a *dispatchUseCase) Deliver(ctx context.Context, e *entity.Event) error {
+	subscriptions, err := a.subscriptionRepository.GetActiveByEventType(ctx, e.Type)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range subscriptions {
+		queued, err := a.deliveryRepository.Enqueue(ctx, &entity.WebhookDelivery{
+			SubscriptionID: s.ID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Payload:        body,
+		})
+		if err != nil {
+			return err
+		}
+		if !queued {
+			logrus.Debugf("event %s is already queued for webhook %s", e.ID, s.ID)
+		}
+	}
+	return nil
+}
+
+// DispatchPending sends one batch of due deliveries and returns how many
+// succeeded. Failures, including that of loading the subscription, are
+// retried with exponential backoff until maxAttempts, after which the delivery
+// is dead until redelivered by hand. The batch is claimed before it is sent,
+// so that no lock is held while the subscribers are called.
+func (a *dispatchUseCase) DispatchPending(ctx context.Context) (int, error) {
+	due, err := a.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	subscriptions := make(map[uuid.UUID]*entity.WebhookSubscription)
+	sent := 0
+	for _, d := range due {
+		d.Attempts++
+		s, err := a.subscription(ctx, subscriptions, d.SubscriptionID)
+		if err != nil {
+			logrus.Errorf("webhook delivery %s: loading subscription %s failed: %v", d.ID, d.SubscriptionID, err)
+			d.LastStatusCode = 0
+		} else {
+			d.LastStatusCode, err = a.send(ctx, s, d)
+		}
+
+		switch {
+		case err == nil:
+			d.Status = entity.DeliverySucceeded
+			d.LastError = ""
+			sent++
+		case d.Attempts >= a.maxAttempts:
+			logrus.Errorf("webhook delivery %s is dead after %d attempts: %v", d.ID, d.Attempts, err)
+			d.Status = entity.DeliveryDead
+			d.LastError = err.Error()
+		default:
+			d.NextAttempt = time.Now().Add(retry.Backoff(d.Attempts, a.minBackoff, a.maxBackoff))
+			d.LastError = err.Error()
+		}
+
+		if err = a.deliveryRepository.Update(ctx, d); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// claim fetches a batch of due deliveries and postpones them by lease in a
+// transaction of its own, which keeps other dispatchers from fetching them at
+// the same time and, once committed, until the lease runs out.
+func (a *dispatchUseCase) claim(ctx context.Context) ([]*entity.WebhookDelivery, error) {
+	var due []*entity.WebhookDelivery
+	err := a.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		due, err = a.deliveryRepository.FetchDue(ctx, a.batchSize)
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(due))
+		for i, d := range due {
+			ids[i] = d.ID
+		}
+		return a.deliveryRepository.Claim(ctx, ids, time.Now().Add(a.lease))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// subscription returns the subscription id, loading it once per batch into
+// loaded.
+func (a *dispatchUseCase) subscription(ctx context.Context, loaded map[uuid.UUID]*entity.WebhookSubscription, id uuid.UUID) (*entity.WebhookSubscription, error) {
+	if s, ok := loaded[id]; ok {
+		return s, nil
+	}
+	s, err := a.subscriptionRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	loaded[id] = s
+	return s, nil
+}
+
+func (a *dispatchUseCase) send(ctx context.Context, s *entity.WebhookSubscription, d *entity.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.EventHeader, d.EventType)
+	req.Header.Set(webhook.DeliveryHeader, d.ID.String())
+	req.Header.Set(webhook.TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(s.Secret, timestamp, d.Payload))
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook %s answered %d", s.URL, res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
@@ -0,0 +1,224 @@
+package usecase_test
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/webhook"
+	"GoMastersTest/webhook/usecase"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeSubscriptions struct {
+	subscriptions []*entity.WebhookSubscription
+}
+
+func (f *fakeSubscriptions) GetAll(ctx context.Context) ([]*entity.WebhookSubscription, error) {
+	return f.subscriptions, nil
+}
+
+func (f *fakeSubscriptions) GetByID(ctx context.Context, id uuid.UUID) (*entity.WebhookSubscription, error) {
+	for _, s := range f.subscriptions {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (f *fakeSubscriptions) GetActiveByEventType(ctx context.Context, eventType string) ([]*entity.WebhookSubscription, error) {
+	res := make([]*entity.WebhookSubscription, 0)
+	for _, s := range f.subscriptions {
+		for _, t := range s.EventTypes {
+			if s.Active && t == eventType {
+				res = append(res, s)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeSubscriptions) Create(ctx context.Context, s *DTOs.WebhookSubscription) (*entity.WebhookSubscription, error) {
+	return nil, models.ErrInternalServerError
+}
+
+func (f *fakeSubscriptions) Update(ctx context.Context, id uuid.UUID, s *DTOs.WebhookSubscription) (*entity.WebhookSubscription, error) {
+	return nil, models.ErrInternalServerError
+}
+
+func (f *fakeSubscriptions) Delete(ctx context.Context, id uuid.UUID) error {
+	return models.ErrInternalServerError
+}
+
+type fakeDeliveries struct {
+	deliveries []*entity.WebhookDelivery
+	claimed    map[uuid.UUID]time.Time
+}
+
+func (f *fakeDeliveries) Enqueue(ctx context.Context, d *entity.WebhookDelivery) (bool, error) {
+	for _, queued := range f.deliveries {
+		if queued.SubscriptionID == d.SubscriptionID && queued.EventID == d.EventID {
+			return false, nil
+		}
+	}
+	d.ID = uuid.New()
+	d.Status = entity.DeliveryPending
+	f.deliveries = append(f.deliveries, d)
+	return true, nil
+}
+
+func (f *fakeDeliveries) GetByID(ctx context.Context, id uuid.UUID) (*entity.WebhookDelivery, error) {
+	for _, d := range f.deliveries {
+		if d.ID == id {
+			return d, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (f *fakeDeliveries) GetBySubscriptionID(ctx context.Context, subscriptionID uuid.UUID, limit int, offset int) ([]*entity.WebhookDelivery, int, error) {
+	return f.deliveries, len(f.deliveries), nil
+}
+
+func (f *fakeDeliveries) FetchDue(ctx context.Context, limit int) ([]*entity.WebhookDelivery, error) {
+	res := make([]*entity.WebhookDelivery, 0)
+	for _, d := range f.deliveries {
+		if d.Status == entity.DeliveryPending && !d.NextAttempt.After(time.Now()) {
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeDeliveries) Claim(ctx context.Context, ids []uuid.UUID, until time.Time) error {
+	if f.claimed == nil {
+		f.claimed = make(map[uuid.UUID]time.Time)
+	}
+	for _, id := range ids {
+		f.claimed[id] = until
+	}
+	return nil
+}
+
+func (f *fakeDeliveries) Update(ctx context.Context, d *entity.WebhookDelivery) error {
+	return nil
+}
+
+type noTransactor struct{}
+
+func (noTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func newSubscription(url string, secret string) *entity.WebhookSubscription {
+	return &entity.WebhookSubscription{
+		ID:         uuid.New(),
+		URL:        url,
+		EventTypes: []string{entity.EventUserCreated},
+		Secret:     secret,
+		Active:     true,
+	}
+}
+
+func TestDispatchSignsDeliveries(t *testing.T) {
+	const secret = "0123456789abcdef"
+	received := make(chan *http.Request, 1)
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		received <- r
+	}))
+	defer receiver.Close()
+
+	subscriptions := &fakeSubscriptions{subscriptions: []*entity.WebhookSubscription{
+		newSubscription(receiver.URL, secret),
+		newSubscription("http://127.0.0.1:0/unused", secret),
+	}}
+	subscriptions.subscriptions[1].Active = false
+	deliveries := &fakeDeliveries{}
+	dispatcher := usecase.NewDispatchUseCase(subscriptions, deliveries, receiver.Client(), 10, 3, time.Second, time.Minute, time.Hour, noTransactor{})
+
+	e := &entity.Event{ID: uuid.New(), AggregateID: uuid.New(), Type: entity.EventUserCreated, Version: 1, Payload: []byte(`{}`)}
+	require.NoError(t, dispatcher.Deliver(context.Background(), e))
+	require.Len(t, deliveries.deliveries, 1)
+	// the relay retrying the event queues it once
+	require.NoError(t, dispatcher.Deliver(context.Background(), e))
+	require.Len(t, deliveries.deliveries, 1)
+
+	sent, err := dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, entity.DeliverySucceeded, deliveries.deliveries[0].Status)
+
+	r := <-received
+	assert.Equal(t, entity.EventUserCreated, r.Header.Get(webhook.EventHeader))
+	assert.Equal(t, deliveries.deliveries[0].ID.String(), r.Header.Get(webhook.DeliveryHeader))
+	timestamp, err := strconv.ParseInt(r.Header.Get(webhook.TimestampHeader), 10, 64)
+	require.NoError(t, err)
+	assert.True(t, webhook.Verify(secret, timestamp, body, r.Header.Get(webhook.SignatureHeader)))
+	assert.False(t, webhook.Verify("another-secret-value", timestamp, body, r.Header.Get(webhook.SignatureHeader)))
+}
+
+func TestDispatchRetriesUntilDead(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	subscriptions := &fakeSubscriptions{subscriptions: []*entity.WebhookSubscription{newSubscription(receiver.URL, "0123456789abcdef")}}
+	deliveries := &fakeDeliveries{}
+	dispatcher := usecase.NewDispatchUseCase(subscriptions, deliveries, receiver.Client(), 10, 2, time.Second, time.Minute, time.Hour, noTransactor{})
+
+	e := &entity.Event{ID: uuid.New(), AggregateID: uuid.New(), Type: entity.EventUserCreated, Payload: []byte(`{}`)}
+	require.NoError(t, dispatcher.Deliver(context.Background(), e))
+	d := deliveries.deliveries[0]
+
+	sent, err := dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Equal(t, entity.DeliveryPending, d.Status)
+	assert.Equal(t, http.StatusServiceUnavailable, d.LastStatusCode)
+	assert.True(t, d.NextAttempt.After(time.Now()))
+
+	d.NextAttempt = time.Now()
+	_, err = dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, entity.DeliveryDead, d.Status)
+	assert.Equal(t, 2, d.Attempts)
+	assert.NotEmpty(t, d.LastError)
+}
+
+func TestDispatchSkipsDeliveriesWithoutSubscription(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	s := newSubscription(receiver.URL, "0123456789abcdef")
+	subscriptions := &fakeSubscriptions{subscriptions: []*entity.WebhookSubscription{s}}
+	orphan := &entity.WebhookDelivery{ID: uuid.New(), SubscriptionID: uuid.New(), Status: entity.DeliveryPending, Payload: []byte(`{}`)}
+	ok := &entity.WebhookDelivery{ID: uuid.New(), SubscriptionID: s.ID, Status: entity.DeliveryPending, Payload: []byte(`{}`)}
+	deliveries := &fakeDeliveries{deliveries: []*entity.WebhookDelivery{orphan, ok}}
+	dispatcher := usecase.NewDispatchUseCase(subscriptions, deliveries, receiver.Client(), 10, 3, time.Second, time.Minute, time.Hour, noTransactor{})
+
+	start := time.Now()
+	sent, err := dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent, "the rest of the batch is sent")
+	assert.Equal(t, entity.DeliverySucceeded, ok.Status)
+
+	assert.Equal(t, entity.DeliveryPending, orphan.Status)
+	assert.Equal(t, 1, orphan.Attempts)
+	assert.Equal(t, models.ErrNotFound.Error(), orphan.LastError)
+	assert.True(t, orphan.NextAttempt.After(start))
+
+	require.Len(t, deliveries.claimed, 2, "the batch is claimed before it is sent")
+	assert.WithinDuration(t, start.Add(time.Hour), deliveries.claimed[ok.ID], time.Second)
+}
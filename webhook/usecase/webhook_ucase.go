@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"GoMastersTest/models"
+	"GoMastersTest/models/DTOs"
+	"GoMastersTest/models/entity"
+	"GoMastersTest/webhook"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type webhookUseCases struct {
+	subscriptionRepository webhook.SubscriptionRepository
+	deliveryRepository     webhook.DeliveryRepository
+	contextTimeout         time.Duration
+}
+
+func NewWebhookUseCase(s webhook.SubscriptionRepository, d webhook.DeliveryRepository, timeout time.Duration) webhook.UseCase {
+	return &webhookUseCases{
+		subscriptionRepository: s,
+		deliveryRepository:     d,
+		contextTimeout:         timeout,
+	}
+}
+
+func (a *webhookUseCases) GetAll(c context.Context) ([]*entity.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.subscriptionRepository.GetAll(ctx)
+}
+
+func (a *webhookUseCases) GetByID(c context.Context, id uuid.UUID) (*entity.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.subscriptionRepository.GetByID(ctx, id)
+}
+
+// Create and Update refuse URLs that are not public, see webhook.ValidateURL.
+func (a *webhookUseCases) Create(c context.Context, s *DTOs.WebhookSubscription) (*entity.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	if err := webhook.ValidateURL(ctx, s.URL); err != nil {
+		return nil, err
+	}
+
+	return a.subscriptionRepository.Create(ctx, s)
+}
+
+func (a *webhookUseCases) Update(c context.Context, id uuid.UUID, s *DTOs.WebhookSubscription) (*entity.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	if err := webhook.ValidateURL(ctx, s.URL); err != nil {
+		return nil, err
+	}
+
+	return a.subscriptionRepository.Update(ctx, id, s)
+}
+
+func (a *webhookUseCases) Delete(c context.Context, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	return a.subscriptionRepository.Delete(ctx, id)
+}
+
+func (a *webhookUseCases) GetDeliveries(c context.Context, id uuid.UUID, limit int, offset int) ([]*entity.WebhookDelivery, int, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	if _, err := a.subscriptionRepository.GetByID(ctx, id); err != nil {
+		return nil, 0, err
+	}
+	return a.deliveryRepository.GetBySubscriptionID(ctx, id, limit, offset)
+}
+
+// Redeliver queues a delivery again with a fresh attempt budget, whatever its
+// current status.
+func (a *webhookUseCases) Redeliver(c context.Context, id uuid.UUID, deliveryID uuid.UUID) (*entity.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(c, a.contextTimeout)
+	defer cancel()
+
+	d, err := a.deliveryRepository.GetByID(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if d.SubscriptionID != id {
+		return nil, models.ErrNotFound
+	}
+
+	d.Status = entity.DeliveryPending
+	d.Attempts = 0
+	d.NextAttempt = time.Now()
+	if err = a.deliveryRepository.Update(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}